@@ -0,0 +1,360 @@
+package mediadevices
+
+import (
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// BandwidthEstimator estimates the bandwidth available for sending the media.
+type BandwidthEstimator interface {
+	// Estimate returns the estimated bandwidth in bps. It returns 0 if the bandwidth is not known yet.
+	Estimate() int
+}
+
+// BandwidthEstimatorFunc is an adapter to use a function as a BandwidthEstimator.
+type BandwidthEstimatorFunc func() int
+
+// Estimate returns f().
+func (f BandwidthEstimatorFunc) Estimate() int {
+	return f()
+}
+
+// ReportedBandwidthEstimator holds the bandwidth reported from outside of the controller, e.g. by RTCP
+// REMB from the receiver, or by a transport-wide congestion control estimator of the application.
+type ReportedBandwidthEstimator struct {
+	mu      sync.Mutex
+	bitRate int
+}
+
+// Report updates the estimate in bps.
+func (e *ReportedBandwidthEstimator) Report(bitRate int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bitRate = bitRate
+}
+
+// Estimate returns the bandwidth reported last.
+func (e *ReportedBandwidthEstimator) Estimate() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.bitRate
+}
+
+// DegradationPreference tells how a video track is downscaled when the bitrate goes below its floor.
+// Reference: https://w3c.github.io/webrtc-pc/#dom-rtcdegradationpreference
+type DegradationPreference int
+
+// DegradationPreference values.
+const (
+	// DegradationMaintainFramerate lowers the resolution.
+	DegradationMaintainFramerate DegradationPreference = iota
+	// DegradationMaintainResolution lowers the frame rate.
+	DegradationMaintainResolution
+)
+
+// TrackBitRateConstraints are the constraints of a track controlled by BitRateController.
+type TrackBitRateConstraints struct {
+	// Priority is the weight of the track to split the bandwidth. 0 is treated as 1.
+	Priority float64
+	// MinBitRate is the floor of the bitrate in bps. If the bitrate of a video track goes below it,
+	// the video is downscaled by DegradationPreference. The video is not downscaled if it's 0.
+	MinBitRate int
+	// MaxBitRate is the cap of the bitrate in bps. The bandwidth over the cap goes to the other tracks.
+	// The bitrate is not capped if it's 0.
+	MaxBitRate int
+	// DegradationPreference tells how the video is downscaled.
+	DegradationPreference DegradationPreference
+}
+
+// BitRateControllerOptions stores parameters used by BitRateController.
+type BitRateControllerOptions struct {
+	// Interval is the interval to apply the estimate to the tracks.
+	Interval time.Duration
+}
+
+// BitRateControllerOption is a type of BitRateController functional option.
+type BitRateControllerOption func(*BitRateControllerOptions)
+
+// WithBitRateControlInterval specifies the interval to apply the estimate to the tracks.
+func WithBitRateControlInterval(interval time.Duration) BitRateControllerOption {
+	return func(o *BitRateControllerOptions) {
+		o.Interval = interval
+	}
+}
+
+const (
+	defaultBitRateControlInterval = time.Second
+	// maxDegradationLevel is the maximum number of the times that the resolution or the frame rate is halved.
+	maxDegradationLevel = 2
+	// upgradeMargin is the ratio of the bitrate over the floor of the upper level, which is required to upgrade
+	// the video. It prevents the video from going up and down around the floor.
+	upgradeMargin = 1.2
+	// defaultDegradationFrameRate is used to lower the frame rate of the video whose frame rate is not known.
+	defaultDegradationFrameRate = 30
+)
+
+// BitRateController splits the estimated bandwidth among the tracks by their priorities, and sets the
+// bitrates to the encoders of the tracks. If the bitrate of a video track goes below its floor, the video
+// is downscaled, so that the encoder can keep the quality of each frame.
+type BitRateController struct {
+	estimator BandwidthEstimator
+	opts      BitRateControllerOptions
+
+	mu     sync.Mutex
+	tracks []*controlledTrack
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type controlledTrack struct {
+	tracker     Tracker
+	constraints TrackBitRateConstraints
+	bitRate     int
+	level       int
+}
+
+// degrader is implemented by the tracks which can be downscaled.
+type degrader interface {
+	setDegradation(d degradation)
+}
+
+// stopper is implemented by the tracks which tell whether they have been stopped.
+type stopper interface {
+	isStopped() bool
+}
+
+// degradation is the downscaling applied to the video of a track. The resolution and the frame rate are
+// divided by the values. Values less than or equal to 1 keep the video.
+type degradation struct {
+	resolution float64
+	frameRate  float64
+}
+
+// NewBitRateController creates a BitRateController which applies the estimate of estimator to the tracks
+// periodically until Stop is called.
+func NewBitRateController(estimator BandwidthEstimator, opts ...BitRateControllerOption) *BitRateController {
+	o := BitRateControllerOptions{
+		Interval: defaultBitRateControlInterval,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &BitRateController{
+		estimator: estimator,
+		opts:      o,
+		done:      make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// AddTrack adds a track controlled by the controller. If the track has already been added, the constraints
+// are updated.
+func (c *BitRateController) AddTrack(t Tracker, constraints TrackBitRateConstraints) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ct := range c.tracks {
+		if ct.tracker == t {
+			ct.constraints = constraints
+			return
+		}
+	}
+	c.tracks = append(c.tracks, &controlledTrack{tracker: t, constraints: constraints})
+}
+
+// RemoveTrack removes a track from the controller. The bitrate and the resolution of the track are kept.
+func (c *BitRateController) RemoveTrack(t Tracker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, ct := range c.tracks {
+		if ct.tracker == t {
+			c.tracks = append(c.tracks[:i], c.tracks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Stop stops controlling the tracks.
+func (c *BitRateController) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *BitRateController) run() {
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.update()
+		}
+	}
+}
+
+// update applies the current estimate to the tracks.
+func (c *BitRateController) update() {
+	estimate := c.estimator.Estimate()
+	if estimate <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Split the bandwidth among the running tracks only.
+	tracks := c.tracks[:0]
+	for _, ct := range c.tracks {
+		if s, ok := ct.tracker.(stopper); ok && s.isStopped() {
+			continue
+		}
+		tracks = append(tracks, ct)
+	}
+	c.tracks = tracks
+
+	constraints := make([]TrackBitRateConstraints, len(c.tracks))
+	for i, ct := range c.tracks {
+		constraints[i] = ct.constraints
+	}
+	bitRates := allocateBitRate(estimate, constraints)
+
+	tracks = c.tracks[:0]
+	for i, ct := range c.tracks {
+		if err := ct.apply(bitRates[i]); err == io.EOF {
+			// The track has been stopped.
+			continue
+		}
+		// The other errors are skipped, and the bitrate is set again in the next interval.
+		tracks = append(tracks, ct)
+	}
+	c.tracks = tracks
+}
+
+func (ct *controlledTrack) apply(bitRate int) error {
+	if bitRate <= 0 {
+		return nil
+	}
+
+	if d, ok := ct.tracker.(degrader); ok && ct.tracker.Kind() == VideoInput && ct.constraints.MinBitRate > 0 {
+		level := degradationLevel(ct.level, bitRate, ct.constraints)
+		if level != ct.level {
+			ct.level = level
+			d.setDegradation(newDegradation(level, ct.constraints.DegradationPreference))
+		}
+	}
+
+	if bitRate == ct.bitRate {
+		return nil
+	}
+	if err := ct.tracker.SetBitRate(bitRate); err != nil {
+		return err
+	}
+	ct.bitRate = bitRate
+	return nil
+}
+
+// allocateBitRate splits the total bitrate by the priorities. The bitrate over the cap of a track is split
+// among the other tracks.
+func allocateBitRate(total int, constraints []TrackBitRateConstraints) []int {
+	bitRates := make([]int, len(constraints))
+	priority := func(i int) float64 {
+		if p := constraints[i].Priority; p > 0 {
+			return p
+		}
+		return 1
+	}
+
+	active := make([]int, len(constraints))
+	for i := range active {
+		active[i] = i
+	}
+	remaining := total
+	for len(active) > 0 {
+		var sum float64
+		for _, i := range active {
+			sum += priority(i)
+		}
+
+		// Cap the tracks whose share exceeds their caps, and split the rest again.
+		uncapped := active[:0:0]
+		for _, i := range active {
+			share := int(float64(remaining) * priority(i) / sum)
+			if max := constraints[i].MaxBitRate; max > 0 && share > max {
+				bitRates[i] = max
+				continue
+			}
+			uncapped = append(uncapped, i)
+		}
+		if len(uncapped) == len(active) {
+			for _, i := range active {
+				bitRates[i] = int(float64(remaining) * priority(i) / sum)
+			}
+			break
+		}
+		for _, i := range active {
+			if bitRates[i] > 0 {
+				remaining -= bitRates[i]
+			}
+		}
+		active = uncapped
+	}
+	return bitRates
+}
+
+// degradationLevel returns the number of the times that the video should be halved to keep the bitrate
+// over the floor. The resolution is halved in both the width and the height, which quarters the bitrate.
+func degradationLevel(current, bitRate int, constraints TrackBitRateConstraints) int {
+	ratio := 4.0
+	if constraints.DegradationPreference == DegradationMaintainResolution {
+		ratio = 2.0
+	}
+	floor := func(level int) float64 {
+		return float64(constraints.MinBitRate) / math.Pow(ratio, float64(level))
+	}
+
+	level := current
+	for level < maxDegradationLevel && float64(bitRate) < floor(level) {
+		level++
+	}
+	for level > 0 && float64(bitRate) >= floor(level-1)*upgradeMargin {
+		level--
+	}
+	return level
+}
+
+func newDegradation(level int, preference DegradationPreference) degradation {
+	if level == 0 {
+		return degradation{}
+	}
+	scale := math.Pow(2, float64(level))
+	if preference == DegradationMaintainResolution {
+		return degradation{frameRate: scale}
+	}
+	return degradation{resolution: scale}
+}
+
+// degrade returns the layer downscaled by d from the video recorded with p.
+func (l SimulcastLayer) degrade(d degradation, p prop.Media) SimulcastLayer {
+	if d.resolution > 1 {
+		l.ScaleResolutionDownBy = math.Max(l.ScaleResolutionDownBy, 1) * d.resolution
+	}
+	if d.frameRate > 1 {
+		frameRate := l.media(p).FrameRate
+		if frameRate == 0 {
+			frameRate = defaultDegradationFrameRate
+		}
+		l.MaxFrameRate = frameRate / float32(d.frameRate)
+	}
+	return l
+}
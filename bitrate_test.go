@@ -0,0 +1,401 @@
+package mediadevices
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+func TestAllocateBitRate(t *testing.T) {
+	testCases := map[string]struct {
+		total       int
+		constraints []TrackBitRateConstraints
+		expected    []int
+	}{
+		"Equal": {
+			total:       1000,
+			constraints: []TrackBitRateConstraints{{}, {}},
+			expected:    []int{500, 500},
+		},
+		"Priority": {
+			total:       1000,
+			constraints: []TrackBitRateConstraints{{Priority: 3}, {Priority: 1}},
+			expected:    []int{750, 250},
+		},
+		"Capped": {
+			total:       1000,
+			constraints: []TrackBitRateConstraints{{Priority: 3, MaxBitRate: 400}, {Priority: 1}},
+			expected:    []int{400, 600},
+		},
+		"CappedTwice": {
+			total: 1000,
+			constraints: []TrackBitRateConstraints{
+				{MaxBitRate: 100}, {MaxBitRate: 300}, {},
+			},
+			expected: []int{100, 300, 600},
+		},
+		"AllCapped": {
+			total:       1000,
+			constraints: []TrackBitRateConstraints{{MaxBitRate: 100}, {MaxBitRate: 200}},
+			expected:    []int{100, 200},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			bitRates := allocateBitRate(testCase.total, testCase.constraints)
+			for i := range testCase.expected {
+				if bitRates[i] != testCase.expected[i] {
+					t.Fatalf("Expected %v, got %v", testCase.expected, bitRates)
+				}
+			}
+		})
+	}
+}
+
+func TestDegradationLevel(t *testing.T) {
+	resolution := TrackBitRateConstraints{MinBitRate: 400000}
+	frameRate := TrackBitRateConstraints{MinBitRate: 400000, DegradationPreference: DegradationMaintainResolution}
+
+	testCases := map[string]struct {
+		current     int
+		bitRate     int
+		constraints TrackBitRateConstraints
+		expected    int
+	}{
+		"OverFloor": {
+			bitRate:     500000,
+			constraints: resolution,
+			expected:    0,
+		},
+		"HalfResolution": {
+			bitRate:     200000,
+			constraints: resolution,
+			expected:    1,
+		},
+		"QuarterResolution": {
+			bitRate:     50000,
+			constraints: resolution,
+			expected:    2,
+		},
+		"MaxLevel": {
+			bitRate:     1000,
+			constraints: resolution,
+			expected:    maxDegradationLevel,
+		},
+		"HalfFrameRate": {
+			bitRate:     300000,
+			constraints: frameRate,
+			expected:    1,
+		},
+		"KeepAroundFloor": {
+			current:     1,
+			bitRate:     420000,
+			constraints: resolution,
+			expected:    1,
+		},
+		"Upgrade": {
+			current:     2,
+			bitRate:     500000,
+			constraints: resolution,
+			expected:    0,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			level := degradationLevel(testCase.current, testCase.bitRate, testCase.constraints)
+			if level != testCase.expected {
+				t.Errorf("Expected %d, got %d", testCase.expected, level)
+			}
+		})
+	}
+}
+
+func TestSimulcastLayerDegrade(t *testing.T) {
+	p := prop.Media{Video: prop.Video{Width: 640, Height: 480, FrameRate: 30}}
+
+	testCases := map[string]struct {
+		layer       SimulcastLayer
+		degradation degradation
+		expected    prop.Video
+	}{
+		"None": {
+			expected: prop.Video{Width: 640, Height: 480, FrameRate: 30},
+		},
+		"Resolution": {
+			degradation: degradation{resolution: 2},
+			expected:    prop.Video{Width: 320, Height: 240, FrameRate: 30},
+		},
+		"ResolutionOfLayer": {
+			layer:       SimulcastLayer{RID: "h", ScaleResolutionDownBy: 2},
+			degradation: degradation{resolution: 2},
+			expected:    prop.Video{Width: 160, Height: 120, FrameRate: 30},
+		},
+		"FrameRate": {
+			degradation: degradation{frameRate: 4},
+			expected:    prop.Video{Width: 640, Height: 480, FrameRate: 7.5},
+		},
+		"FrameRateOfLayer": {
+			layer:       SimulcastLayer{RID: "l", MaxFrameRate: 10},
+			degradation: degradation{frameRate: 2},
+			expected:    prop.Video{Width: 640, Height: 480, FrameRate: 5},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			layer := testCase.layer.degrade(testCase.degradation, p)
+			if v := layer.media(p).Video; v != testCase.expected {
+				t.Errorf("Expected %v, got %v", testCase.expected, v)
+			}
+		})
+	}
+}
+
+// simulatedLink is a network link with a limited capacity for testing. The receiver reports the capacity
+// as the estimate like REMB, and the link measures the bitrate sent through it.
+type simulatedLink struct {
+	mu       sync.Mutex
+	capacity int
+	sent     int
+	since    time.Time
+}
+
+func newSimulatedLink(capacity int) *simulatedLink {
+	return &simulatedLink{capacity: capacity, since: time.Now()}
+}
+
+func (l *simulatedLink) Estimate() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.capacity
+}
+
+func (l *simulatedLink) setCapacity(capacity int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.capacity = capacity
+}
+
+func (l *simulatedLink) send(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sent += n
+}
+
+// measure returns the bitrate sent through the link during dur.
+func (l *simulatedLink) measure(dur time.Duration) int {
+	l.mu.Lock()
+	l.sent = 0
+	l.since = time.Now()
+	l.mu.Unlock()
+
+	time.Sleep(dur)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(float64(l.sent*8) / time.Since(l.since).Seconds())
+}
+
+// linkTrack is a LocalTrack which sends the samples through a simulatedLink.
+type linkTrack struct {
+	*mockTrack
+	link *simulatedLink
+}
+
+func (t *linkTrack) WriteSample(s media.Sample) error {
+	t.link.send(len(s.Data))
+	return nil
+}
+
+// mockRateParams builds encoders which output the data at the bitrate set to them.
+type mockRateParams struct {
+	mockParams
+	mu       sync.Mutex
+	encoders []*mockRateCodec
+}
+
+func (params *mockRateParams) BuildVideoEncoder(r video.Reader, p prop.Media) (codec.ReadCloser, error) {
+	params.mu.Lock()
+	defer params.mu.Unlock()
+	e := &mockRateCodec{r: r, p: p, bitRate: params.BitRate}
+	params.encoders = append(params.encoders, e)
+	return e, nil
+}
+
+func (params *mockRateParams) last() *mockRateCodec {
+	params.mu.Lock()
+	defer params.mu.Unlock()
+	return params.encoders[len(params.encoders)-1]
+}
+
+type mockRateCodec struct {
+	r video.Reader
+	p prop.Media
+
+	mu        sync.Mutex
+	bitRate   int
+	keyFrames int
+	last      time.Time
+}
+
+func (m *mockRateCodec) ReadFrame() (codec.EncodedFrame, error) {
+	if _, err := m.r.Read(); err != nil {
+		return codec.EncodedFrame{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if m.last.IsZero() {
+		m.last = now
+	}
+	n := int(float64(m.bitRate) / 8 * now.Sub(m.last).Seconds())
+	m.last = now
+	return codec.EncodedFrame{Data: make([]byte, n), Timestamp: now}, nil
+}
+
+func (m *mockRateCodec) Read(b []byte) (int, error) {
+	frame, err := m.ReadFrame()
+	if err != nil {
+		return 0, err
+	}
+	return copy(b, frame.Data), nil
+}
+
+func (m *mockRateCodec) SetBitRate(b int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bitRate = b
+	return nil
+}
+
+func (m *mockRateCodec) getBitRate() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bitRate
+}
+
+func (m *mockRateCodec) ForceKeyFrame() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyFrames++
+	return nil
+}
+
+func (m *mockRateCodec) numKeyFrames() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keyFrames
+}
+
+func (m *mockRateCodec) Close() error { return nil }
+
+// newMockRateTrack creates a 640x480 video track of a mock driver, which is encoded by mockRateCodec
+// at 2Mbps. The samples are written to the localTrack created by newLocalTrack.
+func newMockRateTrack(t *testing.T, id string, newLocalTrack func(codec *webrtc.RTPCodec, id string) LocalTrack) (*track, *mockRateParams) {
+	opts := &MediaDevicesOptions{
+		codecs: map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Type: webrtc.RTPCodecTypeVideo, Name: "MockVideo", PayloadType: 1, RTPCodecCapability: webrtc.RTPCodecCapability{ClockRate: 90000}},
+			},
+		},
+		trackGenerator: func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			return newLocalTrack(codec, id), nil
+		},
+	}
+	params := &mockRateParams{mockParams: mockParams{BaseParams: codec.BaseParams{BitRate: 2000000}, name: "MockVideo"}}
+	var constraints MediaTrackConstraints
+	constraints.VideoEncoderBuilders = []codec.VideoEncoderBuilder{params}
+	constraints.selectedMedia = prop.Media{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}}
+
+	tr, err := newTrack(opts, &mockVideoDriver{id: id}, constraints, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr, params
+}
+
+func TestBitRateController(t *testing.T) {
+	link := newSimulatedLink(1200000)
+	newLinkTrack := func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return &linkTrack{mockTrack: newMockTrack(codec, id), link: link}
+	}
+
+	main, mainParams := newMockRateTrack(t, "mockVideoMain", newLinkTrack)
+	defer main.Stop()
+	sub, subParams := newMockRateTrack(t, "mockVideoSub", newLinkTrack)
+	defer sub.Stop()
+
+	// The estimate is applied manually by update.
+	c := NewBitRateController(link, WithBitRateControlInterval(time.Hour))
+	defer c.Stop()
+	c.AddTrack(main, TrackBitRateConstraints{Priority: 2, MinBitRate: 500000})
+	c.AddTrack(sub, TrackBitRateConstraints{Priority: 1})
+
+	waitEncoder := func(params *mockRateParams, width, bitRate int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			e := params.last()
+			if e.p.Width == width && e.getBitRate() == bitRate {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		e := params.last()
+		t.Fatalf("Expected the encoder to be %d pixels wide at %d bps, got %d pixels at %d bps",
+			width, bitRate, e.p.Width, e.getBitRate())
+	}
+
+	checkLink := func() {
+		t.Helper()
+		sent := link.measure(300 * time.Millisecond)
+		capacity := link.Estimate()
+		if sent > capacity*11/10 {
+			t.Errorf("Expected the link not to be congested, sent %d bps over %d bps", sent, capacity)
+		}
+		if sent < capacity*7/10 {
+			t.Errorf("Expected the link to be utilized, sent %d bps over %d bps", sent, capacity)
+		}
+	}
+
+	c.update()
+	waitEncoder(mainParams, 640, 800000)
+	waitEncoder(subParams, 640, 400000)
+	checkLink()
+
+	// The bitrate of the main track goes below the floor, and the resolution is halved.
+	link.setCapacity(300000)
+	c.update()
+	waitEncoder(mainParams, 320, 200000)
+	waitEncoder(subParams, 640, 100000)
+	checkLink()
+
+	// The resolution is restored when the bandwidth recovers.
+	link.setCapacity(1200000)
+	c.update()
+	waitEncoder(mainParams, 640, 800000)
+
+	// The stopped track is removed, and the bandwidth goes to the rest.
+	sub.Stop()
+	c.update()
+	waitEncoder(mainParams, 640, 1200000)
+	c.mu.Lock()
+	n := len(c.tracks)
+	c.mu.Unlock()
+	if n != 1 {
+		t.Errorf("Expected the stopped track to be removed, got %d tracks", n)
+	}
+}
@@ -0,0 +1,285 @@
+package mediadevices
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// capturePollDuration is the interval which the readers of a capture poll the data read by another reader.
+// It's short enough not to delay audio chunks.
+const capturePollDuration = 2 * time.Millisecond
+
+var errInvalidDriverType = errors.New("invalid driver type")
+
+// capture is a running driver shared by the tracks recording from it. The data read from the driver
+// are fanned out to the tracks through a broadcaster, so that every track can have its own transform
+// and encoder.
+type capture struct {
+	d    driver.Driver
+	kind MediaDeviceType
+
+	video *video.Broadcaster
+	audio *audio.Broadcaster
+
+	// mu is held while reading from the driver, so that the driver is never recorded again in the middle of a read.
+	mu        sync.Mutex
+	readVideo func() (image.Image, time.Time, error)
+	readAudio func() (wave.Audio, time.Time, error)
+
+	// settings and recording are protected by settingsMu. recording is incremented every time the driver is recorded.
+	settingsMu sync.Mutex
+	settings   prop.Media
+	recording  int
+
+	// refs is the number of the tracks recording from the capture. It's protected by captures.mu.
+	refs int
+}
+
+// captureStamp is attached to the data passed through the broadcaster of a capture.
+type captureStamp struct {
+	timestamp time.Time
+	settings  prop.Media
+	recording int
+}
+
+type capturedFrame struct {
+	image.Image
+	captureStamp
+}
+
+type capturedChunk struct {
+	wave.Audio
+	captureStamp
+}
+
+// captures holds the running captures by the driver IDs.
+var captures = struct {
+	mu sync.Mutex
+	m  map[string]*capture
+}{m: make(map[string]*capture)}
+
+// acquireCapture returns the running capture of d, or opens d and starts a new capture with the settings
+// returned by choose. choose is called with the properties of d, or with the settings of the running capture
+// to check if the running capture can be shared.
+func acquireCapture(d driver.Driver, choose func(props []prop.Media) (prop.Media, error)) (*capture, error) {
+	captures.mu.Lock()
+	defer captures.mu.Unlock()
+
+	if c, ok := captures.m[d.ID()]; ok {
+		if _, err := choose([]prop.Media{c.Settings()}); err != nil {
+			return nil, err
+		}
+		c.refs++
+		return c, nil
+	}
+
+	if err := d.Open(); err != nil {
+		return nil, err
+	}
+
+	p, err := choose(d.Properties())
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	c, err := newCapture(d, p)
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	c.refs = 1
+	captures.m[d.ID()] = c
+	return c, nil
+}
+
+// runningSettings returns the settings of the running capture of d.
+func runningSettings(d driver.Driver) (prop.Media, bool) {
+	captures.mu.Lock()
+	c, ok := captures.m[d.ID()]
+	captures.mu.Unlock()
+
+	if !ok {
+		return prop.Media{}, false
+	}
+	return c.Settings(), true
+}
+
+func driverKind(d driver.Driver) (MediaDeviceType, bool) {
+	switch d.(type) {
+	case driver.VideoRecorder:
+		return VideoInput, true
+	case driver.AudioRecorder:
+		return AudioInput, true
+	default:
+		return 0, false
+	}
+}
+
+// newCapture records the opened driver with p.
+func newCapture(d driver.Driver, p prop.Media) (*capture, error) {
+	kind, ok := driverKind(d)
+	if !ok {
+		return nil, errInvalidDriverType
+	}
+
+	c := &capture{d: d, kind: kind}
+	if err := c.record(p); err != nil {
+		return nil, err
+	}
+
+	config := &mio.BroadcasterConfig{PollDuration: capturePollDuration}
+	switch kind {
+	case VideoInput:
+		c.video = video.NewBroadcaster(video.ReaderFunc(func() (image.Image, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			img, t, err := c.readVideo()
+			if err != nil {
+				return nil, err
+			}
+			return &capturedFrame{Image: img, captureStamp: c.stamp(t)}, nil
+		}), &video.BroadcasterConfig{Core: config})
+	case AudioInput:
+		c.audio = audio.NewBroadcaster(audio.ReaderFunc(func() (wave.Audio, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			chunk, t, err := c.readAudio()
+			if err != nil {
+				return nil, err
+			}
+			return &capturedChunk{Audio: chunk, captureStamp: c.stamp(t)}, nil
+		}), &audio.BroadcasterConfig{Core: config})
+	}
+	return c, nil
+}
+
+func (c *capture) stamp(t time.Time) captureStamp {
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+	return captureStamp{timestamp: t, settings: c.settings, recording: c.recording}
+}
+
+// record records the driver with p. mu must be held once the capture is created.
+func (c *capture) record(p prop.Media) error {
+	p.DeviceID = c.d.ID()
+
+	switch d := c.d.(type) {
+	case driver.VideoRecorder:
+		record := d.VideoRecord
+		if ed, ok := d.(driver.EncodedVideoRecorder); ok && isCompressed(p.FrameFormat) {
+			// The compressed frames are passed to the encoders, or decoded by the tracks if needed.
+			record = ed.EncodedVideoRecord
+		}
+		r, err := record(p)
+		if err != nil {
+			return err
+		}
+
+		var timestamper video.Timestamper
+		r = timestamper.Transform(r)
+		c.readVideo = func() (image.Image, time.Time, error) {
+			img, err := r.Read()
+			return img, timestamper.Timestamp(), err
+		}
+	case driver.AudioRecorder:
+		r, err := d.AudioRecord(p)
+		if err != nil {
+			return err
+		}
+
+		var timestamper audio.Timestamper
+		r = timestamper.Transform(r)
+		c.readAudio = func() (wave.Audio, time.Time, error) {
+			chunk, err := r.Read()
+			return chunk, timestamper.Timestamp(), err
+		}
+	default:
+		return errInvalidDriverType
+	}
+
+	c.settingsMu.Lock()
+	c.settings = p
+	c.recording++
+	c.settingsMu.Unlock()
+	return nil
+}
+
+// apply records the driver again with the settings which fit the constraints best. All the tracks
+// recording from the capture get the new settings.
+func (c *capture) apply(constraints MediaTrackConstraints) error {
+	// Wait for the running read to finish, and block the readers until the driver is recorded again.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	best, _, ok := fitBestProperty(c.d.Properties(), constraints)
+	if !ok {
+		return errOverconstrained
+	}
+	settings := selectSettings(constraints.MediaConstraints, best)
+
+	old := c.Settings()
+	if err := c.rerecord(settings); err != nil {
+		// Keep the capture running with the previous settings.
+		if errRestore := c.rerecord(old); errRestore != nil {
+			return errRestore
+		}
+		return err
+	}
+	return nil
+}
+
+// rerecord reopens the driver and records it with p. mu must be held.
+func (c *capture) rerecord(p prop.Media) error {
+	if err := c.d.Close(); err != nil {
+		return err
+	}
+	if err := c.d.Open(); err != nil {
+		return err
+	}
+	return c.record(p)
+}
+
+// Settings returns the settings which the driver is recording with.
+func (c *capture) Settings() prop.Media {
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+	return c.settings
+}
+
+// acquire adds a track recording from the capture. It returns false if the capture has already been closed.
+func (c *capture) acquire() bool {
+	captures.mu.Lock()
+	defer captures.mu.Unlock()
+
+	if c.refs == 0 {
+		return false
+	}
+	c.refs++
+	return true
+}
+
+// release removes a track recording from the capture. The driver is closed when the last track is removed.
+func (c *capture) release() {
+	captures.mu.Lock()
+	defer captures.mu.Unlock()
+
+	c.refs--
+	if c.refs > 0 {
+		return
+	}
+	delete(captures.m, c.d.ID())
+	c.d.Close()
+}
@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// Settings of the video encoder tests shared by the codecs
+const (
+	testWidth     = 320
+	testHeight    = 240
+	testFrameRate = 30
+
+	// keyFrameTestBitRate is the bitrate of the key frame tests, which is high enough not to skip frames.
+	keyFrameTestBitRate = 200000
+	// keyFrameTestInterval is the key frame interval of the key frame tests, which is long enough not to
+	// produce a key frame during the tests.
+	keyFrameTestInterval = 1000
+
+	// initialBitRate is the bitrate which VerifySetBitRate builds the encoder with.
+	initialBitRate = 400000
+	// targetBitRate is the bitrate set by VerifySetBitRate.
+	targetBitRate = 100000
+
+	bitRateMeasureDuration = 4 * time.Second
+	bitRateSettleDuration  = 2 * time.Second
+)
+
+// VideoEncoderTestCase is a video encoder to be checked by the tests shared by the codecs.
+type VideoEncoderTestCase struct {
+	// Builder builds the encoder.
+	Builder codec.VideoEncoderBuilder
+	// Params are the params of Builder, which are modified by the tests.
+	Params *codec.BaseParams
+	// IsKeyFrame returns true if the encoded frame is a key frame.
+	IsKeyFrame func([]byte) bool
+}
+
+// BuildVideoEncoder builds the encoder which encodes NewVideoReader of 320x240 at 30 fps.
+func (c VideoEncoderTestCase) BuildVideoEncoder() (codec.ReadCloser, error) {
+	return c.Builder.BuildVideoEncoder(
+		NewVideoReader(testWidth, testHeight, testFrameRate),
+		prop.Media{
+			Video: prop.Video{
+				Width:     testWidth,
+				Height:    testHeight,
+				FrameRate: testFrameRate,
+			},
+		},
+	)
+}
+
+// buildKeyFrameTestEncoder builds the encoder which doesn't produce key frames by itself during the tests.
+func (c VideoEncoderTestCase) buildKeyFrameTestEncoder() (codec.ReadCloser, error) {
+	c.Params.BitRate = keyFrameTestBitRate
+	c.Params.KeyFrameInterval = keyFrameTestInterval
+	return c.BuildVideoEncoder()
+}
+
+// VerifyForceKeyFrame builds the encoder and checks it by VerifyForceKeyFrame.
+func (c VideoEncoderTestCase) VerifyForceKeyFrame() error {
+	e, err := c.buildKeyFrameTestEncoder()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	return VerifyForceKeyFrame(e, c.IsKeyFrame)
+}
+
+// VerifyReadFrame builds the encoder and checks it by VerifyReadFrame.
+func (c VideoEncoderTestCase) VerifyReadFrame() error {
+	e, err := c.buildKeyFrameTestEncoder()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	return VerifyReadFrame(e, c.IsKeyFrame)
+}
+
+// VerifySetBitRate builds the encoder with initialBitRate, and measures the bitrate before and after
+// lowering the target to targetBitRate. The bitrate has to be halved at least. If tolerance is positive,
+// the bitrate after has to be within the ratio of targetBitRate, e.g. 0.5 for ±50%.
+// The measured bitrates are returned for logging.
+func (c VideoEncoderTestCase) VerifySetBitRate(tolerance float64) (before, after float64, err error) {
+	c.Params.BitRate = initialBitRate
+	e, err := c.BuildVideoEncoder()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer e.Close()
+
+	before, err = MeasureBitRate(e, bitRateMeasureDuration)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := e.SetBitRate(targetBitRate); err != nil {
+		return 0, 0, err
+	}
+
+	// Give rate control some time to follow the new target.
+	if _, err := MeasureBitRate(e, bitRateSettleDuration); err != nil {
+		return 0, 0, err
+	}
+	after, err = MeasureBitRate(e, bitRateMeasureDuration)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if after > before/2 {
+		return before, after, fmt.Errorf("expected bitrate to be lowered from %.0f, but got %.0f", before, after)
+	}
+	if tolerance > 0 && (after < targetBitRate*(1-tolerance) || targetBitRate*(1+tolerance) < after) {
+		return before, after, fmt.Errorf("expected bitrate to be around %d, but got %.0f", targetBitRate, after)
+	}
+	return before, after, nil
+}
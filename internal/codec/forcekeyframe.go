@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	mio "github.com/pion/mediadevices/pkg/io"
+)
+
+// KeyFrameForcer is an encoder output which can be asked to produce a key frame.
+type KeyFrameForcer interface {
+	io.Reader
+	ForceKeyFrame() error
+}
+
+// VerifyForceKeyFrame reads frames from e until a delta frame comes, forces a key frame,
+// and then checks that the next frame is actually a key frame by using isKeyFrame.
+func VerifyForceKeyFrame(e KeyFrameForcer, isKeyFrame func([]byte) bool) error {
+	const maxFrames = 30
+
+	buf := make([]byte, 1024)
+	readFrame := func() ([]byte, error) {
+		for {
+			n, err := e.Read(buf)
+			if err != nil {
+				if e, ok := err.(*mio.InsufficientBufferError); ok {
+					buf = make([]byte, 2*e.RequiredSize)
+					continue
+				}
+				return nil, err
+			}
+			// Encoders may skip frames to keep the bitrate.
+			if n > 0 {
+				return buf[:n], nil
+			}
+		}
+	}
+
+	var foundDelta bool
+	for i := 0; i < maxFrames; i++ {
+		frame, err := readFrame()
+		if err != nil {
+			return err
+		}
+		if !isKeyFrame(frame) {
+			foundDelta = true
+			break
+		}
+	}
+	if !foundDelta {
+		return fmt.Errorf("no delta frame in the first %d frames", maxFrames)
+	}
+
+	if err := e.ForceKeyFrame(); err != nil {
+		return err
+	}
+
+	frame, err := readFrame()
+	if err != nil {
+		return err
+	}
+	if !isKeyFrame(frame) {
+		return errors.New("the frame after ForceKeyFrame is not a key frame")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package codec
+
+// IsKeyFrameVP8 checks if the given VP8 frame is a key frame by parsing
+// the frame tag.
+// Reference: https://tools.ietf.org/html/rfc6386#section-9.1
+func IsKeyFrameVP8(frame []byte) bool {
+	if len(frame) < 10 {
+		return false
+	}
+
+	// The lowest bit of the frame tag is the inverse key frame flag.
+	if frame[0]&0x01 != 0 {
+		return false
+	}
+
+	// Key frames have a fixed start code after the frame tag.
+	return frame[3] == 0x9d && frame[4] == 0x01 && frame[5] == 0x2a
+}
+
+// IsKeyFrameVP9 checks if the given VP9 frame is a key frame by parsing
+// the uncompressed header. If the frame is a superframe, the first frame
+// in the superframe is checked.
+// Reference: https://storage.googleapis.com/downloads.webmproject.org/docs/vp9/vp9-bitstream-specification-v0.6-20160331-draft.pdf
+func IsKeyFrameVP9(frame []byte) bool {
+	if len(frame) < 1 {
+		return false
+	}
+
+	var pos uint
+	readBit := func() (uint8, bool) {
+		i := pos / 8
+		if int(i) >= len(frame) {
+			return 0, false
+		}
+		bit := (frame[i] >> (7 - pos%8)) & 0x01
+		pos++
+		return bit, true
+	}
+
+	// frame_marker
+	m0, _ := readBit()
+	m1, ok := readBit()
+	if !ok || m0 != 1 || m1 != 0 {
+		return false
+	}
+
+	profileLow, _ := readBit()
+	profileHigh, ok := readBit()
+	if !ok {
+		return false
+	}
+	if profileHigh<<1|profileLow == 3 {
+		// reserved_zero
+		if _, ok := readBit(); !ok {
+			return false
+		}
+	}
+
+	showExistingFrame, ok := readBit()
+	if !ok || showExistingFrame == 1 {
+		return false
+	}
+
+	// frame_type is 0 for KEY_FRAME
+	frameType, ok := readBit()
+	return ok && frameType == 0
+}
+
+// IsKeyFrameH264 checks if the given H.264 access unit in Annex-B format
+// contains an IDR slice.
+// Reference: https://www.itu.int/rec/T-REC-H.264
+func IsKeyFrameH264(au []byte) bool {
+	const nalTypeIDR = 5
+
+	for i := 0; i+3 < len(au); i++ {
+		// Both 3 bytes and 4 bytes start codes end with 0x00 0x00 0x01
+		if au[i] != 0 || au[i+1] != 0 || au[i+2] != 1 {
+			continue
+		}
+
+		if au[i+3]&0x1f == nalTypeIDR {
+			return true
+		}
+		i += 2
+	}
+	return false
+}
+
+// IsKeyFrameAV1 checks if the given AV1 temporal unit in the low overhead
+// bitstream format starts with a key frame by parsing the uncompressed header
+// of the first frame. The sequence must not use reduced_still_picture_header.
+// Reference: https://aomediacodec.github.io/av1-spec/
+func IsKeyFrameAV1(tu []byte) bool {
+	const (
+		obuFrameHeader = 3
+		obuFrame       = 6
+	)
+
+	for len(tu) > 0 {
+		header := tu[0]
+		pos := 1
+		if header&0x04 != 0 {
+			// obu_extension_header
+			pos++
+		}
+		if header&0x02 == 0 {
+			// The OBUs without obu_size aren't supported.
+			return false
+		}
+
+		var size, i int
+		for ; ; i++ {
+			if pos+i >= len(tu) || i >= 8 {
+				return false
+			}
+			size |= int(tu[pos+i]&0x7f) << (7 * uint(i))
+			if tu[pos+i]&0x80 == 0 {
+				break
+			}
+		}
+		pos += i + 1
+		if size > len(tu)-pos {
+			return false
+		}
+
+		if typ := header >> 3 & 0x0f; typ == obuFrameHeader || typ == obuFrame {
+			// show_existing_frame is the first bit, and frame_type is the next 2 bits, which is 0 for KEY_FRAME.
+			return size > 0 && tu[pos]&0xe0 == 0
+		}
+		tu = tu[pos+size:]
+	}
+	return false
+}
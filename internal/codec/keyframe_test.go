@@ -0,0 +1,178 @@
+package codec
+
+import (
+	"testing"
+)
+
+func TestIsKeyFrameVP8(t *testing.T) {
+	testCases := map[string]struct {
+		frame    []byte
+		expected bool
+	}{
+		"KeyFrame": {
+			frame:    []byte{0x50, 0x42, 0x00, 0x9d, 0x01, 0x2a, 0x40, 0x01, 0xf0, 0x00},
+			expected: true,
+		},
+		"InterFrame": {
+			frame:    []byte{0x51, 0x42, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: false,
+		},
+		"BrokenStartCode": {
+			frame:    []byte{0x50, 0x42, 0x00, 0x9d, 0x01, 0x00, 0x40, 0x01, 0xf0, 0x00},
+			expected: false,
+		},
+		"Short": {
+			frame:    []byte{0x50, 0x42},
+			expected: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if actual := IsKeyFrameVP8(testCase.frame); actual != testCase.expected {
+				t.Errorf("Expected %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsKeyFrameVP9(t *testing.T) {
+	testCases := map[string]struct {
+		frame    []byte
+		expected bool
+	}{
+		"KeyFrameProfile0": {
+			// frame_marker=2, profile=0, show_existing_frame=0, frame_type=0
+			frame:    []byte{0x82, 0x49, 0x83, 0x42},
+			expected: true,
+		},
+		"InterFrameProfile0": {
+			// frame_marker=2, profile=0, show_existing_frame=0, frame_type=1
+			frame:    []byte{0x86, 0x00, 0x40},
+			expected: false,
+		},
+		"KeyFrameProfile3": {
+			// frame_marker=2, profile=3, reserved_zero=0, show_existing_frame=0, frame_type=0
+			frame:    []byte{0xb0, 0x00},
+			expected: true,
+		},
+		"ShowExistingFrame": {
+			// frame_marker=2, profile=0, show_existing_frame=1
+			frame:    []byte{0x88},
+			expected: false,
+		},
+		"InvalidFrameMarker": {
+			frame:    []byte{0x02, 0x49},
+			expected: false,
+		},
+		"Empty": {
+			frame:    []byte{},
+			expected: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if actual := IsKeyFrameVP9(testCase.frame); actual != testCase.expected {
+				t.Errorf("Expected %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsKeyFrameH264(t *testing.T) {
+	testCases := map[string]struct {
+		au       []byte
+		expected bool
+	}{
+		"IDRWithParameterSets": {
+			au: []byte{
+				0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0xc0, 0x1f, // SPS
+				0x00, 0x00, 0x00, 0x01, 0x68, 0xce, 0x3c, 0x80, // PPS
+				0x00, 0x00, 0x01, 0x65, 0x88, 0x84, 0x00, // IDR
+			},
+			expected: true,
+		},
+		"NonIDR": {
+			au:       []byte{0x00, 0x00, 0x00, 0x01, 0x41, 0x9a, 0x02, 0x04},
+			expected: false,
+		},
+		"ParameterSetsOnly": {
+			au: []byte{
+				0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0xc0, 0x1f,
+				0x00, 0x00, 0x00, 0x01, 0x68, 0xce, 0x3c, 0x80,
+			},
+			expected: false,
+		},
+		"NoStartCode": {
+			au:       []byte{0x65, 0x88, 0x84, 0x00},
+			expected: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if actual := IsKeyFrameH264(testCase.au); actual != testCase.expected {
+				t.Errorf("Expected %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsKeyFrameAV1(t *testing.T) {
+	testCases := map[string]struct {
+		tu       []byte
+		expected bool
+	}{
+		"KeyFrame": {
+			tu: []byte{
+				0x12, 0x00, // temporal delimiter
+				0x0a, 0x02, 0x00, 0x00, // sequence header
+				0x32, 0x03, 0x10, 0x01, 0x02, // frame: show_existing_frame=0, frame_type=0
+			},
+			expected: true,
+		},
+		"InterFrame": {
+			tu: []byte{
+				0x12, 0x00,
+				0x32, 0x03, 0x30, 0x01, 0x02, // frame: show_existing_frame=0, frame_type=1
+			},
+			expected: false,
+		},
+		"FrameHeaderWithExtension": {
+			tu: []byte{
+				0x12, 0x00,
+				0x1e, 0x00, 0x01, 0x10, // frame header with obu_extension_header
+			},
+			expected: true,
+		},
+		"ShowExistingFrame": {
+			tu:       []byte{0x12, 0x00, 0x1a, 0x01, 0x80},
+			expected: false,
+		},
+		"NoSizeField": {
+			tu:       []byte{0x30, 0x10, 0x00},
+			expected: false,
+		},
+		"Truncated": {
+			tu:       []byte{0x12, 0x00, 0x32, 0x05, 0x10},
+			expected: false,
+		},
+		"Empty": {
+			tu:       []byte{},
+			expected: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if actual := IsKeyFrameAV1(testCase.tu); actual != testCase.expected {
+				t.Errorf("Expected %v, got %v", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+// PSNR returns the peak signal-to-noise ratio of the luma of b to a in dB.
+// It's +Inf if the images are identical.
+func PSNR(a, b *image.YCbCr) (float64, error) {
+	if a.Rect.Size() != b.Rect.Size() {
+		return 0, fmt.Errorf("size mismatch: %v and %v", a.Rect.Size(), b.Rect.Size())
+	}
+
+	w, h := a.Rect.Dx(), a.Rect.Dy()
+	var sum float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			d := float64(a.Y[y*a.YStride+x]) - float64(b.Y[y*b.YStride+x])
+			sum += d * d
+		}
+	}
+	if sum == 0 {
+		return math.Inf(1), nil
+	}
+	mse := sum / float64(w*h)
+	return 10 * math.Log10(255*255/mse), nil
+}
+
+// SSIM returns the mean structural similarity of the luma of b to a, which is 1 if the images are
+// identical. It's computed over 8x8 windows at every 4 pixels.
+// Reference: https://ece.uwaterloo.ca/~z70wang/publications/ssim.pdf
+func SSIM(a, b *image.YCbCr) (float64, error) {
+	const (
+		window = 8
+		step   = 4
+		c1     = (0.01 * 255) * (0.01 * 255)
+		c2     = (0.03 * 255) * (0.03 * 255)
+	)
+
+	if a.Rect.Size() != b.Rect.Size() {
+		return 0, fmt.Errorf("size mismatch: %v and %v", a.Rect.Size(), b.Rect.Size())
+	}
+	w, h := a.Rect.Dx(), a.Rect.Dy()
+	if w < window || h < window {
+		return 0, fmt.Errorf("image is smaller than the window: %dx%d", w, h)
+	}
+
+	var sum float64
+	var n int
+	for y0 := 0; y0+window <= h; y0 += step {
+		for x0 := 0; x0+window <= w; x0 += step {
+			var sa, sb, saa, sbb, sab float64
+			for y := y0; y < y0+window; y++ {
+				for x := x0; x < x0+window; x++ {
+					va := float64(a.Y[y*a.YStride+x])
+					vb := float64(b.Y[y*b.YStride+x])
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+				}
+			}
+			const size = window * window
+			ma, mb := sa/size, sb/size
+			varA := saa/size - ma*ma
+			varB := sbb/size - mb*mb
+			cov := sab/size - ma*mb
+			sum += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (varA + varB + c2))
+			n++
+		}
+	}
+	return sum / float64(n), nil
+}
+
+// Quality is the quality of the decoded frames.
+type Quality struct {
+	// MinPSNR and MinSSIM are the lowest values of the frames.
+	MinPSNR float64
+	MinSSIM float64
+}
+
+// MeasureQuality encodes the frames read from src by the encoder, decodes them by the decoder, and
+// compares the decoded frames with the source frames. Both of them are built with the given functions.
+// The decoder has to output a picture for each frame, and src has to produce *image.YCbCr in I420.
+func MeasureQuality(
+	src video.Reader,
+	buildEncoder func(r video.Reader) (codec.ReadCloser, error),
+	buildDecoder func(r codec.FrameReader) (codec.VideoDecoder, error),
+	numFrames int,
+) (Quality, error) {
+	var last, current *image.YCbCr
+	e, err := buildEncoder(video.ReaderFunc(func() (image.Image, error) {
+		img, err := src.Read()
+		if err != nil {
+			return nil, err
+		}
+		yuvImg, ok := img.(*image.YCbCr)
+		if !ok {
+			return nil, fmt.Errorf("expected *image.YCbCr, got %T", img)
+		}
+		last = yuvImg
+		return img, nil
+	}))
+	if err != nil {
+		return Quality{}, err
+	}
+	defer e.Close()
+
+	r, ok := e.(codec.FrameReader)
+	if !ok {
+		return Quality{}, errors.New("the encoder doesn't implement codec.FrameReader")
+	}
+	d, err := buildDecoder(codec.FrameReaderFunc(func() (codec.EncodedFrame, error) {
+		f, err := r.ReadFrame()
+		current = last
+		return f, err
+	}))
+	if err != nil {
+		return Quality{}, err
+	}
+	defer d.Close()
+
+	q := Quality{MinPSNR: math.Inf(1), MinSSIM: 1}
+	for i := 0; i < numFrames; i++ {
+		img, err := d.Read()
+		if err != nil {
+			return Quality{}, err
+		}
+		decoded, ok := img.(*image.YCbCr)
+		if !ok {
+			return Quality{}, fmt.Errorf("expected *image.YCbCr, got %T", img)
+		}
+
+		psnr, err := PSNR(current, decoded)
+		if err != nil {
+			return Quality{}, err
+		}
+		ssim, err := SSIM(current, decoded)
+		if err != nil {
+			return Quality{}, err
+		}
+		q.MinPSNR = math.Min(q.MinPSNR, psnr)
+		q.MinSSIM = math.Min(q.MinSSIM, ssim)
+	}
+	return q, nil
+}
@@ -0,0 +1,74 @@
+package codec
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func newGradation(w, h, offset int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Y[y*img.YStride+x] = uint8(x*4 + y + offset)
+		}
+	}
+	return img
+}
+
+func TestPSNR(t *testing.T) {
+	a := newGradation(32, 16, 0)
+
+	psnr, err := PSNR(a, newGradation(32, 16, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(psnr, 1) {
+		t.Errorf("Expected +Inf for identical images, got %f", psnr)
+	}
+
+	// Every pixel differs by 1, so MSE is 1.
+	psnr, err = PSNR(a, newGradation(32, 16, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := 10 * math.Log10(255*255); math.Abs(psnr-expected) > 1e-9 {
+		t.Errorf("Expected %f, got %f", expected, psnr)
+	}
+
+	if _, err := PSNR(a, newGradation(16, 16, 0)); err == nil {
+		t.Error("Expected an error for images of different sizes")
+	}
+}
+
+func TestSSIM(t *testing.T) {
+	a := newGradation(32, 16, 0)
+
+	ssim, err := SSIM(a, newGradation(32, 16, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(ssim-1) > 1e-9 {
+		t.Errorf("Expected 1 for identical images, got %f", ssim)
+	}
+
+	shifted, err := SSIM(a, newGradation(32, 16, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat, err := SSIM(a, image.NewYCbCr(a.Rect, image.YCbCrSubsampleRatio420))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shifting the brightness keeps the structure, but a flat image loses it.
+	if !(flat < shifted && shifted < 1) {
+		t.Errorf("Expected 1 > %f > %f", shifted, flat)
+	}
+
+	if _, err := SSIM(a, newGradation(16, 16, 0)); err == nil {
+		t.Error("Expected an error for images of different sizes")
+	}
+	if _, err := SSIM(newGradation(4, 4, 0), newGradation(4, 4, 0)); err == nil {
+		t.Error("Expected an error for images smaller than the window")
+	}
+}
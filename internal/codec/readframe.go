@@ -0,0 +1,71 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pion/mediadevices/pkg/codec"
+)
+
+// VerifyReadFrame reads frames from e through codec.FrameReader and checks that
+// their metadata is consistent with the bitstream. A key frame is forced in the
+// middle to check that KeyFrame is reported for frames other than the first one.
+func VerifyReadFrame(e codec.ReadCloser, isKeyFrame func([]byte) bool) error {
+	const numFrames = 20
+
+	r, ok := e.(codec.FrameReader)
+	if !ok {
+		return errors.New("the encoder doesn't implement codec.FrameReader")
+	}
+
+	var prev codec.EncodedFrame
+	var numKeyFrames int
+	for i := 0; i < numFrames; i++ {
+		if i == numFrames/2 {
+			if err := e.ForceKeyFrame(); err != nil {
+				return err
+			}
+		}
+
+		frame, err := r.ReadFrame()
+		if err != nil {
+			return err
+		}
+
+		if frame.Size() != len(frame.Data) {
+			return fmt.Errorf("frame %d: expected size %d, got %d", i, len(frame.Data), frame.Size())
+		}
+		if frame.Timestamp.IsZero() {
+			return fmt.Errorf("frame %d: timestamp is not set", i)
+		}
+		if i == 0 {
+			if frame.Duration != 0 {
+				return fmt.Errorf("frame %d: expected duration 0, got %v", i, frame.Duration)
+			}
+		} else {
+			if frame.Timestamp.Before(prev.Timestamp) {
+				return fmt.Errorf("frame %d: timestamp went backward", i)
+			}
+			if d := frame.Timestamp.Sub(prev.Timestamp); frame.Duration != d {
+				return fmt.Errorf("frame %d: expected duration %v, got %v", i, d, frame.Duration)
+			}
+		}
+		prev = frame
+
+		// Encoders may skip frames to keep the bitrate.
+		if frame.Size() == 0 {
+			continue
+		}
+		if expected := isKeyFrame(frame.Data); frame.KeyFrame != expected {
+			return fmt.Errorf("frame %d: expected KeyFrame to be %v, got %v", i, expected, frame.KeyFrame)
+		}
+		if frame.KeyFrame {
+			numKeyFrames++
+		}
+	}
+
+	if numKeyFrames < 2 {
+		return fmt.Errorf("expected at least 2 key frames, got %d", numKeyFrames)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// NewVideoReader returns a video source which produces I420 frames at the given frame rate.
+// The frames have a moving gradation and a noise area to keep encoders busy.
+func NewVideoReader(width, height int, frameRate float32) video.Reader {
+	random := rand.New(rand.NewSource(0))
+	tick := time.NewTicker(time.Duration(float32(time.Second) / frameRate))
+	var cnt int
+
+	return video.ReaderFunc(func() (image.Image, error) {
+		<-tick.C
+		img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := uint8(x + y + cnt)
+				if y > height*3/4 {
+					v = uint8(random.Int31n(2) * 255)
+				}
+				img.Y[y*img.YStride+x] = v
+			}
+		}
+		for i := range img.Cb {
+			img.Cb[i] = 128
+			img.Cr[i] = uint8(cnt)
+		}
+		cnt++
+		return img, nil
+	})
+}
+
+// NewAudioReader returns an audio source which produces chunks of the given latency in real time.
+// The audio is a 480 Hz sine wave with a white noise.
+func NewAudioReader(sampleRate, channels int, latency time.Duration) audio.Reader {
+	random := rand.New(rand.NewSource(0))
+	nSamples := int(int64(sampleRate) * int64(latency) / int64(time.Second))
+	nextReadTime := time.Now()
+	var phase int
+
+	return audio.ReaderFunc(func() (wave.Audio, error) {
+		time.Sleep(time.Until(nextReadTime))
+		nextReadTime = nextReadTime.Add(latency)
+
+		a := wave.NewFloat32Interleaved(wave.ChunkInfo{
+			Len:          nSamples,
+			Channels:     channels,
+			SamplingRate: sampleRate,
+		})
+		for i := 0; i < nSamples; i++ {
+			v := 0.25*math.Sin(2*math.Pi*480*float64(phase)/float64(sampleRate)) + 0.1*(random.Float64()-0.5)
+			phase++
+			for ch := 0; ch < channels; ch++ {
+				a.SetFloat32(i, ch, wave.Float32Sample(v))
+			}
+		}
+		return a, nil
+	})
+}
@@ -0,0 +1,55 @@
+package mediadevices
+
+import (
+	"math"
+	"time"
+)
+
+// MediaClock is a clock shared by the tracks of a MediaStream. The capture timestamps of
+// all the tracks are mapped onto it, so that the tracks can be synchronized with each other,
+// e.g. audio and video lip-sync.
+//
+// The clock starts at the wall clock time when it's created and advances monotonically,
+// so that it's not affected by wall clock adjustments during the session.
+type MediaClock struct {
+	// epoch has the monotonic clock reading of when the clock was created, and wall has the
+	// wall clock reading of the same instant.
+	epoch time.Time
+	wall  time.Time
+}
+
+// NewMediaClock creates a new MediaClock which starts now.
+func NewMediaClock() *MediaClock {
+	now := time.Now()
+	return &MediaClock{epoch: now, wall: now.Round(0)}
+}
+
+// Now returns the current time of the clock.
+func (c *MediaClock) Now() time.Time {
+	return c.Time(time.Now())
+}
+
+// Time maps a capture timestamp onto the clock. Timestamps from time.Now are mapped by their
+// monotonic offset since the clock was created, so that a wall clock adjustment doesn't move
+// them. Timestamps without a monotonic clock reading are mapped by the wall clock.
+func (c *MediaClock) Time(t time.Time) time.Time {
+	// Sub uses the monotonic clock readings if both of the times have them.
+	return c.wall.Add(t.Sub(c.epoch))
+}
+
+// RTPClockMapping is a mapping between a time on the MediaClock and the RTP timestamp of a track.
+// It can be used to generate RTCP Sender Reports.
+type RTPClockMapping struct {
+	// Time is the time on the MediaClock which corresponds to RTPTimestamp.
+	Time time.Time
+	// RTPTimestamp is the RTP timestamp of the sample captured at Time.
+	RTPTimestamp uint32
+	// ClockRate is the RTP clock rate of the track.
+	ClockRate uint32
+}
+
+// RTPTimestampAt extrapolates the RTP timestamp at the given time on the MediaClock.
+func (m RTPClockMapping) RTPTimestampAt(t time.Time) uint32 {
+	elapsed := t.Sub(m.Time).Seconds()
+	return m.RTPTimestamp + uint32(int64(math.Round(elapsed*float64(m.ClockRate))))
+}
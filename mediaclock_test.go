@@ -0,0 +1,170 @@
+package mediadevices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+)
+
+func TestMediaClock(t *testing.T) {
+	c := NewMediaClock()
+
+	t0 := time.Now()
+	now := c.Now()
+	t1 := time.Now()
+	if now.Before(t0.Round(0)) || now.After(t1.Round(0)) {
+		t.Errorf("Expected Now to be between %v and %v, got %v", t0, t1, now)
+	}
+
+	// Timestamps without monotonic clock reading are mapped by the wall clock.
+	wall := time.Unix(1000, 0)
+	if mapped := c.Time(wall); !mapped.Equal(wall) {
+		t.Errorf("Expected %v, got %v", wall, mapped)
+	}
+
+	// Timestamps with monotonic clock reading are mapped by the offset since the epoch,
+	// regardless of their wall clock reading.
+	epoch := time.Now()
+	c = &MediaClock{epoch: epoch, wall: time.Unix(1000, 0)}
+	expected := time.Unix(1001, 0)
+	if mapped := c.Time(epoch.Add(time.Second)); !mapped.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, mapped)
+	}
+}
+
+func TestRTPClockMapping(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	m := RTPClockMapping{
+		Time:         t0,
+		RTPTimestamp: 0xFFFFFF00,
+		ClockRate:    90000,
+	}
+
+	testCases := map[string]struct {
+		t        time.Time
+		expected uint32
+	}{
+		"Same": {
+			t:        t0,
+			expected: 0xFFFFFF00,
+		},
+		"Before": {
+			t:        t0.Add(-10 * time.Millisecond),
+			expected: 0xFFFFFF00 - 900,
+		},
+		"WrapAround": {
+			t:        t0.Add(10 * time.Millisecond),
+			expected: 900 - 0x100,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if ts := m.RTPTimestampAt(testCase.t); ts != testCase.expected {
+				t.Errorf("Expected %d, got %d", testCase.expected, ts)
+			}
+		})
+	}
+}
+
+func TestGetUserMediaLipSync(t *testing.T) {
+	const (
+		warmUp      = 500 * time.Millisecond
+		measureDur  = 3 * time.Second
+		maxDrift    = 30 * time.Millisecond
+		maxAVOffset = 30 * time.Millisecond
+	)
+
+	md := NewMediaDevicesFromCodecs(
+		map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Type: webrtc.RTPCodecTypeVideo, Name: "MockVideo", PayloadType: 1, RTPCodecCapability: webrtc.RTPCodecCapability{ClockRate: 90000}},
+			},
+			webrtc.RTPCodecTypeAudio: {
+				{Type: webrtc.RTPCodecTypeAudio, Name: "MockAudio", PayloadType: 2, RTPCodecCapability: webrtc.RTPCodecCapability{ClockRate: 48000}},
+			},
+		},
+		WithTrackGenerator(
+			func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (
+				LocalTrack, error,
+			) {
+				return newMockRTPTrack(codec, id), nil
+			},
+		),
+	)
+	ms, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.Width = prop.Int(640)
+			c.Height = prop.Int(480)
+			c.VideoEncoderBuilders = []codec.VideoEncoderBuilder{
+				&mockParams{BaseParams: codec.BaseParams{BitRate: 100000}, name: "MockVideo"},
+			}
+		},
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.AudioEncoderBuilders = []codec.AudioEncoderBuilder{
+				&mockParams{BaseParams: codec.BaseParams{BitRate: 32000}, name: "MockAudio"},
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	video, audio := ms.GetVideoTracks()[0], ms.GetAudioTracks()[0]
+	defer video.Stop()
+	defer audio.Stop()
+
+	if video.MediaClock() != audio.MediaClock() {
+		t.Fatal("Expected the tracks of the stream to share the same clock")
+	}
+
+	mappings := func() (RTPClockMapping, RTPClockMapping) {
+		v, err := video.RTPClockMapping()
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := audio.RTPClockMapping()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v, a
+	}
+
+	time.Sleep(warmUp)
+	v0, a0 := mappings()
+	time.Sleep(measureDur)
+	v1, a1 := mappings()
+
+	// drift is how far the RTP timestamps moved away from the capture timestamps on the clock.
+	drift := func(m0, m1 RTPClockMapping) time.Duration {
+		rtpElapsed := time.Duration(m1.RTPTimestamp-m0.RTPTimestamp) * time.Second / time.Duration(m0.ClockRate)
+		return rtpElapsed - m1.Time.Sub(m0.Time)
+	}
+	abs := func(d time.Duration) time.Duration {
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+
+	videoDrift, audioDrift := drift(v0, v1), drift(a0, a1)
+	if abs(videoDrift) > maxDrift {
+		t.Errorf("Expected video drift to be less than %v, got %v", maxDrift, videoDrift)
+	}
+	if abs(audioDrift) > maxDrift {
+		t.Errorf("Expected audio drift to be less than %v, got %v", maxDrift, audioDrift)
+	}
+	if offset := videoDrift - audioDrift; abs(offset) > maxAVOffset {
+		t.Errorf("Expected A/V offset to be less than %v, got %v", maxAVOffset, offset)
+	}
+
+	// The latest samples of both tracks must have been captured at around the same time.
+	if offset := v1.Time.Sub(a1.Time); abs(offset) > 100*time.Millisecond {
+		t.Errorf("Expected capture time offset to be less than 100ms, got %v", offset)
+	}
+}
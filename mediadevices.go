@@ -10,7 +10,10 @@ import (
 	"github.com/pion/webrtc/v2"
 )
 
-var errNotFound = fmt.Errorf("failed to find the best driver that fits the constraints")
+var (
+	errNotFound        = fmt.Errorf("failed to find the best driver that fits the constraints")
+	errOverconstrained = fmt.Errorf("the device doesn't have the properties that fit the constraints")
+)
 
 // MediaDevices is an interface that's defined on https://developer.mozilla.org/en-US/docs/Web/API/MediaDevices
 type MediaDevices interface {
@@ -95,13 +98,13 @@ func (m *mediaDevices) GetDisplayMedia(constraints MediaStreamConstraints) (Medi
 	}
 
 	if videoConstraints.Enabled {
-		tracker, err := m.selectScreen(videoConstraints)
+		tracks, err := m.selectScreen(videoConstraints, NewMediaClock())
 		if err != nil {
 			cleanTrackers()
 			return nil, err
 		}
 
-		trackers = append(trackers, tracker)
+		trackers = append(trackers, tracks...)
 	}
 
 	s, err := NewMediaStream(trackers...)
@@ -135,18 +138,21 @@ func (m *mediaDevices) GetUserMedia(constraints MediaStreamConstraints) (MediaSt
 		constraints.Audio(&audioConstraints)
 	}
 
+	// Tracks of the same stream share the same clock for synchronization.
+	clock := NewMediaClock()
+
 	if videoConstraints.Enabled {
-		tracker, err := m.selectVideo(videoConstraints)
+		tracks, err := m.selectVideo(videoConstraints, clock)
 		if err != nil {
 			cleanTrackers()
 			return nil, err
 		}
 
-		trackers = append(trackers, tracker)
+		trackers = append(trackers, tracks...)
 	}
 
 	if audioConstraints.Enabled {
-		tracker, err := m.selectAudio(audioConstraints)
+		tracker, err := m.selectAudio(audioConstraints, clock)
 		if err != nil {
 			cleanTrackers()
 			return nil, err
@@ -170,6 +176,12 @@ func queryDriverProperties(filter driver.FilterFn) map[driver.Driver][]prop.Medi
 	m := make(map[driver.Driver][]prop.Media)
 
 	for _, d := range drivers {
+		if settings, ok := runningSettings(d); ok {
+			// The running driver can only be shared with the settings it's recording with.
+			m[d] = []prop.Media{settings}
+			continue
+		}
+
 		if d.Status() == driver.StateClosed {
 			err := d.Open()
 			if err != nil {
@@ -199,18 +211,15 @@ func selectBestDriver(filter driver.FilterFn, constraints MediaTrackConstraints)
 
 	driverProperties := queryDriverProperties(filter)
 	for d, props := range driverProperties {
-		priority := float64(d.Info().Priority)
-		for _, p := range props {
-			fitnessDist, ok := constraints.MediaConstraints.FitnessDistance(p)
-			if !ok {
-				continue
-			}
-			fitnessDist -= priority
-			if fitnessDist < minFitnessDist {
-				minFitnessDist = fitnessDist
-				bestDriver = d
-				bestProp = p
-			}
+		p, fitnessDist, ok := fitBestProperty(props, constraints)
+		if !ok {
+			continue
+		}
+		fitnessDist -= float64(d.Info().Priority)
+		if fitnessDist < minFitnessDist {
+			minFitnessDist = fitnessDist
+			bestDriver = d
+			bestProp = p
 		}
 	}
 
@@ -234,13 +243,48 @@ func selectBestDriver(filter driver.FilterFn, constraints MediaTrackConstraints)
 		return nil, MediaTrackConstraints{}, err
 	}
 
-	constraints.selectedMedia = prop.Media{}
-	constraints.selectedMedia.MergeConstraints(constraints.MediaConstraints)
-	constraints.selectedMedia.Merge(bestProp)
+	constraints.selectedMedia = selectSettings(constraints.MediaConstraints, bestProp)
 	return bestDriver, constraints, nil
 }
 
-func (m *mediaDevices) selectAudio(constraints MediaTrackConstraints) (Tracker, error) {
+// fitBestProperty returns the property which fits the constraints best along with its fitness distance.
+// ok is false if none of the properties satisfies the constraints.
+//
+// The properties of the compressed formats which can be passed to the encoders as they are, are preferred
+// over the others with the same fitness distance. The ones which can neither be decoded nor passed through
+// are skipped.
+func fitBestProperty(props []prop.Media, constraints MediaTrackConstraints) (best prop.Media, fitnessDist float64, ok bool) {
+	fitnessDist = math.Inf(1)
+	var bestPassthrough bool
+	for _, p := range props {
+		d, fit := constraints.FitnessDistance(p)
+		if !fit {
+			continue
+		}
+		passthrough := constraints.passthrough(p.FrameFormat)
+		if !passthrough && needsPassthrough(p.FrameFormat) {
+			continue
+		}
+		if d < fitnessDist || (d == fitnessDist && passthrough && !bestPassthrough) {
+			fitnessDist = d
+			best = p
+			bestPassthrough = passthrough
+			ok = true
+		}
+	}
+	return best, fitnessDist, ok
+}
+
+// selectSettings returns the settings which are selected from the constraints and the best property.
+// The property takes priority over the constraints.
+func selectSettings(constraints prop.MediaConstraints, best prop.Media) prop.Media {
+	var settings prop.Media
+	settings.MergeConstraints(constraints)
+	settings.Merge(best)
+	return settings
+}
+
+func (m *mediaDevices) selectAudio(constraints MediaTrackConstraints, clock *MediaClock) (Tracker, error) {
 	typeFilter := driver.FilterAudioRecorder()
 
 	d, c, err := selectBestDriver(typeFilter, constraints)
@@ -248,9 +292,9 @@ func (m *mediaDevices) selectAudio(constraints MediaTrackConstraints) (Tracker,
 		return nil, err
 	}
 
-	return newTrack(&m.MediaDevicesOptions, d, c)
+	return newTrack(&m.MediaDevicesOptions, d, c, clock)
 }
-func (m *mediaDevices) selectVideo(constraints MediaTrackConstraints) (Tracker, error) {
+func (m *mediaDevices) selectVideo(constraints MediaTrackConstraints, clock *MediaClock) ([]Tracker, error) {
 	typeFilter := driver.FilterVideoRecorder()
 	notScreenFilter := driver.FilterNot(driver.FilterDeviceType(driver.Screen))
 	filter := driver.FilterAnd(typeFilter, notScreenFilter)
@@ -260,10 +304,10 @@ func (m *mediaDevices) selectVideo(constraints MediaTrackConstraints) (Tracker,
 		return nil, err
 	}
 
-	return newTrack(&m.MediaDevicesOptions, d, c)
+	return newTracks(&m.MediaDevicesOptions, d, c, clock)
 }
 
-func (m *mediaDevices) selectScreen(constraints MediaTrackConstraints) (Tracker, error) {
+func (m *mediaDevices) selectScreen(constraints MediaTrackConstraints, clock *MediaClock) ([]Tracker, error) {
 	typeFilter := driver.FilterVideoRecorder()
 	screenFilter := driver.FilterDeviceType(driver.Screen)
 	filter := driver.FilterAnd(typeFilter, screenFilter)
@@ -273,7 +317,7 @@ func (m *mediaDevices) selectScreen(constraints MediaTrackConstraints) (Tracker,
 		return nil, err
 	}
 
-	return newTrack(&m.MediaDevicesOptions, d, c)
+	return newTracks(&m.MediaDevicesOptions, d, c, clock)
 }
 
 func (m *mediaDevices) EnumerateDevices() []MediaDeviceInfo {
@@ -3,9 +3,11 @@ package mediadevices
 import (
 	"errors"
 	"io"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v2"
 	"github.com/pion/webrtc/v2/pkg/media"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/pion/mediadevices/pkg/driver"
 	_ "github.com/pion/mediadevices/pkg/driver/audiotest"
 	_ "github.com/pion/mediadevices/pkg/driver/videotest"
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/io/audio"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
@@ -128,6 +131,69 @@ func TestGetUserMedia(t *testing.T) {
 	}
 }
 
+func TestGetUserMediaSharedDriver(t *testing.T) {
+	md := NewMediaDevicesFromCodecs(
+		map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Type: webrtc.RTPCodecTypeVideo, Name: "MockVideo", PayloadType: 1},
+			},
+		},
+		WithTrackGenerator(
+			func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (
+				LocalTrack, error,
+			) {
+				return newMockRTPTrack(codec, id), nil
+			},
+		),
+	)
+	constraints := MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.Width = prop.Int(640)
+			c.Height = prop.Int(480)
+			c.VideoEncoderBuilders = []codec.VideoEncoderBuilder{
+				&mockParams{BaseParams: codec.BaseParams{BitRate: 100000}, name: "MockVideo"},
+			}
+		},
+	}
+
+	first, err := md.GetUserMedia(constraints)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := md.GetUserMedia(constraints)
+	if err != nil {
+		t.Fatalf("Expected the running driver to be shared, got: %v", err)
+	}
+
+	firstTrack, secondTrack := first.GetVideoTracks()[0], second.GetVideoTracks()[0]
+	id := firstTrack.GetSettings().DeviceID
+	if secondID := secondTrack.GetSettings().DeviceID; secondID != id {
+		t.Fatalf("Expected the tracks to share %s, got %s", id, secondID)
+	}
+	d := driver.GetManager().Query(driver.FilterID(id))[0]
+
+	firstTrack.Stop()
+	if d.Status() == driver.StateClosed {
+		t.Fatal("Expected the driver to be kept open for the other track")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	m0, err := secondTrack.RTPClockMapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if m1, _ := secondTrack.RTPClockMapping(); !m1.Time.After(m0.Time) {
+		t.Error("Expected the track to keep running after the other track is stopped")
+	}
+
+	secondTrack.Stop()
+	if d.Status() != driver.StateClosed {
+		t.Errorf("Expected the driver to be closed, got %s", d.Status())
+	}
+}
+
 type mockTrack struct {
 	codec *webrtc.RTPCodec
 	id    string
@@ -156,6 +222,45 @@ func (t *mockTrack) Kind() webrtc.RTPCodecType {
 	return t.codec.Type
 }
 
+// mockRTPTrack is a mockTrack implementing RTPWriter, which starts from a random RTP timestamp
+// like webrtc.Track.
+type mockRTPTrack struct {
+	*mockTrack
+	packetizer rtp.Packetizer
+
+	mu      sync.Mutex
+	packets []*rtp.Packet
+}
+
+func newMockRTPTrack(codec *webrtc.RTPCodec, id string) *mockRTPTrack {
+	return &mockRTPTrack{
+		mockTrack:  newMockTrack(codec, id),
+		packetizer: rtp.NewPacketizer(1200, codec.PayloadType, 0, mockPayloader{}, rtp.NewRandomSequencer(), codec.ClockRate),
+	}
+}
+
+func (t *mockRTPTrack) WriteSample(s media.Sample) error {
+	return errors.New("WriteSample must not be called")
+}
+
+func (t *mockRTPTrack) Packetizer() rtp.Packetizer {
+	return t.packetizer
+}
+
+func (t *mockRTPTrack) WriteRTP(p *rtp.Packet) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.packets = append(t.packets, p)
+	return nil
+}
+
+// mockPayloader puts the whole sample into a packet.
+type mockPayloader struct{}
+
+func (mockPayloader) Payload(mtu int, payload []byte) [][]byte {
+	return [][]byte{payload}
+}
+
 type mockParams struct {
 	codec.BaseParams
 	name string
@@ -276,3 +381,70 @@ func TestSelectBestDriverConstraintsResultIsSetProperly(t *testing.T) {
 		t.Fatalf("failed to return best constraints\nexpected:\n%v\n\ngot:\n%v", expectedProp, bestConstraints.selectedMedia)
 	}
 }
+
+func TestFitBestPropertyPassthrough(t *testing.T) {
+	props := []prop.Media{
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}},
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatMJPEG}},
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatH264}},
+		{Video: prop.Video{Width: 1280, Height: 720, FrameFormat: frame.FormatI420}},
+		{Video: prop.Video{Width: 1152, Height: 648, FrameFormat: frame.FormatH264}},
+	}
+	passthroughH264 := &mockPassthroughParams{mockFrameParams: mockFrameParams{mockParams: mockParams{name: "MockVideo"}}}
+	noPassthrough := &mockFrameParams{mockParams: mockParams{name: "MockVideo"}}
+
+	testCases := map[string]struct {
+		builders       []codec.VideoEncoderBuilder
+		transform      video.TransformFunc
+		layers         []SimulcastLayer
+		width          int
+		expectedFormat frame.Format
+	}{
+		"Passthrough": {
+			builders:       []codec.VideoEncoderBuilder{noPassthrough, passthroughH264},
+			expectedFormat: frame.FormatH264,
+		},
+		"BetterFit": {
+			builders: []codec.VideoEncoderBuilder{passthroughH264},
+			// The passthrough only breaks the ties of the fitness distances.
+			width:          1280,
+			expectedFormat: frame.FormatI420,
+		},
+		"NoPassthroughBuilder": {
+			builders: []codec.VideoEncoderBuilder{noPassthrough},
+			// H.264 can't be decoded, so the first one which can be decoded is selected.
+			expectedFormat: frame.FormatI420,
+		},
+		"Transform": {
+			builders:       []codec.VideoEncoderBuilder{passthroughH264},
+			transform:      func(r video.Reader) video.Reader { return r },
+			expectedFormat: frame.FormatI420,
+		},
+		"ScaledLayer": {
+			builders:       []codec.VideoEncoderBuilder{passthroughH264},
+			layers:         []SimulcastLayer{{RID: "h"}, {RID: "q", ScaleResolutionDownBy: 4}},
+			expectedFormat: frame.FormatI420,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			constraints := MediaTrackConstraints{
+				VideoEncoderBuilders: testCase.builders,
+				VideoTransform:       testCase.transform,
+				SimulcastLayers:      testCase.layers,
+			}
+			if testCase.width > 0 {
+				constraints.Width = prop.Int(testCase.width)
+			}
+			best, _, ok := fitBestProperty(props, constraints)
+			if !ok {
+				t.Fatal("Expected a property to be selected")
+			}
+			if best.FrameFormat != testCase.expectedFormat {
+				t.Errorf("Expected %s, got %s", testCase.expectedFormat, best.FrameFormat)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package mediadevices
 import (
 	"testing"
 
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/prop"
 	"github.com/pion/webrtc/v2"
 )
 
@@ -28,6 +30,57 @@ func (track *mockMediaStreamTrack) Kind() MediaDeviceType {
 func (track *mockMediaStreamTrack) OnEnded(handler func(error)) {
 }
 
+func (track *mockMediaStreamTrack) MediaClock() *MediaClock {
+	return nil
+}
+
+func (track *mockMediaStreamTrack) RTPClockMapping() (RTPClockMapping, error) {
+	return RTPClockMapping{}, errNoRTPSample
+}
+
+func (track *mockMediaStreamTrack) SetEnabled(enabled bool) {
+}
+
+func (track *mockMediaStreamTrack) Enabled() bool {
+	return true
+}
+
+func (track *mockMediaStreamTrack) ReplaceSource(d driver.Driver) error {
+	return nil
+}
+
+func (track *mockMediaStreamTrack) Clone() (Tracker, error) {
+	return &mockMediaStreamTrack{kind: track.kind}, nil
+}
+
+func (track *mockMediaStreamTrack) RID() string {
+	return ""
+}
+
+func (track *mockMediaStreamTrack) SetBitRate(int) error {
+	return nil
+}
+
+func (track *mockMediaStreamTrack) ForceKeyFrame() error {
+	return nil
+}
+
+func (track *mockMediaStreamTrack) HandleRTCP(RTCPReader, ...RTCPHandlerOption) error {
+	return nil
+}
+
+func (track *mockMediaStreamTrack) GetSettings() prop.Media {
+	return prop.Media{}
+}
+
+func (track *mockMediaStreamTrack) GetCapabilities() MediaTrackCapabilities {
+	return MediaTrackCapabilities{}
+}
+
+func (track *mockMediaStreamTrack) ApplyConstraints(constraints MediaTrackConstraints) error {
+	return nil
+}
+
 func TestMediaStreamFilters(t *testing.T) {
 	audioTracks := []Tracker{
 		&mockMediaStreamTrack{AudioInput},
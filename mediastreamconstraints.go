@@ -1,7 +1,10 @@
 package mediadevices
 
 import (
+	"time"
+
 	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/io/audio"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
@@ -30,12 +33,139 @@ type MediaTrackConstraints struct {
 	AudioEncoderBuilders []codec.AudioEncoderBuilder
 	// VideoTransform will be used to transform the video that's coming from the driver.
 	// So, basically it'll look like following: driver -> VideoTransform -> codec
+	// The frames keep their capture timestamps if the transformed reader is a video.TimestampReader,
+	// e.g. by video.WithTimestamp. Otherwise, they are timestamped when the codec reads them.
 	VideoTransform video.TransformFunc
 	// AudioTransform will be used to transform the audio that's coming from the driver.
 	// So, basically it'll look like following: driver -> AudioTransform -> code
 	AudioTransform audio.TransformFunc
+	// SimulcastLayers are the encodings of the video. If it's not empty, a video track is created
+	// for each layer. The tracks share the capture of the device and the track ID, and each of them
+	// has its own encoder and localTrack. The layers are ignored for audio.
+	SimulcastLayers []SimulcastLayer
 
 	selectedMedia prop.Media
 }
 
+// SimulcastLayer represents an encoding of a simulcast video.
+// Reference: https://w3c.github.io/webrtc-pc/#dom-rtcrtpencodingparameters
+type SimulcastLayer struct {
+	// RID is the RTP stream ID of the layer. It must be unique among the layers.
+	RID string
+	// ScaleResolutionDownBy is the factor which the resolution of the captured video is divided by.
+	// The resolution is kept if it's less than or equal to 1.
+	ScaleResolutionDownBy float64
+	// MaxBitrate is the bitrate in bps which the encoder of the layer is set to.
+	// The bitrate of the encoder builder is kept if it's 0.
+	MaxBitrate int
+	// MaxFrameRate is the frame rate which the video of the layer is throttled to.
+	// The frame rate of the capture is kept if it's 0.
+	MaxFrameRate float32
+}
+
 type MediaOption func(*MediaTrackConstraints)
+
+// passthrough returns true if the frames compressed in f can be passed to one of the video encoder builders
+// as they are. The frames have to be decoded if the video is transformed.
+func (c *MediaTrackConstraints) passthrough(f frame.Format) bool {
+	if c.VideoTransform != nil {
+		return false
+	}
+	for _, layer := range c.SimulcastLayers {
+		if layer.ScaleResolutionDownBy > 1 || layer.MaxFrameRate > 0 {
+			return false
+		}
+	}
+	for _, b := range c.VideoEncoderBuilders {
+		if pb, ok := b.(codec.PassthroughVideoEncoderBuilder); ok && pb.Passthrough(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompressed returns true if the frames in f are frame.Compressed.
+func isCompressed(f frame.Format) bool {
+	_, err := frame.NewCompressedDecoder(f)
+	return err == nil
+}
+
+// needsPassthrough returns true if the frames in f are compressed and can't be decoded, e.g. H.264,
+// so that they can only be passed to the encoders as they are.
+func needsPassthrough(f frame.Format) bool {
+	if !isCompressed(f) {
+		return false
+	}
+	_, err := frame.NewDecoder(f)
+	return err != nil
+}
+
+// MediaTrackCapabilities represents https://w3c.github.io/mediacapture-main/#dom-mediatrackcapabilities
+// Each range holds the minimum and the maximum values among the properties of the device.
+// Zero values mean that the device doesn't tell the capability.
+type MediaTrackCapabilities struct {
+	DeviceID      string
+	Width, Height prop.IntRanged
+	FrameRate     prop.FloatRanged
+	FrameFormat   prop.FrameFormatOneOf
+	ChannelCount  prop.IntRanged
+	Latency       prop.DurationRanged
+	SampleRate    prop.IntRanged
+	SampleSize    prop.IntRanged
+}
+
+// newMediaTrackCapabilities builds the capabilities from the properties of a device.
+func newMediaTrackCapabilities(deviceID string, props []prop.Media) MediaTrackCapabilities {
+	c := MediaTrackCapabilities{DeviceID: deviceID}
+	formats := make(map[frame.Format]bool)
+	for _, p := range props {
+		extendIntRange(&c.Width, p.Width)
+		extendIntRange(&c.Height, p.Height)
+		extendFloatRange(&c.FrameRate, p.FrameRate)
+		if p.FrameFormat != "" && !formats[p.FrameFormat] {
+			formats[p.FrameFormat] = true
+			c.FrameFormat = append(c.FrameFormat, p.FrameFormat)
+		}
+		extendIntRange(&c.ChannelCount, p.ChannelCount)
+		extendDurationRange(&c.Latency, p.Latency)
+		extendIntRange(&c.SampleRate, p.SampleRate)
+		extendIntRange(&c.SampleSize, p.SampleSize)
+	}
+	return c
+}
+
+func extendIntRange(r *prop.IntRanged, v int) {
+	if v == 0 {
+		return
+	}
+	if r.Min == 0 || v < r.Min {
+		r.Min = v
+	}
+	if v > r.Max {
+		r.Max = v
+	}
+}
+
+func extendFloatRange(r *prop.FloatRanged, v float32) {
+	if v == 0 {
+		return
+	}
+	if r.Min == 0 || v < r.Min {
+		r.Min = v
+	}
+	if v > r.Max {
+		r.Max = v
+	}
+}
+
+func extendDurationRange(r *prop.DurationRanged, v time.Duration) {
+	if v == 0 {
+		return
+	}
+	if r.Min == 0 || v < r.Min {
+		r.Min = v
+	}
+	if v > r.Max {
+		r.Max = v
+	}
+}
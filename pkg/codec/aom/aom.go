@@ -0,0 +1,381 @@
+// Package aom implements AV1 encoder.
+// This package requires libaom 2.0 or later headers and libraries to be built.
+package aom
+
+// #cgo pkg-config: aom
+// #include <stdlib.h>
+// #include <aom/aom_encoder.h>
+// #include <aom/aom_image.h>
+// #include <aom/aomcx.h>
+//
+// // C union helpers
+// void *pktBuf(const aom_codec_cx_pkt_t *pkt) {
+//   return pkt->data.frame.buf;
+// }
+// int pktSz(const aom_codec_cx_pkt_t *pkt) {
+//   return pkt->data.frame.sz;
+// }
+// int pktIsKey(const aom_codec_cx_pkt_t *pkt) {
+//   return (pkt->data.frame.flags & AOM_FRAME_IS_KEY) != 0;
+// }
+//
+// // Alloc helpers
+// aom_codec_ctx_t *newCtx() {
+//   return malloc(sizeof(aom_codec_ctx_t));
+// }
+// aom_image_t *newImage() {
+//   return malloc(sizeof(aom_image_t));
+// }
+//
+// // aom_codec_control is a type checked macro, so it can't be called from Go
+// aom_codec_err_t setCPUUsed(aom_codec_ctx_t *codec, int v) {
+//   return aom_codec_control(codec, AOME_SET_CPUUSED, v);
+// }
+// aom_codec_err_t setTileColumns(aom_codec_ctx_t *codec, int v) {
+//   return aom_codec_control(codec, AV1E_SET_TILE_COLUMNS, v);
+// }
+// aom_codec_err_t setTileRows(aom_codec_ctx_t *codec, int v) {
+//   return aom_codec_control(codec, AV1E_SET_TILE_ROWS, v);
+// }
+//
+// // Wrap encode function to keep Go memory safe
+// aom_codec_err_t encode_wrapper(
+//     aom_codec_ctx_t* codec, aom_image_t* raw,
+//     long t, unsigned long dt, long flags,
+//     unsigned char *y_ptr, unsigned char *cb_ptr, unsigned char *cr_ptr) {
+//   raw->planes[0] = y_ptr;
+//   raw->planes[1] = cb_ptr;
+//   raw->planes[2] = cr_ptr;
+//   aom_codec_err_t ret = aom_codec_encode(codec, raw, t, dt, flags);
+//   raw->planes[0] = raw->planes[1] = raw->planes[2] = 0;
+//   return ret;
+// }
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+type encoder struct {
+	codec      *C.aom_codec_ctx_t
+	raw        *C.aom_image_t
+	cfg        *C.aom_codec_enc_cfg_t
+	r          video.Reader
+	frameIndex int
+	buff       []byte
+	tStart     time.Time
+	tLastFrame time.Time
+	pts        int64
+	// tLastOutput is the capture time of the last frame.
+	tLastOutput time.Time
+
+	requireKeyFrame bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewAV1Params returns default AV1 codec specific parameters for realtime usage.
+func NewAV1Params() (AV1Params, error) {
+	cfg := &C.aom_codec_enc_cfg_t{}
+	if ec := C.aom_codec_enc_config_default(C.aom_codec_av1_cx(), cfg, C.AOM_USAGE_REALTIME); ec != C.AOM_CODEC_OK {
+		return AV1Params{}, fmt.Errorf("aom_codec_enc_config_default failed (%d)", ec)
+	}
+	return AV1Params{
+		Usage:                        UsageRealtime,
+		CPUUsed:                      8,
+		RateControlEndUsage:          RateControlMode(cfg.rc_end_usage),
+		RateControlUndershootPercent: uint(cfg.rc_undershoot_pct),
+		RateControlOvershootPercent:  uint(cfg.rc_overshoot_pct),
+		RateControlMinQuantizer:      uint(cfg.rc_min_quantizer),
+		RateControlMaxQuantizer:      uint(cfg.rc_max_quantizer),
+	}, nil
+}
+
+// RTPCodec represents the codec metadata
+func (p *AV1Params) RTPCodec() *codec.RTPCodec {
+	return newRTPCodec(p.PayloadType)
+}
+
+// BuildVideoEncoder builds AV1 encoder with given params
+func (p *AV1Params) BuildVideoEncoder(r video.Reader, property prop.Media) (codec.ReadCloser, error) {
+	return newEncoder(r, property, *p)
+}
+
+// toKbps converts the bitrate from bit/s to kbit/s, which libaom uses. It's rounded to the nearest value,
+// but kept at least 1 kbit/s.
+func toKbps(b int) int {
+	kbps := (b + 500) / 1000
+	if kbps < 1 {
+		kbps = 1
+	}
+	return kbps
+}
+
+func newEncoder(r video.Reader, p prop.Media, params AV1Params) (codec.ReadCloser, error) {
+	if params.BitRate == 0 {
+		params.BitRate = 100000
+	}
+
+	if params.KeyFrameInterval == 0 {
+		params.KeyFrameInterval = 60
+	}
+
+	cfg := &C.aom_codec_enc_cfg_t{}
+	if ec := C.aom_codec_enc_config_default(C.aom_codec_av1_cx(), cfg, C.uint(params.Usage)); ec != C.AOM_CODEC_OK {
+		return nil, fmt.Errorf("aom_codec_enc_config_default failed (%d)", ec)
+	}
+
+	cfg.rc_end_usage = uint32(params.RateControlEndUsage)
+	cfg.rc_undershoot_pct = C.uint(params.RateControlUndershootPercent)
+	cfg.rc_overshoot_pct = C.uint(params.RateControlOvershootPercent)
+	cfg.rc_min_quantizer = C.uint(params.RateControlMinQuantizer)
+	cfg.rc_max_quantizer = C.uint(params.RateControlMaxQuantizer)
+	cfg.rc_target_bitrate = C.uint(toKbps(params.BitRate))
+	cfg.g_threads = C.uint(params.Threads)
+	if params.ErrorResilient {
+		cfg.g_error_resilient = C.AOM_ERROR_RESILIENT_DEFAULT
+	}
+
+	cfg.g_w = C.uint(p.Width)
+	cfg.g_h = C.uint(p.Height)
+	cfg.g_timebase.num = 1
+	cfg.g_timebase.den = 1000
+	cfg.kf_max_dist = C.uint(params.KeyFrameInterval)
+
+	// Every frame has to be output as soon as it's read.
+	cfg.g_lag_in_frames = 0
+	cfg.g_pass = C.AOM_RC_ONE_PASS
+
+	raw := &C.aom_image_t{}
+	if C.aom_img_alloc(raw, C.AOM_IMG_FMT_I420, cfg.g_w, cfg.g_h, 1) == nil {
+		return nil, errors.New("aom_img_alloc failed")
+	}
+	rawNoBuffer := C.newImage()
+	*rawNoBuffer = *raw // Copy only parameters
+	C.aom_img_free(raw) // Pointers will be overwritten by the raw buffer
+
+	codec := C.newCtx()
+	if ec := C.aom_codec_enc_init_ver(
+		codec, C.aom_codec_av1_cx(), cfg, 0, C.AOM_ENCODER_ABI_VERSION,
+	); ec != C.AOM_CODEC_OK {
+		C.free(unsafe.Pointer(codec))
+		C.free(unsafe.Pointer(rawNoBuffer))
+		return nil, fmt.Errorf("aom_codec_enc_init failed (%d)", ec)
+	}
+
+	if err := setControls(codec, params); err != nil {
+		C.aom_codec_destroy(codec)
+		C.free(unsafe.Pointer(codec))
+		C.free(unsafe.Pointer(rawNoBuffer))
+		return nil, err
+	}
+
+	t0 := time.Now()
+	return &encoder{
+		r:          video.ToI420(r),
+		codec:      codec,
+		raw:        rawNoBuffer,
+		cfg:        cfg,
+		tStart:     t0,
+		tLastFrame: t0,
+		pts:        -1,
+	}, nil
+}
+
+func setControls(codec *C.aom_codec_ctx_t, params AV1Params) error {
+	if ec := C.setCPUUsed(codec, C.int(params.CPUUsed)); ec != C.AOM_CODEC_OK {
+		return fmt.Errorf("failed to set cpu-used to %d (%d)", params.CPUUsed, ec)
+	}
+	if ec := C.setTileColumns(codec, C.int(params.TileColumns)); ec != C.AOM_CODEC_OK {
+		return fmt.Errorf("failed to set tile columns to %d (%d)", params.TileColumns, ec)
+	}
+	if ec := C.setTileRows(codec, C.int(params.TileRows)); ec != C.AOM_CODEC_OK {
+		return fmt.Errorf("failed to set tile rows to %d (%d)", params.TileRows, ec)
+	}
+	return nil
+}
+
+func (e *encoder) Read(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return 0, io.EOF
+	}
+
+	if e.buff != nil {
+		n, err := mio.Copy(p, e.buff)
+		if err == nil {
+			e.buff = nil
+		}
+		return n, err
+	}
+
+	frame, err := e.readFrame()
+	if err != nil {
+		return 0, err
+	}
+	n, err := mio.Copy(p, frame.Data)
+	if err != nil {
+		e.buff = frame.Data
+	}
+	return n, err
+}
+
+// ReadFrame encodes the next frame and returns it with its metadata.
+// The frame is a temporal unit in the low overhead bitstream format, where every OBU has the size field.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return codec.EncodedFrame{}, io.EOF
+	}
+
+	return e.readFrame()
+}
+
+func (e *encoder) readFrame() (codec.EncodedFrame, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+	yuvImg := img.(*image.YCbCr)
+	bounds := yuvImg.Bounds()
+	height := C.int(bounds.Dy())
+	width := C.int(bounds.Dx())
+
+	e.raw.stride[0] = C.int(yuvImg.YStride)
+	e.raw.stride[1] = C.int(yuvImg.CStride)
+	e.raw.stride[2] = C.int(yuvImg.CStride)
+
+	// libaom is given the times when the pictures are read, and the output is timestamped by the capture
+	// times of the pictures.
+	now := time.Now()
+	t := video.Timestamp(e.r)
+
+	if e.cfg.g_w != C.uint(width) || e.cfg.g_h != C.uint(height) {
+		e.cfg.g_w, e.cfg.g_h = C.uint(width), C.uint(height)
+		if ec := C.aom_codec_enc_config_set(e.codec, e.cfg); ec != C.AOM_CODEC_OK {
+			return codec.EncodedFrame{}, fmt.Errorf("aom_codec_enc_config_set failed (%d)", ec)
+		}
+		e.raw.w, e.raw.h = C.uint(width), C.uint(height)
+		e.raw.r_w, e.raw.r_h = C.uint(width), C.uint(height)
+		e.raw.d_w, e.raw.d_h = C.uint(width), C.uint(height)
+	}
+
+	// libaom rejects the frames without increasing timestamps, which happens if frames are read
+	// within a millisecond.
+	pts := int64(now.Sub(e.tStart) / time.Millisecond)
+	if pts <= e.pts {
+		pts = e.pts + 1
+	}
+	e.pts = pts
+
+	var flags int
+	if e.requireKeyFrame {
+		flags |= C.AOM_EFLAG_FORCE_KF
+	}
+	if ec := C.encode_wrapper(
+		e.codec, e.raw,
+		C.long(pts), C.ulong(now.Sub(e.tLastFrame)/time.Millisecond),
+		C.long(flags),
+		(*C.uchar)(&yuvImg.Y[0]), (*C.uchar)(&yuvImg.Cb[0]), (*C.uchar)(&yuvImg.Cr[0]),
+	); ec != C.AOM_CODEC_OK {
+		return codec.EncodedFrame{}, fmt.Errorf("aom_codec_encode failed (%d)", ec)
+	}
+
+	var duration time.Duration
+	if e.frameIndex > 0 {
+		duration = t.Sub(e.tLastOutput)
+	}
+	e.frameIndex++
+	e.tLastFrame = now
+	e.tLastOutput = t
+	e.requireKeyFrame = false
+
+	var data []byte
+	var keyFrame bool
+	var iter C.aom_codec_iter_t
+	for {
+		pkt := C.aom_codec_get_cx_data(e.codec, &iter)
+		if pkt == nil {
+			break
+		}
+		if pkt.kind == C.AOM_CODEC_CX_FRAME_PKT {
+			data = append(data, C.GoBytes(C.pktBuf(pkt), C.pktSz(pkt))...)
+			keyFrame = keyFrame || C.pktIsKey(pkt) != 0
+		}
+	}
+
+	return codec.EncodedFrame{
+		Data:      data,
+		KeyFrame:  keyFrame,
+		Timestamp: t,
+		Duration:  duration,
+	}, nil
+}
+
+// SetBitRate updates the target bitrate of the running encoder. The new value
+// takes effect from the next encoded frame.
+func (e *encoder) SetBitRate(b int) error {
+	if b <= 0 {
+		return fmt.Errorf("invalid bitrate: %d", b)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	e.cfg.rc_target_bitrate = C.uint(toKbps(b))
+	if ec := C.aom_codec_enc_config_set(e.codec, e.cfg); ec != C.AOM_CODEC_OK {
+		return fmt.Errorf("aom_codec_enc_config_set failed (%d)", ec)
+	}
+	return nil
+}
+
+// ForceKeyFrame forces the next encoded frame to be a key frame.
+func (e *encoder) ForceKeyFrame() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	e.requireKeyFrame = true
+	return nil
+}
+
+func (e *encoder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	C.free(unsafe.Pointer(e.raw))
+	defer C.free(unsafe.Pointer(e.codec))
+
+	if C.aom_codec_destroy(e.codec) != C.AOM_CODEC_OK {
+		return errors.New("aom_codec_destroy failed")
+	}
+	return nil
+}
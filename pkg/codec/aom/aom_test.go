@@ -0,0 +1,128 @@
+package aom
+
+import (
+	"testing"
+
+	codectest "github.com/pion/mediadevices/internal/codec"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+func newTestEncoder(t *testing.T, params AV1Params) codec.ReadCloser {
+	e, err := params.BuildVideoEncoder(
+		codectest.NewVideoReader(320, 240, 30),
+		prop.Media{
+			Video: prop.Video{
+				Width:     320,
+				Height:    240,
+				FrameRate: 30,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// newEncoderTestCase returns AV1 to be checked by the tests shared by the codecs.
+func newEncoderTestCase(t *testing.T) codectest.VideoEncoderTestCase {
+	p, err := NewAV1Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RateControlEndUsage = RateControlCBR
+	return codectest.VideoEncoderTestCase{Builder: &p, Params: &p.BaseParams, IsKeyFrame: codectest.IsKeyFrameAV1}
+}
+
+func TestSetBitRate(t *testing.T) {
+	before, after, err := newEncoderTestCase(t).VerifySetBitRate(0.5)
+	t.Logf("bitrate before: %.0f, after: %.0f", before, after)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetBitRateAfterClose(t *testing.T) {
+	p, err := NewAV1Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEncoder(t, p)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetBitRate(100000); err == nil {
+		t.Error("Expected error after close, but got nil")
+	}
+}
+
+func TestForceKeyFrame(t *testing.T) {
+	if err := newEncoderTestCase(t).VerifyForceKeyFrame(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParams(t *testing.T) {
+	testCases := map[string]func(p *AV1Params){
+		"Tiles": func(p *AV1Params) {
+			p.TileColumns = 1
+			p.TileRows = 1
+			p.Threads = 2
+		},
+		"ErrorResilient": func(p *AV1Params) {
+			p.ErrorResilient = true
+		},
+		"GoodQuality": func(p *AV1Params) {
+			p.Usage = UsageGoodQuality
+			p.CPUUsed = 6
+		},
+	}
+
+	for name, modify := range testCases {
+		modify := modify
+		t.Run(name, func(t *testing.T) {
+			p, err := NewAV1Params()
+			if err != nil {
+				t.Fatal(err)
+			}
+			modify(&p)
+
+			e := newTestEncoder(t, p)
+			defer e.Close()
+
+			// Every frame has to be output without lag.
+			for i := 0; i < 3; i++ {
+				f, err := e.(codec.FrameReader).ReadFrame()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(f.Data) == 0 {
+					t.Fatalf("Expected frame[%d] to be output", i)
+				}
+				if f.KeyFrame != (i == 0) {
+					t.Errorf("Expected KeyFrame of frame[%d] to be %v, got %v", i, i == 0, f.KeyFrame)
+				}
+				if f.KeyFrame != codectest.IsKeyFrameAV1(f.Data) {
+					t.Errorf("Expected KeyFrame of frame[%d] to match the bitstream", i)
+				}
+			}
+		})
+	}
+}
+
+func TestToKbps(t *testing.T) {
+	testCases := map[int]int{
+		1:       1,
+		499:     1,
+		1499:    1,
+		1500:    2,
+		100000:  100,
+		1000000: 1000,
+	}
+	for b, expected := range testCases {
+		if kbps := toKbps(b); kbps != expected {
+			t.Errorf("Expected %d kbit/s for %d bit/s, got %d", expected, b, kbps)
+		}
+	}
+}
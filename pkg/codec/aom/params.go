@@ -0,0 +1,52 @@
+package aom
+
+import (
+	"github.com/pion/mediadevices/pkg/codec"
+)
+
+// AV1Params stores libaom specific encoding parameters.
+type AV1Params struct {
+	codec.BaseParams
+	// Usage is the encoder usage, which selects the set of the coding tools and the range of CPUUsed.
+	Usage Usage
+	// CPUUsed is the speed of the encoder. The larger value is faster with lower quality.
+	// The range is 0 to 6 for UsageGoodQuality, and 0 to 10 for UsageRealtime, depending on the
+	// version of libaom.
+	CPUUsed                      int
+	RateControlEndUsage          RateControlMode
+	RateControlUndershootPercent uint
+	RateControlOvershootPercent  uint
+	RateControlMinQuantizer      uint
+	RateControlMaxQuantizer      uint
+	// TileColumns and TileRows are the log2 of the number of the tile columns and rows.
+	// The tiles can be encoded and decoded in parallel, but limit the prediction across them.
+	TileColumns int
+	TileRows    int
+	// Threads is the maximum number of the threads used by the encoder. 0 means 1.
+	Threads uint
+	// ErrorResilient disables the features which make the frames depend on the state of the
+	// preceding frames beyond the references, so that the decoder can recover from losses sooner.
+	ErrorResilient bool
+	// PayloadType is the RTP payload type. If it's 0, defaultPayloadType is used.
+	PayloadType uint8
+}
+
+// Usage represents the encoder usage.
+type Usage int
+
+// Usage values.
+const (
+	UsageGoodQuality Usage = iota
+	UsageRealtime
+)
+
+// RateControlMode represents rate control mode.
+type RateControlMode int
+
+// RateControlMode values.
+const (
+	RateControlVBR RateControlMode = iota
+	RateControlCBR
+	RateControlCQ
+	RateControlQ
+)
@@ -0,0 +1,165 @@
+package aom
+
+import (
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+// Reference: https://aomediacodec.github.io/av1-rtp-spec/
+
+const (
+	// defaultPayloadType is the dynamic RTP payload type used if AV1Params.PayloadType is 0.
+	defaultPayloadType = 100
+	// clockRate is the RTP clock rate of AV1.
+	clockRate = 90000
+)
+
+// OBU types
+const (
+	obuSequenceHeader     = 1
+	obuTemporalDelimiter  = 2
+	obuTileList           = 8
+	obuPadding            = 15
+	obuHeaderExtension    = 0x04
+	obuHeaderHasSizeField = 0x02
+)
+
+// Aggregation header flags
+const (
+	// aggregationContinued (Z) is set if the first OBU element continues the last one of the previous packet.
+	aggregationContinued = 0x80
+	// aggregationWillContinue (Y) is set if the last OBU element continues in the next packet.
+	aggregationWillContinue = 0x40
+	// aggregationNewSequence (N) is set in the first packet of a coded video sequence.
+	aggregationNewSequence = 0x08
+)
+
+func newRTPCodec(payloadType uint8) *codec.RTPCodec {
+	if payloadType == 0 {
+		payloadType = defaultPayloadType
+	}
+	return &codec.RTPCodec{
+		RTPCodec: webrtc.NewRTPCodec(
+			webrtc.RTPCodecTypeVideo,
+			"AV1",
+			clockRate,
+			0,
+			"",
+			payloadType,
+			&payloader{},
+		),
+	}
+}
+
+// payloader splits a temporal unit into RTP payloads. Every OBU element is preceded by its length (W=0),
+// and the OBUs are sent without the size fields. The temporal units which can't be parsed are dropped.
+type payloader struct{}
+
+func (p *payloader) Payload(mtu int, payload []byte) [][]byte {
+	obus, ok := splitOBUs(payload)
+	// At least an aggregation header and an element of one byte with its length have to fit.
+	if !ok || mtu < 3 {
+		return nil
+	}
+
+	var payloads [][]byte
+	out := []byte{0}
+	for _, obu := range obus {
+		if obuType(obu) == obuSequenceHeader {
+			// libaom outputs the sequence header only with the key frames.
+			out[0] |= aggregationNewSequence
+			break
+		}
+	}
+	for _, obu := range obus {
+		for len(obu) > 0 {
+			n := len(obu)
+			if avail := mtu - len(out); lebSize(n)+n > avail {
+				n = avail - lebSize(avail)
+			}
+			if n <= 0 {
+				payloads = append(payloads, out)
+				out = []byte{0}
+				continue
+			}
+			out = appendLEB128(out, n)
+			out = append(out, obu[:n]...)
+			obu = obu[n:]
+			if len(obu) > 0 {
+				out[0] |= aggregationWillContinue
+				payloads = append(payloads, out)
+				out = []byte{aggregationContinued}
+			}
+		}
+	}
+	if len(out) > 1 {
+		payloads = append(payloads, out)
+	}
+	return payloads
+}
+
+// splitOBUs splits a temporal unit in the low overhead bitstream format into the OBUs without the size
+// fields. The OBUs which must not be sent by RTP are removed.
+func splitOBUs(tu []byte) ([][]byte, bool) {
+	var obus [][]byte
+	for len(tu) > 0 {
+		header := tu[0]
+		headerSize := 1
+		if header&obuHeaderExtension != 0 {
+			headerSize++
+		}
+		if header&obuHeaderHasSizeField == 0 || len(tu) < headerSize {
+			// Only the last OBU of a temporal unit can omit the size, which isn't output by libaom.
+			return nil, false
+		}
+		size, n, ok := readLEB128(tu[headerSize:])
+		if !ok || uint64(len(tu)-headerSize-n) < size {
+			return nil, false
+		}
+		end := headerSize + n + int(size)
+
+		switch obuType(tu) {
+		case obuTemporalDelimiter, obuTileList, obuPadding:
+		default:
+			obu := make([]byte, 0, headerSize+int(size))
+			obu = append(obu, header&^obuHeaderHasSizeField)
+			obu = append(obu, tu[1:headerSize]...)
+			obu = append(obu, tu[headerSize+n:end]...)
+			obus = append(obus, obu)
+		}
+		tu = tu[end:]
+	}
+	return obus, true
+}
+
+func obuType(obu []byte) int {
+	return int(obu[0] >> 3 & 0x0f)
+}
+
+func readLEB128(b []byte) (uint64, int, bool) {
+	var v uint64
+	for i := 0; i < 8 && i < len(b); i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i]&0x80 == 0 {
+			return v, i + 1, true
+		}
+	}
+	return 0, 0, false
+}
+
+func appendLEB128(b []byte, v int) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v&0x7f)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func lebSize(v int) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
@@ -0,0 +1,178 @@
+package aom
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// obu returns an OBU of the type with the size field and the payload of the size.
+func obu(typ byte, size int) []byte {
+	b := appendLEB128([]byte{typ<<3 | obuHeaderHasSizeField}, size)
+	for i := 0; i < size; i++ {
+		b = append(b, byte(i))
+	}
+	return b
+}
+
+// depayload reconstructs the OBUs without the size fields from the payloads, and checks the aggregation headers.
+func depayload(t *testing.T, payloads [][]byte) ([][]byte, bool) {
+	var obus [][]byte
+	var fragment []byte
+	var newSequence bool
+	for i, payload := range payloads {
+		header := payload[0]
+		if continued := header&aggregationContinued != 0; continued != (fragment != nil) {
+			t.Fatalf("Expected Z of payload[%d] to be %v, got %v", i, fragment != nil, continued)
+		}
+		if header&aggregationNewSequence != 0 {
+			if i != 0 {
+				t.Errorf("Expected N to be set only in the first payload, got in payload[%d]", i)
+			}
+			newSequence = true
+		}
+		if w := header >> 4 & 0x03; w != 0 {
+			t.Fatalf("Expected W to be 0, got %d", w)
+		}
+
+		b := payload[1:]
+		for len(b) > 0 {
+			size, n, ok := readLEB128(b)
+			if !ok || uint64(len(b)-n) < size {
+				t.Fatalf("Broken OBU element in payload[%d]", i)
+			}
+			fragment = append(fragment, b[n:n+int(size)]...)
+			b = b[n+int(size):]
+			if len(b) == 0 && header&aggregationWillContinue != 0 {
+				break
+			}
+			obus = append(obus, fragment)
+			fragment = nil
+		}
+	}
+	if fragment != nil {
+		t.Fatal("Expected the last OBU to be completed")
+	}
+	return obus, newSequence
+}
+
+func TestPayloader(t *testing.T) {
+	testCases := map[string]struct {
+		tu          [][]byte
+		expected    []int
+		newSequence bool
+	}{
+		"KeyFrame": {
+			tu: [][]byte{
+				obu(obuTemporalDelimiter, 0),
+				obu(obuSequenceHeader, 10),
+				obu(6, 3000),
+			},
+			expected:    []int{1, 2},
+			newSequence: true,
+		},
+		"InterFrame": {
+			tu: [][]byte{
+				obu(obuTemporalDelimiter, 0),
+				obu(6, 100),
+			},
+			expected: []int{1},
+		},
+		"Padding": {
+			tu: [][]byte{
+				obu(obuTemporalDelimiter, 0),
+				obu(3, 20),
+				obu(obuPadding, 5),
+				obu(4, 2500),
+			},
+			expected: []int{1, 3},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			const mtu = 1000
+
+			tu := bytes.Join(testCase.tu, nil)
+			payloads := (&payloader{}).Payload(mtu, tu)
+			for i, payload := range payloads {
+				if len(payload) > mtu {
+					t.Errorf("Expected payload[%d] to fit in %d bytes, got %d bytes", i, mtu, len(payload))
+				}
+			}
+
+			var expected [][]byte
+			for _, i := range testCase.expected {
+				o := testCase.tu[i]
+				_, n, _ := readLEB128(o[1:])
+				expected = append(expected, append([]byte{o[0] &^ obuHeaderHasSizeField}, o[1+n:]...))
+			}
+			obus, newSequence := depayload(t, payloads)
+			if !reflect.DeepEqual(expected, obus) {
+				t.Error("Wrong depayload result")
+			}
+			if newSequence != testCase.newSequence {
+				t.Errorf("Expected N to be %v, got %v", testCase.newSequence, newSequence)
+			}
+		})
+	}
+}
+
+func TestPayloaderInvalid(t *testing.T) {
+	testCases := map[string]struct {
+		tu  []byte
+		mtu int
+	}{
+		"NoSizeField": {
+			tu:  []byte{0x30, 0x00, 0x01},
+			mtu: 1000,
+		},
+		"Truncated": {
+			tu:  []byte{0x32, 0x10, 0x00},
+			mtu: 1000,
+		},
+		"SmallMTU": {
+			tu:  obu(6, 10),
+			mtu: 2,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if payloads := (&payloader{}).Payload(testCase.mtu, testCase.tu); payloads != nil {
+				t.Errorf("Expected the temporal unit to be dropped, got %d payloads", len(payloads))
+			}
+		})
+	}
+}
+
+func TestRTPCodec(t *testing.T) {
+	testCases := map[string]struct {
+		payloadType uint8
+		expected    uint8
+	}{
+		"Default": {
+			payloadType: 0,
+			expected:    defaultPayloadType,
+		},
+		"Custom": {
+			payloadType: 120,
+			expected:    120,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			c := newRTPCodec(testCase.payloadType)
+			if c.PayloadType != testCase.expected {
+				t.Errorf("Expected payload type %d, got %d", testCase.expected, c.PayloadType)
+			}
+			if c.ClockRate != clockRate {
+				t.Errorf("Expected clock rate %d, got %d", clockRate, c.ClockRate)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package codec
 
 import (
 	"io"
+	"time"
 
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/io/audio"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
@@ -34,6 +36,21 @@ func NewRTPOpusCodec(clockrate uint32) *RTPCodec {
 	return &RTPCodec{webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, clockrate)}
 }
 
+// NewRTPPCMUCodec is a helper to create a PCMU (G.711 mu-law) codec
+func NewRTPPCMUCodec(clockrate uint32) *RTPCodec {
+	return &RTPCodec{webrtc.NewRTPPCMUCodec(webrtc.DefaultPayloadTypePCMU, clockrate)}
+}
+
+// NewRTPPCMACodec is a helper to create a PCMA (G.711 A-law) codec
+func NewRTPPCMACodec(clockrate uint32) *RTPCodec {
+	return &RTPCodec{webrtc.NewRTPPCMACodec(webrtc.DefaultPayloadTypePCMA, clockrate)}
+}
+
+// NewRTPG722Codec is a helper to create a G722 codec
+func NewRTPG722Codec(clockrate uint32) *RTPCodec {
+	return &RTPCodec{webrtc.NewRTPG722Codec(webrtc.DefaultPayloadTypeG722, clockrate)}
+}
+
 // AudioEncoderBuilder is the interface that wraps basic operations that are
 // necessary to build the audio encoder.
 //
@@ -58,6 +75,53 @@ type VideoEncoderBuilder interface {
 	BuildVideoEncoder(r video.Reader, p prop.Media) (ReadCloser, error)
 }
 
+// AudioDecoderBuilder is the interface that wraps basic operations that are
+// necessary to build the audio decoder.
+type AudioDecoderBuilder interface {
+	// BuildAudioDecoder builds audio decoder which decodes the frames read from r
+	BuildAudioDecoder(r FrameReader, p prop.Media) (AudioDecoder, error)
+}
+
+// AudioDecoder is an audio.Reader which decodes the encoded frames into samples. Since it's an
+// audio.Reader, the decoded audio can be processed by audio.TransformFunc in the same way as the
+// captured audio.
+//
+// A frame with empty Data represents a lost frame, e.g. a missing RTP packet. Its Duration is the
+// duration of the lost samples, and the decoder may conceal it. If Duration is zero, the duration
+// of the last frame is assumed.
+type AudioDecoder interface {
+	// Read decodes the next frame and returns the samples. The chunk is owned by the caller.
+	audio.Reader
+	// Close releases the decoder. It doesn't close the source of the encoded frames.
+	Close() error
+}
+
+// VideoDecoderBuilder is the interface that wraps basic operations that are
+// necessary to build the video decoder.
+type VideoDecoderBuilder interface {
+	// BuildVideoDecoder builds video decoder which decodes the frames read from r
+	BuildVideoDecoder(r FrameReader, p prop.Media) (VideoDecoder, error)
+}
+
+// VideoDecoder is a video.Reader which decodes the encoded frames into images. Since it's a video.Reader,
+// the decoded video can be processed by video.TransformFunc in the same way as the captured video.
+type VideoDecoder interface {
+	// Read decodes the encoded frames until a picture is output, and returns it.
+	// The image is owned by the caller.
+	video.Reader
+	// Close releases the decoder. It doesn't close the source of the encoded frames.
+	Close() error
+}
+
+// PassthroughVideoEncoderBuilder is a VideoEncoderBuilder whose encoders can send the frames compressed by
+// the driver as they are, e.g. *frame.JPEG. Such frames are passed to the encoders without being decoded
+// if no transform is applied to the video. The other encoders always get decoded frames.
+type PassthroughVideoEncoderBuilder interface {
+	VideoEncoderBuilder
+	// Passthrough returns true if the encoder accepts the frames compressed in f.
+	Passthrough(f frame.Format) bool
+}
+
 // ReadCloser is an io.ReadCloser with methods for rate limiting: SetBitRate and ForceKeyFrame
 type ReadCloser interface {
 	io.ReadCloser
@@ -68,6 +132,48 @@ type ReadCloser interface {
 	ForceKeyFrame() error
 }
 
+// EncodedFrame is an encoded frame with its metadata.
+type EncodedFrame struct {
+	// Data is the encoded bitstream of the frame. The caller owns it.
+	Data []byte
+	// KeyFrame is true if the frame can be decoded without any preceding frames.
+	KeyFrame bool
+	// Timestamp is the presentation timestamp of the source frame. Video encoders set the capture time
+	// of the source frame by video.Timestamp, also for the frames output later than they are read,
+	// e.g. by the lookahead.
+	Timestamp time.Time
+	// Duration is the presentation duration of the frame.
+	// For video, it is the interval from the previous frame, and zero for the first frame.
+	// For audio, it is the duration of the encoded samples.
+	Duration time.Duration
+	// TemporalLayerID is the temporal layer the frame belongs to. 0 if the stream is not layered.
+	TemporalLayerID int
+	// SpatialLayerID is the spatial layer the frame belongs to. 0 if the stream is not layered.
+	// If the frame holds several spatial layers, e.g. a VP9 superframe, it is the top one.
+	SpatialLayerID int
+}
+
+// Size returns the encoded size of the frame in bytes.
+func (f EncodedFrame) Size() int {
+	return len(f.Data)
+}
+
+// FrameReader is implemented by encoders which can return an encoded frame
+// along with its metadata. It is an extension of ReadCloser.
+type FrameReader interface {
+	// ReadFrame encodes the next frame and returns it along with its metadata.
+	ReadFrame() (EncodedFrame, error)
+}
+
+// FrameReaderFunc is a proxy type for FrameReader, e.g. to pass the frames received from the network
+// to a VideoDecoder.
+type FrameReaderFunc func() (EncodedFrame, error)
+
+// ReadFrame implements FrameReader.
+func (f FrameReaderFunc) ReadFrame() (EncodedFrame, error) {
+	return f()
+}
+
 // BaseParams represents an codec's encoding properties
 type BaseParams struct {
 	// Target bitrate in bps.
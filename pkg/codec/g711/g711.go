@@ -0,0 +1,178 @@
+// Package g711 implements G.711 mu-law (PCMU) and A-law (PCMA) audio encoders in pure Go.
+// Reference: https://www.itu.int/rec/T-REC-G.711
+package g711
+
+import (
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+	"github.com/pion/mediadevices/pkg/wave/mixer"
+)
+
+const (
+	// sampleRate is the sampling rate of G.711, which is also the RTP clock rate.
+	sampleRate = 8000
+	// frameDuration is the duration of the samples in a frame.
+	frameDuration = 20 * time.Millisecond
+)
+
+type encoder struct {
+	reader audio.Reader
+	encode func(int16) byte
+}
+
+func newEncoder(r audio.Reader, p prop.Media, params Params, encode func(int16) byte) (codec.ReadCloser, error) {
+	if params.ChannelMixer == nil {
+		params.ChannelMixer = &mixer.MonoMixer{}
+	}
+
+	rMix := audio.NewChannelMixer(1, params.ChannelMixer)
+	rResample := audio.NewResampler(sampleRate)
+	rBuf := audio.NewBuffer(int(sampleRate * frameDuration / time.Second))
+	return &encoder{
+		reader: rBuf(rResample(rMix(r))),
+		encode: encode,
+	}, nil
+}
+
+func (e *encoder) Read(p []byte) (int, error) {
+	buff, err := e.reader.Read()
+	if err != nil {
+		return 0, err
+	}
+	return mio.Copy(p, e.encodeAudio(buff))
+}
+
+// ReadFrame encodes the next 20 ms of samples and returns it with its metadata.
+// Every G.711 frame can be decoded independently, so KeyFrame is always true.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	buff, err := e.reader.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+	t := time.Now()
+
+	return codec.EncodedFrame{
+		Data:      e.encodeAudio(buff),
+		KeyFrame:  true,
+		Timestamp: t,
+		Duration:  time.Duration(buff.ChunkInfo().Len) * time.Second / sampleRate,
+	}, nil
+}
+
+func (e *encoder) encodeAudio(buff wave.Audio) []byte {
+	n := buff.ChunkInfo().Len
+	data := make([]byte, n)
+	switch b := buff.(type) {
+	case *wave.Int16Interleaved:
+		for i, s := range b.Data[:n] {
+			data[i] = e.encode(s)
+		}
+	case *wave.Float32Interleaved:
+		for i, s := range b.Data[:n] {
+			data[i] = e.encode(floatToInt16(s))
+		}
+	default:
+		for i := range data {
+			data[i] = e.encode(sampleToInt16(buff.At(i, 0)))
+		}
+	}
+	return data
+}
+
+// floatToInt16 scales a float sample in [-1, 1] to 16 bits.
+func floatToInt16(s float32) int16 {
+	return int16(math.Max(-1, math.Min(1, float64(s))) * math.MaxInt16)
+}
+
+// sampleToInt16 converts a sample of any format to 16 bits. Float samples are scaled by floatToInt16,
+// since wave.Int16SampleFormat doesn't scale them to the full range.
+func sampleToInt16(s wave.Sample) int16 {
+	if f, ok := s.(wave.Float32Sample); ok {
+		return floatToInt16(float32(f))
+	}
+	return int16(wave.Int16SampleFormat.Convert(s).(wave.Int16Sample))
+}
+
+// SetBitRate does nothing since G.711 always has 64 kbps.
+func (e *encoder) SetBitRate(b int) error {
+	return nil
+}
+
+// ForceKeyFrame does nothing since G.711 has no concept of key frames.
+func (e *encoder) ForceKeyFrame() error {
+	return nil
+}
+
+func (e *encoder) Close() error {
+	return nil
+}
+
+// segmentEnds are the upper bounds of the segments of mu-law (14 bits) and A-law (13 bits) magnitudes.
+var (
+	uLawSegmentEnds = [8]int{0x3f, 0x7f, 0xff, 0x1ff, 0x3ff, 0x7ff, 0xfff, 0x1fff}
+	aLawSegmentEnds = [8]int{0x1f, 0x3f, 0x7f, 0xff, 0x1ff, 0x3ff, 0x7ff, 0xfff}
+)
+
+const (
+	// uLawBias is added to the magnitude, so that the segments start at the powers of 2.
+	uLawBias = 0x84
+	// uLawClip is the maximum 14 bits magnitude before adding the bias.
+	uLawClip = 8159
+)
+
+func segment(v int, ends *[8]int) int {
+	for i, end := range ends {
+		if v <= end {
+			return i
+		}
+	}
+	return len(ends)
+}
+
+// linearToULaw converts a 16 bits linear PCM sample to mu-law.
+func linearToULaw(s int16) byte {
+	v := int(s) >> 2
+	mask := 0xff
+	if v < 0 {
+		v = -v
+		mask = 0x7f
+	}
+	if v > uLawClip {
+		v = uLawClip
+	}
+	v += uLawBias >> 2
+
+	seg := segment(v, &uLawSegmentEnds)
+	if seg >= 8 {
+		return byte(0x7f ^ mask)
+	}
+	return byte((seg<<4 | (v>>uint(seg+1))&0xf) ^ mask)
+}
+
+// linearToALaw converts a 16 bits linear PCM sample to A-law.
+func linearToALaw(s int16) byte {
+	v := int(s) >> 3
+	mask := 0xd5
+	if v < 0 {
+		v = -v - 1
+		mask = 0x55
+	}
+
+	seg := segment(v, &aLawSegmentEnds)
+	if seg >= 8 {
+		return byte(0x7f ^ mask)
+	}
+	a := seg << 4
+	if seg < 2 {
+		a |= (v >> 1) & 0xf
+	} else {
+		a |= (v >> uint(seg)) & 0xf
+	}
+	return byte(a ^ mask)
+}
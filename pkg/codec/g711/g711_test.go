@@ -0,0 +1,287 @@
+package g711
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// uLawTable and aLawTable are the reference linear values of the mu-law and A-law codes.
+// Reference: ITU-T G.191 Software Tools Library, g711.c
+var uLawTable = [256]int16{
+	-32124, -31100, -30076, -29052, -28028, -27004, -25980, -24956,
+	-23932, -22908, -21884, -20860, -19836, -18812, -17788, -16764,
+	-15996, -15484, -14972, -14460, -13948, -13436, -12924, -12412,
+	-11900, -11388, -10876, -10364, -9852, -9340, -8828, -8316,
+	-7932, -7676, -7420, -7164, -6908, -6652, -6396, -6140,
+	-5884, -5628, -5372, -5116, -4860, -4604, -4348, -4092,
+	-3900, -3772, -3644, -3516, -3388, -3260, -3132, -3004,
+	-2876, -2748, -2620, -2492, -2364, -2236, -2108, -1980,
+	-1884, -1820, -1756, -1692, -1628, -1564, -1500, -1436,
+	-1372, -1308, -1244, -1180, -1116, -1052, -988, -924,
+	-876, -844, -812, -780, -748, -716, -684, -652,
+	-620, -588, -556, -524, -492, -460, -428, -396,
+	-372, -356, -340, -324, -308, -292, -276, -260,
+	-244, -228, -212, -196, -180, -164, -148, -132,
+	-120, -112, -104, -96, -88, -80, -72, -64,
+	-56, -48, -40, -32, -24, -16, -8, 0,
+	32124, 31100, 30076, 29052, 28028, 27004, 25980, 24956,
+	23932, 22908, 21884, 20860, 19836, 18812, 17788, 16764,
+	15996, 15484, 14972, 14460, 13948, 13436, 12924, 12412,
+	11900, 11388, 10876, 10364, 9852, 9340, 8828, 8316,
+	7932, 7676, 7420, 7164, 6908, 6652, 6396, 6140,
+	5884, 5628, 5372, 5116, 4860, 4604, 4348, 4092,
+	3900, 3772, 3644, 3516, 3388, 3260, 3132, 3004,
+	2876, 2748, 2620, 2492, 2364, 2236, 2108, 1980,
+	1884, 1820, 1756, 1692, 1628, 1564, 1500, 1436,
+	1372, 1308, 1244, 1180, 1116, 1052, 988, 924,
+	876, 844, 812, 780, 748, 716, 684, 652,
+	620, 588, 556, 524, 492, 460, 428, 396,
+	372, 356, 340, 324, 308, 292, 276, 260,
+	244, 228, 212, 196, 180, 164, 148, 132,
+	120, 112, 104, 96, 88, 80, 72, 64,
+	56, 48, 40, 32, 24, 16, 8, 0,
+}
+
+var aLawTable = [256]int16{
+	-5504, -5248, -6016, -5760, -4480, -4224, -4992, -4736,
+	-7552, -7296, -8064, -7808, -6528, -6272, -7040, -6784,
+	-2752, -2624, -3008, -2880, -2240, -2112, -2496, -2368,
+	-3776, -3648, -4032, -3904, -3264, -3136, -3520, -3392,
+	-22016, -20992, -24064, -23040, -17920, -16896, -19968, -18944,
+	-30208, -29184, -32256, -31232, -26112, -25088, -28160, -27136,
+	-11008, -10496, -12032, -11520, -8960, -8448, -9984, -9472,
+	-15104, -14592, -16128, -15616, -13056, -12544, -14080, -13568,
+	-344, -328, -376, -360, -280, -264, -312, -296,
+	-472, -456, -504, -488, -408, -392, -440, -424,
+	-88, -72, -120, -104, -24, -8, -56, -40,
+	-216, -200, -248, -232, -152, -136, -184, -168,
+	-1376, -1312, -1504, -1440, -1120, -1056, -1248, -1184,
+	-1888, -1824, -2016, -1952, -1632, -1568, -1760, -1696,
+	-688, -656, -752, -720, -560, -528, -624, -592,
+	-944, -912, -1008, -976, -816, -784, -880, -848,
+	5504, 5248, 6016, 5760, 4480, 4224, 4992, 4736,
+	7552, 7296, 8064, 7808, 6528, 6272, 7040, 6784,
+	2752, 2624, 3008, 2880, 2240, 2112, 2496, 2368,
+	3776, 3648, 4032, 3904, 3264, 3136, 3520, 3392,
+	22016, 20992, 24064, 23040, 17920, 16896, 19968, 18944,
+	30208, 29184, 32256, 31232, 26112, 25088, 28160, 27136,
+	11008, 10496, 12032, 11520, 8960, 8448, 9984, 9472,
+	15104, 14592, 16128, 15616, 13056, 12544, 14080, 13568,
+	344, 328, 376, 360, 280, 264, 312, 296,
+	472, 456, 504, 488, 408, 392, 440, 424,
+	88, 72, 120, 104, 24, 8, 56, 40,
+	216, 200, 248, 232, 152, 136, 184, 168,
+	1376, 1312, 1504, 1440, 1120, 1056, 1248, 1184,
+	1888, 1824, 2016, 1952, 1632, 1568, 1760, 1696,
+	688, 656, 752, 720, 560, 528, 624, 592,
+	944, 912, 1008, 976, 816, 784, 880, 848,
+}
+
+func TestReferenceTables(t *testing.T) {
+	testCases := map[string]struct {
+		table  *[256]int16
+		encode func(int16) byte
+	}{
+		"ULaw": {table: &uLawTable, encode: linearToULaw},
+		"ALaw": {table: &aLawTable, encode: linearToALaw},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			for code, linear := range testCase.table {
+				expected := byte(code)
+				if name == "ULaw" && code == 0x7f {
+					// Both 0x7f and 0xff are 0, and 0 is encoded as the positive one.
+					expected = 0xff
+				}
+				if c := testCase.encode(linear); c != expected {
+					t.Errorf("Expected %d to be encoded as 0x%02x, got 0x%02x", linear, expected, c)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeRange(t *testing.T) {
+	testCases := map[string]struct {
+		table  *[256]int16
+		encode func(int16) byte
+		// maxError is the largest step of the quantization in 16 bits.
+		maxError int
+	}{
+		"ULaw": {table: &uLawTable, encode: linearToULaw, maxError: 1024},
+		"ALaw": {table: &aLawTable, encode: linearToALaw, maxError: 1024},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			// Every sample is encoded to a code next to it, except the values beyond the largest one.
+			for s := -32768; s <= 32767; s++ {
+				decoded := int(testCase.table[testCase.encode(int16(s))])
+				diff := decoded - s
+				if diff < 0 {
+					diff = -diff
+				}
+				if diff > testCase.maxError && (s > -32124 && s < 32124) {
+					t.Fatalf("Expected %d to be encoded within %d, got %d", s, testCase.maxError, decoded)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeFloat(t *testing.T) {
+	p, err := NewPCMUParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The half of the full scale in float is encoded as the half of the full scale in 16 bits.
+	r := audio.ReaderFunc(func() (wave.Audio, error) {
+		a := wave.NewFloat32Interleaved(wave.ChunkInfo{Len: 160, Channels: 1, SamplingRate: 8000})
+		for i := range a.Data {
+			a.Data[i] = 0.5
+		}
+		return a, nil
+	})
+	e, err := p.BuildAudioEncoder(r, prop.Media{
+		Audio: prop.Audio{SampleRate: 8000, ChannelCount: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	buf := make([]byte, 160)
+	if _, err := e.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := linearToULaw(16383)
+	for _, c := range buf {
+		if c != expected {
+			t.Fatalf("Expected the samples to be encoded as 0x%02x, got 0x%02x", expected, c)
+		}
+	}
+}
+
+func TestEncodeAudioFormats(t *testing.T) {
+	info := wave.ChunkInfo{Len: 4, Channels: 1, SamplingRate: 8000}
+	testCases := map[string]wave.Audio{
+		"Int16Interleaved": &wave.Int16Interleaved{
+			Data: []int16{16383, -16383, 0, 32767},
+			Size: info,
+		},
+		"Int16NonInterleaved": &wave.Int16NonInterleaved{
+			Data: [][]int16{{16383, -16383, 0, 32767}},
+			Size: info,
+		},
+		"Float32Interleaved": &wave.Float32Interleaved{
+			Data: []float32{0.5, -0.5, 0, 1},
+			Size: info,
+		},
+		"Float32NonInterleaved": &wave.Float32NonInterleaved{
+			Data: [][]float32{{0.5, -0.5, 0, 1}},
+			Size: info,
+		},
+	}
+
+	// All formats are encoded as the same 16 bits samples.
+	expected := []byte{linearToULaw(16383), linearToULaw(-16383), linearToULaw(0), linearToULaw(32767)}
+	for name, a := range testCases {
+		a := a
+		t.Run(name, func(t *testing.T) {
+			e := &encoder{encode: linearToULaw}
+			if data := e.encodeAudio(a); !bytes.Equal(expected, data) {
+				t.Errorf("Expected %x, got %x", expected, data)
+			}
+		})
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	pcmu, err := NewPCMUParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcma, err := NewPCMAParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		builder codec.AudioEncoderBuilder
+		name    string
+		code    byte
+	}{
+		"PCMU": {builder: &pcmu, name: "PCMU", code: linearToULaw(2000)},
+		"PCMA": {builder: &pcma, name: "PCMA", code: linearToALaw(2000)},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if c := testCase.builder.RTPCodec(); c.Name != testCase.name || c.ClockRate != 8000 {
+				t.Errorf("Expected %s/8000, got %s/%d", testCase.name, c.Name, c.ClockRate)
+			}
+
+			// 48 kHz stereo in 10 ms chunks, which is downmixed to 2000 and resampled to 8 kHz.
+			r := audio.ReaderFunc(func() (wave.Audio, error) {
+				a := wave.NewInt16Interleaved(wave.ChunkInfo{Len: 480, Channels: 2, SamplingRate: 48000})
+				for i := 0; i < a.Size.Len; i++ {
+					a.Data[2*i] = 1000
+					a.Data[2*i+1] = 3000
+				}
+				return a, nil
+			})
+			e, err := testCase.builder.BuildAudioEncoder(r, prop.Media{
+				Audio: prop.Audio{SampleRate: 48000, ChannelCount: 2},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+
+			fr, ok := e.(codec.FrameReader)
+			if !ok {
+				t.Fatal("Expected the encoder to implement codec.FrameReader")
+			}
+			for i := 0; i < 3; i++ {
+				frame, err := fr.ReadFrame()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(frame.Data) != 160 {
+					t.Fatalf("Expected 160 samples in a frame, got %d", len(frame.Data))
+				}
+				if frame.Duration != 20*time.Millisecond {
+					t.Errorf("Expected the frame to be 20ms, got %v", frame.Duration)
+				}
+				if !frame.KeyFrame {
+					t.Error("Expected every frame to be a key frame")
+				}
+				if i == 0 {
+					// The first frame starts with the delay of the resampler.
+					continue
+				}
+				for _, c := range frame.Data {
+					if c != testCase.code {
+						t.Fatalf("Expected the samples to be encoded as 0x%02x, got 0x%02x", testCase.code, c)
+					}
+				}
+			}
+
+			buf := make([]byte, 100)
+			if _, err := e.Read(buf); err == nil {
+				t.Error("Expected an error for the insufficient buffer")
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package g711
+
+import (
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave/mixer"
+)
+
+// Params stores G.711 specific encoding parameters.
+// BitRate is ignored since G.711 always has 64 kbps.
+type Params struct {
+	codec.BaseParams
+	// ChannelMixer is a mixer to be used to downmix the input to mono.
+	// mixer.MonoMixer is used if it's nil.
+	ChannelMixer mixer.ChannelMixer
+}
+
+// PCMUParams is codec specific paramaters of G.711 mu-law.
+type PCMUParams struct {
+	Params
+}
+
+// NewPCMUParams returns default G.711 mu-law codec specific parameters.
+func NewPCMUParams() (PCMUParams, error) {
+	return PCMUParams{}, nil
+}
+
+// RTPCodec represents the codec metadata
+func (p *PCMUParams) RTPCodec() *codec.RTPCodec {
+	return codec.NewRTPPCMUCodec(sampleRate)
+}
+
+// BuildAudioEncoder builds G.711 mu-law encoder with given params
+func (p *PCMUParams) BuildAudioEncoder(r audio.Reader, property prop.Media) (codec.ReadCloser, error) {
+	return newEncoder(r, property, p.Params, linearToULaw)
+}
+
+// PCMAParams is codec specific paramaters of G.711 A-law.
+type PCMAParams struct {
+	Params
+}
+
+// NewPCMAParams returns default G.711 A-law codec specific parameters.
+func NewPCMAParams() (PCMAParams, error) {
+	return PCMAParams{}, nil
+}
+
+// RTPCodec represents the codec metadata
+func (p *PCMAParams) RTPCodec() *codec.RTPCodec {
+	return codec.NewRTPPCMACodec(sampleRate)
+}
+
+// BuildAudioEncoder builds G.711 A-law encoder with given params
+func (p *PCMAParams) BuildAudioEncoder(r audio.Reader, property prop.Media) (codec.ReadCloser, error) {
+	return newEncoder(r, property, p.Params, linearToALaw)
+}
@@ -0,0 +1,295 @@
+package g722
+
+// This file implements the sub-band ADPCM of ITU-T G.722 at 64 kbps. The block names in the comments
+// refer to the blocks of the recommendation.
+// Reference: https://www.itu.int/rec/T-REC-G.722
+
+var (
+	// q6 are the decision levels of the 6 bits low-band quantizer.
+	q6 = [32]int{
+		0, 35, 72, 110, 150, 190, 233, 276,
+		323, 370, 422, 473, 530, 587, 650, 714,
+		786, 858, 940, 1023, 1121, 1219, 1339, 1458,
+		1612, 1765, 1980, 2195, 2557, 2919, 0, 0,
+	}
+	// iln and ilp are the codes of the negative and the positive low-band quantizer intervals.
+	iln = [32]int{
+		0, 63, 62, 31, 30, 29, 28, 27,
+		26, 25, 24, 23, 22, 21, 20, 19,
+		18, 17, 16, 15, 14, 13, 12, 11,
+		10, 9, 8, 7, 6, 5, 4, 0,
+	}
+	ilp = [32]int{
+		0, 61, 60, 59, 58, 57, 56, 55,
+		54, 53, 52, 51, 50, 49, 48, 47,
+		46, 45, 44, 43, 42, 41, 40, 39,
+		38, 37, 36, 35, 34, 33, 32, 0,
+	}
+	// wl are the logarithmic scale factor multipliers of the low band.
+	wl = [8]int{-60, -30, 58, 172, 334, 538, 1198, 3042}
+	// rl42 maps the 4 bits low-band code to the index of wl.
+	rl42 = [16]int{0, 7, 6, 5, 4, 3, 2, 1, 7, 6, 5, 4, 3, 2, 1, 0}
+	// ilb is the inverse logarithmic table used to scale the quantizers.
+	ilb = [32]int{
+		2048, 2093, 2139, 2186, 2233, 2282, 2332,
+		2383, 2435, 2489, 2543, 2599, 2656, 2714,
+		2774, 2834, 2896, 2960, 3025, 3091, 3158,
+		3228, 3298, 3371, 3444, 3520, 3597, 3676,
+		3756, 3838, 3922, 4008,
+	}
+	// qm4 are the output levels of the 4 bits low-band inverse quantizer used by the predictor.
+	qm4 = [16]int{
+		0, -20456, -12896, -8968,
+		-6288, -4240, -2584, -1200,
+		20456, 12896, 8968, 6288,
+		4240, 2584, 1200, 0,
+	}
+	// qm2 are the output levels of the 2 bits high-band inverse quantizer.
+	qm2 = [4]int{-7408, -1616, 7408, 1616}
+	// qmfCoeffs are the coefficients of the quadrature mirror filters.
+	qmfCoeffs = [12]int{3, -11, 12, 32, -210, 951, 3876, -805, 362, -156, 53, -11}
+	// ihn and ihp are the codes of the negative and the positive high-band quantizer intervals.
+	ihn = [3]int{0, 1, 0}
+	ihp = [3]int{0, 3, 2}
+	// wh are the logarithmic scale factor multipliers of the high band.
+	wh = [3]int{0, -214, 798}
+	// rh2 maps the 2 bits high-band code to the index of wh.
+	rh2 = [4]int{2, 1, 2, 1}
+)
+
+func saturate(v int) int {
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return v
+}
+
+// band is the state of the adaptive predictor and the quantizer scale of a sub-band.
+type band struct {
+	s, sp, sz int
+	r, a, ap  [3]int
+	p         [3]int
+	d, b, bp  [7]int
+	sg        [7]int
+	nb, det   int
+}
+
+// update updates the predictor by the quantized difference signal d.
+// It's the block 4 of the recommendation.
+func (b *band) update(d int) {
+	// RECONS
+	b.d[0] = d
+	b.r[0] = saturate(b.s + d)
+
+	// PARREC
+	b.p[0] = saturate(b.sz + d)
+
+	// UPPOL2
+	for i := 0; i < 3; i++ {
+		b.sg[i] = b.p[i] >> 15
+	}
+	wd1 := saturate(b.a[1] << 2)
+	wd2 := wd1
+	if b.sg[0] == b.sg[1] {
+		wd2 = -wd1
+	}
+	if wd2 > 32767 {
+		wd2 = 32767
+	}
+	wd3 := -128
+	if b.sg[0] == b.sg[2] {
+		wd3 = 128
+	}
+	wd3 += wd2 >> 7
+	wd3 += (b.a[2] * 32512) >> 15
+	if wd3 > 12288 {
+		wd3 = 12288
+	} else if wd3 < -12288 {
+		wd3 = -12288
+	}
+	b.ap[2] = wd3
+
+	// UPPOL1
+	b.sg[0] = b.p[0] >> 15
+	b.sg[1] = b.p[1] >> 15
+	wd1 = -192
+	if b.sg[0] == b.sg[1] {
+		wd1 = 192
+	}
+	wd2 = (b.a[1] * 32640) >> 15
+	b.ap[1] = saturate(wd1 + wd2)
+	wd3 = saturate(15360 - b.ap[2])
+	if b.ap[1] > wd3 {
+		b.ap[1] = wd3
+	} else if b.ap[1] < -wd3 {
+		b.ap[1] = -wd3
+	}
+
+	// UPZERO
+	wd1 = 128
+	if d == 0 {
+		wd1 = 0
+	}
+	b.sg[0] = d >> 15
+	for i := 1; i < 7; i++ {
+		b.sg[i] = b.d[i] >> 15
+		wd2 = -wd1
+		if b.sg[i] == b.sg[0] {
+			wd2 = wd1
+		}
+		wd3 = (b.b[i] * 32640) >> 15
+		b.bp[i] = saturate(wd2 + wd3)
+	}
+
+	// DELAYA
+	for i := 6; i > 0; i-- {
+		b.d[i] = b.d[i-1]
+		b.b[i] = b.bp[i]
+	}
+	for i := 2; i > 0; i-- {
+		b.r[i] = b.r[i-1]
+		b.p[i] = b.p[i-1]
+		b.a[i] = b.ap[i]
+	}
+
+	// FILTEP
+	wd1 = saturate(b.r[1] + b.r[1])
+	wd1 = (b.a[1] * wd1) >> 15
+	wd2 = saturate(b.r[2] + b.r[2])
+	wd2 = (b.a[2] * wd2) >> 15
+	b.sp = saturate(wd1 + wd2)
+
+	// FILTEZ
+	b.sz = 0
+	for i := 6; i > 0; i-- {
+		wd1 = saturate(b.d[i] + b.d[i])
+		b.sz += (b.b[i] * wd1) >> 15
+	}
+	b.sz = saturate(b.sz)
+
+	// PREDIC
+	b.s = saturate(b.sp + b.sz)
+}
+
+// scale updates the logarithmic scale factor nb by the multiplier w, and the linear scale factor det.
+// It's the blocks 3L and 3H of the recommendation.
+func (b *band) scale(w, maxNB, shift int) {
+	// LOGSCL, LOGSCH
+	b.nb = (b.nb*127)>>7 + w
+	if b.nb < 0 {
+		b.nb = 0
+	} else if b.nb > maxNB {
+		b.nb = maxNB
+	}
+
+	// SCALEL, SCALEH
+	wd1 := (b.nb >> 6) & 31
+	wd2 := shift - (b.nb >> 11)
+	var wd3 int
+	if wd2 < 0 {
+		wd3 = ilb[wd1] << uint(-wd2)
+	} else {
+		wd3 = ilb[wd1] >> uint(wd2)
+	}
+	b.det = wd3 << 2
+}
+
+// adpcmEncoder is the G.722 encoder at 64 kbps, which encodes a pair of 16 kHz samples into a byte.
+type adpcmEncoder struct {
+	// x is the history of the input samples of the transmit QMF.
+	x    [24]int
+	band [2]band
+}
+
+func newADPCMEncoder() *adpcmEncoder {
+	e := &adpcmEncoder{}
+	e.band[0].det = 32
+	e.band[1].det = 8
+	return e
+}
+
+// encode encodes the samples, whose length must be even.
+func (e *adpcmEncoder) encode(dst []byte, samples []int16) {
+	for j := 0; j+1 < len(samples); j += 2 {
+		// Apply the transmit QMF, and discard every other output.
+		copy(e.x[:22], e.x[2:])
+		e.x[22] = int(samples[j])
+		e.x[23] = int(samples[j+1])
+
+		var sumOdd, sumEven int
+		for i := 0; i < 12; i++ {
+			sumOdd += e.x[2*i] * qmfCoeffs[i]
+			sumEven += e.x[2*i+1] * qmfCoeffs[11-i]
+		}
+		xlow := (sumEven + sumOdd) >> 14
+		xhigh := (sumEven - sumOdd) >> 14
+
+		ilow := e.encodeLow(xlow)
+		ihigh := e.encodeHigh(xhigh)
+		dst[j/2] = byte(ihigh<<6 | ilow)
+	}
+}
+
+// encodeLow encodes a low-band sample into 6 bits.
+func (e *adpcmEncoder) encodeLow(xlow int) int {
+	b := &e.band[0]
+
+	// SUBTRA
+	el := saturate(xlow - b.s)
+
+	// QUANTL
+	wd := el
+	if el < 0 {
+		wd = -(el + 1)
+	}
+	i := 1
+	for ; i < 30; i++ {
+		if wd < (q6[i]*b.det)>>12 {
+			break
+		}
+	}
+	ilow := ilp[i]
+	if el < 0 {
+		ilow = iln[i]
+	}
+
+	// INVQAL
+	ril := ilow >> 2
+	dlow := (b.det * qm4[ril]) >> 15
+
+	b.scale(wl[rl42[ril]], 18432, 8)
+	b.update(dlow)
+	return ilow
+}
+
+// encodeHigh encodes a high-band sample into 2 bits.
+func (e *adpcmEncoder) encodeHigh(xhigh int) int {
+	b := &e.band[1]
+
+	// SUBTRA
+	eh := saturate(xhigh - b.s)
+
+	// QUANTH
+	wd := eh
+	if eh < 0 {
+		wd = -(eh + 1)
+	}
+	mih := 1
+	if wd >= (564*b.det)>>12 {
+		mih = 2
+	}
+	ihigh := ihp[mih]
+	if eh < 0 {
+		ihigh = ihn[mih]
+	}
+
+	// INVQAH
+	dhigh := (b.det * qm2[ihigh]) >> 15
+
+	b.scale(wh[rh2[ihigh]], 22528, 10)
+	b.update(dhigh)
+	return ihigh
+}
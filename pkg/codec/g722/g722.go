@@ -0,0 +1,117 @@
+// Package g722 implements ITU-T G.722 wideband audio encoder at 64 kbps in pure Go.
+// Reference: https://www.itu.int/rec/T-REC-G.722
+package g722
+
+import (
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+	"github.com/pion/mediadevices/pkg/wave/mixer"
+)
+
+const (
+	// sampleRate is the sampling rate of the input of G.722.
+	sampleRate = 16000
+	// frameDuration is the duration of the samples in a frame.
+	frameDuration = 20 * time.Millisecond
+)
+
+type encoder struct {
+	reader audio.Reader
+	engine *adpcmEncoder
+}
+
+func newEncoder(r audio.Reader, p prop.Media, params Params) (codec.ReadCloser, error) {
+	if params.ChannelMixer == nil {
+		params.ChannelMixer = &mixer.MonoMixer{}
+	}
+
+	rMix := audio.NewChannelMixer(1, params.ChannelMixer)
+	rResample := audio.NewResampler(sampleRate)
+	rBuf := audio.NewBuffer(int(sampleRate * frameDuration / time.Second))
+	return &encoder{
+		reader: rBuf(rResample(rMix(r))),
+		engine: newADPCMEncoder(),
+	}, nil
+}
+
+func (e *encoder) Read(p []byte) (int, error) {
+	buff, err := e.reader.Read()
+	if err != nil {
+		return 0, err
+	}
+	return mio.Copy(p, e.encodeAudio(buff))
+}
+
+// ReadFrame encodes the next 20 ms of samples and returns it with its metadata.
+// G.722 has no key frames, so KeyFrame is always true.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	buff, err := e.reader.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+	t := time.Now()
+
+	return codec.EncodedFrame{
+		Data:      e.encodeAudio(buff),
+		KeyFrame:  true,
+		Timestamp: t,
+		Duration:  time.Duration(buff.ChunkInfo().Len) * time.Second / sampleRate,
+	}, nil
+}
+
+func (e *encoder) encodeAudio(buff wave.Audio) []byte {
+	n := buff.ChunkInfo().Len
+	var samples []int16
+	switch b := buff.(type) {
+	case *wave.Int16Interleaved:
+		samples = b.Data[:n]
+	case *wave.Float32Interleaved:
+		samples = make([]int16, n)
+		for i, s := range b.Data[:n] {
+			samples[i] = floatToInt16(s)
+		}
+	default:
+		samples = make([]int16, n)
+		for i := range samples {
+			samples[i] = sampleToInt16(buff.At(i, 0))
+		}
+	}
+
+	data := make([]byte, n/2)
+	e.engine.encode(data, samples)
+	return data
+}
+
+// floatToInt16 scales a float sample in [-1, 1] to 16 bits.
+func floatToInt16(s float32) int16 {
+	return int16(math.Max(-1, math.Min(1, float64(s))) * math.MaxInt16)
+}
+
+// sampleToInt16 converts a sample of any format to 16 bits. Float samples are scaled by floatToInt16,
+// since wave.Int16SampleFormat doesn't scale them to the full range.
+func sampleToInt16(s wave.Sample) int16 {
+	if f, ok := s.(wave.Float32Sample); ok {
+		return floatToInt16(float32(f))
+	}
+	return int16(wave.Int16SampleFormat.Convert(s).(wave.Int16Sample))
+}
+
+// SetBitRate does nothing since the encoder always has 64 kbps.
+func (e *encoder) SetBitRate(b int) error {
+	return nil
+}
+
+// ForceKeyFrame does nothing since G.722 has no concept of key frames.
+func (e *encoder) ForceKeyFrame() error {
+	return nil
+}
+
+func (e *encoder) Close() error {
+	return nil
+}
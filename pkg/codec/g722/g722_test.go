@@ -0,0 +1,337 @@
+package g722
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// qm6 are the output levels of the 6 bits low-band inverse quantizer of the decoder.
+var qm6 = [64]int{
+	-136, -136, -136, -136, -24808, -21904, -19008, -16704,
+	-14984, -13512, -12280, -11192, -10232, -9360, -8576, -7856,
+	-7192, -6576, -6000, -5456, -4944, -4464, -4008, -3576,
+	-3168, -2776, -2400, -2032, -1688, -1360, -1040, -728,
+	24808, 21904, 19008, 16704, 14984, 13512, 12280, 11192,
+	10232, 9360, 8576, 7856, 7192, 6576, 6000, 5456,
+	4944, 4464, 4008, 3576, 3168, 2776, 2400, 2032,
+	1688, 1360, 1040, 728, 432, 136, -432, -136,
+}
+
+// adpcmDecoder is the G.722 decoder at 64 kbps to verify the encoder.
+type adpcmDecoder struct {
+	x    [24]int
+	band [2]band
+}
+
+func newADPCMDecoder() *adpcmDecoder {
+	d := &adpcmDecoder{}
+	d.band[0].det = 32
+	d.band[1].det = 8
+	return d
+}
+
+func limit(v int) int {
+	if v > 16383 {
+		return 16383
+	}
+	if v < -16384 {
+		return -16384
+	}
+	return v
+}
+
+func (d *adpcmDecoder) decode(codes []byte) []int16 {
+	out := make([]int16, 0, 2*len(codes))
+	for _, code := range codes {
+		ilow := int(code) & 0x3f
+		ihigh := int(code) >> 6
+
+		// Low band: INVQBL, RECONS, LIMIT
+		low := &d.band[0]
+		rlow := limit(low.s + (low.det*qm6[ilow])>>15)
+		ril := ilow >> 2
+		dlow := (low.det * qm4[ril]) >> 15
+		low.scale(wl[rl42[ril]], 18432, 8)
+		low.update(dlow)
+
+		// High band: INVQAH, RECONS, LIMIT
+		high := &d.band[1]
+		dhigh := (high.det * qm2[ihigh]) >> 15
+		rhigh := limit(high.s + dhigh)
+		high.scale(wh[rh2[ihigh]], 22528, 10)
+		high.update(dhigh)
+
+		// Receive QMF
+		copy(d.x[:22], d.x[2:])
+		d.x[22] = rlow + rhigh
+		d.x[23] = rlow - rhigh
+		var xout1, xout2 int
+		for i := 0; i < 12; i++ {
+			xout2 += d.x[2*i] * qmfCoeffs[i]
+			xout1 += d.x[2*i+1] * qmfCoeffs[11-i]
+		}
+		out = append(out, int16(saturate(xout1>>11)), int16(saturate(xout2>>11)))
+	}
+	return out
+}
+
+// snr returns the signal-to-noise ratio in dB of the decoded signal, which is delayed by the QMFs.
+func snr(original, decoded []int16) float64 {
+	best := math.Inf(-1)
+	for delay := 0; delay < 64; delay++ {
+		var signal, noise float64
+		for i := 1000; i+delay < len(decoded) && i < len(original); i++ {
+			s := float64(original[i])
+			n := float64(decoded[i+delay]) - s
+			signal += s * s
+			noise += n * n
+		}
+		if v := 10 * math.Log10(signal/noise); v > best {
+			best = v
+		}
+	}
+	return best
+}
+
+func TestADPCM(t *testing.T) {
+	testCases := map[string]struct {
+		frequencies []float64
+		minSNR      float64
+	}{
+		// The low band is encoded in 6 bits.
+		"LowBand": {
+			frequencies: []float64{440, 1000, 2500},
+			minSNR:      30,
+		},
+		// The high band is encoded in 2 bits.
+		"HighBand": {
+			frequencies: []float64{5000},
+			minSNR:      18,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			samples := make([]int16, sampleRate)
+			for i := range samples {
+				var v float64
+				for _, f := range testCase.frequencies {
+					v += 8000 / float64(len(testCase.frequencies)) * math.Sin(2*math.Pi*f*float64(i)/sampleRate)
+				}
+				samples[i] = int16(v)
+			}
+
+			codes := make([]byte, len(samples)/2)
+			newADPCMEncoder().encode(codes, samples)
+			decoded := newADPCMDecoder().decode(codes)
+
+			if v := snr(samples, decoded); v < testCase.minSNR {
+				t.Errorf("Expected SNR to be higher than %.0f dB, got %.1f dB", testCase.minSNR, v)
+			}
+		})
+	}
+}
+
+// referenceInput returns 1 second of the samples at 16 kHz, which are encoded and decoded by the reference
+// codec into testdata/reference.g722 and testdata/reference.raw (16 bits little endian). The reference is the
+// G.722 codec of spandsp (g722_encode.c and g722_decode.c by Steve Underwood) at 64 kbps. The input is kept
+// below the full scale, since the reference decoder wraps the samples around on overflow.
+func referenceInput() []int16 {
+	samples := make([]int16, sampleRate)
+	// Triangle wave sweeping up to the Nyquist frequency
+	var phase, step uint32
+	for i := 0; i < 4000; i++ {
+		step += 1 << 18
+		phase += step
+		v := int32(phase>>15) - 65536
+		if v < 0 {
+			v = -v
+		}
+		samples[i] = int16((v - 32768) * 15 / 16)
+	}
+	// Loud white noise
+	seed := uint32(1)
+	for i := 4000; i < 8000; i++ {
+		seed = seed*1103515245 + 12345
+		samples[i] = int16(seed>>16) >> 1
+	}
+	// Silence from 8000 to 10000, and a square wave
+	for i := 10000; i < 12000; i++ {
+		if i/8%2 == 0 {
+			samples[i] = 16384
+		} else {
+			samples[i] = -16384
+		}
+	}
+	// Quiet white noise
+	for i := 12000; i < 16000; i++ {
+		seed = seed*1103515245 + 12345
+		samples[i] = int16(seed>>16) >> 8
+	}
+	return samples
+}
+
+func TestADPCMReference(t *testing.T) {
+	expected, err := ioutil.ReadFile("testdata/reference.g722")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := make([]byte, sampleRate/2)
+	newADPCMEncoder().encode(codes, referenceInput())
+	if len(codes) != len(expected) {
+		t.Fatalf("Expected %d bytes, got %d", len(expected), len(codes))
+	}
+	for i := range codes {
+		if codes[i] != expected[i] {
+			t.Fatalf("Expected the codes to be the reference, got 0x%02x instead of 0x%02x at %d", codes[i], expected[i], i)
+		}
+	}
+}
+
+// TestADPCMDecoderReference checks the decoder of the tests, which verifies the encoder in the other tests.
+func TestADPCMDecoderReference(t *testing.T) {
+	codes, err := ioutil.ReadFile("testdata/reference.g722")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile("testdata/reference.raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]int16, len(raw)/2)
+	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := newADPCMDecoder().decode(codes)
+	if len(decoded) != len(expected) {
+		t.Fatalf("Expected %d samples, got %d", len(expected), len(decoded))
+	}
+	for i := range decoded {
+		if decoded[i] != expected[i] {
+			t.Fatalf("Expected the samples to be the reference, got %d instead of %d at %d", decoded[i], expected[i], i)
+		}
+	}
+}
+
+func TestADPCMSilence(t *testing.T) {
+	codes := make([]byte, 100)
+	newADPCMEncoder().encode(codes, make([]int16, 200))
+	for _, s := range newADPCMDecoder().decode(codes) {
+		if s > 2 || s < -2 {
+			t.Fatalf("Expected the silence to be decoded as silence, got %d", s)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := p.RTPCodec(); c.Name != "G722" || c.ClockRate != 8000 {
+		t.Errorf("Expected G722/8000, got %s/%d", c.Name, c.ClockRate)
+	}
+
+	// 48 kHz stereo in 10 ms chunks, which is downmixed and resampled to 16 kHz.
+	var phase int
+	r := audio.ReaderFunc(func() (wave.Audio, error) {
+		a := wave.NewFloat32Interleaved(wave.ChunkInfo{Len: 480, Channels: 2, SamplingRate: 48000})
+		for i := 0; i < a.Size.Len; i++ {
+			v := float32(0.25 * math.Sin(2*math.Pi*1000*float64(phase)/48000))
+			a.Data[2*i] = v
+			a.Data[2*i+1] = v
+			phase++
+		}
+		return a, nil
+	})
+	e, err := p.BuildAudioEncoder(r, prop.Media{
+		Audio: prop.Audio{SampleRate: 48000, ChannelCount: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	fr, ok := e.(codec.FrameReader)
+	if !ok {
+		t.Fatal("Expected the encoder to implement codec.FrameReader")
+	}
+	var codes []byte
+	for i := 0; i < 50; i++ {
+		frame, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 320 samples at 16 kHz in 160 bytes
+		if len(frame.Data) != 160 {
+			t.Fatalf("Expected 160 bytes in a frame, got %d", len(frame.Data))
+		}
+		if frame.Duration != 20*time.Millisecond {
+			t.Errorf("Expected the frame to be 20ms, got %v", frame.Duration)
+		}
+		codes = append(codes, frame.Data...)
+	}
+
+	expected := make([]int16, 2*len(codes))
+	for i := range expected {
+		expected[i] = int16(0.25 * math.MaxInt16 * math.Sin(2*math.Pi*1000*float64(i)/sampleRate))
+	}
+	// The resampler and the QMFs delay the signal.
+	if v := snr(expected, newADPCMDecoder().decode(codes)); v < 15 {
+		t.Errorf("Expected the decoded audio to be the input, got SNR %.1f dB", v)
+	}
+}
+
+func TestEncodeAudioFormats(t *testing.T) {
+	info := wave.ChunkInfo{Len: 320, Channels: 1, SamplingRate: sampleRate}
+	samples := make([]int16, info.Len)
+	for i := range samples {
+		samples[i] = int16(0.25 * math.MaxInt16 * math.Sin(2*math.Pi*1000*float64(i)/sampleRate))
+	}
+
+	testCases := map[string]func() wave.Audio{
+		"Int16NonInterleaved": func() wave.Audio {
+			a := wave.NewInt16NonInterleaved(info)
+			copy(a.Data[0], samples)
+			return a
+		},
+		"Float32Interleaved": func() wave.Audio {
+			a := wave.NewFloat32Interleaved(info)
+			for i, s := range samples {
+				a.Data[i] = float32(s) / math.MaxInt16
+			}
+			return a
+		},
+		"Float32NonInterleaved": func() wave.Audio {
+			a := wave.NewFloat32NonInterleaved(info)
+			for i, s := range samples {
+				a.Data[0][i] = float32(s) / math.MaxInt16
+			}
+			return a
+		},
+	}
+
+	// All formats are encoded as the same 16 bits samples.
+	expected := (&encoder{engine: newADPCMEncoder()}).encodeAudio(&wave.Int16Interleaved{Data: samples, Size: info})
+	for name, newAudio := range testCases {
+		newAudio := newAudio
+		t.Run(name, func(t *testing.T) {
+			e := &encoder{engine: newADPCMEncoder()}
+			if data := e.encodeAudio(newAudio()); !bytes.Equal(expected, data) {
+				t.Errorf("Expected %x, got %x", expected, data)
+			}
+		})
+	}
+}
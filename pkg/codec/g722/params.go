@@ -0,0 +1,34 @@
+package g722
+
+import (
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave/mixer"
+)
+
+// Params stores G.722 specific encoding parameters.
+// BitRate is ignored since the encoder always has 64 kbps.
+type Params struct {
+	codec.BaseParams
+	// ChannelMixer is a mixer to be used to downmix the input to mono.
+	// mixer.MonoMixer is used if it's nil.
+	ChannelMixer mixer.ChannelMixer
+}
+
+// NewParams returns default G.722 codec specific parameters.
+func NewParams() (Params, error) {
+	return Params{}, nil
+}
+
+// RTPCodec represents the codec metadata. The RTP clock rate of G.722 is 8000 Hz, though the audio
+// is sampled at 16 kHz, because of an error in the original RTP profile.
+// Reference: https://tools.ietf.org/html/rfc3551#section-4.5.2
+func (p *Params) RTPCodec() *codec.RTPCodec {
+	return codec.NewRTPG722Codec(8000)
+}
+
+// BuildAudioEncoder builds G.722 encoder with given params
+func (p *Params) BuildAudioEncoder(r audio.Reader, property prop.Media) (codec.ReadCloser, error) {
+	return newEncoder(r, property, *p)
+}
@@ -0,0 +1,219 @@
+// Package l16 implements L16 audio encoder, which sends uncompressed 16 bits linear PCM in network
+// byte order.
+// Reference: https://tools.ietf.org/html/rfc3551#section-4.5.11
+package l16
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+	"github.com/pion/mediadevices/pkg/wave/mixer"
+	"github.com/pion/webrtc/v2"
+)
+
+const (
+	// defaultPayloadType is the dynamic RTP payload type used if no static one is assigned.
+	defaultPayloadType = 118
+	// staticSampleRate is the sampling rate of the static payload types.
+	staticSampleRate = 44100
+	// maxPayloadSize is the maximum size of the payload which fits in an RTP packet of webrtc.Track.
+	// The samples of a frame are sent in a packet, since the packets split from a frame would have
+	// the same timestamp.
+	maxPayloadSize = 1200 - 12
+	// defaultPtime is the packetization time if prop.Media doesn't specify the latency.
+	defaultPtime = 10 * time.Millisecond
+)
+
+// staticPayloadTypes are the static payload types by the channel count.
+var staticPayloadTypes = map[int]uint8{
+	1: 11,
+	2: 10,
+}
+
+type encoder struct {
+	reader       audio.Reader
+	sampleRate   int
+	channelCount int
+}
+
+func newEncoder(r audio.Reader, p prop.Media, params Params) (codec.ReadCloser, error) {
+	if params.SampleRate <= 0 {
+		return nil, fmt.Errorf("l16: invalid sample rate: %d", params.SampleRate)
+	}
+	if params.ChannelCount <= 0 {
+		return nil, fmt.Errorf("l16: invalid channel count: %d", params.ChannelCount)
+	}
+	if params.ChannelMixer == nil {
+		params.ChannelMixer = &mixer.MonoMixer{}
+	}
+	if p.Latency == 0 {
+		p.Latency = defaultPtime
+	}
+	nSamples := frameSamples(params.SampleRate, params.ChannelCount, p.Latency)
+
+	rMix := audio.NewChannelMixer(params.ChannelCount, params.ChannelMixer)
+	rResample := audio.NewResampler(params.SampleRate)
+	rBuf := audio.NewBuffer(nSamples)
+	return &encoder{
+		reader:       rBuf(rResample(rMix(r))),
+		sampleRate:   params.SampleRate,
+		channelCount: params.ChannelCount,
+	}, nil
+}
+
+// frameSamples returns the number of the samples in a frame. The packetization time is the largest divisor of
+// the latency in whole milliseconds, whose frame has a whole number of samples and fits in a packet, e.g. 5 ms
+// for 10 ms of 48 kHz stereo. If there's no such one, e.g. 44.1 kHz stereo, the frame is as many samples as
+// fit in a packet.
+func frameSamples(sampleRate, channelCount int, latency time.Duration) int {
+	maxSamples := maxPayloadSize / (2 * channelCount)
+	ms := int(latency / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	for ptime := ms; ptime >= 1; ptime-- {
+		if ms%ptime != 0 || sampleRate*ptime%1000 != 0 {
+			continue
+		}
+		if n := sampleRate * ptime / 1000; n <= maxSamples {
+			return n
+		}
+	}
+	if maxSamples < 1 {
+		return 1
+	}
+	return maxSamples
+}
+
+func (e *encoder) Read(p []byte) (int, error) {
+	buff, err := e.reader.Read()
+	if err != nil {
+		return 0, err
+	}
+	return mio.Copy(p, e.encode(buff))
+}
+
+// ReadFrame returns the next chunk of samples with its metadata.
+// Every frame can be decoded independently, so KeyFrame is always true.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	buff, err := e.reader.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+	t := time.Now()
+
+	return codec.EncodedFrame{
+		Data:      e.encode(buff),
+		KeyFrame:  true,
+		Timestamp: t,
+		Duration:  time.Duration(buff.ChunkInfo().Len) * time.Second / time.Duration(e.sampleRate),
+	}, nil
+}
+
+// encode converts the samples into interleaved big-endian 16 bits integers.
+func (e *encoder) encode(buff wave.Audio) []byte {
+	ci := buff.ChunkInfo()
+	n := ci.Len * ci.Channels
+	data := make([]byte, 2*n)
+	switch b := buff.(type) {
+	case *wave.Int16Interleaved:
+		for i, s := range b.Data[:n] {
+			binary.BigEndian.PutUint16(data[2*i:], uint16(s))
+		}
+	case *wave.Float32Interleaved:
+		for i, s := range b.Data[:n] {
+			binary.BigEndian.PutUint16(data[2*i:], uint16(floatToInt16(s)))
+		}
+	default:
+		for i := 0; i < ci.Len; i++ {
+			for ch := 0; ch < ci.Channels; ch++ {
+				s := sampleToInt16(buff.At(i, ch))
+				binary.BigEndian.PutUint16(data[2*(i*ci.Channels+ch):], uint16(s))
+			}
+		}
+	}
+	return data
+}
+
+// floatToInt16 scales a float sample in [-1, 1] to 16 bits.
+func floatToInt16(s float32) int16 {
+	return int16(math.Max(-1, math.Min(1, float64(s))) * math.MaxInt16)
+}
+
+// sampleToInt16 converts a sample of any format to 16 bits. Float samples are scaled by floatToInt16,
+// since wave.Int16SampleFormat doesn't scale them to the full range.
+func sampleToInt16(s wave.Sample) int16 {
+	if f, ok := s.(wave.Float32Sample); ok {
+		return floatToInt16(float32(f))
+	}
+	return int16(wave.Int16SampleFormat.Convert(s).(wave.Int16Sample))
+}
+
+// SetBitRate does nothing since the samples are not compressed.
+func (e *encoder) SetBitRate(b int) error {
+	return nil
+}
+
+// ForceKeyFrame does nothing since L16 has no concept of key frames.
+func (e *encoder) ForceKeyFrame() error {
+	return nil
+}
+
+func (e *encoder) Close() error {
+	return nil
+}
+
+func newRTPCodec(params Params) *codec.RTPCodec {
+	payloadType := params.PayloadType
+	if payloadType == 0 {
+		payloadType = defaultPayloadType
+		if pt, ok := staticPayloadTypes[params.ChannelCount]; ok && params.SampleRate == staticSampleRate {
+			payloadType = pt
+		}
+	}
+
+	return &codec.RTPCodec{
+		RTPCodec: webrtc.NewRTPCodec(
+			webrtc.RTPCodecTypeAudio,
+			"L16",
+			uint32(params.SampleRate),
+			uint16(params.ChannelCount),
+			"",
+			payloadType,
+			&payloader{channelCount: params.ChannelCount},
+		),
+	}
+}
+
+// payloader splits the samples into RTP payloads at the boundaries of the samples.
+type payloader struct {
+	channelCount int
+}
+
+func (p *payloader) Payload(mtu int, payload []byte) [][]byte {
+	sampleSize := 2 * p.channelCount
+	size := mtu / sampleSize * sampleSize
+	if size <= 0 || len(payload) == 0 {
+		return nil
+	}
+
+	var payloads [][]byte
+	for len(payload) > 0 {
+		n := size
+		if n > len(payload) {
+			n = len(payload)
+		}
+		out := make([]byte, n)
+		copy(out, payload[:n])
+		payloads = append(payloads, out)
+		payload = payload[n:]
+	}
+	return payloads
+}
@@ -0,0 +1,190 @@
+package l16
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+func TestRTPCodec(t *testing.T) {
+	testCases := map[string]struct {
+		params      Params
+		payloadType uint8
+	}{
+		"Dynamic": {
+			params:      Params{SampleRate: 48000, ChannelCount: 2},
+			payloadType: defaultPayloadType,
+		},
+		"StaticMono": {
+			params:      Params{SampleRate: 44100, ChannelCount: 1},
+			payloadType: 11,
+		},
+		"StaticStereo": {
+			params:      Params{SampleRate: 44100, ChannelCount: 2},
+			payloadType: 10,
+		},
+		"Specified": {
+			params:      Params{SampleRate: 44100, ChannelCount: 2, PayloadType: 100},
+			payloadType: 100,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			c := testCase.params.RTPCodec()
+			if c.Name != "L16" || c.MimeType != "audio/L16" {
+				t.Errorf("Expected L16, got %s (%s)", c.Name, c.MimeType)
+			}
+			if c.ClockRate != uint32(testCase.params.SampleRate) {
+				t.Errorf("Expected the clock rate to be %d, got %d", testCase.params.SampleRate, c.ClockRate)
+			}
+			if c.Channels != uint16(testCase.params.ChannelCount) {
+				t.Errorf("Expected %d channels, got %d", testCase.params.ChannelCount, c.Channels)
+			}
+			if c.PayloadType != testCase.payloadType {
+				t.Errorf("Expected the payload type to be %d, got %d", testCase.payloadType, c.PayloadType)
+			}
+		})
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	testCases := map[string]struct {
+		params   Params
+		input    wave.Audio
+		latency  time.Duration
+		samples  int
+		expected []byte
+	}{
+		"Int16": {
+			params: Params{SampleRate: 8000, ChannelCount: 2},
+			input: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 2, SamplingRate: 8000},
+				Data: []int16{0x1234, -2, 1, -32768},
+			},
+			latency:  time.Millisecond,
+			samples:  8,
+			expected: []byte{0x12, 0x34, 0xff, 0xfe, 0x00, 0x01, 0x80, 0x00},
+		},
+		"Float32": {
+			params: Params{SampleRate: 8000, ChannelCount: 1},
+			input: &wave.Float32Interleaved{
+				Size: wave.ChunkInfo{Len: 2, Channels: 1, SamplingRate: 8000},
+				Data: []float32{0.5, -1},
+			},
+			latency:  time.Millisecond,
+			samples:  8,
+			expected: []byte{0x3f, 0xff, 0x80, 0x01},
+		},
+		"Default": {
+			params: Params{SampleRate: 48000, ChannelCount: 1},
+			input: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 480, Channels: 1, SamplingRate: 48000},
+				Data: make([]int16, 480),
+			},
+			samples: 480,
+		},
+		"RoundedDown": {
+			params: Params{SampleRate: 16000, ChannelCount: 1},
+			input: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 160, Channels: 1, SamplingRate: 16000},
+				Data: make([]int16, 160),
+			},
+			latency: 12500 * time.Microsecond,
+			samples: 192,
+		},
+		// 20 ms of 48 kHz stereo is split into 5 ms frames to fit in a packet.
+		"LimitedToPacket": {
+			params: Params{SampleRate: 48000, ChannelCount: 2},
+			input: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 960, Channels: 2, SamplingRate: 48000},
+				Data: make([]int16, 960*2),
+			},
+			latency: 20 * time.Millisecond,
+			samples: 240,
+		},
+		// No frame in whole milliseconds of 44.1 kHz stereo fits in a packet.
+		"NoWholeMilliseconds": {
+			params: Params{SampleRate: 44100, ChannelCount: 2},
+			input: &wave.Int16Interleaved{
+				Size: wave.ChunkInfo{Len: 441, Channels: 2, SamplingRate: 44100},
+				Data: make([]int16, 441*2),
+			},
+			latency: 10 * time.Millisecond,
+			samples: maxPayloadSize / 4,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			r := audio.ReaderFunc(func() (wave.Audio, error) {
+				return testCase.input, nil
+			})
+			e, err := testCase.params.BuildAudioEncoder(r, prop.Media{
+				Audio: prop.Audio{Latency: testCase.latency},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+
+			frame, err := e.(codec.FrameReader).ReadFrame()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := 2 * testCase.params.ChannelCount * testCase.samples; len(frame.Data) != size {
+				t.Fatalf("Expected %d bytes, got %d", size, len(frame.Data))
+			}
+			if testCase.expected != nil && !bytes.HasPrefix(frame.Data, testCase.expected) {
+				t.Errorf("Expected %v, got %v", testCase.expected, frame.Data[:len(testCase.expected)])
+			}
+			duration := time.Duration(testCase.samples) * time.Second / time.Duration(testCase.params.SampleRate)
+			if frame.Duration != duration {
+				t.Errorf("Expected the frame to be %v, got %v", duration, frame.Duration)
+			}
+		})
+	}
+}
+
+func TestEncodeFormats(t *testing.T) {
+	info := wave.ChunkInfo{Len: 2, Channels: 2, SamplingRate: 8000}
+	testCases := map[string]wave.Audio{
+		"Int16NonInterleaved": &wave.Int16NonInterleaved{
+			Data: [][]int16{{16383, 0}, {-32767, 32767}},
+			Size: info,
+		},
+		"Float32NonInterleaved": &wave.Float32NonInterleaved{
+			Data: [][]float32{{0.5, 0}, {-1, 1}},
+			Size: info,
+		},
+	}
+
+	// The samples are interleaved, and float samples are scaled to the full range of 16 bits.
+	expected := []byte{0x3f, 0xff, 0x80, 0x01, 0x00, 0x00, 0x7f, 0xff}
+	for name, a := range testCases {
+		a := a
+		t.Run(name, func(t *testing.T) {
+			e := &encoder{sampleRate: 8000, channelCount: 2}
+			if data := e.encode(a); !bytes.Equal(expected, data) {
+				t.Errorf("Expected %v, got %v", expected, data)
+			}
+		})
+	}
+}
+
+func TestPayloader(t *testing.T) {
+	p := &payloader{channelCount: 2}
+	payloads := p.Payload(10, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12})
+	expected := [][]byte{{1, 2, 3, 4, 5, 6, 7, 8}, {9, 10, 11, 12}}
+	if !reflect.DeepEqual(payloads, expected) {
+		t.Errorf("Expected the payloads to be split at the samples %v, got %v", expected, payloads)
+	}
+}
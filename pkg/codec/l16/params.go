@@ -0,0 +1,42 @@
+package l16
+
+import (
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave/mixer"
+)
+
+// Params stores L16 specific encoding parameters.
+// BitRate is ignored since the bitrate is determined by SampleRate and ChannelCount.
+type Params struct {
+	codec.BaseParams
+	// SampleRate is the sampling rate of the output, which is also the RTP clock rate.
+	SampleRate int
+	// ChannelCount is the number of the channels of the output.
+	ChannelCount int
+	// ChannelMixer is a mixer to be used if number of given and expected channels differ.
+	// mixer.MonoMixer is used if it's nil.
+	ChannelMixer mixer.ChannelMixer
+	// PayloadType is the RTP payload type. If it's 0, the static payload type is used for
+	// 44.1 kHz mono and stereo, and defaultPayloadType is used for the others.
+	PayloadType uint8
+}
+
+// NewParams returns default L16 codec specific parameters, which is 48 kHz stereo.
+func NewParams() (Params, error) {
+	return Params{
+		SampleRate:   48000,
+		ChannelCount: 2,
+	}, nil
+}
+
+// RTPCodec represents the codec metadata
+func (p *Params) RTPCodec() *codec.RTPCodec {
+	return newRTPCodec(*p)
+}
+
+// BuildAudioEncoder builds L16 encoder with given params
+func (p *Params) BuildAudioEncoder(r audio.Reader, property prop.Media) (codec.ReadCloser, error) {
+	return newEncoder(r, property, *p)
+}
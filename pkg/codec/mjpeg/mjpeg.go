@@ -0,0 +1,240 @@
+// Package mjpeg implements Motion JPEG video encoder in pure Go, which encodes each frame into a JPEG image
+// by image/jpeg. The JPEG images compressed by the driver are sent as they are.
+// Reference: https://tools.ietf.org/html/rfc2435
+package mjpeg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+const (
+	// minQuality is the lowest quality which the bitrate control lowers to.
+	minQuality = 5
+	// defaultFrameRate is used to get the size of a frame from the bitrate if prop.Media doesn't specify it.
+	defaultFrameRate = 30
+)
+
+type encoder struct {
+	r          video.Reader
+	buff       []byte
+	frameIndex int
+	tLastFrame time.Time
+	frameRate  float32
+
+	// quality is the quality of the next frame. If frameSize is not 0, it's adjusted to fit the frames in
+	// frameSize bytes, up to maxQuality.
+	quality    int
+	maxQuality int
+	frameSize  int
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newEncoder(r video.Reader, p prop.Media, params Params) (codec.ReadCloser, error) {
+	if params.Quality < 1 || params.Quality > 100 {
+		return nil, fmt.Errorf("mjpeg: invalid quality: %d", params.Quality)
+	}
+	if p.Width > maxSize || p.Height > maxSize {
+		return nil, fmt.Errorf("mjpeg: %dx%d exceeds the maximum size of RTP, %dx%d", p.Width, p.Height, maxSize, maxSize)
+	}
+	if p.FrameRate == 0 {
+		p.FrameRate = defaultFrameRate
+	}
+
+	e := &encoder{
+		r:          r,
+		frameRate:  p.FrameRate,
+		quality:    params.Quality,
+		maxQuality: params.Quality,
+	}
+	if params.BitRate > 0 {
+		e.setBitRate(params.BitRate)
+	}
+	return e, nil
+}
+
+func (e *encoder) Read(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return 0, io.EOF
+	}
+
+	if e.buff != nil {
+		n, err := mio.Copy(p, e.buff)
+		if err == nil {
+			e.buff = nil
+		}
+		return n, err
+	}
+
+	frame, err := e.readFrame()
+	if err != nil {
+		return 0, err
+	}
+	n, err := mio.Copy(p, frame.Data)
+	if err != nil {
+		e.buff = frame.Data
+	}
+	return n, err
+}
+
+// ReadFrame encodes the next frame and returns it with its metadata.
+// Every JPEG image can be decoded independently, so KeyFrame is always true.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return codec.EncodedFrame{}, io.EOF
+	}
+
+	return e.readFrame()
+}
+
+func (e *encoder) readFrame() (codec.EncodedFrame, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+	t := video.Timestamp(e.r)
+
+	data, err := e.encode(img)
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+
+	var duration time.Duration
+	if e.frameIndex > 0 {
+		duration = t.Sub(e.tLastFrame)
+	}
+	e.frameIndex++
+	e.tLastFrame = t
+
+	return codec.EncodedFrame{
+		Data:      data,
+		KeyFrame:  true,
+		Timestamp: t,
+		Duration:  duration,
+	}, nil
+}
+
+// encode returns the JPEG image of img. The JPEG images compressed by the driver are returned as they are,
+// if they can be carried by RTP.
+func (e *encoder) encode(img image.Image) ([]byte, error) {
+	if compressed, ok := img.(*frame.JPEG); ok {
+		src := compressed.Bytes()
+		if _, err := parseJPEG(src); err == nil {
+			data := make([]byte, len(src))
+			copy(data, src)
+			return data, nil
+		}
+		decoded, err := compressed.Decode()
+		if err != nil {
+			return nil, err
+		}
+		img = decoded
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() > maxSize || bounds.Dy() > maxSize {
+		return nil, fmt.Errorf("mjpeg: %dx%d exceeds the maximum size of RTP, %dx%d", bounds.Dx(), bounds.Dy(), maxSize, maxSize)
+	}
+	if gray, ok := img.(*image.Gray); ok {
+		// image/jpeg encodes *image.Gray in grayscale, which can't be carried by RTP.
+		img = grayToYCbCr(gray)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: e.quality}); err != nil {
+		return nil, err
+	}
+	e.updateQuality(buf.Len())
+	return buf.Bytes(), nil
+}
+
+// updateQuality adjusts the quality of the next frame by the size of the last frame.
+// The quality is lowered by the ratio of the excess, and raised by one step at a time to avoid oscillation.
+func (e *encoder) updateQuality(size int) {
+	if e.frameSize == 0 {
+		return
+	}
+	switch {
+	case size > e.frameSize*11/10:
+		step := e.quality * (size - e.frameSize) / size / 2
+		if step < 1 {
+			step = 1
+		}
+		e.quality -= step
+		if e.quality < minQuality {
+			e.quality = minQuality
+		}
+	case size < e.frameSize*9/10 && e.quality < e.maxQuality:
+		e.quality++
+	}
+}
+
+func grayToYCbCr(src *image.Gray) *image.YCbCr {
+	bounds := src.Bounds()
+	dst := image.NewYCbCr(bounds, image.YCbCrSubsampleRatio420)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		copy(dst.Y[dst.YOffset(bounds.Min.X, y):], src.Pix[src.PixOffset(bounds.Min.X, y):src.PixOffset(bounds.Max.X, y)])
+	}
+	for i := range dst.Cb {
+		dst.Cb[i] = 128
+		dst.Cr[i] = 128
+	}
+	return dst
+}
+
+// SetBitRate sets the target bitrate. The quality of the frames is adjusted from the next frame, so that
+// the size of a frame fits in the bitrate at the frame rate. It has no effect on the JPEG images
+// compressed by the driver.
+func (e *encoder) SetBitRate(b int) error {
+	if b <= 0 {
+		return fmt.Errorf("invalid bitrate: %d", b)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	e.setBitRate(b)
+	return nil
+}
+
+func (e *encoder) setBitRate(b int) {
+	e.frameSize = int(float32(b) / 8 / e.frameRate)
+	if e.frameSize < 1 {
+		e.frameSize = 1
+	}
+}
+
+// ForceKeyFrame does nothing since every frame is a key frame.
+func (e *encoder) ForceKeyFrame() error {
+	return nil
+}
+
+func (e *encoder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	return nil
+}
@@ -0,0 +1,176 @@
+package mjpeg
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+func newTestEncoder(t *testing.T, params Params, img image.Image) *encoder {
+	e, err := params.BuildVideoEncoder(video.ReaderFunc(func() (image.Image, error) {
+		return img, nil
+	}), prop.Media{
+		Video: prop.Video{
+			Width:     img.Bounds().Dx(),
+			Height:    img.Bounds().Dy(),
+			FrameRate: 30,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.(*encoder)
+}
+
+func TestEncoder(t *testing.T) {
+	testCases := map[string]struct {
+		img image.Image
+	}{
+		"YCbCr": {
+			img: newNoiseImage(64, 48),
+		},
+		"RGBA": {
+			img: image.NewRGBA(image.Rect(0, 0, 64, 48)),
+		},
+		"Gray": {
+			img: image.NewGray(image.Rect(0, 0, 64, 48)),
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			params, err := NewParams()
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := newTestEncoder(t, params, testCase.img)
+			defer e.Close()
+
+			for i := 0; i < 2; i++ {
+				f, err := e.ReadFrame()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !f.KeyFrame {
+					t.Error("Expected a key frame")
+				}
+				if i == 0 && f.Duration != 0 {
+					t.Errorf("Expected the duration of the first frame to be 0, got %v", f.Duration)
+				}
+
+				if _, err := parseJPEG(f.Data); err != nil {
+					t.Fatalf("Expected the frame to be carried by RTP, got %v", err)
+				}
+				img, err := jpeg.Decode(bytes.NewReader(f.Data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if img.Bounds() != testCase.img.Bounds() {
+					t.Errorf("Expected bounds %v, got %v", testCase.img.Bounds(), img.Bounds())
+				}
+			}
+		})
+	}
+}
+
+func TestEncoderPassthrough(t *testing.T) {
+	testCases := map[string]struct {
+		img         image.Image
+		passthrough bool
+	}{
+		"YCbCr": {
+			img:         newNoiseImage(64, 48),
+			passthrough: true,
+		},
+		"Gray": {
+			// Grayscale JPEG can't be carried by RTP, so it's re-encoded.
+			img:         image.NewGray(image.Rect(0, 0, 64, 48)),
+			passthrough: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			data := encodeJPEG(t, testCase.img)
+			compressed, err := frame.NewJPEG(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			params, err := NewParams()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !params.Passthrough(frame.FormatMJPEG) {
+				t.Fatal("Expected MJPEG to be passed through")
+			}
+			e := newTestEncoder(t, params, compressed)
+			defer e.Close()
+
+			f, err := e.ReadFrame()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if passthrough := bytes.Equal(data, f.Data); passthrough != testCase.passthrough {
+				t.Errorf("Expected passthrough to be %v, got %v", testCase.passthrough, passthrough)
+			}
+			if _, err := parseJPEG(f.Data); err != nil {
+				t.Errorf("Expected the frame to be carried by RTP, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEncoderSetBitRate(t *testing.T) {
+	params, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.Quality = 90
+	e := newTestEncoder(t, params, newNoiseImage(320, 240))
+	defer e.Close()
+
+	read := func() codec.EncodedFrame {
+		f, err := e.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	initialSize := read().Size()
+
+	const frameSize = 30000
+	if err := e.SetBitRate(frameSize * 8 * 30); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		read()
+	}
+	if size := read().Size(); size > frameSize*11/10 || size > initialSize {
+		t.Errorf("Expected the frame size to be lowered to %d bytes from %d bytes, got %d bytes", frameSize, initialSize, size)
+	}
+	lowered := e.quality
+
+	if err := e.SetBitRate(initialSize * 2 * 8 * 30); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		read()
+	}
+	if e.quality <= lowered || e.quality > params.Quality {
+		t.Errorf("Expected the quality to be raised from %d up to %d, got %d", lowered, params.Quality, e.quality)
+	}
+
+	if err := e.SetBitRate(0); err == nil {
+		t.Error("Expected an error for zero bitrate")
+	}
+}
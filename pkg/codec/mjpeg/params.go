@@ -0,0 +1,43 @@
+package mjpeg
+
+import (
+	"image/jpeg"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// Params stores Motion JPEG specific encoding parameters.
+// KeyFrameInterval is ignored since every frame is a key frame.
+type Params struct {
+	codec.BaseParams
+	// Quality is the JPEG quality from 1 to 100. If BitRate is set, it's the maximum quality, and the
+	// quality of each frame is lowered from it to keep the bitrate.
+	Quality int
+}
+
+// NewParams returns default Motion JPEG codec specific parameters.
+// BitRate is 0 by default, so that the frames are encoded at a constant quality.
+func NewParams() (Params, error) {
+	return Params{
+		Quality: jpeg.DefaultQuality,
+	}, nil
+}
+
+// RTPCodec represents the codec metadata
+func (p *Params) RTPCodec() *codec.RTPCodec {
+	return newRTPCodec()
+}
+
+// BuildVideoEncoder builds Motion JPEG encoder with given params
+func (p *Params) BuildVideoEncoder(r video.Reader, property prop.Media) (codec.ReadCloser, error) {
+	return newEncoder(r, property, *p)
+}
+
+// Passthrough returns true for frame.FormatMJPEG, since the frames compressed by the camera are sent as
+// they are, as long as they can be carried by RTP.
+func (p *Params) Passthrough(f frame.Format) bool {
+	return f == frame.FormatMJPEG
+}
@@ -0,0 +1,249 @@
+package mjpeg
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+// This file implements the RTP payload format for JPEG.
+// Reference: https://tools.ietf.org/html/rfc2435
+
+const (
+	// payloadType is the static RTP payload type of JPEG.
+	payloadType = 26
+	// clockRate is the RTP clock rate of JPEG.
+	clockRate = 90000
+	// maxSize is the maximum width and height which can be represented by the RTP header.
+	maxSize = 255 * 8
+
+	// dynamicQ is the Q value which tells that the quantization tables are sent in the payload.
+	dynamicQ = 255
+	// typeRestart is added to the type if the image has restart markers.
+	typeRestart = 64
+
+	mainHeaderSize    = 8
+	restartHeaderSize = 4
+)
+
+// JPEG markers.
+const (
+	markerSOI  = 0xd8
+	markerSOF0 = 0xc0
+	markerDHT  = 0xc4
+	markerDQT  = 0xdb
+	markerDRI  = 0xdd
+	markerSOS  = 0xda
+	markerRST0 = 0xd0
+	markerRST7 = 0xd7
+)
+
+var (
+	errInvalidJPEG     = errors.New("mjpeg: invalid JPEG")
+	errUnsupportedJPEG = errors.New("mjpeg: JPEG can't be carried by RTP")
+)
+
+// jpegHeader is the information of a JPEG image which is carried by the RTP headers.
+// The Huffman tables are not carried, so the image is assumed to use the tables of the JPEG specification,
+// which are used by image/jpeg and most of the cameras.
+type jpegHeader struct {
+	// typ is 0 for YUV 4:2:2, and 1 for YUV 4:2:0.
+	typ           uint8
+	width, height int
+	// quantTables are the 8 bits quantization tables of the luminance and the chrominance in zigzag order.
+	quantTables     [2][]byte
+	restartInterval uint16
+	// scan is the entropy-coded data of the image.
+	scan []byte
+}
+
+// parseJPEG parses a baseline JPEG image, which consists of a scan of interleaved Y, Cb and Cr.
+func parseJPEG(data []byte) (*jpegHeader, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != markerSOI {
+		return nil, errInvalidJPEG
+	}
+
+	h := &jpegHeader{}
+	var tables [4][]byte
+	var lumaTable, chromaTable int
+	var hasFrame bool
+	pos := 2
+	for {
+		if pos+4 > len(data) || data[pos] != 0xff {
+			return nil, errInvalidJPEG
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			// Fill byte
+			pos++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, errInvalidJPEG
+		}
+		segment := data[pos+4 : pos+2+length]
+		pos += 2 + length
+
+		switch {
+		case marker == markerDQT:
+			for len(segment) > 0 {
+				precision, id := segment[0]>>4, segment[0]&0x0f
+				if precision != 0 {
+					return nil, errUnsupportedJPEG
+				}
+				if id >= uint8(len(tables)) || len(segment) < 65 {
+					return nil, errInvalidJPEG
+				}
+				tables[id] = segment[1:65]
+				segment = segment[65:]
+			}
+
+		case marker == markerSOF0:
+			if len(segment) < 6 {
+				return nil, errInvalidJPEG
+			}
+			if segment[0] != 8 || segment[5] != 3 {
+				return nil, errUnsupportedJPEG
+			}
+			if len(segment) < 6+3*3 {
+				return nil, errInvalidJPEG
+			}
+			h.height = int(binary.BigEndian.Uint16(segment[1:]))
+			h.width = int(binary.BigEndian.Uint16(segment[3:]))
+			y, cb, cr := segment[6:9], segment[9:12], segment[12:15]
+			switch y[1] {
+			case 0x21:
+				h.typ = 0
+			case 0x22:
+				h.typ = 1
+			default:
+				return nil, errUnsupportedJPEG
+			}
+			if cb[1] != 0x11 || cr[1] != 0x11 || cb[2] != cr[2] {
+				return nil, errUnsupportedJPEG
+			}
+			lumaTable, chromaTable = int(y[2]), int(cb[2])
+			hasFrame = true
+
+		case marker > markerSOF0 && marker <= 0xcf && marker != markerDHT && marker != 0xc8 && marker != 0xcc:
+			// Other than baseline, e.g. progressive or arithmetic coding.
+			return nil, errUnsupportedJPEG
+
+		case marker == markerDRI:
+			if len(segment) < 2 {
+				return nil, errInvalidJPEG
+			}
+			h.restartInterval = binary.BigEndian.Uint16(segment)
+
+		case marker == markerSOS:
+			if !hasFrame || len(segment) < 1 {
+				return nil, errInvalidJPEG
+			}
+			if segment[0] != 3 {
+				return nil, errUnsupportedJPEG
+			}
+			if lumaTable >= len(tables) || chromaTable >= len(tables) ||
+				tables[lumaTable] == nil || tables[chromaTable] == nil {
+				return nil, errInvalidJPEG
+			}
+			if h.width == 0 || h.height == 0 || h.width > maxSize || h.height > maxSize {
+				return nil, errUnsupportedJPEG
+			}
+			h.quantTables = [2][]byte{tables[lumaTable], tables[chromaTable]}
+			h.scan = scanData(data[pos:])
+			return h, nil
+		}
+	}
+}
+
+// scanData returns the entropy-coded data at the head of data, which ends at a marker other than RSTn.
+func scanData(data []byte) []byte {
+	for i := 0; i+1 < len(data); i++ {
+		if data[i] != 0xff {
+			continue
+		}
+		if next := data[i+1]; next != 0 && (next < markerRST0 || markerRST7 < next) {
+			return data[:i]
+		}
+	}
+	return data
+}
+
+func newRTPCodec() *codec.RTPCodec {
+	return &codec.RTPCodec{
+		RTPCodec: webrtc.NewRTPCodec(
+			webrtc.RTPCodecTypeVideo,
+			"JPEG",
+			clockRate,
+			0,
+			"",
+			payloadType,
+			&payloader{},
+		),
+	}
+}
+
+// payloader splits a JPEG image into RTP payloads. The quantization tables are sent in the first payload
+// of every image. The images which can't be carried by RTP are dropped.
+type payloader struct{}
+
+func (p *payloader) Payload(mtu int, payload []byte) [][]byte {
+	h, err := parseJPEG(payload)
+	if err != nil {
+		return nil
+	}
+
+	typ := h.typ
+	var restartHeader []byte
+	if h.restartInterval > 0 {
+		typ += typeRestart
+		// The payloads are not aligned to the restart intervals, so F and L bits are set,
+		// and the restart count is 0x3fff.
+		restartHeader = make([]byte, restartHeaderSize)
+		binary.BigEndian.PutUint16(restartHeader, h.restartInterval)
+		binary.BigEndian.PutUint16(restartHeader[2:], 0xffff)
+	}
+
+	tablesSize := len(h.quantTables[0]) + len(h.quantTables[1])
+	quantHeader := make([]byte, 4, 4+tablesSize)
+	// MBZ and Precision are 0, since the tables are 8 bits.
+	binary.BigEndian.PutUint16(quantHeader[2:], uint16(tablesSize))
+	quantHeader = append(quantHeader, h.quantTables[0]...)
+	quantHeader = append(quantHeader, h.quantTables[1]...)
+
+	var payloads [][]byte
+	for offset := 0; offset < len(h.scan); {
+		headerSize := mainHeaderSize + len(restartHeader)
+		if offset == 0 {
+			headerSize += len(quantHeader)
+		}
+		n := mtu - headerSize
+		if n <= 0 {
+			return nil
+		}
+		if n > len(h.scan)-offset {
+			n = len(h.scan) - offset
+		}
+
+		out := make([]byte, headerSize+n)
+		// The type-specific field is 0 since the image is not interlaced.
+		out[1], out[2], out[3] = byte(offset>>16), byte(offset>>8), byte(offset)
+		out[4] = typ
+		out[5] = dynamicQ
+		out[6] = byte((h.width + 7) / 8)
+		out[7] = byte((h.height + 7) / 8)
+		i := mainHeaderSize
+		i += copy(out[i:], restartHeader)
+		if offset == 0 {
+			i += copy(out[i:], quantHeader)
+		}
+		copy(out[i:], h.scan[offset:offset+n])
+
+		payloads = append(payloads, out)
+		offset += n
+	}
+	return payloads
+}
@@ -0,0 +1,205 @@
+package mjpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func newNoiseImage(w, h int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	r := rand.New(rand.NewSource(0))
+	r.Read(img.Y)
+	r.Read(img.Cb)
+	r.Read(img.Cr)
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// insertSegment inserts a marker segment before SOS.
+func insertSegment(data []byte, marker byte, segment []byte) []byte {
+	i := bytes.Index(data, []byte{0xff, markerSOS})
+	out := append([]byte{}, data[:i]...)
+	out = append(out, 0xff, marker, byte((len(segment)+2)>>8), byte(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, data[i:]...)
+}
+
+// huffmanTables returns the DHT segments of data.
+func huffmanTables(data []byte) []byte {
+	var tables []byte
+	for pos := 2; pos+4 <= len(data) && data[pos+1] != markerSOS; {
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if data[pos+1] == markerDHT {
+			tables = append(tables, data[pos:pos+2+length]...)
+		}
+		pos += 2 + length
+	}
+	return tables
+}
+
+// depayload reconstructs a JPEG image from the payloads of type 1 as described in RFC 2435 Appendix A.
+// The Huffman tables are given by dht.
+func depayload(t *testing.T, payloads [][]byte, dht []byte) []byte {
+	var scan, tables []byte
+	var width, height int
+	for i, p := range payloads {
+		if offset := int(p[1])<<16 | int(p[2])<<8 | int(p[3]); offset != len(scan) {
+			t.Fatalf("Expected fragment offset of payload[%d] to be %d, got %d", i, len(scan), offset)
+		}
+		if p[4] != 1 {
+			t.Fatalf("Expected type 1, got %d", p[4])
+		}
+		if p[5] != dynamicQ {
+			t.Fatalf("Expected Q %d, got %d", dynamicQ, p[5])
+		}
+		width, height = int(p[6])*8, int(p[7])*8
+		p = p[mainHeaderSize:]
+		if i == 0 {
+			length := int(binary.BigEndian.Uint16(p[2:]))
+			if p[0] != 0 || p[1] != 0 || length != 128 {
+				t.Fatalf("Invalid quantization table header: %v", p[:4])
+			}
+			tables = p[4 : 4+length]
+			p = p[4+length:]
+		}
+		scan = append(scan, p...)
+	}
+
+	out := []byte{0xff, markerSOI}
+	out = append(out, 0xff, markerDQT, 0, 2+2*65, 0)
+	out = append(out, tables[:64]...)
+	out = append(out, 1)
+	out = append(out, tables[64:]...)
+	out = append(out, 0xff, markerSOF0, 0, 17, 8,
+		byte(height>>8), byte(height), byte(width>>8), byte(width), 3,
+		1, 0x22, 0, 2, 0x11, 1, 3, 0x11, 1,
+	)
+	out = append(out, dht...)
+	out = append(out, 0xff, markerSOS, 0, 12, 3, 1, 0x00, 2, 0x11, 3, 0x11, 0, 63, 0)
+	out = append(out, scan...)
+	return append(out, 0xff, 0xd9)
+}
+
+func TestPayloader(t *testing.T) {
+	data := encodeJPEG(t, newNoiseImage(64, 48))
+
+	p := &payloader{}
+	payloads := p.Payload(200, data)
+	if len(payloads) < 2 {
+		t.Fatalf("Expected the image to be fragmented, got %d payloads", len(payloads))
+	}
+	for i, payload := range payloads {
+		if len(payload) > 200 {
+			t.Errorf("Expected payload[%d] to fit in MTU, got %d bytes", i, len(payload))
+		}
+	}
+
+	reconstructed := depayload(t, payloads, huffmanTables(data))
+	expected, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := jpeg.Decode(bytes.NewReader(reconstructed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Error("Expected the reconstructed image to be the same as the original")
+	}
+}
+
+func TestPayloaderRestartMarker(t *testing.T) {
+	data := insertSegment(encodeJPEG(t, newNoiseImage(64, 48)), markerDRI, []byte{0, 4})
+
+	p := &payloader{}
+	payloads := p.Payload(200, data)
+	if len(payloads) < 2 {
+		t.Fatalf("Expected the image to be fragmented, got %d payloads", len(payloads))
+	}
+	for i, payload := range payloads {
+		if payload[4] != 1+typeRestart {
+			t.Errorf("Expected type of payload[%d] to be %d, got %d", i, 1+typeRestart, payload[4])
+		}
+		expected := []byte{0, 4, 0xff, 0xff}
+		if header := payload[mainHeaderSize : mainHeaderSize+restartHeaderSize]; !bytes.Equal(expected, header) {
+			t.Errorf("Expected restart marker header of payload[%d] to be %v, got %v", i, expected, header)
+		}
+	}
+}
+
+func TestParseJPEG(t *testing.T) {
+	data := encodeJPEG(t, newNoiseImage(64, 48))
+	sof := bytes.Index(data, []byte{0xff, markerSOF0})
+	modify := func(f func(data []byte)) []byte {
+		modified := append([]byte{}, data...)
+		f(modified)
+		return modified
+	}
+
+	testCases := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"YUV420": {
+			data: data,
+		},
+		"YUV422": {
+			data: modify(func(d []byte) { d[sof+11] = 0x21 }),
+		},
+		"YUV444": {
+			data: modify(func(d []byte) { d[sof+11] = 0x11 }),
+			err:  errUnsupportedJPEG,
+		},
+		"Grayscale": {
+			data: encodeJPEG(t, image.NewGray(image.Rect(0, 0, 64, 48))),
+			err:  errUnsupportedJPEG,
+		},
+		"Progressive": {
+			data: modify(func(d []byte) { d[sof+1] = 0xc2 }),
+			err:  errUnsupportedJPEG,
+		},
+		"TooLarge": {
+			data: encodeJPEG(t, image.NewYCbCr(image.Rect(0, 0, maxSize+8, 8), image.YCbCrSubsampleRatio420)),
+			err:  errUnsupportedJPEG,
+		},
+		"Truncated": {
+			data: data[:sof+8],
+			err:  errInvalidJPEG,
+		},
+		"NotJPEG": {
+			data: []byte{0x00, 0x01, 0x02},
+			err:  errInvalidJPEG,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			h, err := parseJPEG(testCase.data)
+			if err != testCase.err {
+				t.Fatalf("Expected error %v, got %v", testCase.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if h.width != 64 || h.height != 48 {
+				t.Errorf("Expected 64x48, got %dx%d", h.width, h.height)
+			}
+			if len(h.scan) == 0 || bytes.HasSuffix(h.scan, []byte{0xff, 0xd9}) {
+				t.Errorf("Expected the scan without EOI, got %d bytes", len(h.scan))
+			}
+		})
+	}
+}
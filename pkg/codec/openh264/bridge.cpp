@@ -74,7 +74,7 @@ void enc_free(Encoder *e, int *eresult) {
 
 // There's a good reference from ffmpeg in using the encode_frame
 // Reference: https://ffmpeg.org/doxygen/2.6/libopenh264enc_8c_source.html
-Slice enc_encode(Encoder *e, Frame f, int *eresult) {
+Slice enc_encode(Encoder *e, Frame f, int force_key_frame, int *eresult) {
   int rv;
   SSourcePicture pic = {0};
   SFrameBSInfo info = {0};
@@ -90,6 +90,14 @@ Slice enc_encode(Encoder *e, Frame f, int *eresult) {
   pic.pData[1] = (unsigned char *)f.u;
   pic.pData[2] = (unsigned char *)f.v;
 
+  if (force_key_frame) {
+    rv = e->engine->ForceIntraFrame(true);
+    if (rv != 0) {
+      *eresult = rv;
+      return payload;
+    }
+  }
+
   rv = e->engine->EncodeFrame(&pic, &info);
   if (rv != 0) {
     *eresult = rv;
@@ -118,5 +126,94 @@ Slice enc_encode(Encoder *e, Frame f, int *eresult) {
 
   payload.data = e->buff;
   payload.data_len = size;
+  payload.key_frame = info.eFrameType == videoFrameTypeIDR;
   return payload;
 }
+
+void enc_set_bitrate(Encoder *e, int bitrate, int *eresult) {
+  int rv;
+  SBitrateInfo info;
+  info.iLayer = SPATIAL_LAYER_ALL;
+  info.iBitrate = bitrate;
+
+  // The target bitrate can't exceed the max bitrate, so the max bitrate is
+  // raised first and lowered last.
+  ENCODER_OPTION first = ENCODER_OPTION_MAX_BITRATE;
+  ENCODER_OPTION second = ENCODER_OPTION_BITRATE;
+  if (bitrate < e->params.iTargetBitrate) {
+    first = ENCODER_OPTION_BITRATE;
+    second = ENCODER_OPTION_MAX_BITRATE;
+  }
+
+  rv = e->engine->SetOption(first, &info);
+  if (rv != 0) {
+    *eresult = rv;
+    return;
+  }
+  rv = e->engine->SetOption(second, &info);
+  if (rv != 0) {
+    *eresult = rv;
+    return;
+  }
+  e->params.iTargetBitrate = bitrate;
+  e->params.iMaxBitrate = bitrate;
+}
+
+Decoder *dec_new(int *eresult) {
+  int rv;
+  ISVCDecoder *engine;
+  SDecodingParam params = {0};
+
+  rv = WelsCreateDecoder(&engine);
+  if (rv != 0) {
+    *eresult = rv;
+    return NULL;
+  }
+
+  params.sVideoProperty.eVideoBsType = VIDEO_BITSTREAM_AVC;
+  rv = engine->Initialize(&params);
+  if (rv != 0) {
+    WelsDestroyDecoder(engine);
+    *eresult = rv;
+    return NULL;
+  }
+
+  Decoder *decoder = (Decoder *)malloc(sizeof(Decoder));
+  decoder->engine = engine;
+  return decoder;
+}
+
+void dec_free(Decoder *d, int *eresult) {
+  int rv = d->engine->Uninitialize();
+  if (rv != 0) {
+    *eresult = rv;
+    return;
+  }
+
+  WelsDestroyDecoder(d->engine);
+  free(d);
+}
+
+// The planes of the decoded frame are owned by the decoder, and valid until
+// the next call.
+DecodedFrame dec_decode(Decoder *d, const unsigned char *data, int data_len,
+                        int *estate) {
+  unsigned char *planes[3] = {0};
+  SBufferInfo info = {0};
+  DecodedFrame frame = {0};
+
+  *estate = d->engine->DecodeFrameNoDelay(data, data_len, planes, &info);
+  if (info.iBufferStatus != 1) {
+    return frame;
+  }
+
+  frame.y = planes[0];
+  frame.u = planes[1];
+  frame.v = planes[2];
+  frame.y_stride = info.UsrData.sSystemBuffer.iStride[0];
+  frame.uv_stride = info.UsrData.sSystemBuffer.iStride[1];
+  frame.width = info.UsrData.sSystemBuffer.iWidth;
+  frame.height = info.UsrData.sSystemBuffer.iHeight;
+  frame.ready = 1;
+  return frame;
+}
@@ -8,6 +8,7 @@ extern "C" {
 typedef struct Slice {
   unsigned char *data;
   int data_len;
+  int key_frame;
 } Slice;
 
 typedef struct Frame {
@@ -29,9 +30,25 @@ typedef struct Encoder {
   int buff_size;
 } Encoder;
 
+typedef struct Decoder {
+  ISVCDecoder *engine;
+} Decoder;
+
+typedef struct DecodedFrame {
+  unsigned char *y, *u, *v;
+  int y_stride, uv_stride;
+  int height;
+  int width;
+  int ready;
+} DecodedFrame;
+
 Encoder *enc_new(const EncoderOptions params, int *eresult);
 void enc_free(Encoder *e, int *eresult);
-Slice enc_encode(Encoder *e, Frame f, int *eresult);
+Slice enc_encode(Encoder *e, Frame f, int force_key_frame, int *eresult);
+void enc_set_bitrate(Encoder *e, int bitrate, int *eresult);
+Decoder *dec_new(int *eresult);
+void dec_free(Decoder *d, int *eresult);
+DecodedFrame dec_decode(Decoder *d, const unsigned char *data, int data_len, int *estate);
 #ifdef __cplusplus
 }
 #endif
@@ -0,0 +1,101 @@
+package openh264
+
+// #include <openh264/codec_api.h>
+// #include "bridge.hpp"
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"sync"
+	"unsafe"
+
+	"github.com/pion/mediadevices/pkg/codec"
+)
+
+type decoder struct {
+	engine *C.Decoder
+	r      codec.FrameReader
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newDecoder(r codec.FrameReader) (codec.VideoDecoder, error) {
+	var rv C.int
+	cDecoder := C.dec_new(&rv)
+	if err := errResult(rv); err != nil {
+		return nil, fmt.Errorf("failed in creating decoder: %v", err)
+	}
+
+	return &decoder{
+		engine: cDecoder,
+		r:      r,
+	}, nil
+}
+
+// Read decodes the access units in Annex B format read from the source until a picture is output.
+// The pictures are output without delay, so the access units without pictures, e.g. only with
+// the parameter sets, are skipped.
+func (d *decoder) Read() (image.Image, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, io.EOF
+	}
+
+	for {
+		f, err := d.r.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		if len(f.Data) == 0 {
+			// The encoder skipped the frame.
+			continue
+		}
+
+		var state C.int
+		frame := C.dec_decode(d.engine, (*C.uchar)(&f.Data[0]), C.int(len(f.Data)), &state)
+		if frame.ready == 0 {
+			if state != 0 {
+				return nil, fmt.Errorf("failed in decoding: decoding state 0x%x", int(state))
+			}
+			continue
+		}
+
+		// Errors are concealed if the picture is output.
+		return copyFrame(frame), nil
+	}
+}
+
+// copyFrame copies the picture from the buffer of the decoder.
+func copyFrame(frame C.DecodedFrame) *image.YCbCr {
+	w, h := int(frame.width), int(frame.height)
+	yStride, cStride := int(frame.y_stride), int(frame.uv_stride)
+	cHeight := (h + 1) / 2
+	return &image.YCbCr{
+		Y:              C.GoBytes(unsafe.Pointer(frame.y), C.int(yStride*h)),
+		Cb:             C.GoBytes(unsafe.Pointer(frame.u), C.int(cStride*cHeight)),
+		Cr:             C.GoBytes(unsafe.Pointer(frame.v), C.int(cStride*cHeight)),
+		YStride:        yStride,
+		CStride:        cStride,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, w, h),
+	}
+}
+
+func (d *decoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
+	var rv C.int
+	C.dec_free(d.engine, &rv)
+	return errResult(rv)
+}
@@ -13,6 +13,7 @@ import (
 	"image"
 	"io"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/pion/mediadevices/pkg/codec"
@@ -28,6 +29,9 @@ type encoder struct {
 
 	mu     sync.Mutex
 	closed bool
+
+	requireKeyFrame bool
+	tLastFrame      time.Time
 }
 
 func newEncoder(r video.Reader, p prop.Media, params Params) (codec.ReadCloser, error) {
@@ -69,13 +73,45 @@ func (e *encoder) Read(p []byte) (n int, err error) {
 		return n, err
 	}
 
-	img, err := e.r.Read()
+	frame, err := e.readFrame()
 	if err != nil {
 		return 0, err
 	}
 
+	n, err = mio.Copy(p, frame.Data)
+	if err != nil {
+		e.buff = frame.Data
+	}
+
+	return n, err
+}
+
+// ReadFrame encodes the next frame and returns it with its metadata.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return codec.EncodedFrame{}, io.EOF
+	}
+
+	return e.readFrame()
+}
+
+func (e *encoder) readFrame() (codec.EncodedFrame, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+	t := video.Timestamp(e.r)
+
 	yuvImg := img.(*image.YCbCr)
 	bounds := yuvImg.Bounds()
+	var forceKeyFrame C.int
+	if e.requireKeyFrame {
+		forceKeyFrame = 1
+	}
+
 	var rv C.int
 	s := C.enc_encode(e.engine, C.Frame{
 		y:      unsafe.Pointer(&yuvImg.Y[0]),
@@ -83,32 +119,68 @@ func (e *encoder) Read(p []byte) (n int, err error) {
 		v:      unsafe.Pointer(&yuvImg.Cr[0]),
 		height: C.int(bounds.Max.Y - bounds.Min.Y),
 		width:  C.int(bounds.Max.X - bounds.Min.X),
-	}, &rv)
+	}, forceKeyFrame, &rv)
 	if err := errResult(rv); err != nil {
-		return 0, fmt.Errorf("failed in encoding: %v", err)
+		return codec.EncodedFrame{}, fmt.Errorf("failed in encoding: %v", err)
 	}
+	e.requireKeyFrame = false
 
-	encoded := C.GoBytes(unsafe.Pointer(s.data), s.data_len)
-	n, err = mio.Copy(p, encoded)
-	if err != nil {
-		e.buff = encoded
+	var duration time.Duration
+	if !e.tLastFrame.IsZero() {
+		duration = t.Sub(e.tLastFrame)
 	}
+	e.tLastFrame = t
 
-	return n, err
+	return codec.EncodedFrame{
+		Data:      C.GoBytes(unsafe.Pointer(s.data), s.data_len),
+		KeyFrame:  s.key_frame != 0,
+		Timestamp: t,
+		Duration:  duration,
+	}, nil
 }
 
+// SetBitRate updates the target and the max bitrate of the running encoder.
+// The new value takes effect from the next encoded frame.
 func (e *encoder) SetBitRate(b int) error {
-	panic("SetBitRate is not implemented")
+	if b <= 0 {
+		return fmt.Errorf("invalid bitrate: %d", b)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	var rv C.int
+	C.enc_set_bitrate(e.engine, C.int(b), &rv)
+	if err := errResult(rv); err != nil {
+		return fmt.Errorf("failed in setting bitrate: %v", err)
+	}
+	return nil
 }
 
+// ForceKeyFrame forces the next encoded frame to be an IDR frame.
 func (e *encoder) ForceKeyFrame() error {
-	panic("ForceKeyFrame is not implemented")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	e.requireKeyFrame = true
+	return nil
 }
 
 func (e *encoder) Close() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if e.closed {
+		return nil
+	}
 	e.closed = true
 
 	var rv C.int
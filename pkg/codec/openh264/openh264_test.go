@@ -0,0 +1,156 @@
+package openh264
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"testing"
+
+	codectest "github.com/pion/mediadevices/internal/codec"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// newEncoderTestCase returns openh264 to be checked by the tests shared by the codecs.
+func newEncoderTestCase(t *testing.T) codectest.VideoEncoderTestCase {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codectest.VideoEncoderTestCase{Builder: &p, Params: &p.BaseParams, IsKeyFrame: codectest.IsKeyFrameH264}
+}
+
+func TestSetBitRate(t *testing.T) {
+	// The rate control of openh264 can't follow the target closely on the noisy test source
+	// since the QP is limited, so only the ratio is checked.
+	before, after, err := newEncoderTestCase(t).VerifySetBitRate(0)
+	t.Logf("bitrate before: %.0f, after: %.0f", before, after)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetBitRateAfterClose(t *testing.T) {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := p.BuildVideoEncoder(
+		codectest.NewVideoReader(320, 240, 30),
+		prop.Media{Video: prop.Video{Width: 320, Height: 240, FrameRate: 30}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetBitRate(100000); err == nil {
+		t.Error("Expected error after close, but got nil")
+	}
+}
+
+func TestForceKeyFrame(t *testing.T) {
+	if err := newEncoderTestCase(t).VerifyForceKeyFrame(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	if err := newEncoderTestCase(t).VerifyReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Passthrough(frame.FormatH264) {
+		t.Fatal("Expected H.264 to be passed through")
+	}
+
+	// The H.264 frames from the camera are mixed with the black frames of a disabled track.
+	compressed := []byte{0, 0, 0, 1, 0x65, 0x88, 0x84}
+	var i int
+	r := video.ReaderFunc(func() (image.Image, error) {
+		i++
+		if i%2 == 1 {
+			return frame.NewH264(compressed, 320, 240), nil
+		}
+		return image.NewYCbCr(image.Rect(0, 0, 320, 240), image.YCbCrSubsampleRatio420), nil
+	})
+	e, err := p.BuildVideoEncoder(r, prop.Media{Video: prop.Video{Width: 320, Height: 240, FrameFormat: frame.FormatH264}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	for j := 0; j < 4; j++ {
+		f, err := e.(codec.FrameReader).ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if passthrough := bytes.Equal(f.Data, compressed); passthrough != (j%2 == 0) {
+			t.Errorf("Expected frame[%d] to be passed through: %v, got %v", j, j%2 == 0, passthrough)
+		}
+		if j == 1 && !codectest.IsKeyFrameH264(f.Data) {
+			t.Error("Expected the first encoded frame to be a key frame")
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.BitRate = 1000000
+
+	property := prop.Media{Video: prop.Video{Width: 320, Height: 240, FrameRate: 30}}
+	q, err := codectest.MeasureQuality(
+		codectest.NewVideoReader(320, 240, 30),
+		func(r video.Reader) (codec.ReadCloser, error) {
+			return p.BuildVideoEncoder(r, property)
+		},
+		func(r codec.FrameReader) (codec.VideoDecoder, error) {
+			return p.BuildVideoDecoder(r, property)
+		},
+		30,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("PSNR: %.2f dB, SSIM: %.4f", q.MinPSNR, q.MinSSIM)
+
+	// The noise area of the source lowers PSNR.
+	if q.MinPSNR < 20 {
+		t.Errorf("Expected PSNR to be 20 dB or higher, got %.2f dB", q.MinPSNR)
+	}
+	if q.MinSSIM < 0.9 {
+		t.Errorf("Expected SSIM to be 0.9 or higher, got %.4f", q.MinSSIM)
+	}
+}
+
+func TestDecoderClose(t *testing.T) {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := p.BuildVideoDecoder(codec.FrameReaderFunc(func() (codec.EncodedFrame, error) {
+		t.Fatal("Expected the source not to be read after Close")
+		return codec.EncodedFrame{}, nil
+	}), prop.Media{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF after Close, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package openh264
 
 import (
 	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
 )
@@ -27,5 +28,18 @@ func (p *Params) RTPCodec() *codec.RTPCodec {
 
 // BuildVideoEncoder builds openh264 encoder with given params
 func (p *Params) BuildVideoEncoder(r video.Reader, property prop.Media) (codec.ReadCloser, error) {
-	return newEncoder(r, property, *p)
+	return codec.NewPassthroughEncoder(r, frame.FormatH264, func(r video.Reader) (codec.ReadCloser, error) {
+		return newEncoder(r, property, *p)
+	})
+}
+
+// BuildVideoDecoder builds openh264 decoder, which decodes the access units in Annex B format
+func (p *Params) BuildVideoDecoder(r codec.FrameReader, property prop.Media) (codec.VideoDecoder, error) {
+	return newDecoder(r)
+}
+
+// Passthrough returns true for frame.FormatH264, since the H.264 frames compressed by the camera are sent
+// as they are. The other frames, e.g. the black frames of a disabled track, are encoded by openh264.
+func (p *Params) Passthrough(f frame.Format) bool {
+	return f == frame.FormatH264
 }
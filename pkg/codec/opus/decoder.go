@@ -0,0 +1,178 @@
+package opus
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lherman-cs/opus"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// maxPacketDuration is the longest duration of an opus packet.
+const maxPacketDuration = 120 * time.Millisecond
+
+type decoder struct {
+	engine     *opus.Decoder
+	r          codec.FrameReader
+	sampleRate int
+	channels   int
+	isFloat    bool
+	fec        bool
+	// defaultLoss is the number of the samples concealed if the duration of a lost frame is unknown.
+	defaultLoss int
+
+	// next is the frame read ahead to recover the lost frame by FEC, and err is the error on reading it.
+	next *codec.EncodedFrame
+	err  error
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newDecoder(r codec.FrameReader, p prop.Media, params Params) (codec.AudioDecoder, error) {
+	if p.SampleRate == 0 {
+		p.SampleRate = 48000
+	}
+	if p.ChannelCount == 0 {
+		p.ChannelCount = 2
+	}
+	if p.Latency == 0 {
+		p.Latency = 20 * time.Millisecond
+	}
+
+	engine, err := opus.NewDecoder(p.SampleRate, p.ChannelCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decoder{
+		engine:      engine,
+		r:           r,
+		sampleRate:  p.SampleRate,
+		channels:    p.ChannelCount,
+		isFloat:     p.IsFloat,
+		fec:         params.InBandFEC,
+		defaultLoss: int(p.Latency * time.Duration(p.SampleRate) / time.Second),
+	}, nil
+}
+
+// Read decodes the next packet. The lost packets are concealed by PLC, or recovered by FEC from the
+// following packet if InBandFEC is enabled. FEC needs to read the following packet ahead, so it adds
+// a packet of latency after a loss.
+func (d *decoder) Read() (wave.Audio, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, io.EOF
+	}
+
+	f, err := d.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Data) > 0 {
+		return d.decode(f.Data)
+	}
+
+	samples := int(f.Duration * time.Duration(d.sampleRate) / time.Second)
+	if samples == 0 {
+		if samples, err = d.engine.LastPacketDuration(); err != nil {
+			return nil, err
+		}
+	}
+	if samples == 0 {
+		samples = d.defaultLoss
+	}
+
+	var fecData []byte
+	if d.fec {
+		next, err := d.r.ReadFrame()
+		if err != nil {
+			// Conceal the lost packet, and return the error on the next read.
+			d.err = err
+		} else {
+			d.next = &next
+			fecData = next.Data
+		}
+	}
+	return d.conceal(samples, fecData)
+}
+
+func (d *decoder) readFrame() (codec.EncodedFrame, error) {
+	if d.next != nil {
+		f := *d.next
+		d.next = nil
+		return f, nil
+	}
+	if d.err != nil {
+		return codec.EncodedFrame{}, d.err
+	}
+	return d.r.ReadFrame()
+}
+
+func (d *decoder) decode(data []byte) (wave.Audio, error) {
+	maxSamples := int(maxPacketDuration * time.Duration(d.sampleRate) / time.Second)
+	if d.isFloat {
+		pcm := make([]float32, maxSamples*d.channels)
+		n, err := d.engine.DecodeFloat32(data, pcm)
+		if err != nil {
+			return nil, err
+		}
+		chunk := wave.NewFloat32Interleaved(wave.ChunkInfo{Len: n, Channels: d.channels, SamplingRate: d.sampleRate})
+		copy(chunk.Data, pcm)
+		return chunk, nil
+	}
+
+	pcm := make([]int16, maxSamples*d.channels)
+	n, err := d.engine.Decode(data, pcm)
+	if err != nil {
+		return nil, err
+	}
+	chunk := wave.NewInt16Interleaved(wave.ChunkInfo{Len: n, Channels: d.channels, SamplingRate: d.sampleRate})
+	copy(chunk.Data, pcm)
+	return chunk, nil
+}
+
+// conceal generates the samples of a lost packet from the FEC data in the following packet, or by PLC
+// if fecData is empty. libopus falls back to PLC if the packet has no FEC data.
+func (d *decoder) conceal(samples int, fecData []byte) (wave.Audio, error) {
+	info := wave.ChunkInfo{Len: samples, Channels: d.channels, SamplingRate: d.sampleRate}
+	if d.isFloat {
+		chunk := wave.NewFloat32Interleaved(info)
+		var err error
+		if len(fecData) > 0 {
+			err = d.engine.DecodeFECFloat32(fecData, chunk.Data)
+		} else {
+			err = d.engine.DecodePLCFloat32(chunk.Data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("opus: failed to conceal a lost packet: %v", err)
+		}
+		return chunk, nil
+	}
+
+	chunk := wave.NewInt16Interleaved(info)
+	var err error
+	if len(fecData) > 0 {
+		err = d.engine.DecodeFEC(fecData, chunk.Data)
+	} else {
+		err = d.engine.DecodePLC(chunk.Data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opus: failed to conceal a lost packet: %v", err)
+	}
+	return chunk, nil
+}
+
+func (d *decoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sync"
+	"time"
 
 	"github.com/lherman-cs/opus"
 	"github.com/pion/mediadevices/pkg/codec"
@@ -14,13 +16,35 @@ import (
 )
 
 type encoder struct {
-	engine *opus.Encoder
-	inBuff wave.Audio
-	reader audio.Reader
+	engine     *opus.Encoder
+	inBuff     wave.Audio
+	reader     audio.Reader
+	sampleRate int
+	frame      []byte
+
+	// mu protects engine since libopus encoder is not thread safe
+	mu sync.Mutex
 }
 
 var latencies = []float64{5, 10, 20, 40, 60}
 
+// maxPacketSize is the recommended size of the output buffer given to libopus.
+const maxPacketSize = 4000
+
+var applications = map[Application]opus.Application{
+	ApplicationVoIP:     opus.AppVoIP,
+	ApplicationAudio:    opus.AppAudio,
+	ApplicationLowDelay: opus.AppRestrictedLowdelay,
+}
+
+var bandwidths = map[Bandwidth]opus.Bandwidth{
+	BandwidthNarrowband:    opus.Narrowband,
+	BandwidthMediumband:    opus.Mediumband,
+	BandwidthWideband:      opus.Wideband,
+	BandwidthSuperWideband: opus.SuperWideband,
+	BandwidthFullband:      opus.Fullband,
+}
+
 func newEncoder(r audio.Reader, p prop.Media, params Params) (codec.ReadCloser, error) {
 	if p.SampleRate == 0 {
 		return nil, fmt.Errorf("opus: inProp.SampleRate is required")
@@ -55,19 +79,49 @@ func newEncoder(r audio.Reader, p prop.Media, params Params) (codec.ReadCloser,
 
 	channels := p.ChannelCount
 
-	engine, err := opus.NewEncoder(p.SampleRate, channels, opus.AppVoIP)
+	application, ok := applications[params.Application]
+	if !ok {
+		return nil, fmt.Errorf("opus: unknown application: %d", params.Application)
+	}
+
+	engine, err := opus.NewEncoder(p.SampleRate, channels, application)
 	if err != nil {
 		return nil, err
 	}
 	if err := engine.SetBitrate(params.BitRate); err != nil {
 		return nil, err
 	}
+	if err := engine.SetInBandFEC(params.InBandFEC); err != nil {
+		return nil, err
+	}
+	if err := engine.SetPacketLossPerc(params.PacketLossPercentage); err != nil {
+		return nil, err
+	}
+	if err := engine.SetDTX(params.DTX); err != nil {
+		return nil, err
+	}
+	if params.Complexity != 0 {
+		if err := engine.SetComplexity(params.Complexity); err != nil {
+			return nil, err
+		}
+	}
+	if params.MaxBandwidth != 0 {
+		bandwidth, ok := bandwidths[params.MaxBandwidth]
+		if !ok {
+			return nil, fmt.Errorf("opus: unknown bandwidth: %d", params.MaxBandwidth)
+		}
+		if err := engine.SetMaxBandwidth(bandwidth); err != nil {
+			return nil, err
+		}
+	}
 
 	rMix := audio.NewChannelMixer(channels, params.ChannelMixer)
 	rBuf := audio.NewBuffer(int(targetLatency * float64(p.SampleRate) / 1000))
 	e := encoder{
-		engine: engine,
-		reader: rMix(rBuf(r)),
+		engine:     engine,
+		reader:     rMix(rBuf(r)),
+		sampleRate: p.SampleRate,
+		frame:      make([]byte, maxPacketSize),
 	}
 	return &e, nil
 }
@@ -78,6 +132,40 @@ func (e *encoder) Read(p []byte) (int, error) {
 		return 0, err
 	}
 
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.encode(buff, p)
+}
+
+// ReadFrame encodes the next chunk of samples and returns it with its metadata.
+// Every opus packet can be decoded independently, so KeyFrame is always true.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	buff, err := e.reader.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+	t := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n, err := e.encode(buff, e.frame)
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+
+	data := make([]byte, n)
+	copy(data, e.frame[:n])
+	return codec.EncodedFrame{
+		Data:      data,
+		KeyFrame:  true,
+		Timestamp: t,
+		Duration:  time.Duration(buff.ChunkInfo().Len) * time.Second / time.Duration(e.sampleRate),
+	}, nil
+}
+
+func (e *encoder) encode(buff wave.Audio, p []byte) (int, error) {
 	switch b := buff.(type) {
 	case *wave.Int16Interleaved:
 		n, err := e.engine.Encode(b.Data, p)
@@ -96,12 +184,17 @@ func (e *encoder) Read(p []byte) (int, error) {
 	}
 }
 
+// SetBitRate updates the target bitrate of the running encoder.
 func (e *encoder) SetBitRate(b int) error {
-	panic("SetBitRate is not implemented")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.engine.SetBitrate(b)
 }
 
+// ForceKeyFrame does nothing since opus has no concept of key frames.
 func (e *encoder) ForceKeyFrame() error {
-	panic("ForceKeyFrame is not implemented")
+	return nil
 }
 
 func (e *encoder) Close() error {
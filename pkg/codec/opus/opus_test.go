@@ -0,0 +1,317 @@
+package opus
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	codectest "github.com/pion/mediadevices/internal/codec"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+func TestSetBitRate(t *testing.T) {
+	const (
+		initialBitRate = 64000
+		targetBitRate  = 16000
+		measureDur     = 3 * time.Second
+	)
+
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.BitRate = initialBitRate
+	p.Application = ApplicationAudio
+
+	e, err := p.BuildAudioEncoder(
+		codectest.NewAudioReader(48000, 1, 20*time.Millisecond),
+		prop.Media{
+			Audio: prop.Audio{
+				SampleRate:   48000,
+				ChannelCount: 1,
+				Latency:      20 * time.Millisecond,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	before, err := codectest.MeasureBitRate(e, measureDur)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.SetBitRate(targetBitRate); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := codectest.MeasureBitRate(e, measureDur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("bitrate before: %.0f, after: %.0f", before, after)
+
+	if after > before/2 {
+		t.Errorf("Expected bitrate to be lowered from %.0f, but got %.0f", before, after)
+	}
+	if after > targetBitRate*1.2 {
+		t.Errorf("Expected bitrate to be lower than %d, but got %.0f", targetBitRate, after)
+	}
+}
+
+func TestForceKeyFrame(t *testing.T) {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := p.BuildAudioEncoder(
+		codectest.NewAudioReader(48000, 1, 20*time.Millisecond),
+		prop.Media{
+			Audio: prop.Audio{
+				SampleRate:   48000,
+				ChannelCount: 1,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	if err := e.ForceKeyFrame(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestParams(t *testing.T) {
+	testCases := map[string]struct {
+		modify      func(*Params)
+		expectError bool
+	}{
+		"Default": {
+			modify: func(*Params) {},
+		},
+		"LowDelayWithFEC": {
+			modify: func(p *Params) {
+				p.Application = ApplicationLowDelay
+				p.InBandFEC = true
+				p.PacketLossPercentage = 10
+			},
+		},
+		"DTX": {
+			modify: func(p *Params) {
+				p.DTX = true
+				p.Complexity = 5
+				p.MaxBandwidth = BandwidthWideband
+			},
+		},
+		"InvalidApplication": {
+			modify: func(p *Params) {
+				p.Application = 100
+			},
+			expectError: true,
+		},
+		"InvalidBandwidth": {
+			modify: func(p *Params) {
+				p.MaxBandwidth = 100
+			},
+			expectError: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			p, err := NewParams()
+			if err != nil {
+				t.Fatal(err)
+			}
+			testCase.modify(&p)
+
+			e, err := p.BuildAudioEncoder(
+				codectest.NewAudioReader(48000, 1, 20*time.Millisecond),
+				prop.Media{
+					Audio: prop.Audio{
+						SampleRate:   48000,
+						ChannelCount: 1,
+					},
+				},
+			)
+			if testCase.expectError {
+				if err == nil {
+					t.Error("Expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer e.Close()
+
+			buf := make([]byte, 1024)
+			if _, err := e.Read(buf); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	const latency = 20 * time.Millisecond
+
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := p.BuildAudioEncoder(
+		codectest.NewAudioReader(48000, 1, latency),
+		prop.Media{
+			Audio: prop.Audio{
+				SampleRate:   48000,
+				ChannelCount: 1,
+				Latency:      latency,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	r, ok := e.(codec.FrameReader)
+	if !ok {
+		t.Fatal("Expected the encoder to implement codec.FrameReader")
+	}
+
+	var prev codec.EncodedFrame
+	for i := 0; i < 10; i++ {
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.Size() == 0 {
+			t.Errorf("Expected frame %d to have data", i)
+		}
+		if !frame.KeyFrame {
+			t.Errorf("Expected frame %d to be a key frame", i)
+		}
+		if frame.Duration != latency {
+			t.Errorf("Expected duration of frame %d to be %v, got %v", i, latency, frame.Duration)
+		}
+		if i > 0 && frame.Timestamp.Before(prev.Timestamp) {
+			t.Errorf("Expected timestamp of frame %d to be after %v, got %v", i, prev.Timestamp, frame.Timestamp)
+		}
+		prev = frame
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	const (
+		latency   = 20 * time.Millisecond
+		numFrames = 20
+		// expectedRMS is the RMS of the sine wave of the source.
+		expectedRMS = 0.25 / math.Sqrt2
+	)
+
+	testCases := map[string]struct {
+		isFloat bool
+		fec     bool
+		// lost returns true if the i-th frame is lost.
+		lost func(i int) bool
+	}{
+		"Int16": {
+			lost: func(int) bool { return false },
+		},
+		"Float32": {
+			isFloat: true,
+			lost:    func(int) bool { return false },
+		},
+		"PLC": {
+			lost: func(i int) bool { return i%5 == 4 },
+		},
+		"FEC": {
+			isFloat: true,
+			fec:     true,
+			lost:    func(i int) bool { return i%5 == 4 },
+		},
+		"FECConsecutiveLoss": {
+			fec:  true,
+			lost: func(i int) bool { return i%5 >= 3 },
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			p, err := NewParams()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.BitRate = 64000
+			p.InBandFEC = testCase.fec
+			p.PacketLossPercentage = 20
+
+			property := prop.Media{
+				Audio: prop.Audio{
+					SampleRate:   48000,
+					ChannelCount: 1,
+					Latency:      latency,
+					IsFloat:      testCase.isFloat,
+				},
+			}
+			e, err := p.BuildAudioEncoder(codectest.NewAudioReader(48000, 1, latency), property)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+
+			var i int
+			d, err := p.BuildAudioDecoder(codec.FrameReaderFunc(func() (codec.EncodedFrame, error) {
+				f, err := e.(codec.FrameReader).ReadFrame()
+				if testCase.lost(i) {
+					f.Data = nil
+				}
+				i++
+				return f, err
+			}), property)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			for j := 0; j < numFrames; j++ {
+				chunk, err := d.Read()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := chunk.(*wave.Float32Interleaved); ok != testCase.isFloat {
+					t.Fatalf("Expected float output to be %v, got %T", testCase.isFloat, chunk)
+				}
+				info := chunk.ChunkInfo()
+				if info.Len != 960 || info.Channels != 1 || info.SamplingRate != 48000 {
+					t.Fatalf("Expected 960 samples in 1 channel at 48000 Hz, got %+v", info)
+				}
+				if j == 0 {
+					// The first frame includes the delay of the codec.
+					continue
+				}
+
+				var sum float64
+				for k := 0; k < info.Len; k++ {
+					v := float64(wave.Float32SampleFormat.Convert(chunk.At(k, 0)).(wave.Float32Sample))
+					sum += v * v
+				}
+				rms := math.Sqrt(sum / float64(info.Len))
+				if rms < expectedRMS/2 || expectedRMS*3/2 < rms {
+					t.Errorf("Expected RMS of frame %d (lost: %v) to be around %.3f, got %.3f", j, testCase.lost(j), expectedRMS, rms)
+				}
+			}
+		})
+	}
+}
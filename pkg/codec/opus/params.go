@@ -12,8 +12,50 @@ type Params struct {
 	codec.BaseParams
 	// ChannelMixer is a mixer to be used if number of given and expected channels differ.
 	ChannelMixer mixer.ChannelMixer
+
+	// Application is an intended application of the encoder.
+	Application Application
+	// InBandFEC enables in-band forward error correction.
+	// The redundant data is sent only when PacketLossPercentage is greater than 0.
+	// The decoder recovers the lost packets from the redundant data in the following packets.
+	InBandFEC bool
+	// PacketLossPercentage is an expected packet loss percentage ranging from 0 to 100.
+	PacketLossPercentage int
+	// DTX enables discontinuous transmission which reduces the bitrate during silence.
+	DTX bool
+	// Complexity is a computational complexity ranging from 1 to 10.
+	// Zero uses the libopus default.
+	Complexity int
+	// MaxBandwidth limits the audio bandwidth which the encoder selects automatically.
+	// Zero doesn't limit the bandwidth.
+	MaxBandwidth Bandwidth
 }
 
+// Application represents an intended application of the encoder.
+type Application int
+
+// Application values.
+const (
+	// ApplicationVoIP gives the best quality for speech.
+	ApplicationVoIP Application = iota
+	// ApplicationAudio gives the best quality for music and mixed contents.
+	ApplicationAudio
+	// ApplicationLowDelay minimizes the coding delay by disabling speech-optimized modes.
+	ApplicationLowDelay
+)
+
+// Bandwidth represents an audio bandpass.
+type Bandwidth int
+
+// Bandwidth values.
+const (
+	BandwidthNarrowband    Bandwidth = iota + 1 // 4 kHz passband
+	BandwidthMediumband                         // 6 kHz passband
+	BandwidthWideband                           // 8 kHz passband
+	BandwidthSuperWideband                      // 12 kHz passband
+	BandwidthFullband                           // 20 kHz passband
+)
+
 // NewParams returns default opus codec specific parameters.
 func NewParams() (Params, error) {
 	return Params{}, nil
@@ -28,3 +70,10 @@ func (p *Params) RTPCodec() *codec.RTPCodec {
 func (p *Params) BuildAudioEncoder(r audio.Reader, property prop.Media) (codec.ReadCloser, error) {
 	return newEncoder(r, property, *p)
 }
+
+// BuildAudioDecoder builds opus decoder with given params. The samples are output at SampleRate in
+// ChannelCount channels of property, which are 48000 Hz and 2 channels if they are zero. They are
+// wave.Float32Interleaved if IsFloat is true, and wave.Int16Interleaved otherwise.
+func (p *Params) BuildAudioDecoder(r codec.FrameReader, property prop.Media) (codec.AudioDecoder, error) {
+	return newDecoder(r, property, *p)
+}
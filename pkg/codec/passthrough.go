@@ -0,0 +1,199 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+var errPassthroughBitRate = errors.New("the bitrate of the frames passed through can't be changed")
+
+// passthroughEncoder sends the frames compressed in format as they are, and encodes the other frames by
+// the encoder, e.g. the black frames of a disabled track.
+type passthroughEncoder struct {
+	r       video.Reader
+	format  frame.Format
+	encoder ReadCloser
+	// img is the image which the encoder reads next, and t is its capture time.
+	img image.Image
+	t   time.Time
+
+	buff       []byte
+	tLastFrame time.Time
+
+	mu     sync.Mutex
+	closed bool
+
+	// stateMu guards the states below, which are also used by SetBitRate and ForceKeyFrame while reading.
+	stateMu sync.Mutex
+	// passthrough is true if the last frame was passed through, and waitKeyFrame is true if the frames
+	// passed through are dropped until the next key frame.
+	passthrough  bool
+	waitKeyFrame bool
+}
+
+// NewPassthroughEncoder creates an encoder which sends the frame.Compressed in f read from r as they are.
+// The other frames are encoded by the encoder built by build, which has to implement FrameReader.
+//
+// The key frames of the compressed frames can't be forced, so the compressed frames are dropped until
+// the next key frame after the start, after ForceKeyFrame, and after the frames encoded by the encoder.
+// SetBitRate is applied to the built encoder, and returns an error while the frames are passed through.
+func NewPassthroughEncoder(r video.Reader, f frame.Format, build func(r video.Reader) (ReadCloser, error)) (ReadCloser, error) {
+	e := &passthroughEncoder{r: r, format: f, waitKeyFrame: true}
+	encoder, err := build(video.NewTimestampReader(func() (image.Image, time.Time, error) {
+		return e.img, e.t, nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := encoder.(FrameReader); !ok {
+		encoder.Close()
+		return nil, fmt.Errorf("%T doesn't implement FrameReader", encoder)
+	}
+	e.encoder = encoder
+	return e, nil
+}
+
+func (e *passthroughEncoder) Read(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return 0, io.EOF
+	}
+
+	if e.buff != nil {
+		n, err := mio.Copy(p, e.buff)
+		if err == nil {
+			e.buff = nil
+		}
+		return n, err
+	}
+
+	frame, err := e.readFrame()
+	if err != nil {
+		return 0, err
+	}
+	n, err := mio.Copy(p, frame.Data)
+	if err != nil {
+		e.buff = frame.Data
+	}
+	return n, err
+}
+
+// ReadFrame returns the next frame with its metadata.
+func (e *passthroughEncoder) ReadFrame() (EncodedFrame, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return EncodedFrame{}, io.EOF
+	}
+
+	return e.readFrame()
+}
+
+func (e *passthroughEncoder) readFrame() (EncodedFrame, error) {
+	encoded, err := e.nextFrame()
+	if err != nil {
+		return EncodedFrame{}, err
+	}
+
+	// The encoder doesn't know the frames passed through, so the durations are from the timestamps of
+	// all the frames.
+	t := encoded.Timestamp
+	encoded.Duration = 0
+	if !e.tLastFrame.IsZero() && t.After(e.tLastFrame) {
+		encoded.Duration = t.Sub(e.tLastFrame)
+	}
+	if t.After(e.tLastFrame) {
+		e.tLastFrame = t
+	}
+	return encoded, nil
+}
+
+// nextFrame returns the next frame which can be sent.
+func (e *passthroughEncoder) nextFrame() (EncodedFrame, error) {
+	for {
+		img, err := e.r.Read()
+		if err != nil {
+			return EncodedFrame{}, err
+		}
+		t := video.Timestamp(e.r)
+
+		compressed, ok := img.(frame.Compressed)
+		passthrough := ok && compressed.Format() == e.format
+
+		e.stateMu.Lock()
+		switched := passthrough != e.passthrough
+		e.passthrough = passthrough
+		if passthrough && switched {
+			e.waitKeyFrame = true
+		}
+		if passthrough && compressed.KeyFrame() {
+			e.waitKeyFrame = false
+		}
+		drop := passthrough && e.waitKeyFrame
+		e.stateMu.Unlock()
+
+		if drop {
+			// The frame can't be decoded without the frames before it.
+			continue
+		}
+		if passthrough {
+			src := compressed.Bytes()
+			data := make([]byte, len(src))
+			copy(data, src)
+			return EncodedFrame{Data: data, KeyFrame: compressed.KeyFrame(), Timestamp: t}, nil
+		}
+
+		if switched {
+			// The frames of the encoder can't refer to the ones before the frames passed through.
+			if err := e.encoder.ForceKeyFrame(); err != nil {
+				return EncodedFrame{}, err
+			}
+		}
+		e.img, e.t = img, t
+		encoded, err := e.encoder.(FrameReader).ReadFrame()
+		e.img = nil
+		return encoded, err
+	}
+}
+
+func (e *passthroughEncoder) SetBitRate(b int) error {
+	if err := e.encoder.SetBitRate(b); err != nil {
+		return err
+	}
+
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	if e.passthrough {
+		return errPassthroughBitRate
+	}
+	return nil
+}
+
+func (e *passthroughEncoder) ForceKeyFrame() error {
+	e.stateMu.Lock()
+	e.waitKeyFrame = true
+	e.stateMu.Unlock()
+	return e.encoder.ForceKeyFrame()
+}
+
+func (e *passthroughEncoder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.encoder.Close()
+}
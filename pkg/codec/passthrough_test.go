@@ -0,0 +1,195 @@
+package codec
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+// mockEncoder encodes every frame into its width.
+type mockEncoder struct {
+	r          video.Reader
+	forced     bool
+	bitRate    int
+	closed     bool
+	numEncoded int
+}
+
+func (e *mockEncoder) Read(p []byte) (int, error) {
+	f, err := e.ReadFrame()
+	if err != nil {
+		return 0, err
+	}
+	return copy(p, f.Data), nil
+}
+
+func (e *mockEncoder) ReadFrame() (EncodedFrame, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return EncodedFrame{}, err
+	}
+	e.numEncoded++
+	keyFrame := e.forced
+	e.forced = false
+	return EncodedFrame{Data: []byte{byte(img.Bounds().Dx())}, KeyFrame: keyFrame, Timestamp: video.Timestamp(e.r)}, nil
+}
+
+func (e *mockEncoder) SetBitRate(b int) error {
+	e.bitRate = b
+	return nil
+}
+
+func (e *mockEncoder) ForceKeyFrame() error {
+	e.forced = true
+	return nil
+}
+
+func (e *mockEncoder) Close() error {
+	e.closed = true
+	return nil
+}
+
+func TestPassthroughEncoder(t *testing.T) {
+	idr := []byte{0, 0, 0, 1, 0x65, 0x88}
+	nonIDR := []byte{0, 0, 0, 1, 0x41, 0x9a}
+	inputs := []image.Image{
+		frame.NewH264(idr, 16, 8),
+		frame.NewH264(nonIDR, 16, 8),
+		image.NewYCbCr(image.Rect(0, 0, 16, 8), image.YCbCrSubsampleRatio420),
+	}
+	expected := []EncodedFrame{
+		{Data: idr, KeyFrame: true},
+		{Data: nonIDR, KeyFrame: false},
+		{Data: []byte{16}, KeyFrame: true},
+	}
+
+	// The frames are captured every second.
+	var i int
+	r := video.NewTimestampReader(func() (image.Image, time.Time, error) {
+		img := inputs[i%len(inputs)]
+		i++
+		return img, time.Unix(int64(i), 0), nil
+	})
+	var inner *mockEncoder
+	e, err := NewPassthroughEncoder(r, frame.FormatH264, func(r video.Reader) (ReadCloser, error) {
+		inner = &mockEncoder{r: r}
+		return inner, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.ForceKeyFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetBitRate(1000); err != nil {
+		t.Fatal(err)
+	}
+	if inner.bitRate != 1000 {
+		t.Errorf("Expected the bitrate to be set to the encoder, got %d", inner.bitRate)
+	}
+
+	for j, exp := range expected {
+		f, err := e.(FrameReader).ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(exp.Data, f.Data) || exp.KeyFrame != f.KeyFrame {
+			t.Errorf("Expected frame[%d] to be %v (key frame: %v), got %v (key frame: %v)",
+				j, exp.Data, exp.KeyFrame, f.Data, f.KeyFrame)
+		}
+		if expected := time.Unix(int64(j+1), 0); !f.Timestamp.Equal(expected) {
+			t.Errorf("Expected frame[%d] to have the capture time %v, got %v", j, expected, f.Timestamp)
+		}
+		// The duration is the interval from the previous frame.
+		expectedDuration := time.Second
+		if j == 0 {
+			expectedDuration = 0
+		}
+		if f.Duration != expectedDuration {
+			t.Errorf("Expected the duration of frame[%d] to be %v, got %v", j, expectedDuration, f.Duration)
+		}
+	}
+	if inner.numEncoded != 1 {
+		t.Errorf("Expected only the uncompressed frame to be encoded, got %d frames", inner.numEncoded)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !inner.closed {
+		t.Error("Expected the encoder to be closed")
+	}
+	if _, err := e.Read(make([]byte, 16)); err == nil {
+		t.Error("Expected an error after close")
+	}
+}
+
+func TestPassthroughEncoderKeyFrame(t *testing.T) {
+	idr := []byte{0, 0, 0, 1, 0x65, 0x88}
+	nonIDR := []byte{0, 0, 0, 1, 0x41, 0x9a}
+	raw := image.NewYCbCr(image.Rect(0, 0, 16, 8), image.YCbCrSubsampleRatio420)
+
+	var inputs []image.Image
+	r := video.ReaderFunc(func() (image.Image, error) {
+		if len(inputs) == 0 {
+			return nil, io.EOF
+		}
+		img := inputs[0]
+		inputs = inputs[1:]
+		return img, nil
+	})
+	var inner *mockEncoder
+	e, err := NewPassthroughEncoder(r, frame.FormatH264, func(r video.Reader) (ReadCloser, error) {
+		inner = &mockEncoder{r: r}
+		return inner, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	read := func(expected EncodedFrame, remaining int) {
+		t.Helper()
+		f, err := e.(FrameReader).ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected.Data, f.Data) || expected.KeyFrame != f.KeyFrame {
+			t.Errorf("Expected %v (key frame: %v), got %v (key frame: %v)", expected.Data, expected.KeyFrame, f.Data, f.KeyFrame)
+		}
+		if len(inputs) != remaining {
+			t.Errorf("Expected %d frames to remain, got %d", remaining, len(inputs))
+		}
+	}
+
+	// The frames passed through start from a key frame.
+	inputs = []image.Image{frame.NewH264(nonIDR, 16, 8), frame.NewH264(idr, 16, 8), frame.NewH264(nonIDR, 16, 8)}
+	read(EncodedFrame{Data: idr, KeyFrame: true}, 1)
+	read(EncodedFrame{Data: nonIDR}, 0)
+
+	if err := e.SetBitRate(1000); err != errPassthroughBitRate {
+		t.Errorf("Expected %v while passing through, got %v", errPassthroughBitRate, err)
+	}
+
+	// The frames are dropped until the next key frame after ForceKeyFrame.
+	if err := e.ForceKeyFrame(); err != nil {
+		t.Fatal(err)
+	}
+	inputs = []image.Image{frame.NewH264(nonIDR, 16, 8), frame.NewH264(idr, 16, 8)}
+	read(EncodedFrame{Data: idr, KeyFrame: true}, 0)
+
+	// The encoder starts with a key frame after the frames passed through, and vice versa.
+	inputs = []image.Image{raw, raw, frame.NewH264(nonIDR, 16, 8), frame.NewH264(idr, 16, 8)}
+	read(EncodedFrame{Data: []byte{16}, KeyFrame: true}, 3)
+	read(EncodedFrame{Data: []byte{16}}, 2)
+	if err := e.SetBitRate(2000); err != nil {
+		t.Errorf("Expected the bitrate to be set while encoding, got %v", err)
+	}
+	read(EncodedFrame{Data: idr, KeyFrame: true}, 0)
+}
@@ -6,6 +6,7 @@
 package vaapi
 
 import (
+	"errors"
 	"fmt"
 	"unsafe"
 )
@@ -20,6 +21,11 @@ import (
 // #include "helper.h"
 import "C"
 
+var (
+	errSetBitRateNotImplemented    = errors.New("SetBitRate is not implemented")
+	errForceKeyFrameNotImplemented = errors.New("ForceKeyFrame is not implemented")
+)
+
 const (
 	bufferCoded = iota
 	bufferSeqParam
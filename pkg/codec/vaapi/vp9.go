@@ -44,6 +44,7 @@ import (
 	"image"
 	"io"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/pion/mediadevices/pkg/codec"
@@ -93,6 +94,8 @@ type encoderVP9 struct {
 
 	rate *framerateDetector
 
+	tLastFrame time.Time
+
 	mu     sync.Mutex
 	closed bool
 }
@@ -302,11 +305,36 @@ func (e *encoderVP9) Read(p []byte) (int, error) {
 		return n, err
 	}
 
-	img, err := e.r.Read()
+	frame, err := e.readFrame()
 	if err != nil {
 		return 0, err
 	}
+	n, err := mio.Copy(p, frame.Data)
+	if err != nil {
+		e.buf = frame.Data
+	}
+	return n, err
+}
+
+// ReadFrame encodes the next frame and returns it with its metadata.
+func (e *encoderVP9) ReadFrame() (codec.EncodedFrame, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return codec.EncodedFrame{}, io.EOF
+	}
+
+	return e.readFrame()
+}
+
+func (e *encoderVP9) readFrame() (codec.EncodedFrame, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
 	yuvImg := img.(*image.YCbCr)
+	t := video.Timestamp(e.r)
 
 	kf := e.frameCnt%e.params.KeyFrameInterval == 0
 	e.frameCnt++
@@ -388,7 +416,7 @@ func (e *encoderVP9) Read(p []byte) (int, error) {
 			C.size_t(uintptr(p.src)),
 			&id,
 		); s != C.VA_STATUS_SUCCESS {
-			return 0, fmt.Errorf("failed to create buffer: %s", C.GoString(C.vaErrorStr(s)))
+			return codec.EncodedFrame{}, fmt.Errorf("failed to create buffer: %s", C.GoString(C.vaErrorStr(s)))
 		}
 		buffs = append(buffs, id)
 	}
@@ -398,17 +426,17 @@ func (e *encoderVP9) Read(p []byte) (int, error) {
 		e.display, e.ctxID,
 		e.surfs[surfaceVP9Input],
 	); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to begin picture: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to begin picture: %s", C.GoString(C.vaErrorStr(s)))
 	}
 
 	// Upload image
 	var vaImg C.VAImage
 	var rawBuf unsafe.Pointer
 	if s := C.vaDeriveImage(e.display, e.surfs[surfaceVP9Input], &vaImg); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to derive image: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to derive image: %s", C.GoString(C.vaErrorStr(s)))
 	}
 	if s := C.vaMapBuffer(e.display, vaImg.buf, &rawBuf); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to map buffer: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to map buffer: %s", C.GoString(C.vaErrorStr(s)))
 	}
 	// TODO: use vaImg.pitches to support padding
 	C.copyI420toNV12(
@@ -419,10 +447,10 @@ func (e *encoderVP9) Read(p []byte) (int, error) {
 		C.uint(len(yuvImg.Y)),
 	)
 	if s := C.vaUnmapBuffer(e.display, vaImg.buf); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to unmap buffer: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to unmap buffer: %s", C.GoString(C.vaErrorStr(s)))
 	}
 	if s := C.vaDestroyImage(e.display, vaImg.image_id); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to destroy image: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to destroy image: %s", C.GoString(C.vaErrorStr(s)))
 	}
 
 	if s := C.vaRenderPicture(
@@ -430,27 +458,27 @@ func (e *encoderVP9) Read(p []byte) (int, error) {
 		&buffs[1], // 0 is for ouput
 		C.int(len(buffs)-1),
 	); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to render picture: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to render picture: %s", C.GoString(C.vaErrorStr(s)))
 	}
 	if s := C.vaEndPicture(
 		e.display, e.ctxID,
 	); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to end picture: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to end picture: %s", C.GoString(C.vaErrorStr(s)))
 	}
 
 	// Load encoded data
 	if s := C.vaSyncSurface(e.display, e.picParam.reconstructed_frame); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to sync surface: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to sync surface: %s", C.GoString(C.vaErrorStr(s)))
 	}
 	var surfStat C.VASurfaceStatus
 	if s := C.vaQuerySurfaceStatus(
 		e.display, e.picParam.reconstructed_frame, &surfStat,
 	); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to query surface status: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to query surface status: %s", C.GoString(C.vaErrorStr(s)))
 	}
 	var seg *C.VACodedBufferSegment
 	if s := C.vaMapBufferSeg(e.display, buffs[0], &seg); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to map buffer: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to map buffer: %s", C.GoString(C.vaErrorStr(s)))
 	}
 	if cap(e.frame) < int(seg.size) {
 		e.frame = make([]byte, int(seg.size))
@@ -462,13 +490,13 @@ func (e *encoderVP9) Read(p []byte) (int, error) {
 	)
 
 	if s := C.vaUnmapBuffer(e.display, buffs[0]); s != C.VA_STATUS_SUCCESS {
-		return 0, fmt.Errorf("failed to unmap buffer: %s", C.GoString(C.vaErrorStr(s)))
+		return codec.EncodedFrame{}, fmt.Errorf("failed to unmap buffer: %s", C.GoString(C.vaErrorStr(s)))
 	}
 
 	// Destroy buffers
 	for _, b := range buffs {
 		if s := C.vaDestroyBuffer(e.display, b); s != C.VA_STATUS_SUCCESS {
-			return 0, fmt.Errorf("failed to destroy buffer: %s", C.GoString(C.vaErrorStr(s)))
+			return codec.EncodedFrame{}, fmt.Errorf("failed to destroy buffer: %s", C.GoString(C.vaErrorStr(s)))
 		}
 	}
 
@@ -480,19 +508,28 @@ func (e *encoderVP9) Read(p []byte) (int, error) {
 		e.slotCurr = 0
 	}
 
-	n, err := mio.Copy(p, e.frame)
-	if err != nil {
-		e.buf = e.frame
+	var duration time.Duration
+	if !e.tLastFrame.IsZero() {
+		duration = t.Sub(e.tLastFrame)
 	}
-	return n, err
+	e.tLastFrame = t
+
+	data := make([]byte, len(e.frame))
+	copy(data, e.frame)
+	return codec.EncodedFrame{
+		Data:      data,
+		KeyFrame:  kf,
+		Timestamp: t,
+		Duration:  duration,
+	}, nil
 }
 
 func (e *encoderVP9) SetBitRate(b int) error {
-	panic("SetBitRate is not implemented")
+	return errSetBitRateNotImplemented
 }
 
 func (e *encoderVP9) ForceKeyFrame() error {
-	panic("ForceKeyFrame is not implemented")
+	return errForceKeyFrameNotImplemented
 }
 
 func (e *encoderVP9) Close() error {
@@ -0,0 +1,140 @@
+package vpx
+
+// #include <stdlib.h>
+// #include <vpx/vpx_decoder.h>
+// #include <vpx/vp8dx.h>
+//
+// // C function pointers
+// vpx_codec_iface_t *ifaceVP8Decoder() {
+//   return vpx_codec_vp8_dx();
+// }
+// vpx_codec_iface_t *ifaceVP9Decoder() {
+//   return vpx_codec_vp9_dx();
+// }
+//
+// // Alloc helpers
+// vpx_codec_ctx_t *newDecoderCtx() {
+//   return malloc(sizeof(vpx_codec_ctx_t));
+// }
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+	"unsafe"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+type decoder struct {
+	codec *C.vpx_codec_ctx_t
+	r     codec.FrameReader
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// BuildVideoDecoder builds VP8 decoder
+func (p *VP8Params) BuildVideoDecoder(r codec.FrameReader, property prop.Media) (codec.VideoDecoder, error) {
+	return newDecoder(r, C.ifaceVP8Decoder())
+}
+
+// BuildVideoDecoder builds VP9 decoder. The superframes of the spatial layers are decoded into the
+// pictures of the top layer.
+func (p *VP9Params) BuildVideoDecoder(r codec.FrameReader, property prop.Media) (codec.VideoDecoder, error) {
+	return newDecoder(r, C.ifaceVP9Decoder())
+}
+
+func newDecoder(r codec.FrameReader, codecIface *C.vpx_codec_iface_t) (codec.VideoDecoder, error) {
+	codec := C.newDecoderCtx()
+	if ec := C.vpx_codec_dec_init_ver(
+		codec, codecIface, nil, 0, C.VPX_DECODER_ABI_VERSION,
+	); ec != C.VPX_CODEC_OK {
+		C.free(unsafe.Pointer(codec))
+		return nil, fmt.Errorf("vpx_codec_dec_init failed (%d)", ec)
+	}
+	return &decoder{
+		codec: codec,
+		r:     r,
+	}, nil
+}
+
+// Read decodes the frames read from the source until a picture is output.
+func (d *decoder) Read() (image.Image, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, io.EOF
+	}
+
+	for {
+		f, err := d.r.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		if len(f.Data) == 0 {
+			// The encoder dropped the frame.
+			continue
+		}
+
+		if ec := C.vpx_codec_decode(
+			d.codec, (*C.uint8_t)(&f.Data[0]), C.uint(len(f.Data)), nil, 0,
+		); ec != C.VPX_CODEC_OK {
+			return nil, fmt.Errorf("vpx_codec_decode failed (%d)", ec)
+		}
+
+		// The last picture is output if the frame has several ones.
+		var img *image.YCbCr
+		var iter C.vpx_codec_iter_t
+		for {
+			raw := C.vpx_codec_get_frame(d.codec, &iter)
+			if raw == nil {
+				break
+			}
+			if raw.fmt != C.VPX_IMG_FMT_I420 {
+				return nil, fmt.Errorf("unsupported image format (%d)", raw.fmt)
+			}
+			img = copyImage(raw)
+		}
+		if img != nil {
+			return img, nil
+		}
+	}
+}
+
+// copyImage copies the picture from the buffer of the decoder.
+func copyImage(raw *C.vpx_image_t) *image.YCbCr {
+	w, h := int(raw.d_w), int(raw.d_h)
+	yStride, cStride := int(raw.stride[0]), int(raw.stride[1])
+	cHeight := (h + 1) / 2
+	return &image.YCbCr{
+		Y:              C.GoBytes(unsafe.Pointer(raw.planes[0]), C.int(yStride*h)),
+		Cb:             C.GoBytes(unsafe.Pointer(raw.planes[1]), C.int(cStride*cHeight)),
+		Cr:             C.GoBytes(unsafe.Pointer(raw.planes[2]), C.int(cStride*cHeight)),
+		YStride:        yStride,
+		CStride:        cStride,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, w, h),
+	}
+}
+
+func (d *decoder) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
+	defer C.free(unsafe.Pointer(d.codec))
+	if C.vpx_codec_destroy(d.codec) != C.VPX_CODEC_OK {
+		return errors.New("vpx_codec_destroy failed")
+	}
+	return nil
+}
@@ -17,6 +17,19 @@ type Params struct {
 	RateControlMinQuantizer      uint
 	RateControlMaxQuantizer      uint
 	ErrorResilient               ErrorResilientMode
+
+	// TemporalLayers is the number of the temporal layers, which is 1 (no temporal scalability), 2 or 3.
+	// The frames of a layer only refer to the frames of the same or lower layers, so that the upper
+	// layers can be dropped without re-encoding. The layer of each frame is reported as
+	// codec.EncodedFrame.TemporalLayerID. The layers repeat in the order of 0, 1 for 2 layers,
+	// and 0, 2, 1, 2 for 3 layers.
+	TemporalLayers int
+	// TemporalLayerBitRates are the target bitrates of the temporal layers in bps, from the lowest one.
+	// The bitrate of a layer includes the bitrates of the lower layers, so the last one is the total
+	// bitrate, and BitRate is ignored. SetBitRate scales the bitrates of all the layers by the same
+	// ratio. If it's empty, 60% (2 layers) or 40% and 60% (3 layers) of BitRate are allocated to the
+	// lower layers.
+	TemporalLayerBitRates []int
 }
 
 // RateControlMode represents rate control mode.
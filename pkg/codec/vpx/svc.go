@@ -0,0 +1,212 @@
+package vpx
+
+// #include <vpx/vpx_encoder.h>
+// #include <vpx/vp8cx.h>
+//
+// // vpx_codec_control is a macro, which can't be called from Go.
+// vpx_codec_err_t setSVC(vpx_codec_ctx_t *ctx, int enabled) {
+//   return vpx_codec_control(ctx, VP9E_SET_SVC, enabled);
+// }
+// vpx_codec_err_t setSVCParameters(vpx_codec_ctx_t *ctx, vpx_svc_extra_cfg_t *params) {
+//   return vpx_codec_control(ctx, VP9E_SET_SVC_PARAMETERS, params);
+// }
+// vpx_codec_err_t getSVCLayerID(vpx_codec_ctx_t *ctx, vpx_svc_layer_id_t *id) {
+//   return vpx_codec_control(ctx, VP9E_GET_SVC_LAYER_ID, id);
+// }
+// vpx_codec_err_t setTemporalLayerID(vpx_codec_ctx_t *ctx, int id) {
+//   return vpx_codec_control(ctx, VP8E_SET_TEMPORAL_LAYER_ID, id);
+// }
+import "C"
+
+import (
+	"fmt"
+)
+
+const maxLayers = 3
+
+// temporalPatterns are the temporal layer IDs of the frames in a period by the number of the layers.
+var temporalPatterns = [maxLayers + 1][]int{
+	1: {0},
+	2: {0, 1},
+	3: {0, 2, 1, 2},
+}
+
+// defaultTemporalRatios are the ratios of the cumulative bitrates of the temporal layers to the total bitrate.
+var defaultTemporalRatios = [maxLayers + 1][]float64{
+	1: {1},
+	2: {0.6, 1},
+	3: {0.4, 0.6, 1},
+}
+
+// layers is the configuration of the temporal and the spatial layers of an encoder.
+type layers struct {
+	temporal, spatial int
+	// temporalRatios are the ratios of the cumulative bitrates of the temporal layers to the bitrate of
+	// the spatial layer.
+	temporalRatios []float64
+	// spatialRatios are the ratios of the bitrates of the spatial layers to the total bitrate.
+	spatialRatios []float64
+}
+
+// newLayers validates the layer parameters and returns the configuration along with the total bitrate in bps.
+func newLayers(params Params, spatial int) (layers, int, error) {
+	l := layers{temporal: params.TemporalLayers, spatial: spatial}
+	if l.temporal == 0 {
+		l.temporal = 1
+	}
+	if l.spatial == 0 {
+		l.spatial = 1
+	}
+	if l.temporal < 1 || maxLayers < l.temporal {
+		return layers{}, 0, fmt.Errorf("invalid number of temporal layers: %d", params.TemporalLayers)
+	}
+	if l.spatial < 1 || maxLayers < l.spatial {
+		return layers{}, 0, fmt.Errorf("invalid number of spatial layers: %d", spatial)
+	}
+
+	bitRate := params.BitRate
+	l.temporalRatios = defaultTemporalRatios[l.temporal]
+	if len(params.TemporalLayerBitRates) > 0 {
+		if len(params.TemporalLayerBitRates) != l.temporal {
+			return layers{}, 0, fmt.Errorf("expected %d temporal layer bitrates, got %d", l.temporal, len(params.TemporalLayerBitRates))
+		}
+		bitRate = params.TemporalLayerBitRates[l.temporal-1]
+		l.temporalRatios = make([]float64, l.temporal)
+		prev := 0
+		for i, b := range params.TemporalLayerBitRates {
+			if b <= prev {
+				return layers{}, 0, fmt.Errorf("temporal layer bitrates must be positive and increasing: %v", params.TemporalLayerBitRates)
+			}
+			l.temporalRatios[i] = float64(b) / float64(bitRate)
+			prev = b
+		}
+	}
+
+	// Each spatial layer gets twice the bitrate of the lower one.
+	l.spatialRatios = make([]float64, l.spatial)
+	total := float64(int(1)<<uint(l.spatial) - 1)
+	for i := range l.spatialRatios {
+		l.spatialRatios[i] = float64(int(1)<<uint(i)) / total
+	}
+	return l, bitRate, nil
+}
+
+func (l layers) enabled() bool {
+	return l.temporal > 1 || l.spatial > 1
+}
+
+// pattern returns the temporal layer IDs of the frames in a period.
+func (l layers) pattern() []int {
+	return temporalPatterns[l.temporal]
+}
+
+// configure sets the layers to cfg. The bitrates are set by setBitRate.
+func (l layers) configure(cfg *C.vpx_codec_enc_cfg_t, vp9 bool) {
+	if !l.enabled() {
+		return
+	}
+
+	cfg.ts_number_layers = C.uint(l.temporal)
+	pattern := l.pattern()
+	cfg.ts_periodicity = C.uint(len(pattern))
+	for i, id := range pattern {
+		cfg.ts_layer_id[i] = C.uint(id)
+	}
+	for i := 0; i < l.temporal; i++ {
+		// The frame rate of a layer is the half of the upper one.
+		cfg.ts_rate_decimator[i] = C.uint(1 << uint(l.temporal-1-i))
+	}
+	cfg.ss_number_layers = C.uint(l.spatial)
+
+	if vp9 {
+		cfg.temporal_layering_mode = C.int(l.vp9TemporalLayeringMode())
+	}
+}
+
+func (l layers) vp9TemporalLayeringMode() int {
+	switch l.temporal {
+	case 2:
+		return C.VP9E_TEMPORAL_LAYERING_MODE_0101
+	case 3:
+		return C.VP9E_TEMPORAL_LAYERING_MODE_0212
+	default:
+		return C.VP9E_TEMPORAL_LAYERING_MODE_NOLAYERING
+	}
+}
+
+// setBitRate sets the total bitrate in kbit/s and the bitrates of the layers to cfg.
+func (l layers) setBitRate(cfg *C.vpx_codec_enc_cfg_t, kbps int) {
+	cfg.rc_target_bitrate = C.uint(kbps)
+	if !l.enabled() {
+		return
+	}
+
+	for tl := 0; tl < l.temporal; tl++ {
+		cfg.ts_target_bitrate[tl] = C.uint(float64(kbps) * l.temporalRatios[tl])
+	}
+	for sl := 0; sl < l.spatial; sl++ {
+		spatialKbps := float64(kbps) * l.spatialRatios[sl]
+		cfg.ss_target_bitrate[sl] = C.uint(spatialKbps)
+		for tl := 0; tl < l.temporal; tl++ {
+			cfg.layer_target_bitrate[sl*l.temporal+tl] = C.uint(spatialKbps * l.temporalRatios[tl])
+		}
+	}
+}
+
+// enableVP9SVC enables the layers of the initialized VP9 encoder. The resolution of each spatial layer is
+// the half of the upper one.
+func (l layers) enableVP9SVC(codec *C.vpx_codec_ctx_t, cfg *C.vpx_codec_enc_cfg_t) error {
+	if ec := C.setSVC(codec, 1); ec != C.VPX_CODEC_OK {
+		return fmt.Errorf("VP9E_SET_SVC failed (%d)", ec)
+	}
+
+	var svc C.vpx_svc_extra_cfg_t
+	svc.temporal_layering_mode = C.int(l.vp9TemporalLayeringMode())
+	for sl := 0; sl < l.spatial; sl++ {
+		svc.scaling_factor_num[sl] = 1
+		svc.scaling_factor_den[sl] = C.int(1 << uint(l.spatial-1-sl))
+		for tl := 0; tl < l.temporal; tl++ {
+			i := sl*l.temporal + tl
+			svc.max_quantizers[i] = C.int(cfg.rc_max_quantizer)
+			svc.min_quantizers[i] = C.int(cfg.rc_min_quantizer)
+		}
+	}
+	if ec := C.setSVCParameters(codec, &svc); ec != C.VPX_CODEC_OK {
+		return fmt.Errorf("VP9E_SET_SVC_PARAMETERS failed (%d)", ec)
+	}
+	return nil
+}
+
+// vp9LayerID returns the temporal layer and the top spatial layer of the frame encoded last.
+func vp9LayerID(codec *C.vpx_codec_ctx_t) (temporal, spatial int, err error) {
+	var id C.vpx_svc_layer_id_t
+	if ec := C.getSVCLayerID(codec, &id); ec != C.VPX_CODEC_OK {
+		return 0, 0, fmt.Errorf("VP9E_GET_SVC_LAYER_ID failed (%d)", ec)
+	}
+	return int(id.temporal_layer_id), int(id.spatial_layer_id), nil
+}
+
+// setVP8TemporalLayer sets the temporal layer of the next VP8 frame, and returns the encoding flags which
+// restrict the references of the frame, so that the upper layers can be dropped. Frames of the upper layers
+// don't update the entropy context either, since the context would be lost along with the dropped frames.
+func setVP8TemporalLayer(codec *C.vpx_codec_ctx_t, temporal, id int) (int, error) {
+	if ec := C.setTemporalLayerID(codec, C.int(id)); ec != C.VPX_CODEC_OK {
+		return 0, fmt.Errorf("VP8E_SET_TEMPORAL_LAYER_ID failed (%d)", ec)
+	}
+
+	const noRefUpper = C.VP8_EFLAG_NO_REF_GF | C.VP8_EFLAG_NO_REF_ARF
+	switch {
+	case id == 0:
+		// Refers to and updates the last frame, which is always in the base layer.
+		return noRefUpper | C.VP8_EFLAG_NO_UPD_GF | C.VP8_EFLAG_NO_UPD_ARF, nil
+	case id == 1 && temporal == 3:
+		// Refers to the base layer, and updates the golden frame for the layer 2.
+		return noRefUpper | C.VP8_EFLAG_NO_UPD_LAST | C.VP8_EFLAG_NO_UPD_ARF | C.VP8_EFLAG_NO_UPD_ENTROPY, nil
+	case id == 1:
+		// Refers to the base layer and the previous frame of the layer 1 held in the golden frame.
+		return C.VP8_EFLAG_NO_REF_ARF | C.VP8_EFLAG_NO_UPD_LAST | C.VP8_EFLAG_NO_UPD_ARF | C.VP8_EFLAG_NO_UPD_ENTROPY, nil
+	default:
+		// The top layer of the 3 layers refers to the lower layers, and isn't referred to.
+		return C.VP8_EFLAG_NO_UPD_LAST | C.VP8_EFLAG_NO_UPD_GF | C.VP8_EFLAG_NO_UPD_ARF | C.VP8_EFLAG_NO_UPD_ENTROPY, nil
+	}
+}
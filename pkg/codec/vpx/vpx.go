@@ -23,6 +23,12 @@ package vpx
 // int pktSz(vpx_codec_cx_pkt_t *pkt) {
 //   return pkt->data.frame.sz;
 // }
+// int pktIsKey(vpx_codec_cx_pkt_t *pkt) {
+//   return (pkt->data.frame.flags & VPX_FRAME_IS_KEY) != 0;
+// }
+// vpx_codec_pts_t pktPts(vpx_codec_cx_pkt_t *pkt) {
+//   return pkt->data.frame.pts;
+// }
 //
 // // Alloc helpers
 // vpx_codec_ctx_t *newCtx() {
@@ -68,10 +74,27 @@ type encoder struct {
 	r          video.Reader
 	frameIndex int
 	buff       []byte
-	tStart     int
-	tLastFrame int
-	frame      []byte
-	deadline   int
+	tStart     time.Time
+	tLastFrame time.Time
+	// pts is the presentation timestamp of the last input picture, and times are the capture times of
+	// the pictures not output yet. The output is delayed if g_lag_in_frames is not 0.
+	pts   int64
+	times map[int64]time.Time
+	// tLastOutput is the timestamp of the last output frame.
+	tLastOutput time.Time
+	frame       []byte
+	deadline    int
+	vp9         bool
+
+	requireKeyFrame bool
+
+	layers layers
+	// pos is the position of the next frame in the period of the temporal layers, and framesSinceKeyFrame
+	// is the number of the frames encoded since the last key frame. They are used to align the key frames
+	// to the base temporal layer of VP8.
+	pos                 int
+	framesSinceKeyFrame int
+	keyFrameInterval    int
 
 	mu     sync.Mutex
 	closed bool
@@ -101,12 +124,19 @@ func (p *VP8Params) RTPCodec() *codec.RTPCodec {
 
 // BuildVideoEncoder builds VP8 encoder with given params
 func (p *VP8Params) BuildVideoEncoder(r video.Reader, property prop.Media) (codec.ReadCloser, error) {
-	return newEncoder(r, property, p.Params, C.ifaceVP8())
+	return newEncoder(r, property, p.Params, 1, C.ifaceVP8())
 }
 
 // VP9Params is codec specific paramaters
 type VP9Params struct {
 	Params
+
+	// SpatialLayers is the number of the spatial layers, which is 1 (no spatial scalability), 2 or 3.
+	// The top layer has the resolution of the input, and the resolution of each lower layer is the half
+	// of the upper one. The bitrate is split among the layers in the ratio of 1:2:4 from the lowest one.
+	// The layers of a picture are output as a superframe, and codec.EncodedFrame.SpatialLayerID is the
+	// top layer in it.
+	SpatialLayers int
 }
 
 // NewVP9Params returns default VP9 codec specific parameters.
@@ -128,7 +158,7 @@ func (p *VP9Params) RTPCodec() *codec.RTPCodec {
 
 // BuildVideoEncoder builds VP9 encoder with given params
 func (p *VP9Params) BuildVideoEncoder(r video.Reader, property prop.Media) (codec.ReadCloser, error) {
-	return newEncoder(r, property, p.Params, C.ifaceVP9())
+	return newEncoder(r, property, p.Params, p.SpatialLayers, C.ifaceVP9())
 }
 
 func newParams(codecIface *C.vpx_codec_iface_t) (Params, error) {
@@ -147,7 +177,17 @@ func newParams(codecIface *C.vpx_codec_iface_t) (Params, error) {
 	}, nil
 }
 
-func newEncoder(r video.Reader, p prop.Media, params Params, codecIface *C.vpx_codec_iface_t) (codec.ReadCloser, error) {
+// toKbps converts the bitrate from bit/s to kbit/s, which libvpx uses. It's rounded to the nearest value,
+// but kept at least 1 kbit/s.
+func toKbps(b int) int {
+	kbps := (b + 500) / 1000
+	if kbps < 1 {
+		kbps = 1
+	}
+	return kbps
+}
+
+func newEncoder(r video.Reader, p prop.Media, params Params, spatialLayers int, codecIface *C.vpx_codec_iface_t) (codec.ReadCloser, error) {
 	if params.BitRate == 0 {
 		params.BitRate = 100000
 	}
@@ -156,6 +196,13 @@ func newEncoder(r video.Reader, p prop.Media, params Params, codecIface *C.vpx_c
 		params.KeyFrameInterval = 60
 	}
 
+	layers, bitRate, err := newLayers(params, spatialLayers)
+	if err != nil {
+		return nil, err
+	}
+	vp9 := codecIface == C.ifaceVP9()
+	vp8Temporal := !vp9 && layers.temporal > 1
+
 	cfg := &C.vpx_codec_enc_cfg_t{}
 	if ec := C.vpx_codec_enc_config_default(codecIface, cfg, 0); ec != 0 {
 		return nil, fmt.Errorf("vpx_codec_enc_config_default failed (%d)", ec)
@@ -173,8 +220,13 @@ func newEncoder(r video.Reader, p prop.Media, params Params, codecIface *C.vpx_c
 	cfg.g_h = C.uint(p.Height)
 	cfg.g_timebase.num = 1
 	cfg.g_timebase.den = 1000
-	cfg.rc_target_bitrate = C.uint(params.BitRate) / 1000
 	cfg.kf_max_dist = C.uint(params.KeyFrameInterval)
+	if vp8Temporal {
+		// Key frames are forced at the base layer instead.
+		cfg.kf_mode = C.VPX_KF_DISABLED
+	}
+	layers.configure(cfg, vp9)
+	layers.setBitRate(cfg, toKbps(bitRate))
 
 	cfg.rc_resize_allowed = 0
 	cfg.g_pass = C.VPX_RC_ONE_PASS
@@ -193,7 +245,15 @@ func newEncoder(r video.Reader, p prop.Media, params Params, codecIface *C.vpx_c
 	); ec != 0 {
 		return nil, fmt.Errorf("vpx_codec_enc_init failed (%d)", ec)
 	}
-	t0 := time.Now().Nanosecond() / 1000000
+	if vp9 && layers.enabled() {
+		if err := layers.enableVP9SVC(codec, cfg); err != nil {
+			C.vpx_codec_destroy(codec)
+			C.free(unsafe.Pointer(codec))
+			C.free(unsafe.Pointer(rawNoBuffer))
+			return nil, err
+		}
+	}
+	t0 := time.Now()
 	return &encoder{
 		r:          video.ToI420(r),
 		codec:      codec,
@@ -201,8 +261,14 @@ func newEncoder(r video.Reader, p prop.Media, params Params, codecIface *C.vpx_c
 		cfg:        cfg,
 		tStart:     t0,
 		tLastFrame: t0,
+		pts:        -1,
+		times:      make(map[int64]time.Time),
 		deadline:   int(params.Deadline / time.Microsecond),
 		frame:      make([]byte, 1024),
+		vp9:        vp9,
+		layers:     layers,
+
+		keyFrameInterval: params.KeyFrameInterval,
 	}, nil
 }
 
@@ -222,10 +288,34 @@ func (e *encoder) Read(p []byte) (int, error) {
 		return n, err
 	}
 
-	img, err := e.r.Read()
+	frame, err := e.readFrame()
 	if err != nil {
 		return 0, err
 	}
+	n, err := mio.Copy(p, frame.Data)
+	if err != nil {
+		e.buff = frame.Data
+	}
+	return n, err
+}
+
+// ReadFrame encodes the next frame and returns it with its metadata.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return codec.EncodedFrame{}, io.EOF
+	}
+
+	return e.readFrame()
+}
+
+func (e *encoder) readFrame() (codec.EncodedFrame, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
 	yuvImg := img.(*image.YCbCr)
 	bounds := yuvImg.Bounds()
 	height := C.int(bounds.Dy())
@@ -235,12 +325,15 @@ func (e *encoder) Read(p []byte) (int, error) {
 	e.raw.stride[1] = C.int(yuvImg.CStride)
 	e.raw.stride[2] = C.int(yuvImg.CStride)
 
-	t := time.Now().Nanosecond() / 1000000
+	// libvpx is given the times when the pictures are read, and the output is timestamped by the capture
+	// times of the pictures.
+	now := time.Now()
+	t := video.Timestamp(e.r)
 
 	if e.cfg.g_w != C.uint(width) || e.cfg.g_h != C.uint(height) {
 		e.cfg.g_w, e.cfg.g_h = C.uint(width), C.uint(height)
 		if ec := C.vpx_codec_enc_config_set(e.codec, e.cfg); ec != C.VPX_CODEC_OK {
-			return 0, fmt.Errorf("vpx_codec_enc_config_set failed (%d)", ec)
+			return codec.EncodedFrame{}, fmt.Errorf("vpx_codec_enc_config_set failed (%d)", ec)
 		}
 		e.raw.w, e.raw.h = C.uint(width), C.uint(height)
 		e.raw.r_w, e.raw.r_h = C.uint(width), C.uint(height)
@@ -248,18 +341,50 @@ func (e *encoder) Read(p []byte) (int, error) {
 	}
 
 	var flags int
+	var temporalLayerID, spatialLayerID int
+	if !e.vp9 && e.layers.temporal > 1 {
+		if e.framesSinceKeyFrame >= e.keyFrameInterval {
+			e.requireKeyFrame = true
+		}
+		if e.requireKeyFrame {
+			// Restart the period so that the key frame is in the base layer.
+			e.pos = 0
+		}
+		pattern := e.layers.pattern()
+		temporalLayerID = pattern[e.pos%len(pattern)]
+		layerFlags, err := setVP8TemporalLayer(e.codec, e.layers.temporal, temporalLayerID)
+		if err != nil {
+			return codec.EncodedFrame{}, err
+		}
+		flags |= layerFlags
+		e.pos++
+	}
+	if e.requireKeyFrame {
+		flags |= C.VPX_EFLAG_FORCE_KF
+	}
+	// The timestamps must be increasing.
+	pts := int64(now.Sub(e.tStart) / time.Millisecond)
+	if pts <= e.pts {
+		pts = e.pts + 1
+	}
+	e.pts = pts
+	e.times[pts] = t
+
 	if ec := C.encode_wrapper(
 		e.codec, e.raw,
-		C.long(t-e.tStart), C.ulong(t-e.tLastFrame), C.long(flags), C.ulong(e.deadline),
+		C.long(pts), C.ulong(now.Sub(e.tLastFrame)/time.Millisecond),
+		C.long(flags), C.ulong(e.deadline),
 		(*C.uchar)(&yuvImg.Y[0]), (*C.uchar)(&yuvImg.Cb[0]), (*C.uchar)(&yuvImg.Cr[0]),
 	); ec != C.VPX_CODEC_OK {
-		return 0, fmt.Errorf("vpx_codec_encode failed (%d)", ec)
+		return codec.EncodedFrame{}, fmt.Errorf("vpx_codec_encode failed (%d)", ec)
 	}
 
-	e.frameIndex++
-	e.tLastFrame = t
+	e.tLastFrame = now
+	e.requireKeyFrame = false
 
 	e.frame = e.frame[:0]
+	var keyFrame, output bool
+	var outputPTS int64
 	var iter C.vpx_codec_iter_t
 	for {
 		pkt := C.vpx_codec_get_cx_data(e.codec, &iter)
@@ -269,27 +394,107 @@ func (e *encoder) Read(p []byte) (int, error) {
 		if pkt.kind == C.VPX_CODEC_CX_FRAME_PKT {
 			encoded := C.GoBytes(unsafe.Pointer(C.pktBuf(pkt)), C.pktSz(pkt))
 			e.frame = append(e.frame, encoded...)
+			keyFrame = keyFrame || C.pktIsKey(pkt) != 0
+			if !output {
+				output = true
+				outputPTS = int64(C.pktPts(pkt))
+			}
 		}
 	}
-	n, err := mio.Copy(p, e.frame)
-	if err != nil {
-		e.buff = e.frame
+
+	if output {
+		// The output can be a picture read before if the output is delayed, so its capture time is looked up.
+		// The pictures before it are dropped by the rate control.
+		if tRead, ok := e.times[outputPTS]; ok {
+			t = tRead
+		}
+		for pts := range e.times {
+			if pts <= outputPTS {
+				delete(e.times, pts)
+			}
+		}
 	}
-	return n, err
+
+	var duration time.Duration
+	if e.frameIndex > 0 && t.After(e.tLastOutput) {
+		duration = t.Sub(e.tLastOutput)
+	}
+	if t.After(e.tLastOutput) {
+		e.tLastOutput = t
+	}
+	e.frameIndex++
+
+	if keyFrame {
+		e.framesSinceKeyFrame = 0
+	}
+	e.framesSinceKeyFrame++
+
+	if e.vp9 && e.layers.enabled() {
+		var err error
+		temporalLayerID, spatialLayerID, err = vp9LayerID(e.codec)
+		if err != nil {
+			return codec.EncodedFrame{}, err
+		}
+	}
+
+	data := make([]byte, len(e.frame))
+	copy(data, e.frame)
+	return codec.EncodedFrame{
+		Data:            data,
+		KeyFrame:        keyFrame,
+		Timestamp:       t,
+		Duration:        duration,
+		TemporalLayerID: temporalLayerID,
+		SpatialLayerID:  spatialLayerID,
+	}, nil
 }
 
+// SetBitRate updates the target bitrate of the running encoder. The new value
+// takes effect from the next encoded frame. The bitrates of the layers are
+// scaled by the same ratio.
+//
+// Undershoot and overshoot limits are percentages of the target bitrate and the
+// rate control buffer sizes are given in milliseconds, so they follow the new
+// target without being touched.
 func (e *encoder) SetBitRate(b int) error {
-	panic("SetBitRate is not implemented")
+	if b <= 0 {
+		return fmt.Errorf("invalid bitrate: %d", b)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	e.layers.setBitRate(e.cfg, toKbps(b))
+	if ec := C.vpx_codec_enc_config_set(e.codec, e.cfg); ec != C.VPX_CODEC_OK {
+		return fmt.Errorf("vpx_codec_enc_config_set failed (%d)", ec)
+	}
+	return nil
 }
 
+// ForceKeyFrame forces the next encoded frame to be a key frame.
 func (e *encoder) ForceKeyFrame() error {
-	panic("ForceKeyFrame is not implemented")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	e.requireKeyFrame = true
+	return nil
 }
 
 func (e *encoder) Close() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if e.closed {
+		return nil
+	}
 	e.closed = true
 
 	C.free(unsafe.Pointer(e.raw))
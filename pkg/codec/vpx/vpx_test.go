@@ -0,0 +1,345 @@
+package vpx
+
+import (
+	"testing"
+
+	codectest "github.com/pion/mediadevices/internal/codec"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// newEncoderTestCases returns VP8 and VP9 to be checked by the tests shared by the codecs.
+func newEncoderTestCases(t *testing.T) map[string]codectest.VideoEncoderTestCase {
+	vp8, err := NewVP8Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp9, err := NewVP9Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp8.RateControlEndUsage = RateControlCBR
+	vp9.RateControlEndUsage = RateControlCBR
+	return map[string]codectest.VideoEncoderTestCase{
+		"VP8": {Builder: &vp8, Params: &vp8.BaseParams, IsKeyFrame: codectest.IsKeyFrameVP8},
+		"VP9": {Builder: &vp9, Params: &vp9.BaseParams, IsKeyFrame: codectest.IsKeyFrameVP9},
+	}
+}
+
+func TestSetBitRate(t *testing.T) {
+	for name, c := range newEncoderTestCases(t) {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			before, after, err := c.VerifySetBitRate(0.5)
+			t.Logf("bitrate before: %.0f, after: %.0f", before, after)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSetBitRateAfterClose(t *testing.T) {
+	p, err := NewVP8Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := p.BuildVideoEncoder(
+		codectest.NewVideoReader(320, 240, 30),
+		prop.Media{Video: prop.Video{Width: 320, Height: 240}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetBitRate(100000); err == nil {
+		t.Error("Expected error after close, but got nil")
+	}
+}
+
+func TestForceKeyFrame(t *testing.T) {
+	for name, c := range newEncoderTestCases(t) {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			if err := c.VerifyForceKeyFrame(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	for name, c := range newEncoderTestCases(t) {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			if err := c.VerifyReadFrame(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNewLayers(t *testing.T) {
+	testCases := map[string]struct {
+		params   Params
+		spatial  int
+		bitRate  int
+		temporal []float64
+		spatials []float64
+		err      bool
+	}{
+		"NoLayers": {
+			params:   Params{BaseParams: codec.BaseParams{BitRate: 100000}},
+			bitRate:  100000,
+			temporal: []float64{1},
+			spatials: []float64{1},
+		},
+		"DefaultTemporalRatios": {
+			params:   Params{BaseParams: codec.BaseParams{BitRate: 100000}, TemporalLayers: 3},
+			bitRate:  100000,
+			temporal: []float64{0.4, 0.6, 1},
+			spatials: []float64{1},
+		},
+		"TemporalLayerBitRates": {
+			params:   Params{BaseParams: codec.BaseParams{BitRate: 100000}, TemporalLayers: 2, TemporalLayerBitRates: []int{100000, 400000}},
+			bitRate:  400000,
+			temporal: []float64{0.25, 1},
+			spatials: []float64{1},
+		},
+		"SpatialLayers": {
+			params:   Params{BaseParams: codec.BaseParams{BitRate: 700000}},
+			spatial:  3,
+			bitRate:  700000,
+			temporal: []float64{1},
+			spatials: []float64{1.0 / 7, 2.0 / 7, 4.0 / 7},
+		},
+		"TooManyTemporalLayers": {
+			params: Params{TemporalLayers: 4},
+			err:    true,
+		},
+		"TooManySpatialLayers": {
+			spatial: 4,
+			err:     true,
+		},
+		"WrongNumberOfBitRates": {
+			params: Params{TemporalLayers: 3, TemporalLayerBitRates: []int{100000, 200000}},
+			err:    true,
+		},
+		"DecreasingBitRates": {
+			params: Params{TemporalLayers: 2, TemporalLayerBitRates: []int{200000, 100000}},
+			err:    true,
+		},
+	}
+
+	equal := func(a, b []float64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if d := a[i] - b[i]; d > 1e-9 || d < -1e-9 {
+				return false
+			}
+		}
+		return true
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			l, bitRate, err := newLayers(testCase.params, testCase.spatial)
+			if testCase.err {
+				if err == nil {
+					t.Fatal("Expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bitRate != testCase.bitRate {
+				t.Errorf("Expected bitrate %d, got %d", testCase.bitRate, bitRate)
+			}
+			if !equal(l.temporalRatios, testCase.temporal) {
+				t.Errorf("Expected temporal ratios %v, got %v", testCase.temporal, l.temporalRatios)
+			}
+			if !equal(l.spatialRatios, testCase.spatials) {
+				t.Errorf("Expected spatial ratios %v, got %v", testCase.spatials, l.spatialRatios)
+			}
+		})
+	}
+}
+
+func TestTemporalLayers(t *testing.T) {
+	vp8, err := NewVP8Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp9, err := NewVP9Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]struct {
+		builder codec.VideoEncoderBuilder
+		params  *Params
+	}{
+		"VP8": {&vp8, &vp8.Params},
+		"VP9": {&vp9, &vp9.Params},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			c.params.BitRate = 300000
+			c.params.RateControlEndUsage = RateControlCBR
+			c.params.TemporalLayers = 3
+			c.params.KeyFrameInterval = 10
+
+			e, err := c.builder.BuildVideoEncoder(
+				codectest.NewVideoReader(320, 240, 30),
+				prop.Media{
+					Video: prop.Video{
+						Width:     320,
+						Height:    240,
+						FrameRate: 30,
+					},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+
+			r := e.(codec.FrameReader)
+			layers := make(map[int]int)
+			for i := 0; i < 30; i++ {
+				if i == 15 {
+					if err := e.ForceKeyFrame(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				frame, err := r.ReadFrame()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(frame.Data) == 0 {
+					continue
+				}
+				layers[frame.TemporalLayerID]++
+				if frame.KeyFrame && frame.TemporalLayerID != 0 {
+					t.Errorf("Expected key frames to be in the base layer, got layer %d", frame.TemporalLayerID)
+				}
+			}
+			for id := 0; id < 3; id++ {
+				if layers[id] == 0 {
+					t.Errorf("Expected frames of the layer %d, got %v", id, layers)
+				}
+			}
+			if layers[2] <= layers[0] {
+				t.Errorf("Expected the top layer to have the most frames, got %v", layers)
+			}
+		})
+	}
+}
+
+func TestSpatialLayers(t *testing.T) {
+	p, err := NewVP9Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.BitRate = 500000
+	p.RateControlEndUsage = RateControlCBR
+	p.SpatialLayers = 2
+
+	e, err := p.BuildVideoEncoder(
+		codectest.NewVideoReader(320, 240, 30),
+		prop.Media{
+			Video: prop.Video{
+				Width:     320,
+				Height:    240,
+				FrameRate: 30,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	r := e.(codec.FrameReader)
+	for i := 0; i < 10; i++ {
+		frame, err := r.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frame.Data) == 0 {
+			continue
+		}
+		if frame.SpatialLayerID != 1 {
+			t.Errorf("Expected the top spatial layer 1, got %d", frame.SpatialLayerID)
+		}
+		// The layers are packed in a superframe, which ends with the superframe index.
+		if marker := frame.Data[len(frame.Data)-1]; marker&0xe0 != 0xc0 || int(marker&0x07)+1 != 2 {
+			t.Errorf("Expected a superframe of 2 frames, got the marker %x", marker)
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	vp8, err := NewVP8Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp9, err := NewVP9Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp9SVC, err := NewVP9Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp9SVC.SpatialLayers = 2
+
+	cases := map[string]struct {
+		encoder codec.VideoEncoderBuilder
+		decoder codec.VideoDecoderBuilder
+		params  *Params
+	}{
+		"VP8":    {&vp8, &vp8, &vp8.Params},
+		"VP9":    {&vp9, &vp9, &vp9.Params},
+		"VP9SVC": {&vp9SVC, &vp9SVC, &vp9SVC.Params},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			c.params.BitRate = 1000000
+
+			property := prop.Media{Video: prop.Video{Width: 320, Height: 240, FrameRate: 30}}
+			q, err := codectest.MeasureQuality(
+				codectest.NewVideoReader(320, 240, 30),
+				func(r video.Reader) (codec.ReadCloser, error) {
+					return c.encoder.BuildVideoEncoder(r, property)
+				},
+				func(r codec.FrameReader) (codec.VideoDecoder, error) {
+					return c.decoder.BuildVideoDecoder(r, property)
+				},
+				30,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("PSNR: %.2f dB, SSIM: %.4f", q.MinPSNR, q.MinSSIM)
+
+			// The noise area of the source lowers PSNR.
+			if q.MinPSNR < 20 {
+				t.Errorf("Expected PSNR to be 20 dB or higher, got %.2f dB", q.MinPSNR)
+			}
+			if q.MinSSIM < 0.9 {
+				t.Errorf("Expected SSIM to be 0.9 or higher, got %.4f", q.MinSSIM)
+			}
+		})
+	}
+}
@@ -8,10 +8,13 @@
 #define ERR_ALLOC_PICTURE -3
 #define ERR_OPEN_ENGINE -4
 #define ERR_ENCODE -5
+#define ERR_RECONFIG -6
 
 typedef struct Slice {
   unsigned char *data;
   int data_len;
+  int key_frame;
+  int64_t pts;
 } Slice;
 
 typedef struct Encoder {
@@ -20,26 +23,35 @@ typedef struct Encoder {
   x264_param_t param;
 } Encoder;
 
-Encoder *enc_new(x264_param_t param, char *preset, int *rc) {
+Encoder *enc_new(x264_param_t param, char *preset, char *tune, char *profile, int *rc) {
   Encoder *e = (Encoder *)malloc(sizeof(Encoder));
 
-  if (x264_param_default_preset(&e->param, preset, "zerolatency") < 0) {
+  if (x264_param_default_preset(&e->param, preset, tune[0] ? tune : NULL) < 0) {
     free(preset);
+    free(tune);
+    free(profile);
     *rc = ERR_DEFAULT_PRESET;
     goto fail;
   }
   free(preset);
+  free(tune);
 
   /* Configure non-default params */
   e->param.i_csp = param.i_csp;
   e->param.i_width = param.i_width;
   e->param.i_height = param.i_height;
-  e->param.i_fps_num = param.i_fps_num;
-  e->param.i_fps_den = 1;
+  if (param.i_fps_num > 0) {
+    e->param.i_fps_num = param.i_fps_num;
+    e->param.i_fps_den = 1;
+  }
+  if (param.i_level_idc > 0) {
+    e->param.i_level_idc = param.i_level_idc;
+  }
   // Intra refres:
   e->param.i_keyint_max = param.i_keyint_max;
   // Rate control:
-  e->param.rc.i_rc_method = X264_RC_ABR;
+  e->param.rc.i_rc_method = param.rc.i_rc_method;
+  e->param.rc.f_rf_constant = param.rc.f_rf_constant;
   e->param.rc.i_bitrate = param.rc.i_bitrate;
   e->param.rc.i_vbv_max_bitrate = param.rc.i_vbv_max_bitrate;
   e->param.rc.i_vbv_buffer_size = param.rc.i_vbv_buffer_size;
@@ -47,10 +59,12 @@ Encoder *enc_new(x264_param_t param, char *preset, int *rc) {
   e->param.b_repeat_headers = 1;
   e->param.b_annexb = 1;
 
-  if (x264_param_apply_profile(&e->param, "baseline") < 0) {
+  if (x264_param_apply_profile(&e->param, profile) < 0) {
+    free(profile);
     *rc = ERR_APPLY_PROFILE;
     goto fail;
   }
+  free(profile);
 
   if (x264_picture_alloc(&e->pic_in, param.i_csp, param.i_width, param.i_height) < 0) {
     *rc = ERR_ALLOC_PICTURE;
@@ -71,26 +85,44 @@ fail:
   return NULL;
 }
 
-Slice enc_encode(Encoder *e, uint8_t *y, uint8_t *cb, uint8_t *cr, int *rc) {
+Slice enc_encode(Encoder *e, uint8_t *y, uint8_t *cb, uint8_t *cr, int64_t pts, int force_key_frame, int *rc) {
   x264_nal_t *nal;
   int i_nal;
 
   e->pic_in.img.plane[0] = y;
   e->pic_in.img.plane[1] = cb;
   e->pic_in.img.plane[2] = cr;
+  e->pic_in.i_pts = pts;
+  e->pic_in.i_type = force_key_frame ? X264_TYPE_IDR : X264_TYPE_AUTO;
 
   int frame_size = x264_encoder_encode(e->h, &nal, &i_nal, &e->pic_in, &e->pic_out);
   Slice s = {.data_len = frame_size};
-  if (frame_size <= 0) {
+  if (frame_size < 0) {
     *rc = ERR_ENCODE;
     return s;
   }
+  if (frame_size == 0) {
+    // The picture is buffered by the lookahead or for B-frames.
+    return s;
+  }
 
-  e->pic_in.i_pts++;
+  // The output picture can be a preceding input with lookahead or B-frames.
   s.data = nal->p_payload;
+  s.key_frame = e->pic_out.i_type == X264_TYPE_IDR;
+  s.pts = e->pic_out.i_pts;
   return s;
 }
 
+void enc_reconfig_bitrate(Encoder *e, int bitrate, int vbv_max_bitrate, int vbv_buffer_size, int *rc) {
+  e->param.rc.i_bitrate = bitrate;
+  e->param.rc.i_vbv_max_bitrate = vbv_max_bitrate;
+  e->param.rc.i_vbv_buffer_size = vbv_buffer_size;
+
+  if (x264_encoder_reconfig(e->h, &e->param) < 0) {
+    *rc = ERR_RECONFIG;
+  }
+}
+
 void enc_close(Encoder *e, int *rc) {
   x264_encoder_close(e->h);
   x264_picture_clean(&e->pic_in);
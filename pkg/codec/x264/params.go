@@ -2,6 +2,7 @@ package x264
 
 import (
 	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
 )
@@ -12,6 +13,22 @@ type Params struct {
 
 	// Faster preset has lower CPU usage but lower quality
 	Preset Preset
+	// Tune optimizes the configurations for a specific type of source or situation.
+	// The tunes other than TuneZeroLatency enable the lookahead, and B-frames with ProfileMain or
+	// ProfileHigh. The encoder returns empty frames while the pictures are buffered, and the frames
+	// are output in decoding order with B-frames.
+	Tune Tune
+	// Profile restricts the encoder to use a subset of H.264 features
+	Profile Profile
+	// Level is H.264 level_idc multiplied by 10, e.g. 31 for level 3.1.
+	// Zero lets libx264 choose the level from the resolution and the bitrate.
+	Level int
+
+	// RateControl is a rate control method
+	RateControl RateControlMode
+	// ConstantRateFactor is a quality of RateControlCRF. Lower value means higher quality.
+	// If BitRate is also given, the output is capped to BitRate by VBV.
+	ConstantRateFactor float32
 }
 
 // Preset represents a set of default configurations from libx264
@@ -30,12 +47,88 @@ const (
 	PresetPlacebo
 )
 
+// Tune represents a tuning of libx264 configurations
+type Tune int
+
+const (
+	// TuneZeroLatency disables frame lookahead and B-frames for real-time streaming
+	TuneZeroLatency Tune = iota
+	// TuneNone uses the preset without tuning
+	TuneNone
+	TuneFilm
+	TuneAnimation
+	TuneGrain
+	TuneStillImage
+	TunePSNR
+	TuneSSIM
+	TuneFastDecode
+)
+
+func (t Tune) String() string {
+	switch t {
+	case TuneZeroLatency:
+		return "zerolatency"
+	case TuneFilm:
+		return "film"
+	case TuneAnimation:
+		return "animation"
+	case TuneGrain:
+		return "grain"
+	case TuneStillImage:
+		return "stillimage"
+	case TunePSNR:
+		return "psnr"
+	case TuneSSIM:
+		return "ssim"
+	case TuneFastDecode:
+		return "fastdecode"
+	default:
+		return ""
+	}
+}
+
+// Profile represents H.264 profile
+type Profile int
+
+const (
+	ProfileBaseline Profile = iota
+	ProfileMain
+	ProfileHigh
+)
+
+func (p Profile) String() string {
+	switch p {
+	case ProfileMain:
+		return "main"
+	case ProfileHigh:
+		return "high"
+	default:
+		return "baseline"
+	}
+}
+
+// RateControlMode represents rate control method
+type RateControlMode int
+
+const (
+	// RateControlCBR keeps the bitrate constant by limiting VBV max rate to the target bitrate
+	RateControlCBR RateControlMode = iota
+	// RateControlABR keeps the average bitrate, and allows VBV max rate up to twice the target bitrate
+	RateControlABR
+	// RateControlCRF keeps the quality constant by using ConstantRateFactor
+	RateControlCRF
+)
+
 // NewParams returns default x264 codec specific parameters.
 func NewParams() (Params, error) {
 	return Params{
 		BaseParams: codec.BaseParams{
 			KeyFrameInterval: 60,
 		},
+		Tune:               TuneZeroLatency,
+		Profile:            ProfileBaseline,
+		RateControl:        RateControlCBR,
+		ConstantRateFactor: 23,
 	}, nil
 }
 
@@ -46,5 +139,13 @@ func (p *Params) RTPCodec() *codec.RTPCodec {
 
 // BuildVideoEncoder builds x264 encoder with given params
 func (p *Params) BuildVideoEncoder(r video.Reader, property prop.Media) (codec.ReadCloser, error) {
-	return newEncoder(r, property, *p)
+	return codec.NewPassthroughEncoder(r, frame.FormatH264, func(r video.Reader) (codec.ReadCloser, error) {
+		return newEncoder(r, property, *p)
+	})
+}
+
+// Passthrough returns true for frame.FormatH264, since the H.264 frames compressed by the camera are sent
+// as they are. The other frames, e.g. the black frames of a disabled track, are encoded by libx264.
+func (p *Params) Passthrough(f frame.Format) bool {
+	return f == frame.FormatH264
 }
@@ -11,6 +11,7 @@ import (
 	"image"
 	"io"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/pion/mediadevices/pkg/codec"
@@ -25,6 +26,15 @@ type encoder struct {
 	r      video.Reader
 	mu     sync.Mutex
 	closed bool
+
+	rateControl     RateControlMode
+	requireKeyFrame bool
+	tLastFrame      time.Time
+
+	// pts is the presentation timestamp of the next input picture, and times are the capture times of
+	// the pictures buffered in libx264.
+	pts   int64
+	times map[int64]time.Time
 }
 
 type cerror int
@@ -41,6 +51,8 @@ func (e cerror) Error() string {
 		return errOpenEngine.Error()
 	case C.ERR_ENCODE:
 		return errEncode.Error()
+	case C.ERR_RECONFIG:
+		return errReconfig.Error()
 	default:
 		return "unknown error"
 	}
@@ -60,38 +72,82 @@ var (
 	errAllocPicture  = fmt.Errorf("failed to alloc picture")
 	errOpenEngine    = fmt.Errorf("failed to open x264")
 	errEncode        = fmt.Errorf("failed to encode")
+	errReconfig      = fmt.Errorf("failed to reconfigure x264")
+	errNoVBV         = fmt.Errorf("bitrate can't be changed in CRF mode without initial BitRate")
 )
 
+// toKbps converts the bitrate from bit/s to kbit/s, which x264 uses. It's rounded to the nearest value,
+// but kept at least 1 kbit/s unless it's 0.
+// Reference: https://code.videolan.org/videolan/x264/-/blob/7923c5818b50a3d8816eed222a7c43b418a73b36/encoder/ratecontrol.c#L657
+func toKbps(b int) int {
+	if b <= 0 {
+		return 0
+	}
+	kbps := (b + 500) / 1000
+	if kbps < 1 {
+		kbps = 1
+	}
+	return kbps
+}
+
+// vbv returns VBV max bitrate in kbit/s and VBV buffer size in kbit for
+// the rate control method and the target bitrate in kbit/s.
+// Zero values mean that VBV is disabled. x264_encoder_reconfig changes the
+// bitrate only if VBV is enabled, so it's enabled for every method with a bitrate.
+func vbv(rc RateControlMode, bitRate int) (int, int) {
+	if rc == RateControlABR {
+		// ABR allows the peaks up to twice the target bitrate
+		return bitRate * 2, bitRate * 2
+	}
+	// CBR, and CRF with BitRate, are capped by the target bitrate
+	return bitRate, bitRate * 2
+}
+
 func newEncoder(r video.Reader, p prop.Media, params Params) (codec.ReadCloser, error) {
 	if params.KeyFrameInterval == 0 {
 		params.KeyFrameInterval = 60
 	}
 
-	// Convert from bit/s to kbit/s because x264 uses kbit/s instead.
-	// Reference: https://code.videolan.org/videolan/x264/-/blob/7923c5818b50a3d8816eed222a7c43b418a73b36/encoder/ratecontrol.c#L657
-	params.BitRate /= 1000
+	if params.BitRate < 0 {
+		return nil, fmt.Errorf("invalid bitrate: %d", params.BitRate)
+	}
+	params.BitRate = toKbps(params.BitRate)
 
 	param := C.x264_param_t{
 		i_csp:        C.X264_CSP_I420,
 		i_width:      C.int(p.Width),
 		i_height:     C.int(p.Height),
+		i_fps_num:    C.uint32_t(p.FrameRate),
 		i_keyint_max: C.int(params.KeyFrameInterval),
+		i_level_idc:  C.int(params.Level),
+	}
+	switch params.RateControl {
+	case RateControlCRF:
+		param.rc.i_rc_method = C.X264_RC_CRF
+		param.rc.f_rf_constant = C.float(params.ConstantRateFactor)
+	default:
+		param.rc.i_rc_method = C.X264_RC_ABR
 	}
+	vbvMaxBitRate, vbvBufferSize := vbv(params.RateControl, params.BitRate)
 	param.rc.i_bitrate = C.int(params.BitRate)
-	param.rc.i_vbv_max_bitrate = param.rc.i_bitrate
-	param.rc.i_vbv_buffer_size = param.rc.i_vbv_max_bitrate * 2
+	param.rc.i_vbv_max_bitrate = C.int(vbvMaxBitRate)
+	param.rc.i_vbv_buffer_size = C.int(vbvBufferSize)
 
 	var rc C.int
-	// cPreset will be freed in C.enc_new
+	// cPreset, cTune, and cProfile will be freed in C.enc_new
 	cPreset := C.CString(fmt.Sprint(params.Preset))
-	engine := C.enc_new(param, cPreset, &rc)
+	cTune := C.CString(params.Tune.String())
+	cProfile := C.CString(params.Profile.String())
+	engine := C.enc_new(param, cPreset, cTune, cProfile, &rc)
 	if err := errFromC(rc); err != nil {
 		return nil, err
 	}
 
 	e := encoder{
-		engine: engine,
-		r:      video.ToI420(r),
+		engine:      engine,
+		r:           video.ToI420(r),
+		rateControl: params.RateControl,
+		times:       make(map[int64]time.Time),
 	}
 	return &e, nil
 }
@@ -112,11 +168,41 @@ func (e *encoder) Read(p []byte) (int, error) {
 		return n, err
 	}
 
-	img, err := e.r.Read()
+	frame, err := e.readFrame()
 	if err != nil {
 		return 0, err
 	}
+	n, err := mio.Copy(p, frame.Data)
+	if err != nil {
+		e.buff = frame.Data
+	}
+	return n, err
+}
+
+// ReadFrame encodes the next frame and returns it with its metadata.
+func (e *encoder) ReadFrame() (codec.EncodedFrame, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return codec.EncodedFrame{}, io.EOF
+	}
+
+	return e.readFrame()
+}
+
+func (e *encoder) readFrame() (codec.EncodedFrame, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
 	yuvImg := img.(*image.YCbCr)
+	t := video.Timestamp(e.r)
+
+	var forceKeyFrame C.int
+	if e.requireKeyFrame {
+		forceKeyFrame = 1
+	}
 
 	var rc C.int
 	s := C.enc_encode(
@@ -124,26 +210,83 @@ func (e *encoder) Read(p []byte) (int, error) {
 		(*C.uchar)(&yuvImg.Y[0]),
 		(*C.uchar)(&yuvImg.Cb[0]),
 		(*C.uchar)(&yuvImg.Cr[0]),
+		C.int64_t(e.pts),
+		forceKeyFrame,
 		&rc,
 	)
 	if err := errFromC(rc); err != nil {
-		return 0, err
+		return codec.EncodedFrame{}, err
 	}
+	e.requireKeyFrame = false
+	e.times[e.pts] = t
+	e.pts++
 
-	encoded := C.GoBytes(unsafe.Pointer(s.data), s.data_len)
-	n, err := mio.Copy(p, encoded)
-	if err != nil {
-		e.buff = encoded
+	if s.data_len == 0 {
+		// The picture is buffered, and will be output by the following calls.
+		return codec.EncodedFrame{Timestamp: t}, nil
 	}
-	return n, err
+
+	// The output can be a picture read before with lookahead or B-frames, so its capture time is looked up.
+	pts := int64(s.pts)
+	if tCapture, ok := e.times[pts]; ok {
+		t = tCapture
+		delete(e.times, pts)
+	}
+
+	// With B-frames, the pictures are output in decoding order, and the timestamps can go backward.
+	var duration time.Duration
+	if !e.tLastFrame.IsZero() && t.After(e.tLastFrame) {
+		duration = t.Sub(e.tLastFrame)
+	}
+	if t.After(e.tLastFrame) {
+		e.tLastFrame = t
+	}
+
+	return codec.EncodedFrame{
+		Data:      C.GoBytes(unsafe.Pointer(s.data), s.data_len),
+		KeyFrame:  s.key_frame != 0,
+		Timestamp: t,
+		Duration:  duration,
+	}, nil
 }
 
+// SetBitRate reconfigures the target bitrate and VBV of the running encoder.
 func (e *encoder) SetBitRate(b int) error {
-	panic("SetBitRate is not implemented")
+	if b <= 0 {
+		return fmt.Errorf("invalid bitrate: %d", b)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	// VBV can't be enabled by reconfiguration if it was disabled on open.
+	if e.rateControl == RateControlCRF && e.engine.param.rc.i_vbv_max_bitrate == 0 {
+		return errNoVBV
+	}
+
+	bitRate := toKbps(b)
+	vbvMaxBitRate, vbvBufferSize := vbv(e.rateControl, bitRate)
+
+	var rc C.int
+	C.enc_reconfig_bitrate(e.engine, C.int(bitRate), C.int(vbvMaxBitRate), C.int(vbvBufferSize), &rc)
+	return errFromC(rc)
 }
 
+// ForceKeyFrame forces the next encoded frame to be an IDR frame.
 func (e *encoder) ForceKeyFrame() error {
-	panic("ForceKeyFrame is not implemented")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return io.EOF
+	}
+
+	e.requireKeyFrame = true
+	return nil
 }
 
 func (e *encoder) Close() error {
@@ -0,0 +1,184 @@
+package x264
+
+import (
+	"testing"
+	"time"
+
+	codectest "github.com/pion/mediadevices/internal/codec"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// newEncoderTestCase returns x264 to be checked by the tests shared by the codecs.
+func newEncoderTestCase(t *testing.T, rc RateControlMode) codectest.VideoEncoderTestCase {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RateControl = rc
+	return codectest.VideoEncoderTestCase{Builder: &p, Params: &p.BaseParams, IsKeyFrame: codectest.IsKeyFrameH264}
+}
+
+func TestForceKeyFrame(t *testing.T) {
+	if err := newEncoderTestCase(t, RateControlCBR).VerifyForceKeyFrame(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	if err := newEncoderTestCase(t, RateControlCBR).VerifyReadFrame(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetBitRate(t *testing.T) {
+	for name, rc := range map[string]RateControlMode{
+		"CBR": RateControlCBR,
+		"ABR": RateControlABR,
+	} {
+		rc := rc
+		t.Run(name, func(t *testing.T) {
+			before, after, err := newEncoderTestCase(t, rc).VerifySetBitRate(0.5)
+			t.Logf("bitrate before: %.0f, after: %.0f", before, after)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestVBV(t *testing.T) {
+	// VBV has to be enabled for x264_encoder_reconfig to change the bitrate.
+	testCases := map[string]struct {
+		rc                           RateControlMode
+		expectedMaxRate, expectedBuf int
+	}{
+		"CBR": {rc: RateControlCBR, expectedMaxRate: 500, expectedBuf: 1000},
+		"ABR": {rc: RateControlABR, expectedMaxRate: 1000, expectedBuf: 1000},
+		"CRF": {rc: RateControlCRF, expectedMaxRate: 500, expectedBuf: 1000},
+	}
+	for name, testCase := range testCases {
+		maxRate, buf := vbv(testCase.rc, 500)
+		if maxRate != testCase.expectedMaxRate || buf != testCase.expectedBuf {
+			t.Errorf("%s: Expected VBV of %d kbit/s and %d kbit, got %d kbit/s and %d kbit",
+				name, testCase.expectedMaxRate, testCase.expectedBuf, maxRate, buf)
+		}
+	}
+}
+
+func TestToKbps(t *testing.T) {
+	testCases := map[int]int{
+		0:       0,
+		1:       1,
+		499:     1,
+		1499:    1,
+		1500:    2,
+		1000000: 1000,
+	}
+	for b, expected := range testCases {
+		if kbps := toKbps(b); kbps != expected {
+			t.Errorf("Expected %d kbit/s for %d bit/s, got %d", expected, b, kbps)
+		}
+	}
+}
+
+func TestLookahead(t *testing.T) {
+	p, err := NewParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pictures are buffered by the lookahead of the preset without zerolatency tune.
+	p.Preset = PresetMedium
+	p.Tune = TuneFilm
+	p.BitRate = 200000
+
+	e, err := p.BuildVideoEncoder(
+		codectest.NewVideoReader(320, 240, 30),
+		prop.Media{Video: prop.Video{Width: 320, Height: 240, FrameRate: 30}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	r := e.(codec.FrameReader)
+	var buffered int
+	for i := 0; i < 100; i++ {
+		start := time.Now()
+		f, err := r.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Data) == 0 {
+			buffered++
+			continue
+		}
+
+		if buffered == 0 {
+			t.Fatal("Expected the first pictures to be buffered")
+		}
+		if !f.KeyFrame || !codectest.IsKeyFrameH264(f.Data) {
+			t.Error("Expected the first output to be a key frame")
+		}
+		// The output is the picture read by the first call.
+		if !f.Timestamp.Before(start) {
+			t.Errorf("Expected the timestamp of the picture read before %v, got %v", start, f.Timestamp)
+		}
+		return
+	}
+	t.Fatal("No output from the encoder")
+}
+
+func TestSetBitRateCRF(t *testing.T) {
+	testCases := map[string]struct {
+		bitRate     int
+		expectError bool
+	}{
+		"WithoutVBV": {bitRate: 0, expectError: true},
+		"WithVBV":    {bitRate: 400000, expectError: false},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			p, err := NewParams()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.BitRate = testCase.bitRate
+			p.RateControl = RateControlCRF
+
+			e, err := p.BuildVideoEncoder(
+				codectest.NewVideoReader(320, 240, 30),
+				prop.Media{Video: prop.Video{Width: 320, Height: 240}},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+
+			err = e.SetBitRate(100000)
+			if testCase.expectError && err == nil {
+				t.Error("Expected error, but got nil")
+			}
+			if !testCase.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestParamsString(t *testing.T) {
+	if s := TuneZeroLatency.String(); s != "zerolatency" {
+		t.Errorf("Expected zerolatency, got %s", s)
+	}
+	if s := TuneNone.String(); s != "" {
+		t.Errorf("Expected empty tune, got %s", s)
+	}
+	if s := ProfileBaseline.String(); s != "baseline" {
+		t.Errorf("Expected baseline, got %s", s)
+	}
+	if s := ProfileHigh.String(); s != "high" {
+		t.Errorf("Expected high, got %s", s)
+	}
+}
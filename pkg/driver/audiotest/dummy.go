@@ -52,14 +52,15 @@ func (d *dummy) AudioRecord(p prop.Media) (audio.Reader, error) {
 
 	closed := d.closed
 
-	reader := audio.ReaderFunc(func() (wave.Audio, error) {
+	reader := audio.NewTimestampReader(func() (wave.Audio, time.Time, error) {
 		select {
 		case <-closed:
-			return nil, io.EOF
+			return nil, time.Time{}, io.EOF
 		default:
 		}
 
-		time.Sleep(nextReadTime.Sub(time.Now()))
+		t := nextReadTime
+		time.Sleep(t.Sub(time.Now()))
 		nextReadTime = nextReadTime.Add(p.Latency)
 
 		a := wave.NewFloat32Interleaved(
@@ -78,7 +79,7 @@ func (d *dummy) AudioRecord(p prop.Media) (audio.Reader, error) {
 				a.SetFloat32(i, ch, wave.Float32Sample(sin[phase]))
 			}
 		}
-		return a, nil
+		return a, t, nil
 	})
 	return reader, nil
 }
@@ -10,6 +10,7 @@ import (
 	"io"
 	"io/ioutil"
 	"sync"
+	"time"
 
 	"github.com/blackjack/webcam"
 	"github.com/pion/mediadevices/pkg/driver"
@@ -62,6 +63,7 @@ func newCamera(path string) *camera {
 		webcam.PixelFormat(C.V4L2_PIX_FMT_UYVY):   frame.FormatUYVY,
 		webcam.PixelFormat(C.V4L2_PIX_FMT_NV12):   frame.FormatNV21,
 		webcam.PixelFormat(C.V4L2_PIX_FMT_MJPEG):  frame.FormatMJPEG,
+		webcam.PixelFormat(C.V4L2_PIX_FMT_H264):   frame.FormatH264,
 	}
 
 	reversedFormats := make(map[frame.Format]webcam.PixelFormat)
@@ -116,9 +118,21 @@ func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
+	return c.record(p, decoder)
+}
+
+// EncodedVideoRecord records MJPEG or H.264 frames without decoding them.
+func (c *camera) EncodedVideoRecord(p prop.Media) (video.Reader, error) {
+	decoder, err := frame.NewCompressedDecoder(p.FrameFormat)
+	if err != nil {
+		return nil, err
+	}
+	return c.record(p, decoder)
+}
 
+func (c *camera) record(p prop.Media, decoder frame.Decoder) (video.Reader, error) {
 	pf := c.reversedFormats[p.FrameFormat]
-	_, _, _, err = c.cam.SetImageFormat(pf, uint32(p.Width), uint32(p.Height))
+	_, _, _, err := c.cam.SetImageFormat(pf, uint32(p.Width), uint32(p.Height))
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +146,7 @@ func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancel = cancel
 	var buf []byte
-	r := video.ReaderFunc(func() (img image.Image, err error) {
+	r := video.NewTimestampReader(func() (img image.Image, t time.Time, err error) {
 		// Lock to avoid accessing the buffer after StopStreaming()
 		c.mutex.Lock()
 		defer c.mutex.Unlock()
@@ -141,23 +155,24 @@ func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
 		for i := 0; i < maxEmptyFrameCount; i++ {
 			if ctx.Err() != nil {
 				// Return EOF if the camera is already closed.
-				return nil, io.EOF
+				return nil, time.Time{}, io.EOF
 			}
 
 			err := cam.WaitForFrame(5) // 5 seconds
 			switch err.(type) {
 			case nil:
 			case *webcam.Timeout:
-				return nil, errReadTimeout
+				return nil, time.Time{}, errReadTimeout
 			default:
 				// Camera has been stopped.
-				return nil, err
+				return nil, time.Time{}, err
 			}
 
+			t = time.Now()
 			b, err := cam.ReadFrame()
 			if err != nil {
 				// Camera has been stopped.
-				return nil, err
+				return nil, time.Time{}, err
 			}
 
 			// Frame is empty.
@@ -175,9 +190,10 @@ func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
 			// from this reader will be Go safe. Otherwise, it's possible that outside of this reader
 			// that this memory is still being used even after we close it.
 			n := copy(buf, b)
-			return decoder.Decode(buf[:n], p.Width, p.Height)
+			img, err := decoder.Decode(buf[:n], p.Width, p.Height)
+			return img, t, err
 		}
-		return nil, errEmptyFrame
+		return nil, time.Time{}, errEmptyFrame
 	})
 
 	return r, nil
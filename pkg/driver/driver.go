@@ -10,6 +10,17 @@ type VideoRecorder interface {
 	VideoRecord(p prop.Media) (r video.Reader, err error)
 }
 
+// EncodedVideoRecorder is a VideoRecorder which can also record the video compressed by the device, e.g.
+// MJPEG or H.264 of UVC cameras. The properties of the compressed formats are listed in Properties, and the
+// video in them is recorded by EncodedVideoRecord, so that it's passed to the encoders accepting the format
+// without decoding and re-encoding.
+type EncodedVideoRecorder interface {
+	VideoRecorder
+	// EncodedVideoRecord records the video compressed in p.FrameFormat. The images read from r are
+	// frame.Compressed.
+	EncodedVideoRecord(p prop.Media) (r video.Reader, err error)
+}
+
 type AudioRecorder interface {
 	AudioRecord(p prop.Media) (r audio.Reader, err error)
 }
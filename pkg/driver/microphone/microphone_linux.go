@@ -14,7 +14,13 @@ import (
 type microphone struct {
 	c           *pulse.Client
 	id          string
-	samplesChan chan<- []int16
+	samplesChan chan<- samples
+}
+
+// samples is a chunk of samples with the time when it's delivered by pulseaudio.
+type samples struct {
+	data []int16
+	t    time.Time
 }
 
 func init() {
@@ -85,10 +91,10 @@ func (m *microphone) AudioRecord(p prop.Media) (audio.Reader, error) {
 		pulse.RecordSource(src),
 	)
 
-	samplesChan := make(chan []int16, 1)
+	samplesChan := make(chan samples, 1)
 
 	handler := func(b []int16) (int, error) {
-		samplesChan <- b
+		samplesChan <- samples{data: b, t: time.Now()}
 		return len(b), nil
 	}
 
@@ -97,12 +103,13 @@ func (m *microphone) AudioRecord(p prop.Media) (audio.Reader, error) {
 		return nil, err
 	}
 
-	reader := audio.ReaderFunc(func() (wave.Audio, error) {
-		buff, ok := <-samplesChan
+	reader := audio.NewTimestampReader(func() (wave.Audio, time.Time, error) {
+		s, ok := <-samplesChan
 		if !ok {
 			stream.Close()
-			return nil, io.EOF
+			return nil, time.Time{}, io.EOF
 		}
+		buff := s.data
 
 		a := wave.NewInt16Interleaved(
 			wave.ChunkInfo{
@@ -112,7 +119,7 @@ func (m *microphone) AudioRecord(p prop.Media) (audio.Reader, error) {
 		)
 		copy(a.Data, buff)
 
-		return a, nil
+		return a, s.t, nil
 	})
 
 	stream.Start()
@@ -103,14 +103,14 @@ func (d *dummy) VideoRecord(p prop.Media) (video.Reader, error) {
 	d.tick = tick
 	closed := d.closed
 
-	r := video.ReaderFunc(func() (image.Image, error) {
+	r := video.NewTimestampReader(func() (image.Image, time.Time, error) {
 		select {
 		case <-closed:
-			return nil, io.EOF
+			return nil, time.Time{}, io.EOF
 		default:
 		}
 
-		<-tick.C
+		t := <-tick.C
 
 		copy(yy, yyBase)
 		copy(cb, cbBase)
@@ -130,7 +130,7 @@ func (d *dummy) VideoRecord(p prop.Media) (video.Reader, error) {
 			CStride:        p.Width / 2,
 			SubsampleRatio: image.YCbCrSubsampleRatio422,
 			Rect:           image.Rect(0, 0, p.Width, p.Height),
-		}, nil
+		}, t, nil
 	})
 
 	return r, nil
@@ -17,6 +17,14 @@ func wrapAdapter(a Adapter, info Info) Driver {
 	}
 
 	switch v := a.(type) {
+	case EncodedVideoRecorder:
+		// Only expose Driver and EncodedVideoRecorder interfaces
+		d.VideoRecorder = v
+		d.encodedVideoRecorder = v
+		return &struct {
+			Driver
+			EncodedVideoRecorder
+		}{d, d}
 	case VideoRecorder:
 		// Only expose Driver and VideoRecorder interfaces
 		d.VideoRecorder = v
@@ -41,9 +49,10 @@ type adapterWrapper struct {
 	Adapter
 	VideoRecorder
 	AudioRecorder
-	id    string
-	info  Info
-	state State
+	encodedVideoRecorder EncodedVideoRecorder
+	id                   string
+	info                 Info
+	state                State
 }
 
 func (w *adapterWrapper) ID() string {
@@ -89,6 +98,17 @@ func (w *adapterWrapper) VideoRecord(p prop.Media) (r video.Reader, err error) {
 	return
 }
 
+func (w *adapterWrapper) EncodedVideoRecord(p prop.Media) (r video.Reader, err error) {
+	err = w.state.Update(StateRunning, func() error {
+		r, err = w.encodedVideoRecorder.EncodedVideoRecord(p)
+		return err
+	})
+	if err != nil {
+		_ = w.Close()
+	}
+	return
+}
+
 func (w *adapterWrapper) AudioRecord(p prop.Media) (r audio.Reader, err error) {
 	err = w.state.Update(StateRunning, func() error {
 		r, err = w.AudioRecorder.AudioRecord(p)
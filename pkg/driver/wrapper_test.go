@@ -29,6 +29,12 @@ func (a *videoAdapterBrokenMock) VideoRecord(p prop.Media) (r video.Reader, err
 	return nil, recordErr
 }
 
+type encodedVideoAdapterMock struct{ videoAdapterMock }
+
+func (a *encodedVideoAdapterMock) EncodedVideoRecord(p prop.Media) (r video.Reader, err error) {
+	return nil, nil
+}
+
 type audioAdapterMock struct{ adapterMock }
 
 func (a *audioAdapterMock) AudioRecord(p prop.Media) (r audio.Reader, err error) { return nil, nil }
@@ -88,6 +94,33 @@ func TestVideoWrapperWithBrokenRecorderState(t *testing.T) {
 	}
 }
 
+func TestEncodedVideoWrapperState(t *testing.T) {
+	var a encodedVideoAdapterMock
+	d := wrapAdapter(&a, Info{})
+
+	evr, ok := d.(EncodedVideoRecorder)
+	if !ok {
+		t.Fatal("expected the driver to expose EncodedVideoRecorder")
+	}
+	_, err := evr.EncodedVideoRecord(prop.Media{})
+	if err == nil {
+		t.Errorf("expected to get an invalid state")
+	}
+
+	err = d.Open()
+	if err != nil {
+		t.Errorf("expected to successfully open, but got %v", err)
+	}
+
+	_, err = evr.EncodedVideoRecord(prop.Media{})
+	if err != nil {
+		t.Errorf("expected to successfully start recording, but got %v", err)
+	}
+	if d.Status() != StateRunning {
+		t.Errorf("expected the status to be %v, but got %v", StateRunning, d.Status())
+	}
+}
+
 func TestAudioWrapperState(t *testing.T) {
 	var a audioAdapterMock
 	d := wrapAdapter(&a, Info{})
@@ -3,9 +3,154 @@ package frame
 import (
 	"bytes"
 	"image"
+	"image/color"
 	"image/jpeg"
+	"sync"
 )
 
+// Compressed is an image which keeps the frame compressed by the device, so that the encoders accepting
+// the format can send it without decoding and re-encoding.
+type Compressed interface {
+	image.Image
+	// Format returns the format of the compressed frame.
+	Format() Format
+	// Bytes returns the compressed frame. It must not be modified.
+	Bytes() []byte
+	// KeyFrame returns true if the frame can be decoded without any preceding frames.
+	KeyFrame() bool
+}
+
+// JPEG is an image compressed in JPEG. The pixels are decoded when they are accessed first.
+type JPEG struct {
+	data   []byte
+	config image.Config
+	once   sync.Once
+	img    image.Image
+	err    error
+}
+
+// NewJPEG creates a JPEG from the compressed data, from SOI to EOI markers. Only the headers are parsed here.
+// The data must not be modified after calling NewJPEG.
+func NewJPEG(data []byte) (*JPEG, error) {
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &JPEG{data: data, config: config}, nil
+}
+
+// Format implements Compressed.
+func (j *JPEG) Format() Format {
+	return FormatMJPEG
+}
+
+// Bytes implements Compressed.
+func (j *JPEG) Bytes() []byte {
+	return j.data
+}
+
+// KeyFrame implements Compressed. Every JPEG image is a key frame.
+func (j *JPEG) KeyFrame() bool {
+	return true
+}
+
+// Decode decodes the image. The decoded image is cached, and the subsequent calls return the same one.
+func (j *JPEG) Decode() (image.Image, error) {
+	j.once.Do(func() {
+		j.img, j.err = jpeg.Decode(bytes.NewReader(j.data))
+	})
+	return j.img, j.err
+}
+
+// ColorModel implements image.Image.
+func (j *JPEG) ColorModel() color.Model {
+	return j.config.ColorModel
+}
+
+// Bounds implements image.Image.
+func (j *JPEG) Bounds() image.Rectangle {
+	return image.Rect(0, 0, j.config.Width, j.config.Height)
+}
+
+// At implements image.Image. It returns black if the image can't be decoded.
+func (j *JPEG) At(x, y int) color.Color {
+	img, err := j.Decode()
+	if err != nil {
+		return j.config.ColorModel.Convert(color.Black)
+	}
+	return img.At(x, y)
+}
+
+// H264 is a frame compressed in H.264, which is an access unit in Annex B byte stream format.
+// It can't be decoded, so all the pixels are black.
+type H264 struct {
+	data     []byte
+	rect     image.Rectangle
+	keyFrame bool
+}
+
+// nalUnitTypeIDR is the type of the NAL units of an IDR picture.
+const nalUnitTypeIDR = 5
+
+// NewH264 creates an H264 from an access unit of the video of the given size.
+// The data must not be modified after calling NewH264.
+func NewH264(data []byte, width, height int) *H264 {
+	h := &H264{data: data, rect: image.Rect(0, 0, width, height)}
+	// Look for the NAL units following the start codes.
+	for i := 0; i+3 < len(data); i++ {
+		if data[i] == 0 && data[i+1] == 0 && data[i+2] == 1 && data[i+3]&0x1f == nalUnitTypeIDR {
+			h.keyFrame = true
+			break
+		}
+	}
+	return h
+}
+
+// Format implements Compressed.
+func (h *H264) Format() Format {
+	return FormatH264
+}
+
+// Bytes implements Compressed.
+func (h *H264) Bytes() []byte {
+	return h.data
+}
+
+// KeyFrame implements Compressed. It's true if the access unit has an IDR picture.
+func (h *H264) KeyFrame() bool {
+	return h.keyFrame
+}
+
+// ColorModel implements image.Image.
+func (h *H264) ColorModel() color.Model {
+	return color.YCbCrModel
+}
+
+// Bounds implements image.Image.
+func (h *H264) Bounds() image.Rectangle {
+	return h.rect
+}
+
+// At implements image.Image. It always returns black.
+func (h *H264) At(x, y int) color.Color {
+	return color.YCbCr{Y: 16, Cb: 128, Cr: 128}
+}
+
 func decodeMJPEG(frame []byte, width, height int) (image.Image, error) {
 	return jpeg.Decode(bytes.NewReader(frame))
 }
+
+func wrapMJPEG(frame []byte, width, height int) (image.Image, error) {
+	return NewJPEG(copyFrame(frame))
+}
+
+func wrapH264(frame []byte, width, height int) (image.Image, error) {
+	return NewH264(copyFrame(frame), width, height), nil
+}
+
+// copyFrame copies the frame, since the frame buffer is reused by the driver.
+func copyFrame(frame []byte) []byte {
+	data := make([]byte, len(frame))
+	copy(data, frame)
+	return data
+}
@@ -0,0 +1,161 @@
+package frame
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"reflect"
+	"testing"
+)
+
+// newTestJPEG returns a gray image compressed in JPEG and its decoded image.
+func newTestJPEG(t *testing.T) ([]byte, image.Image) {
+	src := image.NewGray(image.Rect(0, 0, 16, 8))
+	for i := range src.Pix {
+		src.Pix[i] = 0x80
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), expected
+}
+
+func TestDecodeMJPEG(t *testing.T) {
+	input, expected := newTestJPEG(t)
+
+	decoder, err := NewDecoder(FormatMJPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := decoder.Decode(input, 16, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Expected the decoded %T, got %T", expected, img)
+	}
+}
+
+func TestWrapMJPEG(t *testing.T) {
+	input, expected := newTestJPEG(t)
+
+	decoder, err := NewCompressedDecoder(FormatMJPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := decoder.Decode(input, 16, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, ok := img.(*JPEG)
+	if !ok {
+		t.Fatalf("Expected *JPEG, got %T", img)
+	}
+	if compressed.img != nil {
+		t.Error("Expected the image not to be decoded before accessing the pixels")
+	}
+	if compressed.Bounds() != expected.Bounds() {
+		t.Errorf("Expected bounds %v, got %v", expected.Bounds(), compressed.Bounds())
+	}
+	if compressed.ColorModel() != color.GrayModel {
+		t.Errorf("Expected gray color model, got %v", compressed.ColorModel())
+	}
+
+	// The driver reuses the frame buffer.
+	data := append([]byte{}, input...)
+	for i := range input {
+		input[i] = 0
+	}
+	if !bytes.Equal(data, compressed.Bytes()) {
+		t.Error("Expected the compressed data to be copied")
+	}
+
+	decoded, err := compressed.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, decoded) {
+		t.Error("Wrong decode result")
+	}
+	if c := compressed.At(1, 1); c != expected.At(1, 1) {
+		t.Errorf("Expected %v, got %v", expected.At(1, 1), c)
+	}
+}
+
+func TestDecodeMJPEGInvalid(t *testing.T) {
+	for name, newDecoder := range map[string]func(Format) (Decoder, error){
+		"Decoder":           NewDecoder,
+		"CompressedDecoder": NewCompressedDecoder,
+	} {
+		newDecoder := newDecoder
+		t.Run(name, func(t *testing.T) {
+			decoder, err := newDecoder(FormatMJPEG)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := decoder.Decode([]byte{0x00, 0x01}, 16, 8); err == nil {
+				t.Error("Expected an error for invalid data")
+			}
+		})
+	}
+}
+
+func TestH264KeyFrame(t *testing.T) {
+	testCases := map[string]struct {
+		data     []byte
+		keyFrame bool
+	}{
+		"IDR": {
+			// SPS, PPS and IDR slice
+			data:     []byte{0, 0, 0, 1, 0x67, 0x42, 0, 0, 0, 1, 0x68, 0xce, 0, 0, 1, 0x65, 0x88},
+			keyFrame: true,
+		},
+		"NonIDR": {
+			data:     []byte{0, 0, 0, 1, 0x41, 0x9a},
+			keyFrame: false,
+		},
+		"Empty": {
+			data:     []byte{},
+			keyFrame: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			decoder, err := NewCompressedDecoder(FormatH264)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := decoder.Decode(testCase.data, 16, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compressed := img.(Compressed)
+			if compressed.Format() != FormatH264 {
+				t.Errorf("Expected %s, got %s", FormatH264, compressed.Format())
+			}
+			if compressed.KeyFrame() != testCase.keyFrame {
+				t.Errorf("Expected KeyFrame to be %v, got %v", testCase.keyFrame, compressed.KeyFrame())
+			}
+			if !bytes.Equal(compressed.Bytes(), testCase.data) {
+				t.Errorf("Expected %v, got %v", testCase.data, compressed.Bytes())
+			}
+			if img.Bounds() != image.Rect(0, 0, 16, 8) {
+				t.Errorf("Expected 16x8, got %v", img.Bounds())
+			}
+		})
+	}
+}
+
+func TestNewCompressedDecoderUnsupported(t *testing.T) {
+	if _, err := NewCompressedDecoder(FormatI420); err == nil {
+		t.Error("Expected an error for an uncompressed format")
+	}
+}
@@ -19,6 +19,9 @@ const (
 
 	// FormatMJPEG https://www.fourcc.org/mjpg/
 	FormatMJPEG = "MJPEG"
+	// FormatH264 is H.264 in Annex B byte stream format, which is output by some cameras.
+	// https://www.fourcc.org/h264/
+	FormatH264 Format = "H264"
 )
 
 const FormatYUYV = FormatYUY2
@@ -24,3 +24,20 @@ func NewDecoder(f Format) (Decoder, error) {
 
 	return decoder, nil
 }
+
+// NewCompressedDecoder returns a decoder which wraps the frames compressed in f into Compressed images
+// without decoding them.
+func NewCompressedDecoder(f Format) (Decoder, error) {
+	var decoder decoderFunc
+
+	switch f {
+	case FormatMJPEG:
+		decoder = wrapMJPEG
+	case FormatH264:
+		decoder = wrapH264
+	default:
+		return nil, fmt.Errorf("%s is not a compressed format", f)
+	}
+
+	return decoder, nil
+}
@@ -0,0 +1,156 @@
+package audio
+
+import (
+	"math"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// NewResampler creates audio transform to convert the sampling rate to sampleRate.
+// When the sampling rate is raised, the samples are linearly interpolated. When it's lowered, the
+// samples are interpolated by a windowed sinc whose cutoff is at the Nyquist frequency of sampleRate,
+// which removes the frequencies that would be aliased. The windowed sinc delays the signal by
+// sincZeroCrossings output samples.
+func NewResampler(sampleRate int) TransformFunc {
+	return func(r Reader) Reader {
+		var rs *resampler
+		return ReaderFunc(func() (wave.Audio, error) {
+			buff, err := r.Read()
+			if err != nil {
+				return nil, err
+			}
+			ci := buff.ChunkInfo()
+			if ci.SamplingRate == sampleRate {
+				return buff, nil
+			}
+			if rs == nil || rs.inRate != ci.SamplingRate || rs.channels != ci.Channels {
+				rs = newResampler(ci.SamplingRate, sampleRate, ci.Channels)
+			}
+
+			switch b := buff.(type) {
+			case *wave.Int16Interleaved:
+				in := make([]float64, len(b.Data))
+				for i, s := range b.Data {
+					in[i] = float64(s)
+				}
+				out := rs.process(in)
+				resampled := &wave.Int16Interleaved{
+					Data: make([]int16, len(out)),
+					Size: wave.ChunkInfo{Len: len(out) / ci.Channels, Channels: ci.Channels, SamplingRate: sampleRate},
+				}
+				for i, s := range out {
+					resampled.Data[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(s))))
+				}
+				return resampled, nil
+
+			case *wave.Float32Interleaved:
+				in := make([]float64, len(b.Data))
+				for i, s := range b.Data {
+					in[i] = float64(s)
+				}
+				out := rs.process(in)
+				resampled := &wave.Float32Interleaved{
+					Data: make([]float32, len(out)),
+					Size: wave.ChunkInfo{Len: len(out) / ci.Channels, Channels: ci.Channels, SamplingRate: sampleRate},
+				}
+				for i, s := range out {
+					resampled.Data[i] = float32(s)
+				}
+				return resampled, nil
+
+			default:
+				return nil, errUnsupported
+			}
+		})
+	}
+}
+
+// sincZeroCrossings is the number of the zero crossings of the windowed sinc on each side.
+// The stopband attenuation of the Blackman window is around 74 dB.
+const sincZeroCrossings = 16
+
+// resampler keeps the state of the resampling across the chunks.
+type resampler struct {
+	inRate, channels int
+	// step is the interval of the output samples in the input samples.
+	step float64
+
+	// kernel is the interpolation kernel, which is 0 outside of (-halfWidth, halfWidth). The output
+	// sample at pos is interpolated around pos-delay, so that the kernel doesn't need the future samples.
+	kernel           func(t float64) float64
+	halfWidth, delay float64
+
+	// buf holds the interleaved input samples which are still needed by the kernel.
+	buf []float64
+	// pos is the position of the next output sample in buf.
+	pos float64
+}
+
+func newResampler(inRate, outRate, channels int) *resampler {
+	r := &resampler{
+		inRate:   inRate,
+		channels: channels,
+		step:     float64(inRate) / float64(outRate),
+	}
+	if r.step <= 1 {
+		r.kernel = linearKernel
+		r.halfWidth = 1
+		return r
+	}
+
+	// The cutoff of the sinc is at the output Nyquist frequency.
+	ratio := 1 / r.step
+	r.halfWidth = sincZeroCrossings * r.step
+	r.delay = r.halfWidth
+	r.kernel = func(t float64) float64 {
+		return ratio * sinc(ratio*t) * blackman(t/r.halfWidth)
+	}
+	return r
+}
+
+func linearKernel(t float64) float64 {
+	return math.Max(0, 1-math.Abs(t))
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman is the Blackman window over [-1, 1].
+func blackman(x float64) float64 {
+	if x <= -1 || 1 <= x {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
+
+// process resamples the interleaved samples.
+func (r *resampler) process(in []float64) []float64 {
+	r.buf = append(r.buf, in...)
+	n := len(r.buf) / r.channels
+
+	// The samples before the first input are taken as silence.
+	var out []float64
+	for ; r.pos <= float64(n-1); r.pos += r.step {
+		center := r.pos - r.delay
+		first := int(math.Max(0, math.Floor(center-r.halfWidth)+1))
+		last := int(math.Min(float64(n-1), math.Ceil(center+r.halfWidth)-1))
+		for ch := 0; ch < r.channels; ch++ {
+			var v float64
+			for i := first; i <= last; i++ {
+				v += r.buf[i*r.channels+ch] * r.kernel(center-float64(i))
+			}
+			out = append(out, v)
+		}
+	}
+
+	// Drop the samples which the next output sample doesn't need.
+	if drop := int(math.Floor(r.pos - r.delay - r.halfWidth)); drop > 0 {
+		r.buf = append(r.buf[:0], r.buf[drop*r.channels:]...)
+		r.pos -= float64(drop)
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package audio
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+func TestResamplerUpsample(t *testing.T) {
+	input := []wave.Audio{
+		&wave.Int16Interleaved{
+			Size: wave.ChunkInfo{Len: 3, Channels: 2, SamplingRate: 8000},
+			Data: []int16{0, 0, 2, -2, 4, -4},
+		},
+		&wave.Int16Interleaved{
+			Size: wave.ChunkInfo{Len: 3, Channels: 2, SamplingRate: 8000},
+			Data: []int16{6, -6, 8, -8, 10, -10},
+		},
+	}
+	expected := []wave.Audio{
+		&wave.Int16Interleaved{
+			Size: wave.ChunkInfo{Len: 5, Channels: 2, SamplingRate: 16000},
+			Data: []int16{0, 0, 1, -1, 2, -2, 3, -3, 4, -4},
+		},
+		&wave.Int16Interleaved{
+			Size: wave.ChunkInfo{Len: 6, Channels: 2, SamplingRate: 16000},
+			Data: []int16{5, -5, 6, -6, 7, -7, 8, -8, 9, -9, 10, -10},
+		},
+	}
+
+	var iSent int
+	r := NewResampler(16000)(ReaderFunc(func() (wave.Audio, error) {
+		if iSent < len(input) {
+			iSent++
+			return input[iSent-1], nil
+		}
+		return nil, io.EOF
+	}))
+
+	for i := 0; ; i++ {
+		a, err := r.Read()
+		if err != nil {
+			if err == io.EOF && i >= len(expected) {
+				break
+			}
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected[i], a) {
+			t.Errorf("Expected wave[%d]: %v, got: %v", i, expected[i], a)
+		}
+	}
+}
+
+// resampleTone resamples a sine wave of the frequency at 48 kHz to 8 kHz in the chunks of an odd length.
+func resampleTone(t *testing.T, frequency float64) []float32 {
+	const (
+		inRate   = 48000
+		outRate  = 8000
+		chunkLen = 441 // not a multiple of the ratio
+		nChunks  = 100
+	)
+
+	omega := 2 * math.Pi * frequency / inRate
+	var iSent int
+	r := NewResampler(outRate)(ReaderFunc(func() (wave.Audio, error) {
+		if iSent >= nChunks {
+			return nil, io.EOF
+		}
+		a := wave.NewFloat32Interleaved(wave.ChunkInfo{Len: chunkLen, Channels: 1, SamplingRate: inRate})
+		for i := range a.Data {
+			a.Data[i] = float32(math.Sin(omega * float64(iSent*chunkLen+i)))
+		}
+		iSent++
+		return a, nil
+	}))
+
+	var out []float32
+	for {
+		a, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ci := a.ChunkInfo(); ci.SamplingRate != outRate || ci.Channels != 1 {
+			t.Fatalf("Expected %d Hz mono, got %v", outRate, ci)
+		}
+		out = append(out, a.(*wave.Float32Interleaved).Data...)
+	}
+
+	if expected := nChunks * chunkLen * outRate / inRate; len(out) < expected-1 || expected+1 < len(out) {
+		t.Fatalf("Expected %d samples, got %d", expected, len(out))
+	}
+	return out
+}
+
+func TestResamplerDownsample(t *testing.T) {
+	const (
+		frequency = 1000
+		step      = 6
+	)
+	out := resampleTone(t, frequency)
+
+	// The tone below the output Nyquist frequency passes through, delayed by the windowed sinc.
+	// The first samples are skipped since they are interpolated with the silence before the input.
+	omega := 2 * math.Pi * frequency / 48000
+	for k := 2 * sincZeroCrossings; k < len(out); k++ {
+		expected := math.Sin(omega * float64((k-sincZeroCrossings)*step))
+		if diff := math.Abs(float64(out[k]) - expected); diff > 1e-3 {
+			t.Fatalf("Expected sample[%d] to be %f, got %f", k, expected, out[k])
+		}
+	}
+}
+
+func TestResamplerAntiAliasing(t *testing.T) {
+	// 6 kHz is above the Nyquist frequency of 8 kHz, which would be aliased to 2 kHz.
+	out := resampleTone(t, 6000)
+
+	for k := 2 * sincZeroCrossings; k < len(out); k++ {
+		if math.Abs(float64(out[k])) > 1e-2 {
+			t.Fatalf("Expected the tone to be attenuated by 40 dB at least, got sample[%d] = %f", k, out[k])
+		}
+	}
+}
+
+func TestResamplerSameRate(t *testing.T) {
+	in := &wave.Int16Interleaved{
+		Size: wave.ChunkInfo{Len: 2, Channels: 1, SamplingRate: 8000},
+		Data: []int16{1, 2},
+	}
+	r := NewResampler(8000)(ReaderFunc(func() (wave.Audio, error) {
+		return in, nil
+	}))
+	a, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != in {
+		t.Errorf("Expected the input to be passed through, got %v", a)
+	}
+}
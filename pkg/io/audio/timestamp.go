@@ -0,0 +1,85 @@
+package audio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+// TimestampReader is a Reader which knows when the chunk returned by the last Read was captured.
+// Drivers should implement it if they can tell the capture time more accurately than the time
+// Read returns.
+type TimestampReader interface {
+	Reader
+	// Timestamp returns the capture time of the chunk returned by the last Read.
+	Timestamp() time.Time
+}
+
+type timestampReader struct {
+	read func() (wave.Audio, time.Time, error)
+
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewTimestampReader creates a TimestampReader from a function which returns a chunk
+// along with its capture time.
+func NewTimestampReader(read func() (wave.Audio, time.Time, error)) TimestampReader {
+	return &timestampReader{read: read}
+}
+
+func (r *timestampReader) Read() (wave.Audio, error) {
+	a, t, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.t = t
+	r.mu.Unlock()
+	return a, nil
+}
+
+func (r *timestampReader) Timestamp() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.t
+}
+
+// Timestamper records the capture time of the chunks read through it, so that the capture time
+// can be recovered after the chunks went through transforms and encoders.
+type Timestamper struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// Transform returns a Reader which records the capture time of every chunk read from r.
+// If r is a TimestampReader, its timestamp is used. Otherwise, the time when the chunk is
+// returned from r is used.
+func (s *Timestamper) Transform(r Reader) Reader {
+	tr, hasTimestamp := r.(TimestampReader)
+	return ReaderFunc(func() (wave.Audio, error) {
+		a, err := r.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		t := time.Now()
+		if hasTimestamp {
+			t = tr.Timestamp()
+		}
+
+		s.mu.Lock()
+		s.t = t
+		s.mu.Unlock()
+		return a, nil
+	})
+}
+
+// Timestamp returns the capture time of the last chunk read through the Timestamper.
+func (s *Timestamper) Timestamp() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.t
+}
@@ -0,0 +1,53 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/wave"
+)
+
+func TestTimestamper(t *testing.T) {
+	chunk := wave.NewInt16Interleaved(wave.ChunkInfo{Len: 4, Channels: 1})
+
+	t.Run("TimestampReader", func(t *testing.T) {
+		t0 := time.Unix(100, 0)
+		var i int
+		src := NewTimestampReader(func() (wave.Audio, time.Time, error) {
+			i++
+			return chunk, t0.Add(time.Duration(i) * time.Second), nil
+		})
+
+		var s Timestamper
+		r := s.Transform(src)
+
+		for i := 1; i <= 3; i++ {
+			if _, err := r.Read(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := t0.Add(time.Duration(i) * time.Second)
+			if ts := s.Timestamp(); !ts.Equal(expected) {
+				t.Errorf("Expected timestamp %v, got %v", expected, ts)
+			}
+		}
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		src := ReaderFunc(func() (wave.Audio, error) {
+			return chunk, nil
+		})
+
+		var s Timestamper
+		r := s.Transform(src)
+
+		before := time.Now()
+		if _, err := r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		after := time.Now()
+
+		if ts := s.Timestamp(); ts.Before(before) || ts.After(after) {
+			t.Errorf("Expected timestamp between %v and %v, got %v", before, after, ts)
+		}
+	})
+}
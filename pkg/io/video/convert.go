@@ -63,7 +63,7 @@ func imageToYCbCr(dst *image.YCbCr, src image.Image) {
 // ToI420 converts r to a new reader that will output images in I420 format
 func ToI420(r Reader) Reader {
 	var yuvImg image.YCbCr
-	return ReaderFunc(func() (image.Image, error) {
+	return WithTimestamp(r, ReaderFunc(func() (image.Image, error) {
 		img, err := r.Read()
 		if err != nil {
 			return nil, err
@@ -84,7 +84,7 @@ func ToI420(r Reader) Reader {
 
 		yuvImg.SubsampleRatio = image.YCbCrSubsampleRatio420
 		return &yuvImg, nil
-	})
+	}))
 }
 
 // imageToRGBA converts src to *image.RGBA and store it to dst
@@ -130,7 +130,7 @@ func imageToRGBA(dst *image.RGBA, src image.Image) {
 // ToRGBA converts r to a new reader that will output images in RGBA format
 func ToRGBA(r Reader) Reader {
 	var dst image.RGBA
-	return ReaderFunc(func() (image.Image, error) {
+	return WithTimestamp(r, ReaderFunc(func() (image.Image, error) {
 		img, err := r.Read()
 		if err != nil {
 			return nil, err
@@ -138,5 +138,5 @@ func ToRGBA(r Reader) Reader {
 
 		imageToRGBA(&dst, img)
 		return &dst, nil
-	})
+	}))
 }
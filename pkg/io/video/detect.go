@@ -14,7 +14,7 @@ func DetectChanges(interval time.Duration, onChange func(prop.Media)) TransformF
 		var currentProp prop.Media
 		var lastTaken time.Time
 		var frames uint
-		return ReaderFunc(func() (image.Image, error) {
+		return WithTimestamp(r, ReaderFunc(func() (image.Image, error) {
 			var dirty bool
 
 			img, err := r.Read()
@@ -53,6 +53,6 @@ func DetectChanges(interval time.Duration, onChange func(prop.Media)) TransformF
 
 			frames++
 			return img, nil
-		})
+		}))
 	}
 }
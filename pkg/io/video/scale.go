@@ -156,7 +156,7 @@ func Scale(width, height int, scaler Scaler) TransformFunc {
 			}
 		}
 
-		return ReaderFunc(func() (image.Image, error) {
+		return WithTimestamp(r, ReaderFunc(func() (image.Image, error) {
 			img, err := r.Read()
 			if err != nil {
 				return nil, err
@@ -189,6 +189,6 @@ func Scale(width, height int, scaler Scaler) TransformFunc {
 			default:
 				return nil, errUnsupportedImageType
 			}
-		})
+		}))
 	}
 }
@@ -10,7 +10,7 @@ import (
 func Throttle(rate float32) TransformFunc {
 	return func(r Reader) Reader {
 		ticker := time.NewTicker(time.Duration(int64(float64(time.Second) / float64(rate))))
-		return ReaderFunc(func() (image.Image, error) {
+		return WithTimestamp(r, ReaderFunc(func() (image.Image, error) {
 			for {
 				img, err := r.Read()
 				if err != nil {
@@ -23,6 +23,6 @@ func Throttle(rate float32) TransformFunc {
 				default:
 				}
 			}
-		})
+		}))
 	}
 }
@@ -0,0 +1,114 @@
+package video
+
+import (
+	"image"
+	"sync"
+	"time"
+)
+
+// TimestampReader is a Reader which knows when the frame returned by the last Read was captured.
+// Drivers should implement it if they can tell the capture time more accurately than the time
+// Read returns.
+type TimestampReader interface {
+	Reader
+	// Timestamp returns the capture time of the frame returned by the last Read.
+	Timestamp() time.Time
+}
+
+type timestampReader struct {
+	read func() (image.Image, time.Time, error)
+
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewTimestampReader creates a TimestampReader from a function which returns a frame
+// along with its capture time.
+func NewTimestampReader(read func() (image.Image, time.Time, error)) TimestampReader {
+	return &timestampReader{read: read}
+}
+
+func (r *timestampReader) Read() (image.Image, error) {
+	img, t, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.t = t
+	r.mu.Unlock()
+	return img, nil
+}
+
+func (r *timestampReader) Timestamp() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.t
+}
+
+type timestampForwarder struct {
+	Reader
+	src TimestampReader
+}
+
+func (r *timestampForwarder) Timestamp() time.Time {
+	return r.src.Timestamp()
+}
+
+// WithTimestamp returns r as a TimestampReader telling the timestamp of src if src is a TimestampReader,
+// so that the capture time of the frames is kept through the transform of src to r. Every frame returned
+// by r has to be made from the frame read from src last, e.g. a scaled frame or a frame left by throttling.
+func WithTimestamp(src, r Reader) Reader {
+	tr, ok := src.(TimestampReader)
+	if !ok {
+		return r
+	}
+	return &timestampForwarder{Reader: r, src: tr}
+}
+
+// Timestamp returns the capture time of the frame returned by the last Read of r if r is a TimestampReader.
+// Otherwise, it returns the current time, which is the time when the frame was read if it's called right
+// after Read. Encoders use it to timestamp the frames they read.
+func Timestamp(r Reader) time.Time {
+	if tr, ok := r.(TimestampReader); ok {
+		return tr.Timestamp()
+	}
+	return time.Now()
+}
+
+// Timestamper records the capture time of the frames read through it, so that the capture time
+// can be recovered after the frames went through transforms and encoders.
+type Timestamper struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// Transform returns a Reader which records the capture time of every frame read from r.
+// If r is a TimestampReader, its timestamp is used. Otherwise, the time when the frame is
+// returned from r is used.
+func (s *Timestamper) Transform(r Reader) Reader {
+	tr, hasTimestamp := r.(TimestampReader)
+	return WithTimestamp(r, ReaderFunc(func() (image.Image, error) {
+		img, err := r.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		t := time.Now()
+		if hasTimestamp {
+			t = tr.Timestamp()
+		}
+
+		s.mu.Lock()
+		s.t = t
+		s.mu.Unlock()
+		return img, nil
+	}))
+}
+
+// Timestamp returns the capture time of the last frame read through the Timestamper.
+func (s *Timestamper) Timestamp() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.t
+}
@@ -0,0 +1,92 @@
+package video
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func TestTimestamper(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 1, 1))
+
+	t.Run("TimestampReader", func(t *testing.T) {
+		t0 := time.Unix(100, 0)
+		var i int
+		src := NewTimestampReader(func() (image.Image, time.Time, error) {
+			i++
+			return img, t0.Add(time.Duration(i) * time.Second), nil
+		})
+
+		var s Timestamper
+		// Transforms after the Timestamper must not affect the timestamp.
+		r := Throttle(1000)(s.Transform(src))
+
+		for i := 1; i <= 3; i++ {
+			if _, err := r.Read(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := src.Timestamp()
+			if ts := s.Timestamp(); !ts.Equal(expected) {
+				t.Errorf("Expected timestamp %v, got %v", expected, ts)
+			}
+		}
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		src := ReaderFunc(func() (image.Image, error) {
+			return img, nil
+		})
+
+		var s Timestamper
+		r := s.Transform(src)
+
+		before := time.Now()
+		if _, err := r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		after := time.Now()
+
+		if ts := s.Timestamp(); ts.Before(before) || ts.After(after) {
+			t.Errorf("Expected timestamp between %v and %v, got %v", before, after, ts)
+		}
+	})
+}
+
+func TestWithTimestamp(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)
+	t0 := time.Unix(100, 0)
+	var i int
+	src := NewTimestampReader(func() (image.Image, time.Time, error) {
+		i++
+		return img, t0.Add(time.Duration(i) * time.Second), nil
+	})
+
+	// The frames dropped by the throttling don't affect the timestamp.
+	r, ok := Merge(Scale(2, 2, nil), Throttle(1000))(src).(TimestampReader)
+	if !ok {
+		t.Fatal("Expected the transformed reader to be a TimestampReader")
+	}
+	for n := 0; n < 3; n++ {
+		if _, err := r.Read(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expected := t0.Add(time.Duration(i) * time.Second)
+		if ts := Timestamp(r); !ts.Equal(expected) {
+			t.Errorf("Expected timestamp %v, got %v", expected, ts)
+		}
+	}
+
+	plain := Throttle(1000)(ReaderFunc(func() (image.Image, error) {
+		return img, nil
+	}))
+	if _, ok := plain.(TimestampReader); ok {
+		t.Error("Expected the transformed reader not to be a TimestampReader")
+	}
+	before := time.Now()
+	if _, err := plain.Read(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ts := Timestamp(plain); ts.Before(before) {
+		t.Errorf("Expected the time of the read after %v, got %v", before, ts)
+	}
+}
@@ -0,0 +1,108 @@
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+// H264Writer writes an H.264 track into a raw Annex B stream. The stream starts from the first IDR
+// picture, and the last SPS and PPS are inserted before the IDR pictures which come without them,
+// so that the stream can be decoded from every IDR picture.
+type H264Writer struct {
+	w io.Writer
+
+	mu      sync.Mutex
+	track   *Track
+	closed  bool
+	started bool
+	sps     []byte
+	pps     []byte
+}
+
+// NewH264Writer creates an H264Writer writing into w.
+func NewH264Writer(w io.Writer) *H264Writer {
+	return &H264Writer{w: w}
+}
+
+// NewTrack creates the track to be recorded. It has the signature of mediadevices.TrackGenerator,
+// and only one H.264 track can be created.
+func (w *H264Writer) NewTrack(payloadType uint8, ssrc uint32, id, label string, c *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+	if !isCodec(c, webrtc.H264) {
+		return nil, fmt.Errorf("recorder: Annex B doesn't support %s", c.Name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil, errClosed
+	}
+	if w.track != nil {
+		return nil, errors.New("recorder: Annex B can only have one track")
+	}
+	w.track = &Track{codec: c, id: id, m: w, number: 1}
+	return w.track, nil
+}
+
+func (w *H264Writer) writeFrame(t *Track, frame codec.EncodedFrame) error {
+	if len(frame.Data) == 0 {
+		return nil
+	}
+	info, err := parseH264(frame.Data)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+
+	if info.sps != nil {
+		w.sps = append([]byte{}, info.sps...)
+	}
+	if info.pps != nil {
+		w.pps = append([]byte{}, info.pps...)
+	}
+	if !w.started {
+		if !info.keyFrame || w.sps == nil || w.pps == nil {
+			// The pictures can't be decoded until the first IDR picture with the parameter sets.
+			return nil
+		}
+		w.started = true
+	}
+
+	if info.keyFrame {
+		var params []byte
+		if info.sps == nil {
+			params = append(append(params, annexBStartCode...), w.sps...)
+		}
+		if info.pps == nil {
+			params = append(append(params, annexBStartCode...), w.pps...)
+		}
+		if params != nil {
+			if _, err := w.w.Write(params); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = w.w.Write(frame.Data)
+	return err
+}
+
+// Close stops writing the frames. The underlying writer is not closed.
+func (w *H264Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	return nil
+}
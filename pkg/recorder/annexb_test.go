@@ -0,0 +1,64 @@
+package recorder
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+func TestH264Writer(t *testing.T) {
+	idr := []byte{0x65, 0xb8, 0x00, 0x04}
+	nonIDR := []byte{0x41, 0x9a, 0x00}
+	frames := []codec.EncodedFrame{
+		// The frames before the first IDR picture with the parameter sets are dropped.
+		{Data: annexB(nonIDR)},
+		{Data: annexB(idr), KeyFrame: true},
+		{Data: annexB(sps360p, pps, idr), KeyFrame: true},
+		{Data: annexB(nonIDR)},
+		{},
+		// The parameter sets are inserted.
+		{Data: annexB(idr), KeyFrame: true},
+		{Data: annexB(nonIDR)},
+	}
+
+	buf := &bytes.Buffer{}
+	w := NewH264Writer(buf)
+	track, err := w.NewTrack(webrtc.DefaultPayloadTypeH264, 0, "video", "video", webrtc.NewRTPH264Codec(webrtc.DefaultPayloadTypeH264, 90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range frames {
+		f.Timestamp = time.Unix(1, 0).Add(time.Duration(i) * time.Second / 30)
+		if err := track.(mediadevices.FrameWriter).WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := track.(mediadevices.FrameWriter).WriteFrame(frames[2]); err != errClosed {
+		t.Errorf("Expected %v, got %v", errClosed, err)
+	}
+
+	expected := annexB(sps360p, pps, idr, nonIDR, sps360p, pps, idr, nonIDR)
+	if !bytes.Equal(expected, buf.Bytes()) {
+		t.Errorf("Expected %x, got %x", expected, buf.Bytes())
+	}
+}
+
+func TestH264WriterTracks(t *testing.T) {
+	w := NewH264Writer(&bytes.Buffer{})
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video", "video", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)); err == nil {
+		t.Error("Expected an error for VP8")
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeH264, 0, "video", "video", webrtc.NewRTPH264Codec(webrtc.DefaultPayloadTypeH264, 90000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeH264, 0, "video2", "video2", webrtc.NewRTPH264Codec(webrtc.DefaultPayloadTypeH264, 90000)); err == nil {
+		t.Error("Expected an error for the second track")
+	}
+}
@@ -0,0 +1,143 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Reference: https://tools.ietf.org/html/rfc8794
+
+// Element IDs of Matroska used by WebMWriter. The IDs include their length markers.
+// Reference: https://www.matroska.org/technical/elements.html
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+	idVoid               = 0xEC
+
+	idSegment       = 0x18538067
+	idSeekHead      = 0x114D9B74
+	idSeek          = 0x4DBB
+	idSeekID        = 0x53AB
+	idSeekPosition  = 0x53AC
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+	idDuration      = 0x4489
+	idMuxingApp     = 0x4D80
+	idWritingApp    = 0x5741
+
+	idTracks            = 0x1654AE6B
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackUID          = 0x73C5
+	idTrackType         = 0x83
+	idFlagLacing        = 0x9C
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idCodecDelay        = 0x56AA
+	idSeekPreRoll       = 0x56BB
+	idVideo             = 0xE0
+	idPixelWidth        = 0xB0
+	idPixelHeight       = 0xBA
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+
+	idCluster     = 0x1F43B675
+	idTimecode    = 0xE7
+	idSimpleBlock = 0xA3
+
+	idCues               = 0x1C53BB6B
+	idCuePoint           = 0xBB
+	idCueTime            = 0xB3
+	idCueTrackPositions  = 0xB7
+	idCueTrack           = 0xF7
+	idCueClusterPosition = 0xF1
+)
+
+// Track types
+const (
+	trackTypeVideo = 1
+	trackTypeAudio = 2
+)
+
+// unknownSize is the element size of 8 bytes meaning that the size is unknown.
+const unknownSize = 0x01FFFFFFFFFFFFFF
+
+// appendID appends the element ID.
+func appendID(b []byte, id uint32) []byte {
+	switch {
+	case id >= 1<<24:
+		return append(b, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	case id >= 1<<16:
+		return append(b, byte(id>>16), byte(id>>8), byte(id))
+	case id >= 1<<8:
+		return append(b, byte(id>>8), byte(id))
+	}
+	return append(b, byte(id))
+}
+
+// appendSize appends the element size in the shortest variable size integer.
+func appendSize(b []byte, size uint64) []byte {
+	n := 1
+	// All ones are reserved for the unknown size.
+	for size >= 1<<(7*uint(n))-1 {
+		n++
+	}
+	return appendSizeN(b, size, n)
+}
+
+// appendSizeN appends the element size in the variable size integer of n bytes.
+func appendSizeN(b []byte, size uint64, n int) []byte {
+	v := size | 1<<(7*uint(n))
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*uint(i))))
+	}
+	return b
+}
+
+// appendElement appends the element with the data.
+func appendElement(b []byte, id uint32, data []byte) []byte {
+	b = appendID(b, id)
+	b = appendSize(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendUint appends the unsigned integer element in the shortest form.
+func appendUint(b []byte, id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && v >= 1<<(8*uint(n)) {
+		n++
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, v)
+	return appendElement(b, id, data[8-n:])
+}
+
+// appendFloat appends the float element in 8 bytes.
+func appendFloat(b []byte, id uint32, v float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(v))
+	return appendElement(b, id, data)
+}
+
+// appendString appends the string element.
+func appendString(b []byte, id uint32, s string) []byte {
+	return appendElement(b, id, []byte(s))
+}
+
+// appendVoid appends a Void element taking n bytes, which has to be larger than 1.
+func appendVoid(b []byte, n int) []byte {
+	b = appendID(b, idVoid)
+	if n-2 < 1<<7-1 {
+		b = appendSizeN(b, uint64(n-2), 1)
+		return append(b, make([]byte, n-2)...)
+	}
+	b = appendSizeN(b, uint64(n-9), 8)
+	return append(b, make([]byte, n-9)...)
+}
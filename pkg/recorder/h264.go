@@ -0,0 +1,219 @@
+package recorder
+
+import (
+	"errors"
+)
+
+// NAL unit types of H.264
+const (
+	naluTypeIDR = 5
+	naluTypeSPS = 7
+	naluTypePPS = 8
+	naluTypeAUD = 9
+)
+
+var annexBStartCode = []byte{0x00, 0x00, 0x00, 0x01}
+
+// splitNALUs splits the access unit in Annex B format into the NAL units without the start codes.
+func splitNALUs(data []byte) [][]byte {
+	var nalus [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			end := i
+			// The zero byte before the 3 bytes start code belongs to the start code.
+			for end > start && data[end-1] == 0 {
+				end--
+			}
+			nalus = append(nalus, data[start:end])
+		}
+		i += 2
+		start = i + 1
+	}
+	if start >= 0 && start < len(data) {
+		nalus = append(nalus, data[start:])
+	}
+	return nalus
+}
+
+func naluType(nalu []byte) byte {
+	if len(nalu) == 0 {
+		return 0
+	}
+	return nalu[0] & 0x1f
+}
+
+// h264Info is the information of an H.264 access unit.
+type h264Info struct {
+	frameInfo
+	// sps and pps are the parameter sets in the access unit.
+	sps, pps []byte
+}
+
+// parseH264 parses the access unit in Annex B format. It's a key frame if it has an IDR picture,
+// and the size is parsed from the SPS if it has one.
+func parseH264(data []byte) (h264Info, error) {
+	var info h264Info
+	for _, nalu := range splitNALUs(data) {
+		switch naluType(nalu) {
+		case naluTypeIDR:
+			info.keyFrame = true
+		case naluTypeSPS:
+			width, height, err := parseSPS(nalu)
+			if err != nil {
+				return h264Info{}, err
+			}
+			info.sps = nalu
+			info.width, info.height = width, height
+		case naluTypePPS:
+			info.pps = nalu
+		}
+	}
+	if !info.keyFrame {
+		info.width, info.height = 0, 0
+	}
+	return info, nil
+}
+
+// parseSPS returns the size of the pictures after cropping from the SPS.
+// Reference: ITU-T H.264 7.3.2.1.1
+func parseSPS(nalu []byte) (width, height int, err error) {
+	if len(nalu) < 4 {
+		return 0, 0, errors.New("SPS is too short")
+	}
+	r := &bitReader{data: removeEmulationPrevention(nalu[1:])}
+	profile := r.read(8)
+	r.read(16) // constraint_set flags, level_idc
+	r.readUE() // seq_parameter_set_id
+
+	chromaFormat := uint32(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat = r.readUE()
+		if chromaFormat == 3 {
+			if r.read(1) == 1 {
+				// separate_colour_plane_flag
+				chromaFormat = 0
+			}
+		}
+		r.readUE() // bit_depth_luma_minus8
+		r.readUE() // bit_depth_chroma_minus8
+		r.read(1)  // qpprime_y_zero_transform_bypass_flag
+		if r.read(1) == 1 {
+			// seq_scaling_matrix_present_flag
+			n := 8
+			if chromaFormat == 3 {
+				n = 12
+			}
+			for i := 0; i < n; i++ {
+				if r.read(1) == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := int32(8), int32(8)
+				for j := 0; j < size; j++ {
+					if next != 0 {
+						next = (last + r.readSE() + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+
+	r.readUE() // log2_max_frame_num_minus4
+	switch r.readUE() {
+	case 0:
+		r.readUE() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.read(1)  // delta_pic_order_always_zero_flag
+		r.readSE() // offset_for_non_ref_pic
+		r.readSE() // offset_for_top_to_bottom_field
+		n := r.readUE()
+		for i := uint32(0); i < n && r.err() == nil; i++ {
+			r.readSE()
+		}
+	}
+	r.readUE() // max_num_ref_frames
+	r.read(1)  // gaps_in_frame_num_value_allowed_flag
+	widthInMbs := r.readUE() + 1
+	heightInMapUnits := r.readUE() + 1
+	frameMbsOnly := r.read(1)
+	if frameMbsOnly == 0 {
+		r.read(1) // mb_adaptive_frame_field_flag
+	}
+	r.read(1) // direct_8x8_inference_flag
+
+	var cropLeft, cropRight, cropTop, cropBottom uint32
+	if r.read(1) == 1 {
+		cropLeft, cropRight = r.readUE(), r.readUE()
+		cropTop, cropBottom = r.readUE(), r.readUE()
+	}
+	if err := r.err(); err != nil {
+		return 0, 0, err
+	}
+
+	cropUnitX, cropUnitY := uint32(1), 2-frameMbsOnly
+	switch chromaFormat {
+	case 1:
+		cropUnitX, cropUnitY = 2, 2*(2-frameMbsOnly)
+	case 2:
+		cropUnitX = 2
+	}
+	width = int(widthInMbs*16 - cropUnitX*(cropLeft+cropRight))
+	height = int((2-frameMbsOnly)*heightInMapUnits*16 - cropUnitY*(cropTop+cropBottom))
+	if width <= 0 || height <= 0 {
+		return 0, 0, errors.New("invalid SPS cropping")
+	}
+	return width, height, nil
+}
+
+// removeEmulationPrevention removes the emulation prevention bytes, 0x03 in 0x000003.
+func removeEmulationPrevention(b []byte) []byte {
+	rbsp := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 0x03 {
+			zeros = 0
+			continue
+		}
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, c)
+	}
+	return rbsp
+}
+
+// readUE reads an unsigned Exp-Golomb code.
+func (r *bitReader) readUE() uint32 {
+	zeros := 0
+	for r.read(1) == 0 {
+		zeros++
+		if zeros > 31 {
+			// Beyond the data or broken
+			r.pos = len(r.data)*8 + 1
+			return 0
+		}
+	}
+	return 1<<uint(zeros) - 1 + r.read(zeros)
+}
+
+// readSE reads a signed Exp-Golomb code.
+func (r *bitReader) readSE() int32 {
+	v := r.readUE()
+	if v&1 == 1 {
+		return int32(v/2 + 1)
+	}
+	return -int32(v / 2)
+}
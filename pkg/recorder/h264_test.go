@@ -0,0 +1,217 @@
+package recorder
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// SPS and PPS output by OpenH264
+var (
+	sps1080p = []byte{0x67, 0x42, 0xc0, 0x28, 0x8c, 0x8d, 0x40, 0x3c, 0x01, 0x12, 0xf2, 0xc0, 0x3c, 0x22, 0x11, 0xa8}
+	sps360p  = []byte{0x67, 0x42, 0xc0, 0x1e, 0x8c, 0x8d, 0x40, 0x50, 0x17, 0xbc, 0xb0, 0x0f, 0x08, 0x84, 0x6a}
+	pps      = []byte{0x68, 0xce, 0x3c, 0x80}
+)
+
+func (w *bitWriter) writeUE(v uint32) {
+	n := 0
+	for (v+1)>>uint(n) > 1 {
+		n++
+	}
+	w.write(0, n)
+	w.write(v+1, n+1)
+}
+
+func (w *bitWriter) writeSE(v int32) {
+	if v > 0 {
+		w.writeUE(uint32(2*v - 1))
+	} else {
+		w.writeUE(uint32(-2 * v))
+	}
+}
+
+// addEmulationPrevention inserts the emulation prevention bytes into the RBSP.
+func addEmulationPrevention(rbsp []byte) []byte {
+	var b []byte
+	zeros := 0
+	for _, c := range rbsp {
+		if zeros >= 2 && c <= 0x03 {
+			b = append(b, 0x03)
+			zeros = 0
+		}
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// newHighProfileSPS returns the SPS of the high profile with the scaling matrices, the field coding,
+// and the frame cropping.
+func newHighProfileSPS(widthInMbs, heightInMapUnits, cropBottom uint32) []byte {
+	w := &bitWriter{}
+	w.write(100, 8) // profile_idc
+	w.write(0, 8)   // constraint_set flags
+	w.write(0, 8)   // level_idc
+	w.writeUE(0)    // seq_parameter_set_id
+	w.writeUE(1)    // chroma_format_idc
+	w.writeUE(0)    // bit_depth_luma_minus8
+	w.writeUE(0)    // bit_depth_chroma_minus8
+	w.write(0, 1)   // qpprime_y_zero_transform_bypass_flag
+	w.write(1, 1)   // seq_scaling_matrix_present_flag
+	for i := 0; i < 8; i++ {
+		w.write(1, 1) // seq_scaling_list_present_flag
+		size := 16
+		if i >= 6 {
+			size = 64
+		}
+		// Flat 16, then stop by next scale of 0.
+		w.writeSE(8)
+		for j := 1; j < size/2; j++ {
+			w.writeSE(0)
+		}
+		w.writeSE(-16)
+	}
+	w.writeUE(0)                    // log2_max_frame_num_minus4
+	w.writeUE(1)                    // pic_order_cnt_type
+	w.write(0, 1)                   // delta_pic_order_always_zero_flag
+	w.writeSE(-1)                   // offset_for_non_ref_pic
+	w.writeSE(1)                    // offset_for_top_to_bottom_field
+	w.writeUE(2)                    // num_ref_frames_in_pic_order_cnt_cycle
+	w.writeSE(0)                    // offset_for_ref_frame
+	w.writeSE(0)                    // offset_for_ref_frame
+	w.writeUE(0)                    // max_num_ref_frames
+	w.write(0, 1)                   // gaps_in_frame_num_value_allowed_flag
+	w.writeUE(widthInMbs - 1)       // pic_width_in_mbs_minus1
+	w.writeUE(heightInMapUnits - 1) // pic_height_in_map_units_minus1
+	w.write(0, 1)                   // frame_mbs_only_flag
+	w.write(1, 1)                   // mb_adaptive_frame_field_flag
+	w.write(1, 1)                   // direct_8x8_inference_flag
+	w.write(1, 1)                   // frame_cropping_flag
+	w.writeUE(0)
+	w.writeUE(0)
+	w.writeUE(0)
+	w.writeUE(cropBottom)
+	w.write(0, 1) // vui_parameters_present_flag
+	w.write(1, 1) // rbsp_stop_one_bit
+	return append([]byte{0x67}, addEmulationPrevention(w.data)...)
+}
+
+func annexB(nalus ...[]byte) []byte {
+	var b []byte
+	for _, nalu := range nalus {
+		b = append(b, annexBStartCode...)
+		b = append(b, nalu...)
+	}
+	return b
+}
+
+func TestSplitNALUs(t *testing.T) {
+	testCases := map[string]struct {
+		data     []byte
+		expected [][]byte
+	}{
+		"FourBytesStartCode": {
+			data:     []byte{0, 0, 0, 1, 0x67, 1, 2, 0, 0, 0, 1, 0x68, 3},
+			expected: [][]byte{{0x67, 1, 2}, {0x68, 3}},
+		},
+		"ThreeBytesStartCode": {
+			data:     []byte{0, 0, 1, 0x65, 1, 0, 0, 1, 0x41, 2},
+			expected: [][]byte{{0x65, 1}, {0x41, 2}},
+		},
+		"EmulationPrevention": {
+			data:     []byte{0, 0, 0, 1, 0x65, 0, 0, 3, 1},
+			expected: [][]byte{{0x65, 0, 0, 3, 1}},
+		},
+		"NoStartCode": {
+			data: []byte{0x65, 1, 2},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			nalus := splitNALUs(testCase.data)
+			if !reflect.DeepEqual(testCase.expected, nalus) {
+				t.Errorf("Expected %v, got %v", testCase.expected, nalus)
+			}
+		})
+	}
+}
+
+func TestParseSPS(t *testing.T) {
+	testCases := map[string]struct {
+		sps           []byte
+		width, height int
+	}{
+		"1080p": {
+			sps:    sps1080p,
+			width:  1920,
+			height: 1080,
+		},
+		"360p": {
+			sps:    sps360p,
+			width:  640,
+			height: 360,
+		},
+		"HighProfile": {
+			// 16 macroblocks in width, and 8 map units of 2 fields cropped by 2 * 4 lines.
+			sps:    newHighProfileSPS(16, 8, 4),
+			width:  256,
+			height: 240,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			width, height, err := parseSPS(testCase.sps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if width != testCase.width || height != testCase.height {
+				t.Errorf("Expected %dx%d, got %dx%d", testCase.width, testCase.height, width, height)
+			}
+		})
+	}
+
+	if _, _, err := parseSPS(sps1080p[:6]); err == nil {
+		t.Error("Expected an error for the truncated SPS")
+	}
+}
+
+func TestRemoveEmulationPrevention(t *testing.T) {
+	rbsp := []byte{0, 0, 0, 0, 0, 1, 0, 0, 2, 0, 0, 3, 0, 0, 4}
+	b := addEmulationPrevention(rbsp)
+	if expected := []byte{0, 0, 3, 0, 0, 3, 0, 1, 0, 0, 3, 2, 0, 0, 3, 3, 0, 0, 4}; !bytes.Equal(expected, b) {
+		t.Fatalf("Expected %v, got %v", expected, b)
+	}
+	if actual := removeEmulationPrevention(b); !bytes.Equal(rbsp, actual) {
+		t.Errorf("Expected %v, got %v", rbsp, actual)
+	}
+}
+
+func TestParseH264(t *testing.T) {
+	idr := []byte{0x65, 0xb8, 0x00, 0x04}
+	nonIDR := []byte{0x41, 0x9a, 0x00}
+
+	info, err := parseH264(annexB(sps360p, pps, idr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := h264Info{frameInfo: frameInfo{keyFrame: true, width: 640, height: 360}, sps: sps360p, pps: pps}
+	if !reflect.DeepEqual(expected, info) {
+		t.Errorf("Expected %+v, got %+v", expected, info)
+	}
+
+	info, err = parseH264(annexB(nonIDR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h264Info{}, info) {
+		t.Errorf("Expected a non key frame, got %+v", info)
+	}
+}
@@ -0,0 +1,165 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+// Reference: https://wiki.multimedia.cx/index.php/IVF
+
+const (
+	ivfHeaderSize      = 32
+	ivfFrameHeaderSize = 12
+	// ivfFrameCountOffset is the offset of the number of the frames in the file header.
+	ivfFrameCountOffset = 24
+)
+
+// ivfTimebase is the unit of the frame timestamps.
+const ivfTimebase = time.Millisecond
+
+// IVFWriter writes a VP8 or VP9 track into IVF. The file starts from the first key frame,
+// and the timestamps are in milliseconds from it.
+type IVFWriter struct {
+	w io.Writer
+
+	mu     sync.Mutex
+	track  *Track
+	closed bool
+	// start is the timestamp of the first frame. The header is written when it's set.
+	start  time.Time
+	frames uint32
+	// headerPos is the position of the header if w is an io.WriteSeeker.
+	headerPos int64
+}
+
+// NewIVFWriter creates an IVFWriter writing into w. If w implements io.WriteSeeker, the number of
+// the frames in the header is updated on Close.
+func NewIVFWriter(w io.Writer) *IVFWriter {
+	return &IVFWriter{w: w}
+}
+
+// NewTrack creates the track to be recorded. It has the signature of mediadevices.TrackGenerator,
+// and only one VP8 or VP9 track can be created.
+func (w *IVFWriter) NewTrack(payloadType uint8, ssrc uint32, id, label string, c *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+	if !isCodec(c, webrtc.VP8) && !isCodec(c, webrtc.VP9) {
+		return nil, fmt.Errorf("recorder: IVF doesn't support %s", c.Name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil, errClosed
+	}
+	if w.track != nil {
+		return nil, errors.New("recorder: IVF can only have one track")
+	}
+	w.track = &Track{codec: c, id: id, m: w, number: 1}
+	return w.track, nil
+}
+
+func (w *IVFWriter) writeFrame(t *Track, frame codec.EncodedFrame) error {
+	if len(frame.Data) == 0 {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+
+	if w.start.IsZero() {
+		if !frame.KeyFrame {
+			// The frames can't be decoded until the first key frame.
+			return nil
+		}
+		info, err := t.parse(frame.Data)
+		if err != nil {
+			return err
+		}
+		if err := w.writeHeader(info); err != nil {
+			return err
+		}
+		w.start = frame.Timestamp
+	}
+
+	header := make([]byte, ivfFrameHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:], uint32(len(frame.Data)))
+	binary.LittleEndian.PutUint64(header[4:], uint64(frame.Timestamp.Sub(w.start)/ivfTimebase))
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(frame.Data); err != nil {
+		return err
+	}
+	w.frames++
+	return nil
+}
+
+func (w *IVFWriter) writeHeader(info frameInfo) error {
+	fourcc := "VP80"
+	if isCodec(w.track.codec, webrtc.VP9) {
+		fourcc = "VP90"
+	}
+
+	if ws, ok := w.w.(io.WriteSeeker); ok {
+		pos, err := ws.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		w.headerPos = pos
+	}
+
+	header := make([]byte, ivfHeaderSize)
+	copy(header[0:], "DKIF")
+	binary.LittleEndian.PutUint16(header[4:], 0) // version
+	binary.LittleEndian.PutUint16(header[6:], ivfHeaderSize)
+	copy(header[8:], fourcc)
+	binary.LittleEndian.PutUint16(header[12:], uint16(info.width))
+	binary.LittleEndian.PutUint16(header[14:], uint16(info.height))
+	binary.LittleEndian.PutUint32(header[16:], uint32(time.Second/ivfTimebase)) // timebase denominator
+	binary.LittleEndian.PutUint32(header[20:], 1)                               // timebase numerator
+	_, err := w.w.Write(header)
+	return err
+}
+
+// Close stops writing the frames. The number of the frames in the header is updated if the
+// underlying writer is an io.WriteSeeker. The underlying writer is not closed.
+func (w *IVFWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	ws, ok := w.w.(io.WriteSeeker)
+	if !ok || w.start.IsZero() {
+		return nil
+	}
+	end, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := ws.Seek(w.headerPos+ivfFrameCountOffset, io.SeekStart); err != nil {
+		return err
+	}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, w.frames)
+	if _, err := ws.Write(b); err != nil {
+		return err
+	}
+	_, err = ws.Seek(end, io.SeekStart)
+	return err
+}
@@ -0,0 +1,149 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+func TestIVFWriter(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	vp8 := webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)
+	vp9 := webrtc.NewRTPVP9Codec(webrtc.DefaultPayloadTypeVP9, 90000)
+
+	testCases := map[string]struct {
+		codec     *webrtc.RTPCodec
+		frames    []codec.EncodedFrame
+		fourcc    string
+		seekable  bool
+		numFrames uint32
+	}{
+		"VP8": {
+			codec: vp8,
+			frames: []codec.EncodedFrame{
+				{Data: newVP8InterFrame(), Timestamp: t0},
+				{Data: newVP8KeyFrame(640, 480), KeyFrame: true, Timestamp: t0.Add(33 * time.Millisecond)},
+				{Data: newVP8InterFrame(), Timestamp: t0.Add(66 * time.Millisecond)},
+			},
+			fourcc:    "VP80",
+			seekable:  true,
+			numFrames: 2,
+		},
+		"VP9": {
+			codec: vp9,
+			frames: []codec.EncodedFrame{
+				{Data: newVP9InterFrame(), Timestamp: t0},
+				{Data: newVP9KeyFrame(0, 640, 480), KeyFrame: true, Timestamp: t0.Add(33 * time.Millisecond)},
+				{Data: newVP9InterFrame(), Timestamp: t0.Add(66 * time.Millisecond)},
+			},
+			fourcc:    "VP90",
+			seekable:  true,
+			numFrames: 2,
+		},
+		"Stream": {
+			codec: vp8,
+			frames: []codec.EncodedFrame{
+				{Data: newVP8InterFrame(), Timestamp: t0},
+				{Data: newVP8KeyFrame(640, 480), KeyFrame: true, Timestamp: t0.Add(33 * time.Millisecond)},
+				{Data: newVP8InterFrame(), Timestamp: t0.Add(66 * time.Millisecond)},
+			},
+			fourcc: "VP80",
+			// The number of the frames can't be updated.
+			numFrames: 0,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			var out io.Writer
+			buf := &seekBuffer{}
+			stream := &bytes.Buffer{}
+			if testCase.seekable {
+				out = buf
+			} else {
+				out = stream
+			}
+
+			w := NewIVFWriter(out)
+			track, err := w.NewTrack(testCase.codec.PayloadType, 0, "video", "video", testCase.codec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range testCase.frames {
+				if err := track.(mediadevices.FrameWriter).WriteFrame(f); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data := buf.data
+			if !testCase.seekable {
+				data = stream.Bytes()
+			}
+			if len(data) < ivfHeaderSize {
+				t.Fatalf("Expected the header, got %d bytes", len(data))
+			}
+			if sig := string(data[0:4]); sig != "DKIF" {
+				t.Errorf("Expected DKIF, got %s", sig)
+			}
+			if fourcc := string(data[8:12]); fourcc != testCase.fourcc {
+				t.Errorf("Expected %s, got %s", testCase.fourcc, fourcc)
+			}
+			width, height := binary.LittleEndian.Uint16(data[12:]), binary.LittleEndian.Uint16(data[14:])
+			if width != 640 || height != 480 {
+				t.Errorf("Expected 640x480, got %dx%d", width, height)
+			}
+			rate, scale := binary.LittleEndian.Uint32(data[16:]), binary.LittleEndian.Uint32(data[20:])
+			if rate != 1000 || scale != 1 {
+				t.Errorf("Expected the timebase of 1/1000, got %d/%d", scale, rate)
+			}
+			if n := binary.LittleEndian.Uint32(data[24:]); n != testCase.numFrames {
+				t.Errorf("Expected %d frames in the header, got %d", testCase.numFrames, n)
+			}
+
+			// The frames before the key frame are dropped.
+			expected := testCase.frames[1:]
+			pos := ivfHeaderSize
+			for i, f := range expected {
+				if len(data) < pos+ivfFrameHeaderSize {
+					t.Fatalf("Expected frame %d, got EOF", i)
+				}
+				size := int(binary.LittleEndian.Uint32(data[pos:]))
+				pts := binary.LittleEndian.Uint64(data[pos+4:])
+				if expectedPTS := uint64(f.Timestamp.Sub(expected[0].Timestamp) / time.Millisecond); pts != expectedPTS {
+					t.Errorf("Expected frame %d at %d, got %d", i, expectedPTS, pts)
+				}
+				pos += ivfFrameHeaderSize
+				if !bytes.Equal(f.Data, data[pos:pos+size]) {
+					t.Errorf("Expected frame %d to be %v, got %v", i, f.Data, data[pos:pos+size])
+				}
+				pos += size
+			}
+			if pos != len(data) {
+				t.Errorf("Expected %d bytes, got %d", pos, len(data))
+			}
+		})
+	}
+}
+
+func TestIVFWriterTracks(t *testing.T) {
+	w := NewIVFWriter(&bytes.Buffer{})
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "audio", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000)); err == nil {
+		t.Error("Expected an error for Opus")
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video", "video", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video2", "video2", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)); err == nil {
+		t.Error("Expected an error for the second track")
+	}
+}
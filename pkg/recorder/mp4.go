@@ -0,0 +1,557 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+// Reference: ISO/IEC 14496-12, ISO/IEC 14496-15 and https://opus-codec.org/docs/opus_in_isobmff.html
+
+const (
+	// defaultFragmentDuration is the longest duration of a fragment if FMP4Writer.FragmentDuration is 0.
+	defaultFragmentDuration = time.Second
+	// mp4VideoTimescale is the number of the time units in a second of the video tracks.
+	mp4VideoTimescale = 90000
+	// mp4DefaultFrameDuration is the duration of the last video sample if it's unknown.
+	mp4DefaultFrameDuration = mp4VideoTimescale / 30
+)
+
+// Sample flags of the fragments
+const (
+	mp4SampleDependsOnNothing = 0x02000000
+	mp4SampleDependsOnOthers  = 0x01000000
+	mp4SampleIsNonSync        = 0x00010000
+)
+
+// FMP4Writer muxes an H.264 video track and an Opus audio track into fragmented MP4. Either of them
+// can be omitted. The tracks have to share the MediaClock, e.g. the tracks of a MediaStream, so that
+// their timestamps are comparable.
+//
+// The initialization segment has the parameter sets of H.264, so the frames are buffered until Start
+// is called and the video track gets an IDR picture with the parameter sets. The video track uses
+// the avc3 sample entry and the parameter sets are kept in the samples too, so that the stream can
+// change them, e.g. when the encoder is rebuilt at another resolution. A fragment is started at
+// every IDR picture, or after FragmentDuration. The durations of the video samples are the intervals
+// of their timestamps, and the ones of the audio samples are the numbers of the samples in the packets.
+//
+// The writer never seeks, and the initialization segment and every fragment are written by a single
+// Write call, so that w can be a file or a stream delivering them separately, e.g. by HTTP.
+type FMP4Writer struct {
+	// FragmentDuration is the longest duration of a fragment. It has to be set before Start.
+	// If it's 0, 1 second is used.
+	FragmentDuration time.Duration
+
+	w io.Writer
+
+	mu      sync.Mutex
+	tracks  []*mp4Track
+	started bool
+	closed  bool
+	// pending is the frames written before the initialization segment.
+	pending []mp4Frame
+
+	// origin is the timestamp of the earliest frame. The initialization segment is written when it's set.
+	origin time.Time
+	seq    uint32
+	// fragmentStart is the time of the first sample in the current fragment from origin.
+	fragmentStart time.Duration
+}
+
+type mp4Track struct {
+	*Track
+	video     bool
+	timescale uint32
+	info      h264Info
+
+	// samples are the samples in the current fragment, and baseTime is the decode time of the first one.
+	samples  []mp4Sample
+	baseTime uint64
+	// last is the last video sample whose duration is known when the next one comes, and lastDuration
+	// is the duration of the sample before it.
+	last         *mp4Sample
+	lastDuration uint32
+	// nextTime is the decode time of the next audio sample, or the last video sample.
+	nextTime uint64
+	started  bool
+}
+
+type mp4Sample struct {
+	data     []byte
+	time     uint64
+	duration uint32
+	keyFrame bool
+}
+
+type mp4Frame struct {
+	track *mp4Track
+	frame codec.EncodedFrame
+}
+
+// NewFMP4Writer creates an FMP4Writer writing into w.
+func NewFMP4Writer(w io.Writer) *FMP4Writer {
+	return &FMP4Writer{w: w}
+}
+
+// NewTrack creates the track to be recorded. It has the signature of mediadevices.TrackGenerator.
+// An H.264 track and an Opus track can be created before Start.
+func (w *FMP4Writer) NewTrack(payloadType uint8, ssrc uint32, id, label string, c *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+	video := isCodec(c, webrtc.H264)
+	if !video && !isCodec(c, webrtc.Opus) {
+		return nil, fmt.Errorf("recorder: MP4 doesn't support %s", c.Name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil, errClosed
+	}
+	if w.started {
+		return nil, errStarted
+	}
+	for _, t := range w.tracks {
+		if t.video == video {
+			return nil, fmt.Errorf("recorder: MP4 can only have one %s track", c.Type)
+		}
+	}
+
+	t := &Track{codec: c, id: id, m: w, number: len(w.tracks) + 1}
+	timescale := uint32(mp4VideoTimescale)
+	if !video {
+		timescale = opusSampleRate
+	}
+	w.tracks = append(w.tracks, &mp4Track{Track: t, video: video, timescale: timescale})
+	return t, nil
+}
+
+// Start starts writing the tracks. The initialization segment is written when the video track gets
+// an IDR picture.
+func (w *FMP4Writer) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+	if len(w.tracks) == 0 {
+		return errors.New("recorder: no tracks to write")
+	}
+	if w.FragmentDuration == 0 {
+		w.FragmentDuration = defaultFragmentDuration
+	}
+	w.started = true
+	return w.flushPending()
+}
+
+func (w *FMP4Writer) writeFrame(t *Track, frame codec.EncodedFrame) error {
+	if len(frame.Data) == 0 {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+	track := w.tracks[t.number-1]
+	if track.video && track.info.sps == nil {
+		info, err := parseH264(frame.Data)
+		if err != nil {
+			return err
+		}
+		if !info.keyFrame || info.sps == nil || info.pps == nil {
+			// The pictures can't be decoded until the first IDR picture with the parameter sets.
+			return nil
+		}
+		track.info = h264Info{
+			frameInfo: info.frameInfo,
+			sps:       append([]byte{}, info.sps...),
+			pps:       append([]byte{}, info.pps...),
+		}
+	}
+
+	if w.origin.IsZero() {
+		// The data is owned by the caller, so it's copied while buffered.
+		frame.Data = append([]byte{}, frame.Data...)
+		w.pending = append(w.pending, mp4Frame{track: track, frame: frame})
+		return w.flushPending()
+	}
+	return w.mux(track, frame)
+}
+
+// flushPending writes the initialization segment and the pending frames if the writer is ready.
+func (w *FMP4Writer) flushPending() error {
+	if !w.started || !w.origin.IsZero() || len(w.pending) == 0 {
+		return nil
+	}
+	for _, t := range w.tracks {
+		if t.video && t.info.sps == nil {
+			return nil
+		}
+	}
+
+	sort.SliceStable(w.pending, func(i, j int) bool {
+		return w.pending[i].frame.Timestamp.Before(w.pending[j].frame.Timestamp)
+	})
+	w.origin = w.pending[0].frame.Timestamp
+	if _, err := w.w.Write(w.initSegment()); err != nil {
+		return err
+	}
+
+	pending := w.pending
+	w.pending = nil
+	for _, f := range pending {
+		if err := w.mux(f.track, f.frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mux adds the frame to the fragment. The fragment is written before the frame if it starts
+// a new one.
+func (w *FMP4Writer) mux(t *mp4Track, frame codec.EncodedFrame) error {
+	if frame.Timestamp.Before(w.origin) {
+		// The decode time can't be negative.
+		return nil
+	}
+	elapsed := frame.Timestamp.Sub(w.origin)
+	// The seconds and the rest are converted separately to avoid the overflow.
+	ts := uint64(elapsed/time.Second)*uint64(t.timescale) + uint64(elapsed%time.Second)*uint64(t.timescale)/uint64(time.Second)
+
+	var sample mp4Sample
+	if t.video {
+		info, err := parseH264(frame.Data)
+		if err != nil {
+			return err
+		}
+		sample = mp4Sample{data: avcSample(frame.Data), keyFrame: info.keyFrame}
+	} else {
+		samples, err := opusSamples(frame.Data)
+		if err != nil {
+			return err
+		}
+		sample = mp4Sample{data: append([]byte{}, frame.Data...), duration: uint32(samples), keyFrame: true}
+	}
+
+	if !t.started {
+		t.started = true
+		t.nextTime = ts
+	}
+	if t.video && t.last != nil {
+		// The decode time has to be increasing.
+		if ts <= t.nextTime {
+			ts = t.nextTime + 1
+		}
+		t.last.duration = uint32(ts - t.nextTime)
+		t.addSample(*t.last)
+		t.lastDuration = t.last.duration
+		t.last = nil
+		t.nextTime = ts
+	}
+
+	// A new fragment is started at every IDR picture except the first one, which joins the fragment
+	// with the audio samples before it.
+	newFragment := (t.video && sample.keyFrame && len(t.samples) > 0) || elapsed-w.fragmentStart >= w.FragmentDuration
+	if w.fragmentEmpty() {
+		w.fragmentStart = elapsed
+	} else if newFragment {
+		if err := w.flushFragment(); err != nil {
+			return err
+		}
+		w.fragmentStart = elapsed
+	}
+
+	sample.time = t.nextTime
+	if t.video {
+		t.last = &sample
+		return nil
+	}
+	t.addSample(sample)
+	t.nextTime += uint64(sample.duration)
+	return nil
+}
+
+func (t *mp4Track) addSample(s mp4Sample) {
+	if len(t.samples) == 0 {
+		t.baseTime = s.time
+	}
+	t.samples = append(t.samples, s)
+}
+
+func (w *FMP4Writer) fragmentEmpty() bool {
+	for _, t := range w.tracks {
+		if len(t.samples) > 0 || t.last != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// avcSample converts the access unit in Annex B format into the length prefixed NAL units.
+// The access unit delimiters are removed, and the parameter sets are kept for avc3.
+func avcSample(data []byte) []byte {
+	b := make([]byte, 0, len(data))
+	for _, nalu := range splitNALUs(data) {
+		if naluType(nalu) == naluTypeAUD {
+			continue
+		}
+		b = append(b, be32(uint32(len(nalu)))...)
+		b = append(b, nalu...)
+	}
+	return b
+}
+
+// flushFragment writes the samples of the tracks in a fragment.
+func (w *FMP4Writer) flushFragment() error {
+	var tracks []*mp4Track
+	for _, t := range w.tracks {
+		if len(t.samples) > 0 {
+			tracks = append(tracks, t)
+		}
+	}
+	if len(tracks) == 0 {
+		return nil
+	}
+	w.seq++
+
+	// The size of moof doesn't depend on the data offsets, so it's built twice to get the offsets.
+	offsets := make([]uint32, len(tracks))
+	moof := w.moof(tracks, offsets)
+	offset := uint32(len(moof) + 8)
+	var data []byte
+	for i, t := range tracks {
+		offsets[i] = offset + uint32(len(data))
+		for _, s := range t.samples {
+			data = append(data, s.data...)
+		}
+	}
+	fragment := append(w.moof(tracks, offsets), box("mdat", data)...)
+
+	for _, t := range tracks {
+		t.samples = nil
+	}
+	_, err := w.w.Write(fragment)
+	return err
+}
+
+func (w *FMP4Writer) moof(tracks []*mp4Track, offsets []uint32) []byte {
+	trafs := [][]byte{fullBox("mfhd", 0, 0, be32(w.seq))}
+	for i, t := range tracks {
+		const (
+			tfhdDefaultBaseIsMoof = 0x020000
+			trunDataOffset        = 0x000001
+			trunSampleDuration    = 0x000100
+			trunSampleSize        = 0x000200
+			trunSampleFlags       = 0x000400
+		)
+		trun := append(be32(uint32(len(t.samples))), be32(offsets[i])...)
+		for _, s := range t.samples {
+			flags := uint32(mp4SampleDependsOnNothing)
+			if !s.keyFrame {
+				flags = mp4SampleDependsOnOthers | mp4SampleIsNonSync
+			}
+			trun = append(trun, be32(s.duration)...)
+			trun = append(trun, be32(uint32(len(s.data)))...)
+			trun = append(trun, be32(flags)...)
+		}
+		trafs = append(trafs, box("traf",
+			fullBox("tfhd", 0, tfhdDefaultBaseIsMoof, be32(uint32(t.number))),
+			fullBox("tfdt", 1, 0, be64(t.baseTime)),
+			fullBox("trun", 0, trunDataOffset|trunSampleDuration|trunSampleSize|trunSampleFlags, trun),
+		))
+	}
+	return box("moof", trafs...)
+}
+
+func (w *FMP4Writer) initSegment() []byte {
+	ftyp := box("ftyp", []byte("iso5"), be32(0x200), []byte("iso5iso6mp41"))
+
+	mvhd := fullBox("mvhd", 0, 0,
+		be32(0), be32(0), // creation_time, modification_time
+		be32(1000), be32(0), // timescale, duration
+		be32(0x00010000), be16(0x0100), make([]byte, 10), // rate, volume, reserved
+		mp4Matrix, make([]byte, 24), // matrix, pre_defined
+		be32(uint32(len(w.tracks)+1)), // next_track_ID
+	)
+	traks := [][]byte{mvhd}
+	var trexs [][]byte
+	for _, t := range w.tracks {
+		traks = append(traks, t.trak())
+		trexs = append(trexs, fullBox("trex", 0, 0,
+			be32(uint32(t.number)), be32(1), // track_ID, default_sample_description_index
+			be32(0), be32(0), be32(0), // default_sample_duration, default_sample_size, default_sample_flags
+		))
+	}
+	traks = append(traks, box("mvex", trexs...))
+	return append(ftyp, box("moov", traks...)...)
+}
+
+var mp4Matrix = []byte{
+	0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0x00, 0x00, 0x00,
+}
+
+func (t *mp4Track) trak() []byte {
+	var volume uint16
+	var width, height int
+	handler, name := "vide", "VideoHandler"
+	mediaHeader := fullBox("vmhd", 0, 1, make([]byte, 8))
+	var sampleEntry []byte
+	if t.video {
+		width, height = t.info.width, t.info.height
+		sampleEntry = t.avc3()
+	} else {
+		volume = 0x0100
+		handler, name = "soun", "SoundHandler"
+		mediaHeader = fullBox("smhd", 0, 0, make([]byte, 4))
+		sampleEntry = t.opus()
+	}
+
+	tkhd := fullBox("tkhd", 0, 3, // track_enabled, track_in_movie
+		be32(0), be32(0), be32(uint32(t.number)), be32(0), // creation_time, modification_time, track_ID, reserved
+		be32(0), make([]byte, 8), // duration, reserved
+		be16(0), be16(0), be16(volume), be16(0), // layer, alternate_group, volume, reserved
+		mp4Matrix, be32(uint32(width)<<16), be32(uint32(height)<<16),
+	)
+	mdhd := fullBox("mdhd", 0, 0,
+		be32(0), be32(0), be32(t.timescale), be32(0), // creation_time, modification_time, timescale, duration
+		be16(0x55c4), be16(0), // language "und", pre_defined
+	)
+	hdlr := fullBox("hdlr", 0, 0, be32(0), []byte(handler), make([]byte, 12), []byte(name), []byte{0})
+	dinf := box("dinf", fullBox("dref", 0, 0, be32(1), fullBox("url ", 0, 1)))
+	stbl := box("stbl",
+		fullBox("stsd", 0, 0, be32(1), sampleEntry),
+		fullBox("stts", 0, 0, be32(0)),
+		fullBox("stsc", 0, 0, be32(0)),
+		fullBox("stsz", 0, 0, be32(0), be32(0)),
+		fullBox("stco", 0, 0, be32(0)),
+	)
+	minf := box("minf", mediaHeader, dinf, stbl)
+	return box("trak", tkhd, box("mdia", mdhd, hdlr, minf))
+}
+
+// avc3 returns the sample entry with the first parameter sets in avcC. The later ones are only in
+// the samples.
+func (t *mp4Track) avc3() []byte {
+	sps, pps := t.info.sps, t.info.pps
+	avcC := []byte{
+		1,                      // configurationVersion
+		sps[1], sps[2], sps[3], // AVCProfileIndication, profile_compatibility, AVCLevelIndication
+		0xfc | 3, // lengthSizeMinusOne
+		0xe0 | 1, // numOfSequenceParameterSets
+	}
+	avcC = append(avcC, be16(uint16(len(sps)))...)
+	avcC = append(avcC, sps...)
+	avcC = append(avcC, 1) // numOfPictureParameterSets
+	avcC = append(avcC, be16(uint16(len(pps)))...)
+	avcC = append(avcC, pps...)
+
+	return box("avc3",
+		make([]byte, 6), be16(1), // reserved, data_reference_index
+		make([]byte, 16), // pre_defined, reserved
+		be16(uint16(t.info.width)), be16(uint16(t.info.height)),
+		be32(0x00480000), be32(0x00480000), be32(0), // horizresolution, vertresolution, reserved
+		be16(1), make([]byte, 32), // frame_count, compressorname
+		be16(0x0018), be16(0xffff), // depth, pre_defined
+		box("avcC", avcC),
+	)
+}
+
+func (t *mp4Track) opus() []byte {
+	channels := opusChannels(t.codec)
+	dOps := []byte{0, byte(channels)} // Version, OutputChannelCount
+	dOps = append(dOps, be16(opusPreSkip)...)
+	dOps = append(dOps, be32(t.codec.ClockRate)...)
+	dOps = append(dOps, 0, 0, 0) // OutputGain, ChannelMappingFamily
+
+	return box("Opus",
+		make([]byte, 6), be16(1), // reserved, data_reference_index
+		make([]byte, 8),                  // reserved
+		be16(uint16(channels)), be16(16), // channelcount, samplesize
+		be16(0), be16(0), be32(opusSampleRate<<16), // pre_defined, reserved, samplerate
+		box("dOps", dOps),
+	)
+}
+
+// Close writes the buffered samples. The duration of the last video sample is the same as the one
+// before it. The underlying writer is not closed.
+func (w *FMP4Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.origin.IsZero() {
+		if len(w.pending) > 0 {
+			return errNoKeyFrame
+		}
+		return nil
+	}
+
+	for _, t := range w.tracks {
+		if t.last == nil {
+			continue
+		}
+		t.last.duration = t.lastDuration
+		if t.last.duration == 0 {
+			t.last.duration = mp4DefaultFrameDuration
+		}
+		t.addSample(*t.last)
+		t.last = nil
+	}
+	return w.flushFragment()
+}
+
+// box returns the ISO base media file format box.
+func box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := make([]byte, 0, size)
+	b = append(b, be32(uint32(size))...)
+	b = append(b, typ...)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// fullBox returns the box with the version and the flags.
+func fullBox(typ string, version byte, flags uint32, payload ...[]byte) []byte {
+	header := []byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return box(typ, append([][]byte{header}, payload...)...)
+}
+
+func be16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func be32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func be64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
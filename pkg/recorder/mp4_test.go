@@ -0,0 +1,393 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+type mp4Box struct {
+	typ string
+	// pos is the position of the box in its parent.
+	pos  int
+	data []byte
+}
+
+func readBoxes(t *testing.T, b []byte) []mp4Box {
+	t.Helper()
+	var boxes []mp4Box
+	for pos := 0; pos < len(b); {
+		if len(b)-pos < 8 {
+			t.Fatalf("Expected a box header at %d, got %d bytes", pos, len(b)-pos)
+		}
+		size := int(binary.BigEndian.Uint32(b[pos:]))
+		if size < 8 || pos+size > len(b) {
+			t.Fatalf("Invalid box size %d at %d", size, pos)
+		}
+		boxes = append(boxes, mp4Box{typ: string(b[pos+4 : pos+8]), pos: pos, data: b[pos+8 : pos+size]})
+		pos += size
+	}
+	return boxes
+}
+
+// findBox returns the box at the path. The full boxes in the path have to be skipped by the callers.
+func findBox(t *testing.T, b []byte, path ...string) mp4Box {
+	t.Helper()
+	var found mp4Box
+	for _, typ := range path {
+		var ok bool
+		for _, box := range readBoxes(t, b) {
+			if box.typ == typ {
+				found, ok = box, true
+				break
+			}
+		}
+		if !ok {
+			t.Fatalf("Expected %s box in %v", typ, path)
+		}
+		b = found.data
+	}
+	return found
+}
+
+func findBoxes(t *testing.T, b []byte, typ string) []mp4Box {
+	t.Helper()
+	var found []mp4Box
+	for _, box := range readBoxes(t, b) {
+		if box.typ == typ {
+			found = append(found, box)
+		}
+	}
+	return found
+}
+
+// writeRecorder records the data of every Write call.
+type writeRecorder struct {
+	writes [][]byte
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, append([]byte{}, p...))
+	return len(p), nil
+}
+
+type trun struct {
+	track    uint32
+	baseTime uint64
+	samples  []trunSample
+}
+
+type trunSample struct {
+	duration uint32
+	keyFrame bool
+	data     []byte
+}
+
+func readFragment(t *testing.T, fragment []byte) (uint32, []trun) {
+	t.Helper()
+	boxes := readBoxes(t, fragment)
+	if len(boxes) != 2 || boxes[0].typ != "moof" || boxes[1].typ != "mdat" {
+		t.Fatalf("Expected moof and mdat, got %v", boxes)
+	}
+	moof := boxes[0]
+	seq := binary.BigEndian.Uint32(findBox(t, moof.data, "mfhd").data[4:])
+
+	var truns []trun
+	for _, traf := range findBoxes(t, moof.data, "traf") {
+		tfhd := findBox(t, traf.data, "tfhd").data
+		if flags := binary.BigEndian.Uint32(tfhd) & 0xffffff; flags != 0x020000 {
+			t.Errorf("Expected default-base-is-moof, got tfhd flags %x", flags)
+		}
+		tfdt := findBox(t, traf.data, "tfdt").data
+		r := findBox(t, traf.data, "trun").data
+		tr := trun{
+			track:    binary.BigEndian.Uint32(tfhd[4:]),
+			baseTime: binary.BigEndian.Uint64(tfdt[4:]),
+		}
+		n := int(binary.BigEndian.Uint32(r[4:]))
+		offset := moof.pos + int(binary.BigEndian.Uint32(r[8:]))
+		for i := 0; i < n; i++ {
+			entry := r[12+12*i:]
+			size := int(binary.BigEndian.Uint32(entry[4:]))
+			if offset+size > len(fragment) {
+				t.Fatalf("Sample %d of track %d overruns the fragment", i, tr.track)
+			}
+			tr.samples = append(tr.samples, trunSample{
+				duration: binary.BigEndian.Uint32(entry),
+				keyFrame: binary.BigEndian.Uint32(entry[8:])&mp4SampleIsNonSync == 0,
+				data:     fragment[offset : offset+size],
+			})
+			offset += size
+		}
+		truns = append(truns, tr)
+	}
+	return seq, truns
+}
+
+func TestFMP4Writer(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	ms := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Millisecond) }
+	idr := []byte{0x65, 0xb8, 0x00, 0x04}
+	nonIDR := []byte{0x41, 0x9a, 0x00}
+	avc := func(nalu []byte) []byte { return append(be32(uint32(len(nalu))), nalu...) }
+	opus := func(n byte) []byte { return []byte{0xf8, n} }
+
+	out := &writeRecorder{}
+	w := NewFMP4Writer(out)
+	video, err := w.NewTrack(webrtc.DefaultPayloadTypeH264, 0, "video", "stream", webrtc.NewRTPH264Codec(webrtc.DefaultPayloadTypeH264, 90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	audio, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio2", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000)); err != errStarted {
+		t.Errorf("Expected %v, got %v", errStarted, err)
+	}
+
+	writes := []struct {
+		track mediadevices.LocalTrack
+		frame codec.EncodedFrame
+	}{
+		{audio, codec.EncodedFrame{Data: opus(0), KeyFrame: true, Timestamp: ms(0)}},
+		// The pictures before the first IDR picture are dropped.
+		{video, codec.EncodedFrame{Data: annexB(nonIDR), Timestamp: ms(10)}},
+		{video, codec.EncodedFrame{Data: annexB(sps360p, pps, idr), KeyFrame: true, Timestamp: ms(30)}},
+		{audio, codec.EncodedFrame{Data: opus(1), KeyFrame: true, Timestamp: ms(20)}},
+		{audio, codec.EncodedFrame{Data: opus(2), KeyFrame: true, Timestamp: ms(40)}},
+		{video, codec.EncodedFrame{Data: annexB(nonIDR), Timestamp: ms(63)}},
+		{video, codec.EncodedFrame{Data: annexB(nonIDR), Timestamp: ms(96)}},
+		{video, codec.EncodedFrame{Data: annexB(idr), KeyFrame: true, Timestamp: ms(130)}},
+		{audio, codec.EncodedFrame{Data: opus(3), KeyFrame: true, Timestamp: ms(60)}},
+	}
+	for _, write := range writes {
+		if err := write.track.(mediadevices.FrameWriter).WriteFrame(write.frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The initialization segment and the fragments are written separately.
+	if len(out.writes) != 3 {
+		t.Fatalf("Expected 3 writes, got %d", len(out.writes))
+	}
+
+	init := out.writes[0]
+	ftyp := findBox(t, init, "ftyp").data
+	if brand := string(ftyp[:4]); brand != "iso5" {
+		t.Errorf("Expected major brand iso5, got %s", brand)
+	}
+	moov := findBox(t, init, "moov").data
+	traks := findBoxes(t, moov, "trak")
+	if len(traks) != 2 {
+		t.Fatalf("Expected 2 traks, got %d", len(traks))
+	}
+	if trexs := findBoxes(t, findBox(t, moov, "mvex").data, "trex"); len(trexs) != 2 {
+		t.Errorf("Expected 2 trexs, got %d", len(trexs))
+	}
+
+	tkhd := findBox(t, traks[0].data, "tkhd").data
+	if width, height := binary.BigEndian.Uint32(tkhd[76:])>>16, binary.BigEndian.Uint32(tkhd[80:])>>16; width != 640 || height != 360 {
+		t.Errorf("Expected 640x360, got %dx%d", width, height)
+	}
+	if timescale := binary.BigEndian.Uint32(findBox(t, traks[0].data, "mdia", "mdhd").data[12:]); timescale != 90000 {
+		t.Errorf("Expected the video timescale 90000, got %d", timescale)
+	}
+	stsd := findBox(t, traks[0].data, "mdia", "minf", "stbl", "stsd").data
+	avc3 := findBox(t, stsd[8:], "avc3").data
+	if width, height := binary.BigEndian.Uint16(avc3[24:]), binary.BigEndian.Uint16(avc3[26:]); width != 640 || height != 360 {
+		t.Errorf("Expected avc3 of 640x360, got %dx%d", width, height)
+	}
+	avcC := findBox(t, avc3[78:], "avcC").data
+	expectedAvcC := append([]byte{1, 0x42, 0xc0, 0x1e, 0xff, 0xe1, 0, byte(len(sps360p))}, sps360p...)
+	expectedAvcC = append(append(expectedAvcC, 1, 0, byte(len(pps))), pps...)
+	if !bytes.Equal(expectedAvcC, avcC) {
+		t.Errorf("Expected avcC %x, got %x", expectedAvcC, avcC)
+	}
+
+	if timescale := binary.BigEndian.Uint32(findBox(t, traks[1].data, "mdia", "mdhd").data[12:]); timescale != 48000 {
+		t.Errorf("Expected the audio timescale 48000, got %d", timescale)
+	}
+	stsd = findBox(t, traks[1].data, "mdia", "minf", "stbl", "stsd").data
+	dOps := findBox(t, findBox(t, stsd[8:], "Opus").data[28:], "dOps").data
+	if expected := []byte{0, 2, 0x01, 0x38, 0, 0, 0xbb, 0x80, 0, 0, 0}; !bytes.Equal(expected, dOps) {
+		t.Errorf("Expected dOps %x, got %x", expected, dOps)
+	}
+
+	// The durations of the video samples are from the timestamps at 90kHz, and the last one is
+	// the same as the one before it. The parameter sets are kept in the samples.
+	expected := []struct {
+		seq   uint32
+		truns []trun
+	}{
+		{1, []trun{
+			{1, 2700, []trunSample{{2970, true, append(append(avc(sps360p), avc(pps)...), avc(idr)...)}, {2970, false, avc(nonIDR)}, {3060, false, avc(nonIDR)}}},
+			{2, 0, []trunSample{{960, true, opus(0)}, {960, true, opus(1)}, {960, true, opus(2)}}},
+		}},
+		{2, []trun{
+			{1, 11700, []trunSample{{3060, true, avc(idr)}}},
+			{2, 2880, []trunSample{{960, true, opus(3)}}},
+		}},
+	}
+	for i, e := range expected {
+		seq, truns := readFragment(t, out.writes[i+1])
+		if seq != e.seq {
+			t.Errorf("Expected sequence number %d, got %d", e.seq, seq)
+		}
+		if !reflect.DeepEqual(e.truns, truns) {
+			t.Errorf("Expected fragment %d to be %v, got %v", i, e.truns, truns)
+		}
+	}
+}
+
+func TestFMP4WriterParameterSetChange(t *testing.T) {
+	idr := []byte{0x65, 0xb8, 0x00, 0x04}
+	nonIDR := []byte{0x41, 0x9a, 0x00}
+	avc := func(nalus ...[]byte) []byte {
+		var b []byte
+		for _, nalu := range nalus {
+			b = append(append(b, be32(uint32(len(nalu)))...), nalu...)
+		}
+		return b
+	}
+
+	out := &writeRecorder{}
+	w := NewFMP4Writer(out)
+	video, err := w.NewTrack(webrtc.DefaultPayloadTypeH264, 0, "video", "stream", webrtc.NewRTPH264Codec(webrtc.DefaultPayloadTypeH264, 90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The encoder is rebuilt at 1080p in the middle of the stream.
+	frames := []codec.EncodedFrame{
+		{Data: annexB(sps360p, pps, idr), KeyFrame: true},
+		{Data: annexB(nonIDR)},
+		{Data: annexB(sps1080p, pps, idr), KeyFrame: true},
+		{Data: annexB(nonIDR)},
+	}
+	for i, frame := range frames {
+		frame.Timestamp = time.Unix(1, 0).Add(time.Duration(i) * 33 * time.Millisecond)
+		if err := video.(mediadevices.FrameWriter).WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out.writes) != 3 {
+		t.Fatalf("Expected 3 writes, got %d", len(out.writes))
+	}
+	stsd := findBox(t, findBox(t, out.writes[0], "moov", "trak", "mdia", "minf", "stbl", "stsd").data[8:], "avc3").data
+	avcC := findBox(t, stsd[78:], "avcC").data
+	if !bytes.Contains(avcC, sps360p) {
+		t.Errorf("Expected avcC to have the first SPS, got %x", avcC)
+	}
+
+	// The new parameter sets are in the sample of the IDR picture, so that it can be decoded.
+	expected := [][][]byte{
+		{avc(sps360p, pps, idr), avc(nonIDR)},
+		{avc(sps1080p, pps, idr), avc(nonIDR)},
+	}
+	for i, e := range expected {
+		_, truns := readFragment(t, out.writes[i+1])
+		if len(truns) != 1 || len(truns[0].samples) != len(e) {
+			t.Fatalf("Expected %d samples in fragment %d, got %v", len(e), i, truns)
+		}
+		for j, data := range e {
+			if !bytes.Equal(data, truns[0].samples[j].data) {
+				t.Errorf("Expected sample %d of fragment %d to be %x, got %x", j, i, data, truns[0].samples[j].data)
+			}
+		}
+	}
+}
+
+func TestFMP4WriterFragmentDuration(t *testing.T) {
+	out := &writeRecorder{}
+	w := NewFMP4Writer(out)
+	w.FragmentDuration = 100 * time.Millisecond
+	audio, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		frame := codec.EncodedFrame{Data: []byte{0xf8, byte(i)}, KeyFrame: true, Timestamp: time.Unix(1, 0).Add(time.Duration(i) * 20 * time.Millisecond)}
+		if err := audio.(mediadevices.FrameWriter).WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out.writes) != 4 {
+		t.Fatalf("Expected 4 writes, got %d", len(out.writes))
+	}
+	var baseTimes []uint64
+	var counts []int
+	for _, fragment := range out.writes[1:] {
+		_, truns := readFragment(t, fragment)
+		if len(truns) != 1 {
+			t.Fatalf("Expected 1 track, got %d", len(truns))
+		}
+		baseTimes = append(baseTimes, truns[0].baseTime)
+		counts = append(counts, len(truns[0].samples))
+	}
+	if expected := []uint64{0, 4800, 9600}; !reflect.DeepEqual(expected, baseTimes) {
+		t.Errorf("Expected base media decode times %v, got %v", expected, baseTimes)
+	}
+	if expected := []int{5, 5, 2}; !reflect.DeepEqual(expected, counts) {
+		t.Errorf("Expected %v samples, got %v", expected, counts)
+	}
+}
+
+func TestFMP4WriterErrors(t *testing.T) {
+	w := NewFMP4Writer(&bytes.Buffer{})
+	if err := w.Start(); err == nil {
+		t.Error("Expected an error without tracks")
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video", "stream", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)); err == nil {
+		t.Error("Expected an error for VP8")
+	}
+	video, err := w.NewTrack(webrtc.DefaultPayloadTypeH264, 0, "video", "stream", webrtc.NewRTPH264Codec(webrtc.DefaultPayloadTypeH264, 90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeH264, 0, "video2", "stream", webrtc.NewRTPH264Codec(webrtc.DefaultPayloadTypeH264, 90000)); err == nil {
+		t.Error("Expected an error for the second video track")
+	}
+	audio, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := video.(mediadevices.FrameWriter).WriteFrame(codec.EncodedFrame{Data: annexB([]byte{0x41, 0x9a}), Timestamp: time.Unix(1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := audio.(mediadevices.FrameWriter).WriteFrame(codec.EncodedFrame{Data: []byte{0xf8}, KeyFrame: true, Timestamp: time.Unix(1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != errNoKeyFrame {
+		t.Errorf("Expected %v, got %v", errNoKeyFrame, err)
+	}
+}
@@ -0,0 +1,214 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+// Reference: https://tools.ietf.org/html/rfc3533
+
+const (
+	oggPageHeaderSize = 27
+	// oggMaxSegments is the maximum number of the segments in a page. A packet is split into
+	// the segments of 255 bytes, and it ends with a segment shorter than 255 bytes.
+	oggMaxSegments = 255
+	oggSegmentSize = 255
+	// oggNoGranule is the granule position of the pages where no packets end.
+	oggNoGranule = ^uint64(0)
+)
+
+// Header types of Ogg pages
+const (
+	oggContinued     = 0x01
+	oggBeginOfStream = 0x02
+	oggEndOfStream   = 0x04
+)
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// oggCRC returns the checksum of the page, which is CRC-32 with the polynomial 0x04c11db7,
+// the initial value 0, and no reflection.
+func oggCRC(b []byte) uint32 {
+	var crc uint32
+	for _, v := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^v]
+	}
+	return crc
+}
+
+// OggWriter writes an Opus track into Ogg. The granule positions are the number of the samples
+// at 48kHz in the packets, so the gaps in the timestamps are not kept. Every packet is written in
+// its own page, and the last packet is held until the next one comes or Close is called to mark
+// the end of the stream.
+type OggWriter struct {
+	w      io.Writer
+	serial uint32
+
+	mu     sync.Mutex
+	track  *Track
+	closed bool
+	// started is true if the headers are written.
+	started bool
+	seq     uint32
+	granule uint64
+	last    []byte
+}
+
+// NewOggWriter creates an OggWriter writing into w.
+func NewOggWriter(w io.Writer) *OggWriter {
+	return &OggWriter{w: w, serial: rand.Uint32()}
+}
+
+// NewTrack creates the track to be recorded. It has the signature of mediadevices.TrackGenerator,
+// and only one Opus track can be created.
+func (w *OggWriter) NewTrack(payloadType uint8, ssrc uint32, id, label string, c *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+	if !isCodec(c, webrtc.Opus) {
+		return nil, fmt.Errorf("recorder: Ogg doesn't support %s", c.Name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil, errClosed
+	}
+	if w.track != nil {
+		return nil, errors.New("recorder: Ogg can only have one track")
+	}
+	w.track = &Track{codec: c, id: id, m: w, number: 1}
+	return w.track, nil
+}
+
+func (w *OggWriter) writeFrame(t *Track, frame codec.EncodedFrame) error {
+	if len(frame.Data) == 0 {
+		return nil
+	}
+	samples, err := opusSamples(frame.Data)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+
+	if !w.started {
+		head := opusHead(opusChannels(t.codec), t.codec.ClockRate)
+		if err := w.writePacket(head, 0, oggBeginOfStream); err != nil {
+			return err
+		}
+		if err := w.writePacket(opusTags(muxingApp), 0, 0); err != nil {
+			return err
+		}
+		w.started = true
+	}
+
+	if w.last != nil {
+		if err := w.writePacket(w.last, w.granule, 0); err != nil {
+			return err
+		}
+	}
+	// The data is owned by the caller, so it's copied while held.
+	w.last = append([]byte{}, frame.Data...)
+	w.granule += uint64(samples)
+	return nil
+}
+
+// writePacket writes the packet into the pages. The granule position is set to the page where
+// the packet ends, and the end of the stream flag is only set to it.
+func (w *OggWriter) writePacket(packet []byte, granule uint64, headerType byte) error {
+	var lacing []byte
+	for n := len(packet); ; n -= oggSegmentSize {
+		if n < oggSegmentSize {
+			lacing = append(lacing, byte(n))
+			break
+		}
+		lacing = append(lacing, oggSegmentSize)
+	}
+
+	for {
+		segments := lacing
+		if len(segments) > oggMaxSegments {
+			segments = segments[:oggMaxSegments]
+		}
+		var size int
+		for _, s := range segments {
+			size += int(s)
+		}
+
+		typ, g := headerType&^oggEndOfStream, granule
+		if len(segments) < len(lacing) {
+			g = oggNoGranule
+		} else {
+			typ = headerType
+		}
+		if err := w.writePage(typ, g, segments, packet[:size]); err != nil {
+			return err
+		}
+
+		packet, lacing = packet[size:], lacing[len(segments):]
+		if len(lacing) == 0 {
+			return nil
+		}
+		headerType = headerType&^oggBeginOfStream | oggContinued
+	}
+}
+
+func (w *OggWriter) writePage(headerType byte, granule uint64, segments, data []byte) error {
+	page := make([]byte, oggPageHeaderSize, oggPageHeaderSize+len(segments)+len(data))
+	copy(page, "OggS")
+	page[4] = 0 // version
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], w.serial)
+	binary.LittleEndian.PutUint32(page[18:], w.seq)
+	page[26] = byte(len(segments))
+	page = append(page, segments...)
+	page = append(page, data...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+
+	w.seq++
+	_, err := w.w.Write(page)
+	return err
+}
+
+// Close writes the last packet with the end of the stream flag. The underlying writer is not closed.
+func (w *OggWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.last == nil {
+		return nil
+	}
+	return w.writePacket(w.last, w.granule, oggEndOfStream)
+}
@@ -0,0 +1,167 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+type oggPage struct {
+	headerType byte
+	granule    uint64
+	serial     uint32
+	seq        uint32
+	segments   []byte
+	data       []byte
+}
+
+func readOggPages(t *testing.T, b []byte) []oggPage {
+	t.Helper()
+	var pages []oggPage
+	for len(b) > 0 {
+		if len(b) < oggPageHeaderSize || string(b[:4]) != "OggS" {
+			t.Fatalf("Expected an Ogg page, got %v", b)
+		}
+		n := int(b[26])
+		if len(b) < oggPageHeaderSize+n {
+			t.Fatal("Expected the segment table, got EOF")
+		}
+		segments := b[oggPageHeaderSize : oggPageHeaderSize+n]
+		size := oggPageHeaderSize + n
+		for _, s := range segments {
+			size += int(s)
+		}
+		if len(b) < size {
+			t.Fatal("Expected the page data, got EOF")
+		}
+
+		page := append([]byte{}, b[:size]...)
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if crc, expected := binary.LittleEndian.Uint32(b[22:]), oggCRC(page); crc != expected {
+			t.Errorf("Expected CRC %x, got %x", expected, crc)
+		}
+
+		pages = append(pages, oggPage{
+			headerType: b[5],
+			granule:    binary.LittleEndian.Uint64(b[6:]),
+			serial:     binary.LittleEndian.Uint32(b[14:]),
+			seq:        binary.LittleEndian.Uint32(b[18:]),
+			segments:   segments,
+			data:       b[oggPageHeaderSize+n : size],
+		})
+		b = b[size:]
+	}
+	return pages
+}
+
+func TestOggCRC(t *testing.T) {
+	if crc := oggCRC([]byte("123456789")); crc != 0x89a1897f {
+		t.Errorf("Expected 0x89a1897f, got 0x%x", crc)
+	}
+}
+
+func TestOggWriter(t *testing.T) {
+	large := make([]byte, oggSegmentSize*oggMaxSegments)
+	large[0] = 0xf9
+	medium := make([]byte, 600)
+	medium[0] = 0xf8
+	packets := [][]byte{{0xf8, 0x01}, medium, large, {0xf8, 0x02}}
+
+	buf := &bytes.Buffer{}
+	w := NewOggWriter(buf)
+	track, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "audio", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range packets {
+		frame := codec.EncodedFrame{Data: p, KeyFrame: true, Timestamp: time.Unix(1, 0).Add(time.Duration(i) * 20 * time.Millisecond)}
+		if err := track.(mediadevices.FrameWriter).WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+		// Empty frames are ignored.
+		if err := track.(mediadevices.FrameWriter).WriteFrame(codec.EncodedFrame{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := readOggPages(t, buf.Bytes())
+	type page struct {
+		headerType byte
+		granule    uint64
+		segments   int
+	}
+	expected := []page{
+		{oggBeginOfStream, 0, 1},
+		{0, 0, 1},
+		{0, 960, 1},
+		{0, 1920, 3},
+		// The large packet has 256 segments with the last one of 0 bytes.
+		{0, oggNoGranule, 255},
+		{oggContinued, 3840, 1},
+		{oggEndOfStream, 4800, 1},
+	}
+	var actual []page
+	for i, p := range pages {
+		actual = append(actual, page{p.headerType, p.granule, len(p.segments)})
+		if p.serial != pages[0].serial {
+			t.Errorf("Expected serial %d, got %d", pages[0].serial, p.serial)
+		}
+		if p.seq != uint32(i) {
+			t.Errorf("Expected page %d, got %d", i, p.seq)
+		}
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Expected pages %v, got %v", expected, actual)
+	}
+
+	head := pages[0].data
+	if !bytes.HasPrefix(head, []byte("OpusHead")) || len(head) != 19 {
+		t.Fatalf("Expected OpusHead, got %v", head)
+	}
+	if head[9] != 2 {
+		t.Errorf("Expected 2 channels, got %d", head[9])
+	}
+	if preSkip := binary.LittleEndian.Uint16(head[10:]); preSkip != opusPreSkip {
+		t.Errorf("Expected pre-skip %d, got %d", opusPreSkip, preSkip)
+	}
+	if rate := binary.LittleEndian.Uint32(head[12:]); rate != 48000 {
+		t.Errorf("Expected 48000Hz, got %d", rate)
+	}
+	tags := pages[1].data
+	if !bytes.HasPrefix(tags, []byte("OpusTags")) {
+		t.Fatalf("Expected OpusTags, got %v", tags)
+	}
+	vendorLen := binary.LittleEndian.Uint32(tags[8:])
+	if vendor := string(tags[12 : 12+vendorLen]); vendor != muxingApp {
+		t.Errorf("Expected vendor %s, got %s", muxingApp, vendor)
+	}
+
+	data := append(append([]byte{}, pages[4].data...), pages[5].data...)
+	for i, p := range [][]byte{pages[2].data, pages[3].data, data, pages[6].data} {
+		if !bytes.Equal(packets[i], p) {
+			t.Errorf("Expected packet %d of %d bytes, got %d bytes", i, len(packets[i]), len(p))
+		}
+	}
+}
+
+func TestOggWriterTracks(t *testing.T) {
+	w := NewOggWriter(&bytes.Buffer{})
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video", "video", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)); err == nil {
+		t.Error("Expected an error for VP8")
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "audio", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio2", "audio2", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000)); err == nil {
+		t.Error("Expected an error for the second track")
+	}
+}
@@ -0,0 +1,92 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/pion/webrtc/v2"
+)
+
+// Reference: https://tools.ietf.org/html/rfc7845
+
+const (
+	// opusPreSkip is the number of the samples at 48kHz to be discarded at the beginning, which is
+	// the lookahead of libopus.
+	opusPreSkip = 312
+	// opusSampleRate is the sample rate of the granule positions and the durations of Opus.
+	opusSampleRate = 48000
+)
+
+// opusChannels returns the number of the channels of the Opus codec. The packets are decoded in
+// stereo if it's unknown, which works for the mono streams too.
+func opusChannels(c *webrtc.RTPCodec) int {
+	if c.Channels == 0 {
+		return 2
+	}
+	return int(c.Channels)
+}
+
+// opusHead returns the identification header of Opus.
+// Reference: https://tools.ietf.org/html/rfc7845#section-5.1
+func opusHead(channels int, sampleRate uint32) []byte {
+	b := make([]byte, 19)
+	copy(b, "OpusHead")
+	b[8] = 1 // version
+	b[9] = byte(channels)
+	binary.LittleEndian.PutUint16(b[10:], opusPreSkip)
+	binary.LittleEndian.PutUint32(b[12:], sampleRate)
+	// Output gain and channel mapping family are 0.
+	return b
+}
+
+// opusTags returns the comment header of Opus without the user comments.
+// Reference: https://tools.ietf.org/html/rfc7845#section-5.2
+func opusTags(vendor string) []byte {
+	b := make([]byte, 8+4+len(vendor)+4)
+	copy(b, "OpusTags")
+	binary.LittleEndian.PutUint32(b[8:], uint32(len(vendor)))
+	copy(b[12:], vendor)
+	// The number of the user comments is 0.
+	return b
+}
+
+// opusSamples returns the number of the samples at 48kHz in the packet from its TOC byte.
+// Reference: https://tools.ietf.org/html/rfc6716#section-3.1
+func opusSamples(packet []byte) (int, error) {
+	if len(packet) == 0 {
+		return 0, errors.New("invalid Opus packet: empty")
+	}
+
+	// Frame sizes in 1/400 seconds, i.e. 120 samples at 48kHz.
+	config := packet[0] >> 3
+	var size int
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60ms
+		size = []int{4, 8, 16, 24}[config%4]
+	case config < 16: // Hybrid: 10, 20ms
+		size = []int{4, 8}[config%2]
+	default: // CELT: 2.5, 5, 10, 20ms
+		size = []int{1, 2, 4, 8}[config%4]
+	}
+
+	var frames int
+	switch packet[0] & 0x03 {
+	case 0:
+		frames = 1
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0, errors.New("invalid Opus packet: too short")
+		}
+		frames = int(packet[1] & 0x3f)
+	}
+
+	samples := frames * size * opusSampleRate / 400
+	// The packets can't be longer than 120ms.
+	if samples == 0 || samples > opusSampleRate*120/1000 {
+		return 0, fmt.Errorf("invalid Opus packet: %d samples", samples)
+	}
+	return samples, nil
+}
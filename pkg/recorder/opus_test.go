@@ -0,0 +1,81 @@
+package recorder
+
+import (
+	"testing"
+)
+
+func TestOpusSamples(t *testing.T) {
+	testCases := map[string]struct {
+		packet   []byte
+		expected int
+		err      bool
+	}{
+		"SILK10ms": {
+			packet:   []byte{0x00},
+			expected: 480,
+		},
+		"SILK60ms": {
+			packet:   []byte{0x18},
+			expected: 2880,
+		},
+		"Hybrid20ms": {
+			packet:   []byte{0x68},
+			expected: 960,
+		},
+		"CELT2.5ms": {
+			packet:   []byte{0x80},
+			expected: 120,
+		},
+		"CELT20ms": {
+			packet:   []byte{0xf8},
+			expected: 960,
+		},
+		"TwoFrames": {
+			packet:   []byte{0xf9},
+			expected: 1920,
+		},
+		"TwoFramesDifferentSizes": {
+			packet:   []byte{0xfa},
+			expected: 1920,
+		},
+		"ArbitraryFrames": {
+			packet:   []byte{0xfb, 0x06},
+			expected: 5760,
+		},
+		"Empty": {
+			packet: []byte{},
+			err:    true,
+		},
+		"NoFrameCount": {
+			packet: []byte{0xfb},
+			err:    true,
+		},
+		"ZeroFrames": {
+			packet: []byte{0xfb, 0x00},
+			err:    true,
+		},
+		"TooLong": {
+			packet: []byte{0xfb, 0x07},
+			err:    true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			samples, err := opusSamples(testCase.packet)
+			if testCase.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if samples != testCase.expected {
+				t.Errorf("Expected %d samples, got %d", testCase.expected, samples)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package recorder provides the LocalTracks which write the encoded frames into media containers,
+// so that the tracks can be recorded without peer connections. The writers create the tracks by
+// NewTrack, which can be passed to mediadevices.WithTrackGenerator:
+//
+//	w := recorder.NewWebMWriter(f)
+//	md := mediadevices.NewMediaDevicesFromCodecs(codecs, mediadevices.WithTrackGenerator(w.NewTrack))
+//	s, _ := md.GetUserMedia(constraints)
+//	w.Start()
+//	...
+//	for _, t := range s.GetTracks() {
+//		t.Stop()
+//	}
+//	w.Close()
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// muxingApp is the name of the application written in the containers.
+const muxingApp = "mediadevices"
+
+var (
+	errClosed  = errors.New("recorder: writer is closed")
+	errStarted = errors.New("recorder: tracks can't be added after the writer is started")
+)
+
+// muxer writes the frames of the tracks into a container.
+type muxer interface {
+	writeFrame(t *Track, frame codec.EncodedFrame) error
+}
+
+// Track is a LocalTrack writing the frames into a container. The frames written by WriteFrame keep
+// their timestamps and key frame flags. The frames written by WriteSample are timestamped by
+// the number of samples written before them, and the key frames are found from their bitstreams.
+type Track struct {
+	codec *webrtc.RTPCodec
+	id    string
+	m     muxer
+	// number is the track number in the container, which starts from 1.
+	number int
+
+	mu sync.Mutex
+	// start is the timestamp of the first sample written by WriteSample, and samples is the number of
+	// the samples written after it.
+	start   time.Time
+	samples uint64
+}
+
+var _ mediadevices.LocalTrack = &Track{}
+var _ mediadevices.FrameWriter = &Track{}
+
+// WriteSample writes the sample. It's used if the track is written by the callers which don't
+// know the metadata of the frames.
+func (t *Track) WriteSample(s media.Sample) error {
+	t.mu.Lock()
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	elapsed := time.Duration(t.samples) * time.Second / time.Duration(t.codec.ClockRate)
+	t.samples += uint64(s.Samples)
+	t.mu.Unlock()
+
+	frame := codec.EncodedFrame{
+		Data:      s.Data,
+		Timestamp: t.start.Add(elapsed),
+		Duration:  time.Duration(s.Samples) * time.Second / time.Duration(t.codec.ClockRate),
+	}
+	info, err := t.parse(s.Data)
+	if err != nil {
+		return err
+	}
+	frame.KeyFrame = info.keyFrame
+	return t.m.writeFrame(t, frame)
+}
+
+// WriteFrame writes the frame with its metadata.
+func (t *Track) WriteFrame(frame codec.EncodedFrame) error {
+	return t.m.writeFrame(t, frame)
+}
+
+// Codec returns the codec of the track.
+func (t *Track) Codec() *webrtc.RTPCodec {
+	return t.codec
+}
+
+// ID returns the ID of the track.
+func (t *Track) ID() string {
+	return t.id
+}
+
+// Kind returns the kind of the track.
+func (t *Track) Kind() webrtc.RTPCodecType {
+	return t.codec.Type
+}
+
+// parse parses the frame header. Every audio frame is a key frame.
+func (t *Track) parse(data []byte) (frameInfo, error) {
+	switch {
+	case isCodec(t.codec, webrtc.VP8):
+		return parseVP8(data)
+	case isCodec(t.codec, webrtc.VP9):
+		return parseVP9(data)
+	case isCodec(t.codec, webrtc.H264):
+		info, err := parseH264(data)
+		return info.frameInfo, err
+	case isCodec(t.codec, webrtc.Opus):
+		return frameInfo{keyFrame: true}, nil
+	}
+	return frameInfo{}, fmt.Errorf("recorder: unsupported codec %s", t.codec.Name)
+}
+
+// isCodec returns true if the codec has the name. The codec names are case-insensitive.
+func isCodec(c *webrtc.RTPCodec, name string) bool {
+	return strings.EqualFold(c.Name, name)
+}
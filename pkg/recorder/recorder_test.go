@@ -0,0 +1,84 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// seekBuffer is an in-memory io.WriteSeeker.
+type seekBuffer struct {
+	data []byte
+	pos  int64
+}
+
+func (b *seekBuffer) Write(p []byte) (int, error) {
+	if end := b.pos + int64(len(p)); end > int64(len(b.data)) {
+		b.data = append(b.data, make([]byte, end-int64(len(b.data)))...)
+	}
+	copy(b.data[b.pos:], p)
+	b.pos += int64(len(p))
+	return len(p), nil
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.pos
+	case io.SeekEnd:
+		offset += int64(len(b.data))
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	b.pos = offset
+	return offset, nil
+}
+
+func TestWriteSample(t *testing.T) {
+	buf := &seekBuffer{}
+	w := NewIVFWriter(buf)
+	track, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video", "video", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := []media.Sample{
+		{Data: newVP8InterFrame(), Samples: 3000},
+		{Data: newVP8KeyFrame(320, 240), Samples: 3000},
+		{Data: newVP8InterFrame(), Samples: 6000},
+		{Data: newVP8InterFrame(), Samples: 3000},
+	}
+	for _, s := range samples {
+		if err := track.WriteSample(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The frames are timestamped by the samples written before them, and start from the key frame.
+	expected := []uint64{0, 33, 100}
+	pos := ivfHeaderSize
+	for i, pts := range expected {
+		if len(buf.data) < pos+ivfFrameHeaderSize {
+			t.Fatalf("Expected frame %d, got EOF", i)
+		}
+		size := int(binary.LittleEndian.Uint32(buf.data[pos:]))
+		if actual := binary.LittleEndian.Uint64(buf.data[pos+4:]); actual != pts {
+			t.Errorf("Expected frame %d at %d, got %d", i, pts, actual)
+		}
+		pos += ivfFrameHeaderSize + size
+	}
+	if pos != len(buf.data) {
+		t.Errorf("Expected %d bytes, got %d", pos, len(buf.data))
+	}
+}
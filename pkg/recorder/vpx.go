@@ -0,0 +1,116 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errShortFrame = errors.New("frame is too short")
+
+// frameInfo is the information of a video frame parsed from its bitstream.
+type frameInfo struct {
+	keyFrame bool
+	// width and height are only set for the key frames.
+	width, height int
+}
+
+// parseVP8 parses the frame tag and the key frame header of a VP8 frame.
+// Reference: https://tools.ietf.org/html/rfc6386#section-9.1
+func parseVP8(data []byte) (frameInfo, error) {
+	if len(data) < 3 {
+		return frameInfo{}, errShortFrame
+	}
+	if data[0]&0x01 != 0 {
+		return frameInfo{}, nil
+	}
+
+	if len(data) < 10 {
+		return frameInfo{}, errShortFrame
+	}
+	if data[3] != 0x9d || data[4] != 0x01 || data[5] != 0x2a {
+		return frameInfo{}, errors.New("invalid VP8 start code")
+	}
+	return frameInfo{
+		keyFrame: true,
+		width:    int(binary.LittleEndian.Uint16(data[6:]) & 0x3fff),
+		height:   int(binary.LittleEndian.Uint16(data[8:]) & 0x3fff),
+	}, nil
+}
+
+// parseVP9 parses the uncompressed header of a VP9 frame up to the frame size. Only the first frame
+// of a superframe is parsed, so the size is the one of the bottom spatial layer.
+// Reference: https://storage.googleapis.com/downloads.webmproject.org/docs/vp9/vp9-bitstream-specification-v0.6-20160331-draft.pdf
+func parseVP9(data []byte) (frameInfo, error) {
+	r := &bitReader{data: data}
+	if r.read(2) != 2 {
+		return frameInfo{}, errors.New("invalid VP9 frame marker")
+	}
+	profile := r.read(1)
+	profile |= r.read(1) << 1
+	if profile == 3 {
+		r.read(1)
+	}
+	if r.read(1) == 1 {
+		// show_existing_frame
+		return frameInfo{}, r.err()
+	}
+	if r.read(1) != 0 {
+		// Non key frame
+		return frameInfo{}, r.err()
+	}
+	r.read(2) // show_frame, error_resilient_mode
+
+	if r.read(8) != 0x49 || r.read(8) != 0x83 || r.read(8) != 0x42 {
+		if err := r.err(); err != nil {
+			return frameInfo{}, err
+		}
+		return frameInfo{}, errors.New("invalid VP9 sync code")
+	}
+
+	// color_config
+	if profile >= 2 {
+		r.read(1) // ten_or_twelve_bit
+	}
+	const csRGB = 7
+	if r.read(3) != csRGB {
+		r.read(1) // color_range
+		if profile == 1 || profile == 3 {
+			r.read(3) // subsampling_x, subsampling_y, reserved_zero
+		}
+	} else if profile == 1 || profile == 3 {
+		r.read(1) // reserved_zero
+	}
+
+	width := r.read(16) + 1
+	height := r.read(16) + 1
+	if err := r.err(); err != nil {
+		return frameInfo{}, err
+	}
+	return frameInfo{keyFrame: true, width: int(width), height: int(height)}, nil
+}
+
+// bitReader reads bits from the most significant one. Reading beyond the data returns zeros,
+// and the error is reported by err.
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.pos < len(r.data)*8 {
+			v |= uint32(r.data[r.pos/8]>>(7-uint(r.pos%8))) & 1
+		}
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) err() error {
+	if r.pos > len(r.data)*8 {
+		return errShortFrame
+	}
+	return nil
+}
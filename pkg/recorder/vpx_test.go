@@ -0,0 +1,169 @@
+package recorder
+
+import (
+	"testing"
+)
+
+// bitWriter writes bits from the most significant one.
+type bitWriter struct {
+	data []byte
+	n    int
+}
+
+func (w *bitWriter) write(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte((v>>uint(i))&1) << (7 - uint(w.n%8))
+		w.n++
+	}
+}
+
+func newVP8KeyFrame(width, height int) []byte {
+	return []byte{
+		0x10, 0x02, 0x00, // frame tag
+		0x9d, 0x01, 0x2a, // start code
+		byte(width), byte(width >> 8),
+		byte(height), byte(height >> 8),
+		0x00, 0x00,
+	}
+}
+
+func newVP8InterFrame() []byte {
+	return []byte{0x31, 0x00, 0x00, 0x00}
+}
+
+func newVP9KeyFrame(profile uint32, width, height int) []byte {
+	w := &bitWriter{}
+	w.write(2, 2) // frame_marker
+	w.write(profile&1, 1)
+	w.write(profile>>1, 1)
+	if profile == 3 {
+		w.write(0, 1)
+	}
+	w.write(0, 1) // show_existing_frame
+	w.write(0, 1) // frame_type
+	w.write(1, 1) // show_frame
+	w.write(0, 1) // error_resilient_mode
+	w.write(0x498342, 24)
+	if profile >= 2 {
+		w.write(0, 1)
+	}
+	w.write(2, 3) // color_space
+	w.write(0, 1) // color_range
+	if profile == 1 || profile == 3 {
+		w.write(0, 3)
+	}
+	w.write(uint32(width-1), 16)
+	w.write(uint32(height-1), 16)
+	w.write(0, 8)
+	return w.data
+}
+
+func newVP9InterFrame() []byte {
+	w := &bitWriter{}
+	w.write(2, 2) // frame_marker
+	w.write(0, 2) // profile
+	w.write(0, 1) // show_existing_frame
+	w.write(1, 1) // frame_type
+	w.write(1, 1) // show_frame
+	w.write(0, 9)
+	return w.data
+}
+
+func TestParseVP8(t *testing.T) {
+	testCases := map[string]struct {
+		data     []byte
+		expected frameInfo
+		err      bool
+	}{
+		"KeyFrame": {
+			data:     newVP8KeyFrame(640, 480),
+			expected: frameInfo{keyFrame: true, width: 640, height: 480},
+		},
+		"InterFrame": {
+			data:     newVP8InterFrame(),
+			expected: frameInfo{},
+		},
+		"Short": {
+			data: newVP8KeyFrame(640, 480)[:8],
+			err:  true,
+		},
+		"InvalidStartCode": {
+			data: []byte{0x10, 0x02, 0x00, 0x00, 0x00, 0x00, 0x80, 0x02, 0xe0, 0x01},
+			err:  true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			info, err := parseVP8(testCase.data)
+			if testCase.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info != testCase.expected {
+				t.Errorf("Expected %+v, got %+v", testCase.expected, info)
+			}
+		})
+	}
+}
+
+func TestParseVP9(t *testing.T) {
+	testCases := map[string]struct {
+		data     []byte
+		expected frameInfo
+		err      bool
+	}{
+		"KeyFrameProfile0": {
+			data:     newVP9KeyFrame(0, 640, 480),
+			expected: frameInfo{keyFrame: true, width: 640, height: 480},
+		},
+		"KeyFrameProfile1": {
+			data:     newVP9KeyFrame(1, 1280, 720),
+			expected: frameInfo{keyFrame: true, width: 1280, height: 720},
+		},
+		"KeyFrameProfile3": {
+			data:     newVP9KeyFrame(3, 320, 240),
+			expected: frameInfo{keyFrame: true, width: 320, height: 240},
+		},
+		"InterFrame": {
+			data:     newVP9InterFrame(),
+			expected: frameInfo{},
+		},
+		"Short": {
+			data: newVP9KeyFrame(0, 640, 480)[:6],
+			err:  true,
+		},
+		"InvalidFrameMarker": {
+			data: []byte{0x00, 0x00},
+			err:  true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			info, err := parseVP9(testCase.data)
+			if testCase.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info != testCase.expected {
+				t.Errorf("Expected %+v, got %+v", testCase.expected, info)
+			}
+		})
+	}
+}
@@ -0,0 +1,484 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+// Reference: https://www.webmproject.org/docs/container/
+
+const (
+	// webmTimecodeScale is the unit of the timecodes.
+	webmTimecodeScale = time.Millisecond
+	// maxClusterDuration is the longest duration of a cluster. A new cluster is started at every
+	// video key frame, or after this duration if no key frames come.
+	maxClusterDuration = 5 * time.Second
+	// seekHeadSize is the space reserved for the SeekHead with the entries of Info, Tracks and Cues.
+	seekHeadSize = 68
+	// segmentSizeLength is the length of the Segment size, which is updated on Close.
+	segmentSizeLength = 8
+
+	// opusSeekPreRoll is the duration of the audio to be decoded before the seek point.
+	opusSeekPreRoll = 80 * time.Millisecond
+	// maxPendingDuration is the longest duration of the audio buffered before the header. The header
+	// fails to be written if a video track gets no key frame within this duration after Start.
+	maxPendingDuration = 10 * time.Second
+)
+
+var errNoKeyFrame = errors.New("recorder: the header can't be written before a key frame of every video track")
+
+// WebMWriter muxes the VP8 or VP9 video tracks and the Opus audio tracks into WebM. The tracks have to
+// share the MediaClock, e.g. the tracks of a MediaStream, so that their timestamps are comparable.
+//
+// The header has the list of the tracks, so the frames are buffered until Start is called and
+// every video track gets a key frame. Only the audio of the last maxPendingDuration and the video
+// from the last key frame are buffered. The timecodes are in milliseconds from the earliest frame
+// in the file. A cluster is started at every video key frame, and it's indexed by the cues at the end.
+// The duration, the Segment size and the position of the cues are written on Close if the underlying
+// writer is an io.WriteSeeker.
+type WebMWriter struct {
+	w io.Writer
+
+	mu      sync.Mutex
+	tracks  []*webmTrack
+	started bool
+	closed  bool
+	// pending is the frames written before the header, and waitSince is the timestamp of the first
+	// frame written after Start while the header can't be written.
+	pending   []webmFrame
+	waitSince time.Time
+
+	// origin is the timestamp of the earliest frame. The header is written when it's set.
+	origin time.Time
+	// pos is the position in the Segment. The positions of the elements are relative to the Segment data.
+	pos int64
+	// segmentPos is the absolute position of the Segment data, and durationPos is the position of
+	// the Duration in the Segment. They are only set if w is an io.WriteSeeker.
+	segmentPos  int64
+	durationPos int64
+	infoPos     int64
+	tracksPos   int64
+	end         time.Duration
+
+	cluster     []byte
+	clusterTime time.Duration
+	cues        []byte
+}
+
+type webmTrack struct {
+	*Track
+	video bool
+	info  frameInfo
+}
+
+type webmFrame struct {
+	track *webmTrack
+	frame codec.EncodedFrame
+}
+
+// NewWebMWriter creates a WebMWriter writing into w.
+func NewWebMWriter(w io.Writer) *WebMWriter {
+	return &WebMWriter{w: w}
+}
+
+// NewTrack creates the track to be recorded. It has the signature of mediadevices.TrackGenerator.
+// VP8, VP9 and Opus are supported, and the tracks have to be created before Start.
+func (w *WebMWriter) NewTrack(payloadType uint8, ssrc uint32, id, label string, c *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+	video := isCodec(c, webrtc.VP8) || isCodec(c, webrtc.VP9)
+	if !video && !isCodec(c, webrtc.Opus) {
+		return nil, fmt.Errorf("recorder: WebM doesn't support %s", c.Name)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil, errClosed
+	}
+	if w.started {
+		return nil, errStarted
+	}
+	t := &Track{codec: c, id: id, m: w, number: len(w.tracks) + 1}
+	w.tracks = append(w.tracks, &webmTrack{Track: t, video: video})
+	return t, nil
+}
+
+// Start starts writing the tracks. The header is written when every video track gets a key frame.
+func (w *WebMWriter) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+	if len(w.tracks) == 0 {
+		return errors.New("recorder: no tracks to write")
+	}
+	w.started = true
+	return w.flushPending()
+}
+
+func (w *WebMWriter) writeFrame(t *Track, frame codec.EncodedFrame) error {
+	if len(frame.Data) == 0 {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errClosed
+	}
+	track := w.tracks[t.number-1]
+	if track.video && track.info.width == 0 {
+		if !frame.KeyFrame {
+			// The frames can't be decoded until the first key frame.
+			return w.checkPending(frame.Timestamp)
+		}
+		info, err := t.parse(frame.Data)
+		if err != nil {
+			return err
+		}
+		track.info = info
+	}
+
+	if w.origin.IsZero() {
+		w.bufferPending(track, frame)
+		if err := w.flushPending(); err != nil {
+			return err
+		}
+		return w.checkPending(frame.Timestamp)
+	}
+	return w.mux(track, frame)
+}
+
+// bufferPending buffers the frame until the header is written. The video frames before a key frame
+// of the same track and the audio frames older than maxPendingDuration are dropped.
+func (w *WebMWriter) bufferPending(track *webmTrack, frame codec.EncodedFrame) {
+	pending := w.pending[:0]
+	for _, f := range w.pending {
+		if track.video && frame.KeyFrame && f.track == track {
+			continue
+		}
+		if !f.track.video && frame.Timestamp.Sub(f.frame.Timestamp) > maxPendingDuration {
+			continue
+		}
+		pending = append(pending, f)
+	}
+	// The data is owned by the caller, so it's copied while buffered.
+	frame.Data = append([]byte{}, frame.Data...)
+	w.pending = append(pending, webmFrame{track: track, frame: frame})
+}
+
+// checkPending returns errNoKeyFrame if the header isn't written within maxPendingDuration after Start.
+func (w *WebMWriter) checkPending(t time.Time) error {
+	if !w.started || !w.origin.IsZero() {
+		return nil
+	}
+	if w.waitSince.IsZero() {
+		w.waitSince = t
+	}
+	if t.Sub(w.waitSince) > maxPendingDuration {
+		return errNoKeyFrame
+	}
+	return nil
+}
+
+// flushPending writes the header and the pending frames if the writer is ready.
+func (w *WebMWriter) flushPending() error {
+	if !w.started || !w.origin.IsZero() || len(w.pending) == 0 {
+		return nil
+	}
+	for _, t := range w.tracks {
+		if t.video && t.info.width == 0 {
+			return nil
+		}
+	}
+
+	sort.SliceStable(w.pending, func(i, j int) bool {
+		return w.pending[i].frame.Timestamp.Before(w.pending[j].frame.Timestamp)
+	})
+	w.origin = w.pending[0].frame.Timestamp
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	pending := w.pending
+	w.pending = nil
+	for _, f := range pending {
+		if err := w.mux(f.track, f.frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WebMWriter) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.pos += int64(n)
+	return err
+}
+
+func (w *WebMWriter) writeHeader() error {
+	var header []byte
+	header = appendElement(header, idEBML, w.ebmlHeader())
+	header = appendID(header, idSegment)
+	header = appendSizeN(header, unknownSize, segmentSizeLength)
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+
+	ws, seekable := w.w.(io.WriteSeeker)
+	if seekable {
+		pos, err := ws.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		w.segmentPos = pos
+	}
+
+	// The SeekHead is rewritten with the position of the cues on Close.
+	w.infoPos = seekHeadSize
+	info := w.info(seekable)
+	w.tracksPos = w.infoPos + int64(len(info))
+	if err := w.write(w.seekHead(0)); err != nil {
+		return err
+	}
+	if err := w.write(info); err != nil {
+		return err
+	}
+	return w.write(w.trackEntries())
+}
+
+func (w *WebMWriter) ebmlHeader() []byte {
+	var b []byte
+	b = appendUint(b, idEBMLVersion, 1)
+	b = appendUint(b, idEBMLReadVersion, 1)
+	b = appendUint(b, idEBMLMaxIDLength, 4)
+	b = appendUint(b, idEBMLMaxSizeLength, 8)
+	b = appendString(b, idDocType, "webm")
+	b = appendUint(b, idDocTypeVersion, 4)
+	b = appendUint(b, idDocTypeReadVersion, 2)
+	return b
+}
+
+// seekHead returns the SeekHead padded to seekHeadSize. The cues are not indexed if cuesPos is 0.
+func (w *WebMWriter) seekHead(cuesPos int64) []byte {
+	seek := func(b []byte, id uint32, pos int64) []byte {
+		var entry []byte
+		entry = appendElement(entry, idSeekID, appendID(nil, id))
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, uint64(pos))
+		entry = appendElement(entry, idSeekPosition, data)
+		return appendElement(b, idSeek, entry)
+	}
+
+	var entries []byte
+	entries = seek(entries, idInfo, w.infoPos)
+	entries = seek(entries, idTracks, w.tracksPos)
+	if cuesPos != 0 {
+		entries = seek(entries, idCues, cuesPos)
+	}
+	b := appendElement(nil, idSeekHead, entries)
+	if len(b) < seekHeadSize {
+		b = appendVoid(b, seekHeadSize-len(b))
+	}
+	return b
+}
+
+func (w *WebMWriter) info(withDuration bool) []byte {
+	var b []byte
+	b = appendUint(b, idTimecodeScale, uint64(webmTimecodeScale))
+	b = appendString(b, idMuxingApp, muxingApp)
+	b = appendString(b, idWritingApp, muxingApp)
+	if withDuration {
+		// The duration is written on Close.
+		b = appendFloat(b, idDuration, 0)
+	}
+
+	info := appendElement(nil, idInfo, b)
+	if withDuration {
+		// Duration is the last element, and its value is 8 bytes.
+		w.durationPos = w.infoPos + int64(len(info)) - 8
+	}
+	return info
+}
+
+func (w *WebMWriter) trackEntries() []byte {
+	var entries []byte
+	for _, t := range w.tracks {
+		var b []byte
+		b = appendUint(b, idTrackNumber, uint64(t.number))
+		b = appendUint(b, idTrackUID, uint64(t.number))
+		b = appendUint(b, idFlagLacing, 0)
+		if t.video {
+			codecID := "V_VP8"
+			if isCodec(t.codec, webrtc.VP9) {
+				codecID = "V_VP9"
+			}
+			b = appendUint(b, idTrackType, trackTypeVideo)
+			b = appendString(b, idCodecID, codecID)
+			var v []byte
+			v = appendUint(v, idPixelWidth, uint64(t.info.width))
+			v = appendUint(v, idPixelHeight, uint64(t.info.height))
+			b = appendElement(b, idVideo, v)
+		} else {
+			channels := opusChannels(t.codec)
+			b = appendUint(b, idTrackType, trackTypeAudio)
+			b = appendString(b, idCodecID, "A_OPUS")
+			b = appendElement(b, idCodecPrivate, opusHead(channels, t.codec.ClockRate))
+			b = appendUint(b, idCodecDelay, uint64(opusPreSkip*time.Second/opusSampleRate))
+			b = appendUint(b, idSeekPreRoll, uint64(opusSeekPreRoll))
+			var a []byte
+			a = appendFloat(a, idSamplingFrequency, float64(t.codec.ClockRate))
+			a = appendUint(a, idChannels, uint64(channels))
+			b = appendElement(b, idAudio, a)
+		}
+		entries = appendElement(entries, idTrackEntry, b)
+	}
+	return appendElement(nil, idTracks, entries)
+}
+
+// mux adds the frame to the cluster.
+func (w *WebMWriter) mux(t *webmTrack, frame codec.EncodedFrame) error {
+	if frame.Timestamp.Before(w.origin) {
+		// The timecodes of the clusters can't be negative.
+		return nil
+	}
+	ts := frame.Timestamp.Sub(w.origin).Truncate(webmTimecodeScale)
+
+	rel := (ts - w.clusterTime) / webmTimecodeScale
+	videoKeyFrame := t.video && frame.KeyFrame
+	if w.cluster == nil || videoKeyFrame ||
+		rel < math.MinInt16 || rel > math.MaxInt16 || ts-w.clusterTime >= maxClusterDuration {
+		if err := w.flushCluster(); err != nil {
+			return err
+		}
+		w.clusterTime = ts
+		w.cluster = appendUint(nil, idTimecode, uint64(ts/webmTimecodeScale))
+		rel = 0
+		if videoKeyFrame || !w.hasVideo() {
+			w.addCue(t, ts)
+		}
+	}
+
+	var flags byte
+	if frame.KeyFrame {
+		flags |= 0x80
+	}
+	// The track number is a variable size integer, followed by the relative timecode in int16.
+	block := []byte{0x80 | byte(t.number), byte(uint16(rel) >> 8), byte(uint16(rel)), flags}
+	block = append(block, frame.Data...)
+	w.cluster = appendElement(w.cluster, idSimpleBlock, block)
+
+	end := ts
+	if !t.video {
+		end += frame.Duration
+	}
+	if end > w.end {
+		w.end = end
+	}
+	return nil
+}
+
+func (w *WebMWriter) hasVideo() bool {
+	for _, t := range w.tracks {
+		if t.video {
+			return true
+		}
+	}
+	return false
+}
+
+// addCue indexes the cluster written next.
+func (w *WebMWriter) addCue(t *webmTrack, ts time.Duration) {
+	var pos []byte
+	pos = appendUint(pos, idCueTrack, uint64(t.number))
+	pos = appendUint(pos, idCueClusterPosition, uint64(w.pos))
+
+	var point []byte
+	point = appendUint(point, idCueTime, uint64(ts/webmTimecodeScale))
+	point = appendElement(point, idCueTrackPositions, pos)
+	w.cues = appendElement(w.cues, idCuePoint, point)
+}
+
+func (w *WebMWriter) flushCluster() error {
+	if w.cluster == nil {
+		return nil
+	}
+	cluster := w.cluster
+	w.cluster = nil
+	return w.write(appendElement(nil, idCluster, cluster))
+}
+
+// Close writes the buffered cluster and the cues. The duration, the Segment size and the position of
+// the cues are written if the underlying writer is an io.WriteSeeker. The underlying writer is not closed.
+func (w *WebMWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.origin.IsZero() {
+		if len(w.pending) > 0 {
+			return errNoKeyFrame
+		}
+		return nil
+	}
+
+	if err := w.flushCluster(); err != nil {
+		return err
+	}
+	cuesPos := w.pos
+	if w.cues != nil {
+		if err := w.write(appendElement(nil, idCues, w.cues)); err != nil {
+			return err
+		}
+	}
+
+	ws, ok := w.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	end, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	patch := func(pos int64, b []byte) error {
+		if _, err := ws.Seek(w.segmentPos+pos, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := ws.Write(b)
+		return err
+	}
+
+	if err := patch(-segmentSizeLength, appendSizeN(nil, uint64(w.pos), segmentSizeLength)); err != nil {
+		return err
+	}
+	if w.cues != nil {
+		if err := patch(0, w.seekHead(cuesPos)); err != nil {
+			return err
+		}
+	}
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(float64(w.end)/float64(webmTimecodeScale)))
+	if err := patch(w.durationPos, duration); err != nil {
+		return err
+	}
+
+	_, err = ws.Seek(end, io.SeekStart)
+	return err
+}
@@ -0,0 +1,405 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/webrtc/v2"
+)
+
+type element struct {
+	id uint32
+	// pos is the position of the element in its parent.
+	pos  int64
+	data []byte
+	// unknownSize is true if the element has the unknown size.
+	unknownSize bool
+}
+
+// readVint reads a variable size integer. The length marker is kept if keepMarker is true.
+func readVint(t *testing.T, b []byte, keepMarker bool) (uint64, int, bool) {
+	t.Helper()
+	if len(b) == 0 {
+		t.Fatal("Expected a variable size integer, got EOF")
+	}
+	n := 1
+	for n <= 8 && b[0]&(0x80>>uint(n-1)) == 0 {
+		n++
+	}
+	if n > 8 || len(b) < n {
+		t.Fatalf("Invalid variable size integer: %v", b)
+	}
+	var v uint64
+	for i := 0; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	marker := uint64(1) << (7 * uint(n))
+	// All ones mean the unknown size.
+	unknown := v&(marker-1) == marker-1
+	if !keepMarker {
+		v &^= marker
+	}
+	return v, n, unknown
+}
+
+func readElements(t *testing.T, b []byte) []element {
+	t.Helper()
+	var elements []element
+	var pos int64
+	for pos < int64(len(b)) {
+		id, n, _ := readVint(t, b[pos:], true)
+		size, m, unknown := readVint(t, b[pos+int64(n):], false)
+		start := pos + int64(n+m)
+		end := start + int64(size)
+		if unknown {
+			end = int64(len(b))
+		}
+		if end > int64(len(b)) {
+			t.Fatalf("Element %x at %d overruns the parent", id, pos)
+		}
+		elements = append(elements, element{id: uint32(id), pos: pos, data: b[start:end], unknownSize: unknown})
+		pos = end
+	}
+	return elements
+}
+
+func findElements(t *testing.T, b []byte, id uint32) []element {
+	t.Helper()
+	var found []element
+	for _, e := range readElements(t, b) {
+		if e.id == id {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func findElement(t *testing.T, b []byte, id uint32) element {
+	t.Helper()
+	found := findElements(t, b, id)
+	if len(found) != 1 {
+		t.Fatalf("Expected an element %x, got %d", id, len(found))
+	}
+	return found[0]
+}
+
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+type block struct {
+	track    int
+	timecode int64
+	keyFrame bool
+}
+
+func TestWebMWriter(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	ms := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Millisecond) }
+	audioFrame := func(n int) codec.EncodedFrame {
+		return codec.EncodedFrame{Data: []byte{0xfc, byte(n)}, KeyFrame: true, Timestamp: ms(n), Duration: 20 * time.Millisecond}
+	}
+
+	for name, seekable := range map[string]bool{"Seekable": true, "Stream": false} {
+		seekable := seekable
+		t.Run(name, func(t *testing.T) {
+			var out io.Writer
+			buf := &seekBuffer{}
+			stream := &bytes.Buffer{}
+			if seekable {
+				out = buf
+			} else {
+				out = stream
+			}
+
+			w := NewWebMWriter(out)
+			video, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video", "stream", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000))
+			if err != nil {
+				t.Fatal(err)
+			}
+			audio, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Start(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video2", "stream", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)); err != errStarted {
+				t.Errorf("Expected %v, got %v", errStarted, err)
+			}
+
+			writes := []struct {
+				track mediadevices.LocalTrack
+				frame codec.EncodedFrame
+			}{
+				{audio, audioFrame(0)},
+				// The video frames before the key frame are dropped.
+				{video, codec.EncodedFrame{Data: newVP8InterFrame(), Timestamp: ms(10)}},
+				{video, codec.EncodedFrame{Data: newVP8KeyFrame(640, 480), KeyFrame: true, Timestamp: ms(33)}},
+				{audio, audioFrame(20)},
+				{audio, audioFrame(40)},
+				{video, codec.EncodedFrame{Data: newVP8InterFrame(), Timestamp: ms(66)}},
+				{video, codec.EncodedFrame{Data: newVP8KeyFrame(640, 480), KeyFrame: true, Timestamp: ms(100)}},
+				{audio, audioFrame(60)},
+			}
+			for _, write := range writes {
+				if err := write.track.(mediadevices.FrameWriter).WriteFrame(write.frame); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data := buf.data
+			if !seekable {
+				data = stream.Bytes()
+			}
+			top := readElements(t, data)
+			if len(top) != 2 || top[0].id != idEBML || top[1].id != idSegment {
+				t.Fatalf("Expected EBML and Segment, got %v", top)
+			}
+			docType := findElement(t, top[0].data, idDocType)
+			if string(docType.data) != "webm" {
+				t.Errorf("Expected DocType webm, got %s", docType.data)
+			}
+			segment := top[1]
+			if segment.unknownSize == seekable {
+				t.Errorf("Expected the Segment size to be known only if seekable, got unknown size %v", segment.unknownSize)
+			}
+			body := segment.data
+
+			info := findElement(t, body, idInfo)
+			if scale := readUint(findElement(t, info.data, idTimecodeScale).data); scale != 1000000 {
+				t.Errorf("Expected TimecodeScale 1000000, got %d", scale)
+			}
+			durations := findElements(t, info.data, idDuration)
+			if seekable {
+				if len(durations) != 1 {
+					t.Fatalf("Expected Duration, got %d", len(durations))
+				}
+				if d := math.Float64frombits(binary.BigEndian.Uint64(durations[0].data)); d != 100 {
+					t.Errorf("Expected Duration 100, got %f", d)
+				}
+			} else if len(durations) != 0 {
+				t.Errorf("Expected no Duration, got %d", len(durations))
+			}
+
+			tracks := findElements(t, findElement(t, body, idTracks).data, idTrackEntry)
+			if len(tracks) != 2 {
+				t.Fatalf("Expected 2 tracks, got %d", len(tracks))
+			}
+			if id := string(findElement(t, tracks[0].data, idCodecID).data); id != "V_VP8" {
+				t.Errorf("Expected V_VP8, got %s", id)
+			}
+			v := findElement(t, tracks[0].data, idVideo).data
+			width, height := readUint(findElement(t, v, idPixelWidth).data), readUint(findElement(t, v, idPixelHeight).data)
+			if width != 640 || height != 480 {
+				t.Errorf("Expected 640x480, got %dx%d", width, height)
+			}
+			if id := string(findElement(t, tracks[1].data, idCodecID).data); id != "A_OPUS" {
+				t.Errorf("Expected A_OPUS, got %s", id)
+			}
+			if head := findElement(t, tracks[1].data, idCodecPrivate).data; !bytes.HasPrefix(head, []byte("OpusHead")) || head[9] != 2 {
+				t.Errorf("Expected OpusHead of 2 channels, got %v", head)
+			}
+
+			clusters := findElements(t, body, idCluster)
+			var timecodes []int64
+			var blocks [][]block
+			for _, c := range clusters {
+				timecodes = append(timecodes, int64(readUint(findElement(t, c.data, idTimecode).data)))
+				var bs []block
+				for _, e := range findElements(t, c.data, idSimpleBlock) {
+					bs = append(bs, block{
+						track:    int(e.data[0] &^ 0x80),
+						timecode: int64(int16(binary.BigEndian.Uint16(e.data[1:]))),
+						keyFrame: e.data[3]&0x80 != 0,
+					})
+				}
+				blocks = append(blocks, bs)
+			}
+			if expected := []int64{0, 33, 100}; !reflect.DeepEqual(expected, timecodes) {
+				t.Errorf("Expected clusters at %v, got %v", expected, timecodes)
+			}
+			expectedBlocks := [][]block{
+				{{2, 0, true}},
+				{{1, 0, true}, {2, -13, true}, {2, 7, true}, {1, 33, false}},
+				{{1, 0, true}, {2, -40, true}},
+			}
+			if !reflect.DeepEqual(expectedBlocks, blocks) {
+				t.Errorf("Expected blocks %v, got %v", expectedBlocks, blocks)
+			}
+
+			// The cues point to the clusters started by the video key frames.
+			cues := findElement(t, body, idCues)
+			points := findElements(t, cues.data, idCuePoint)
+			if len(points) != 2 {
+				t.Fatalf("Expected 2 cue points, got %d", len(points))
+			}
+			for i, p := range points {
+				cueTime := int64(readUint(findElement(t, p.data, idCueTime).data))
+				positions := findElement(t, p.data, idCueTrackPositions).data
+				if track := readUint(findElement(t, positions, idCueTrack).data); track != 1 {
+					t.Errorf("Expected the cue of track 1, got %d", track)
+				}
+				pos := int64(readUint(findElement(t, positions, idCueClusterPosition).data))
+				c := clusters[i+1]
+				if pos != c.pos {
+					t.Errorf("Expected the cue at %d, got %d", c.pos, pos)
+				}
+				if cueTime != timecodes[i+1] {
+					t.Errorf("Expected the cue time %d, got %d", timecodes[i+1], cueTime)
+				}
+			}
+
+			seeks := findElements(t, findElement(t, body, idSeekHead).data, idSeek)
+			expectedSeeks := map[uint32]int64{
+				idInfo:   info.pos,
+				idTracks: findElement(t, body, idTracks).pos,
+			}
+			if seekable {
+				expectedSeeks[idCues] = cues.pos
+			}
+			seekPositions := make(map[uint32]int64)
+			for _, s := range seeks {
+				id := uint32(readUint(findElement(t, s.data, idSeekID).data))
+				seekPositions[id] = int64(readUint(findElement(t, s.data, idSeekPosition).data))
+			}
+			if !reflect.DeepEqual(expectedSeeks, seekPositions) {
+				t.Errorf("Expected seek positions %v, got %v", expectedSeeks, seekPositions)
+			}
+		})
+	}
+}
+
+func TestWebMWriterErrors(t *testing.T) {
+	w := NewWebMWriter(&bytes.Buffer{})
+	if err := w.Start(); err == nil {
+		t.Error("Expected an error without tracks")
+	}
+	if _, err := w.NewTrack(webrtc.DefaultPayloadTypeH264, 0, "video", "stream", webrtc.NewRTPH264Codec(webrtc.DefaultPayloadTypeH264, 90000)); err == nil {
+		t.Error("Expected an error for H.264")
+	}
+	video, err := w.NewTrack(webrtc.DefaultPayloadTypeVP9, 0, "video", "stream", webrtc.NewRTPVP9Codec(webrtc.DefaultPayloadTypeVP9, 90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	audio, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := video.(mediadevices.FrameWriter).WriteFrame(codec.EncodedFrame{Data: newVP9InterFrame(), Timestamp: time.Unix(1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := audio.(mediadevices.FrameWriter).WriteFrame(codec.EncodedFrame{Data: []byte{0xfc}, KeyFrame: true, Timestamp: time.Unix(1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != errNoKeyFrame {
+		t.Errorf("Expected %v, got %v", errNoKeyFrame, err)
+	}
+	if err := audio.(mediadevices.FrameWriter).WriteFrame(codec.EncodedFrame{Data: []byte{0xfc}, KeyFrame: true, Timestamp: time.Unix(2, 0)}); err != errClosed {
+		t.Errorf("Expected %v, got %v", errClosed, err)
+	}
+}
+
+func TestWebMWriterPending(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	ms := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Millisecond) }
+	audioFrame := func(n int) codec.EncodedFrame {
+		return codec.EncodedFrame{Data: []byte{0xfc, byte(n)}, KeyFrame: true, Timestamp: ms(n), Duration: 20 * time.Millisecond}
+	}
+
+	t.Run("BeforeStart", func(t *testing.T) {
+		w := NewWebMWriter(&bytes.Buffer{})
+		video, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video", "stream", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		audio, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for n := 0; n <= 12000; n += 20 {
+			if err := audio.(mediadevices.FrameWriter).WriteFrame(audioFrame(n)); err != nil {
+				t.Fatal(err)
+			}
+			var frame codec.EncodedFrame
+			switch n {
+			case 0, 11000:
+				frame = codec.EncodedFrame{Data: newVP8KeyFrame(640, 480), KeyFrame: true, Timestamp: ms(n)}
+			case 1000, 11500:
+				frame = codec.EncodedFrame{Data: newVP8InterFrame(), Timestamp: ms(n)}
+			default:
+				continue
+			}
+			if err := video.(mediadevices.FrameWriter).WriteFrame(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The audio of the last maxPendingDuration and the video from the last key frame are buffered.
+		var videoTimes []time.Time
+		for _, f := range w.pending {
+			if f.track.video {
+				videoTimes = append(videoTimes, f.frame.Timestamp)
+			} else if f.frame.Timestamp.Before(ms(2000)) {
+				t.Errorf("Expected the audio before %v to be dropped, got %v", ms(2000), f.frame.Timestamp)
+			}
+		}
+		if expected := []time.Time{ms(11000), ms(11500)}; !reflect.DeepEqual(expected, videoTimes) {
+			t.Errorf("Expected the video frames at %v, got %v", expected, videoTimes)
+		}
+
+		if err := w.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if expected := ms(2000); !w.origin.Equal(expected) {
+			t.Errorf("Expected the origin to be %v, got %v", expected, w.origin)
+		}
+	})
+
+	t.Run("NoKeyFrame", func(t *testing.T) {
+		w := NewWebMWriter(&bytes.Buffer{})
+		video, err := w.NewTrack(webrtc.DefaultPayloadTypeVP8, 0, "video", "stream", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		audio, err := w.NewTrack(webrtc.DefaultPayloadTypeOpus, 0, "audio", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		n := int(maxPendingDuration / time.Millisecond)
+		if err := video.(mediadevices.FrameWriter).WriteFrame(codec.EncodedFrame{Data: newVP8InterFrame(), Timestamp: ms(0)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := audio.(mediadevices.FrameWriter).WriteFrame(audioFrame(n)); err != nil {
+			t.Fatal(err)
+		}
+		if err := audio.(mediadevices.FrameWriter).WriteFrame(audioFrame(n + 20)); err != errNoKeyFrame {
+			t.Errorf("Expected %v, got %v", errNoKeyFrame, err)
+		}
+		if err := video.(mediadevices.FrameWriter).WriteFrame(codec.EncodedFrame{Data: newVP8InterFrame(), Timestamp: ms(n + 33)}); err != errNoKeyFrame {
+			t.Errorf("Expected %v, got %v", errNoKeyFrame, err)
+		}
+	})
+}
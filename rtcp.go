@@ -0,0 +1,257 @@
+package mediadevices
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// RTCPReader reads the RTCP packets sent from the receivers of a track. *webrtc.RTPSender implements it.
+type RTCPReader interface {
+	ReadRTCP() ([]rtcp.Packet, error)
+}
+
+// RTCPHandlerOptions stores parameters used by Tracker.HandleRTCP.
+type RTCPHandlerOptions struct {
+	// MinKeyFrameInterval is the minimum interval of the key frames forced by PLI and FIR. The requests
+	// received within the interval after a key frame are merged into a single key frame at the end of it.
+	MinKeyFrameInterval time.Duration
+	// BandwidthEstimator receives the bitrate estimated from REMB and the loss in receiver reports.
+	// If it's nil, the bitrate is set to the encoder of the track directly. It can be used to split the
+	// bandwidth among the tracks by BitRateController.
+	BandwidthEstimator *ReportedBandwidthEstimator
+}
+
+// RTCPHandlerOption is a type of Tracker.HandleRTCP functional option.
+type RTCPHandlerOption func(*RTCPHandlerOptions)
+
+// WithMinKeyFrameInterval specifies the minimum interval of the key frames forced by PLI and FIR.
+func WithMinKeyFrameInterval(interval time.Duration) RTCPHandlerOption {
+	return func(o *RTCPHandlerOptions) {
+		o.MinKeyFrameInterval = interval
+	}
+}
+
+// WithRTCPBandwidthEstimator reports the bitrate estimated from RTCP to e instead of setting it to the encoder.
+func WithRTCPBandwidthEstimator(e *ReportedBandwidthEstimator) RTCPHandlerOption {
+	return func(o *RTCPHandlerOptions) {
+		o.BandwidthEstimator = e
+	}
+}
+
+const (
+	defaultMinKeyFrameInterval = 300 * time.Millisecond
+
+	// Thresholds of the loss-based bitrate control.
+	// Reference: https://tools.ietf.org/html/draft-ietf-rmcat-gcc-02#section-6
+	lossIncreaseThreshold = 0.02
+	lossDecreaseThreshold = 0.1
+	lossIncreaseRatio     = 1.05
+)
+
+// HandleRTCP reads the RTCP packets from r, and forces key frames on PLI and FIR, and controls the
+// bitrate of the encoder by REMB and receiver reports. Packets for the other SSRCs are ignored.
+// It blocks until r is closed or the track is stopped.
+func (t *track) HandleRTCP(r RTCPReader, opts ...RTCPHandlerOption) error {
+	o := RTCPHandlerOptions{
+		MinKeyFrameInterval: defaultMinKeyFrameInterval,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t.mu.Lock()
+	initialBitRate := t.bitRate
+	t.mu.Unlock()
+
+	keyFrames := &keyFrameLimiter{interval: o.MinKeyFrameInterval, force: t.ForceKeyFrame}
+	defer keyFrames.stop()
+	estimator := &rtcpBitRateEstimator{max: initialBitRate, bitRate: initialBitRate}
+	setBitRate := t.SetBitRate
+	if o.BandwidthEstimator != nil {
+		setBitRate = func(bitRate int) error {
+			o.BandwidthEstimator.Report(bitRate)
+			return nil
+		}
+	}
+
+	ssrc, hasSSRC := t.ssrc()
+	forTrack := func(ssrcs []uint32) bool {
+		if !hasSSRC {
+			return true
+		}
+		for _, s := range ssrcs {
+			if s == ssrc {
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		packets, err := r.ReadRTCP()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		// The errors of the encoder other than io.EOF are ignored, so that the rest of the feedback is
+		// still handled, e.g. if the encoder doesn't support forcing key frames.
+		bitRate := 0
+		for _, packet := range packets {
+			switch p := packet.(type) {
+			case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+				if !forTrack(p.DestinationSSRC()) {
+					continue
+				}
+				if err := keyFrames.request(); err == io.EOF {
+					// The track has been stopped.
+					return nil
+				}
+			case *rtcp.ReceiverEstimatedMaximumBitrate:
+				if !forTrack(p.SSRCs) {
+					continue
+				}
+				bitRate = estimator.handleREMB(int(p.Bitrate))
+			case *rtcp.ReceiverReport:
+				// A receiver report is handled once by the worst loss of its blocks, since the blocks are
+				// all taken as the track if the SSRC is not known.
+				var fractionLost uint8
+				found := false
+				for _, report := range p.Reports {
+					if hasSSRC && report.SSRC != ssrc {
+						continue
+					}
+					if !found || report.FractionLost > fractionLost {
+						fractionLost = report.FractionLost
+					}
+					found = true
+				}
+				if found {
+					bitRate = estimator.handleLoss(fractionLost)
+				}
+			}
+		}
+		if bitRate > 0 {
+			if err := setBitRate(bitRate); err == io.EOF {
+				// The track has been stopped.
+				return nil
+			}
+		}
+	}
+}
+
+// ssrc returns the SSRC of the localTrack. ok is false if the localTrack doesn't tell it.
+func (t *track) ssrc() (ssrc uint32, ok bool) {
+	s, ok := t.localTrack.(interface{ SSRC() uint32 })
+	if !ok {
+		return 0, false
+	}
+	return s.SSRC(), true
+}
+
+// keyFrameLimiter limits the rate of the forced key frames.
+type keyFrameLimiter struct {
+	interval time.Duration
+	force    func() error
+
+	mu    sync.Mutex
+	last  time.Time
+	timer *time.Timer
+}
+
+// request forces a key frame if the interval has passed since the last one. Otherwise, a key frame is
+// scheduled at the end of the interval.
+func (l *keyFrameLimiter) request() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.timer != nil {
+		// A key frame has already been scheduled.
+		return nil
+	}
+	if wait := l.interval - time.Since(l.last); !l.last.IsZero() && wait > 0 {
+		l.timer = time.AfterFunc(wait, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.timer == nil {
+				// Stopped.
+				return
+			}
+			l.timer = nil
+			l.last = time.Now()
+			// The error is returned by the next request if the track has been stopped.
+			_ = l.force()
+		})
+		return nil
+	}
+
+	l.last = time.Now()
+	return l.force()
+}
+
+func (l *keyFrameLimiter) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// rtcpBitRateEstimator estimates the bitrate from the maximum bitrate of REMB and the loss-based
+// estimate of the receiver reports.
+type rtcpBitRateEstimator struct {
+	// max is the cap of the loss-based estimate without REMB. 0 if it's not known.
+	max int
+	// remb is the bitrate of the last REMB. 0 if no REMB has been received.
+	remb int
+	// bitRate is the loss-based estimate. 0 if it's not known.
+	bitRate int
+}
+
+// handleREMB returns the estimate updated by the bitrate of a REMB.
+func (e *rtcpBitRateEstimator) handleREMB(bitRate int) int {
+	e.remb = bitRate
+	if e.bitRate == 0 {
+		e.bitRate = bitRate
+	}
+	return e.estimate()
+}
+
+// handleLoss returns the estimate updated by the fraction lost of a receiver report. The loss-based
+// estimate is not known until either REMB is received or the track has an initial bitrate.
+func (e *rtcpBitRateEstimator) handleLoss(fractionLost uint8) int {
+	if e.bitRate == 0 {
+		return 0
+	}
+
+	loss := float64(fractionLost) / 256
+	switch {
+	case loss > lossDecreaseThreshold:
+		e.bitRate = int(float64(e.bitRate) * (1 - 0.5*loss))
+	case loss < lossIncreaseThreshold:
+		e.bitRate = int(float64(e.bitRate) * lossIncreaseRatio)
+	}
+
+	// The loss-based estimate doesn't go beyond the bitrate that the receiver or the application wants.
+	limit := e.remb
+	if limit == 0 {
+		limit = e.max
+	}
+	if limit > 0 && e.bitRate > limit {
+		e.bitRate = limit
+	}
+	return e.estimate()
+}
+
+func (e *rtcpBitRateEstimator) estimate() int {
+	if e.remb > 0 && e.remb < e.bitRate {
+		return e.remb
+	}
+	return e.bitRate
+}
@@ -0,0 +1,315 @@
+package mediadevices
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v2"
+)
+
+// fakeRTCPReader is an RTCPReader which returns the packets sent to it.
+type fakeRTCPReader struct {
+	packets chan []rtcp.Packet
+	err     error
+}
+
+func newFakeRTCPReader() *fakeRTCPReader {
+	return &fakeRTCPReader{packets: make(chan []rtcp.Packet)}
+}
+
+func (r *fakeRTCPReader) ReadRTCP() ([]rtcp.Packet, error) {
+	packets, ok := <-r.packets
+	if !ok {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, io.EOF
+	}
+	return packets, nil
+}
+
+// send blocks until the packets are handled. The empty packets sent after them are read after the
+// handler has finished the packets.
+func (r *fakeRTCPReader) send(packets ...rtcp.Packet) {
+	r.packets <- packets
+	r.packets <- nil
+}
+
+// closeWithError makes the reader return err, or io.EOF if err is nil.
+func (r *fakeRTCPReader) closeWithError(err error) {
+	r.err = err
+	close(r.packets)
+}
+
+// ssrcTrack is a LocalTrack with an SSRC like *webrtc.Track.
+type ssrcTrack struct {
+	*mockTrack
+	ssrc uint32
+}
+
+func (t *ssrcTrack) SSRC() uint32 {
+	return t.ssrc
+}
+
+// unsupportedCodec is an encoder which doesn't support changing the bitrate nor forcing key frames.
+type unsupportedCodec struct {
+	closedCodec
+}
+
+func (unsupportedCodec) SetBitRate(int) error { return errors.New("SetBitRate is not supported") }
+func (unsupportedCodec) ForceKeyFrame() error { return errors.New("ForceKeyFrame is not supported") }
+
+func TestRTCPBitRateEstimator(t *testing.T) {
+	const lost25 = 64 // 25% in the fixed point of FractionLost
+
+	testCases := map[string]struct {
+		initial  int
+		handle   func(e *rtcpBitRateEstimator) int
+		expected int
+	}{
+		"REMB": {
+			handle: func(e *rtcpBitRateEstimator) int {
+				return e.handleREMB(500000)
+			},
+			expected: 500000,
+		},
+		"LossWithoutBitRate": {
+			handle: func(e *rtcpBitRateEstimator) int {
+				return e.handleLoss(lost25)
+			},
+			expected: 0,
+		},
+		"HighLoss": {
+			handle: func(e *rtcpBitRateEstimator) int {
+				e.handleREMB(400000)
+				return e.handleLoss(lost25)
+			},
+			expected: 350000,
+		},
+		"ModerateLoss": {
+			initial: 400000,
+			handle: func(e *rtcpBitRateEstimator) int {
+				return e.handleLoss(13) // 5%
+			},
+			expected: 400000,
+		},
+		"NoLoss": {
+			initial: 400000,
+			handle: func(e *rtcpBitRateEstimator) int {
+				e.handleLoss(lost25)
+				return e.handleLoss(0)
+			},
+			expected: 367500,
+		},
+		"NoLossCappedByInitial": {
+			initial: 400000,
+			handle: func(e *rtcpBitRateEstimator) int {
+				return e.handleLoss(0)
+			},
+			expected: 400000,
+		},
+		"NoLossCappedByREMB": {
+			initial: 400000,
+			handle: func(e *rtcpBitRateEstimator) int {
+				e.handleREMB(1000000)
+				e.handleLoss(0)
+				return e.handleLoss(0)
+			},
+			expected: 441000,
+		},
+		"LowerREMB": {
+			initial: 400000,
+			handle: func(e *rtcpBitRateEstimator) int {
+				return e.handleREMB(100000)
+			},
+			expected: 100000,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			e := &rtcpBitRateEstimator{max: testCase.initial, bitRate: testCase.initial}
+			if bitRate := testCase.handle(e); bitRate != testCase.expected {
+				t.Errorf("Expected %d, got %d", testCase.expected, bitRate)
+			}
+		})
+	}
+}
+
+func TestHandleRTCP(t *testing.T) {
+	const ssrc = 1234
+	newSSRCTrack := func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return &ssrcTrack{mockTrack: newMockTrack(codec, id), ssrc: ssrc}
+	}
+
+	run := func(tr *track, r RTCPReader, opts ...RTCPHandlerOption) chan error {
+		done := make(chan error, 1)
+		go func() {
+			done <- tr.HandleRTCP(r, opts...)
+		}()
+		return done
+	}
+	wait := func(t *testing.T, done chan error) error {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(time.Second):
+			t.Fatal("Expected HandleRTCP to return")
+		}
+		return nil
+	}
+
+	t.Run("KeyFrame", func(t *testing.T) {
+		tr, params := newMockRateTrack(t, "mockVideoKeyFrame", newSSRCTrack)
+		defer tr.Stop()
+
+		r := newFakeRTCPReader()
+		done := run(tr, r, WithMinKeyFrameInterval(200*time.Millisecond))
+
+		r.send(&rtcp.PictureLossIndication{MediaSSRC: ssrc})
+		if n := params.last().numKeyFrames(); n != 1 {
+			t.Fatalf("Expected a key frame to be forced, got %d", n)
+		}
+
+		// The requests within the interval are merged into a key frame at the end of the interval.
+		r.send(&rtcp.PictureLossIndication{MediaSSRC: ssrc}, &rtcp.PictureLossIndication{MediaSSRC: ssrc})
+		r.send(&rtcp.FullIntraRequest{MediaSSRC: ssrc, FIR: []rtcp.FIREntry{{SSRC: ssrc}}})
+		if n := params.last().numKeyFrames(); n != 1 {
+			t.Errorf("Expected the key frames to be limited, got %d", n)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if n := params.last().numKeyFrames(); n != 2 {
+			t.Errorf("Expected a key frame at the end of the interval, got %d", n)
+		}
+
+		// Requests for the other SSRCs are ignored.
+		r.send(&rtcp.PictureLossIndication{MediaSSRC: ssrc + 1})
+		r.send(&rtcp.FullIntraRequest{FIR: []rtcp.FIREntry{{SSRC: ssrc + 1}}})
+		if n := params.last().numKeyFrames(); n != 2 {
+			t.Errorf("Expected the requests for the other SSRCs to be ignored, got %d key frames", n)
+		}
+
+		r.closeWithError(nil)
+		if err := wait(t, done); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("BitRate", func(t *testing.T) {
+		tr, params := newMockRateTrack(t, "mockVideoBitRate", newSSRCTrack)
+		defer tr.Stop()
+
+		r := newFakeRTCPReader()
+		done := run(tr, r)
+
+		checkBitRate := func(expected int) {
+			t.Helper()
+			if bitRate := params.last().getBitRate(); bitRate != expected {
+				t.Errorf("Expected the bitrate to be %d, got %d", expected, bitRate)
+			}
+		}
+
+		r.send(&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 400000, SSRCs: []uint32{ssrc}})
+		r.send(&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 100000, SSRCs: []uint32{ssrc + 1}})
+		checkBitRate(400000)
+
+		r.send(&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{
+			{SSRC: ssrc, FractionLost: 64},
+			{SSRC: ssrc + 1, FractionLost: 255},
+		}})
+		checkBitRate(350000)
+
+		r.send(&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{{SSRC: ssrc}}})
+		checkBitRate(367500)
+
+		errRead := errors.New("read error")
+		r.closeWithError(errRead)
+		if err := wait(t, done); err != errRead {
+			t.Errorf("Expected %v, got %v", errRead, err)
+		}
+	})
+
+	t.Run("UnknownSSRC", func(t *testing.T) {
+		tr, params := newMockRateTrack(t, "mockVideoUnknownSSRC", func(codec *webrtc.RTPCodec, id string) LocalTrack {
+			return newMockTrack(codec, id)
+		})
+		defer tr.Stop()
+
+		r := newFakeRTCPReader()
+		done := run(tr, r)
+
+		r.send(&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 400000})
+		// The blocks are taken as the track, and the worst loss of them lowers the bitrate once.
+		r.send(&rtcp.ReceiverReport{Reports: []rtcp.ReceptionReport{
+			{SSRC: ssrc, FractionLost: 64},
+			{SSRC: ssrc + 1, FractionLost: 32},
+			{SSRC: ssrc + 2, FractionLost: 64},
+		}})
+		if bitRate := params.last().getBitRate(); bitRate != 350000 {
+			t.Errorf("Expected the bitrate to be 350000, got %d", bitRate)
+		}
+
+		r.closeWithError(nil)
+		if err := wait(t, done); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("BandwidthEstimator", func(t *testing.T) {
+		tr, params := newMockRateTrack(t, "mockVideoEstimator", newSSRCTrack)
+		defer tr.Stop()
+
+		r := newFakeRTCPReader()
+		estimator := &ReportedBandwidthEstimator{}
+		done := run(tr, r, WithRTCPBandwidthEstimator(estimator))
+
+		r.send(&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 400000, SSRCs: []uint32{ssrc}})
+		if bitRate := estimator.Estimate(); bitRate != 400000 {
+			t.Errorf("Expected the estimate to be 400000, got %d", bitRate)
+		}
+		if bitRate := params.last().getBitRate(); bitRate != 2000000 {
+			t.Errorf("Expected the bitrate of the encoder to be kept, got %d", bitRate)
+		}
+
+		r.closeWithError(nil)
+		if err := wait(t, done); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("EncoderError", func(t *testing.T) {
+		tr := &track{
+			localTrack: newSSRCTrack(webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000), "video"),
+			encoder:    unsupportedCodec{},
+		}
+
+		r := newFakeRTCPReader()
+		done := run(tr, r)
+
+		// The handler keeps reading the packets after the encoder fails to handle them.
+		r.send(&rtcp.PictureLossIndication{MediaSSRC: ssrc})
+		r.send(&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 400000, SSRCs: []uint32{ssrc}})
+
+		r.closeWithError(nil)
+		if err := wait(t, done); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Stopped", func(t *testing.T) {
+		tr, _ := newMockRateTrack(t, "mockVideoStopped", newSSRCTrack)
+
+		r := newFakeRTCPReader()
+		done := run(tr, r)
+
+		tr.Stop()
+		r.packets <- []rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: ssrc}}
+		if err := wait(t, done); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
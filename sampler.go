@@ -4,32 +4,72 @@ import (
 	"math"
 	"time"
 
-	"github.com/pion/webrtc/v2/pkg/media"
+	"github.com/pion/mediadevices/pkg/codec"
 )
 
-type samplerFunc func(b []byte) error
+// samplerFunc returns the number of samples in the codec's clock rate by which the RTP timestamp
+// is advanced after the frame is written.
+type samplerFunc func(frame codec.EncodedFrame) uint32
 
-// newVideoSampler creates a video sampler that uses the actual video frame rate and
+// newVideoSampler creates a video sampler that uses the capture timestamps of the frames and
 // the codec's clock rate to come up with a duration for each sample.
-func newVideoSampler(t LocalTrack) samplerFunc {
-	clockRate := float64(t.Codec().ClockRate)
-	lastTimestamp := time.Now()
+//
+// The RTP timestamp is advanced after each sample is written, so the duration of a frame is only
+// known when the next frame comes. The sampler assumes that the next frame comes after the nominal
+// frame interval, and corrects the error when the next frame actually comes.
+func newVideoSampler(clockRate uint32, frameRate float32) samplerFunc {
+	if frameRate <= 0 {
+		frameRate = 30
+	}
+	interval := 1 / float64(frameRate)
+	var firstTimestamp time.Time
+	var written uint64
 
-	return samplerFunc(func(b []byte) error {
-		now := time.Now()
-		duration := now.Sub(lastTimestamp).Seconds()
-		samples := uint32(math.Round(clockRate * duration))
-		lastTimestamp = now
+	return samplerFunc(func(frame codec.EncodedFrame) uint32 {
+		// Empty frames don't advance the RTP timestamp. Their duration will be added to the next frame.
+		if len(frame.Data) == 0 {
+			return 0
+		}
 
-		return t.WriteSample(media.Sample{Data: b, Samples: samples})
+		if firstTimestamp.IsZero() {
+			firstTimestamp = frame.Timestamp
+		}
+
+		// Samples are calculated from the first timestamp to avoid accumulating rounding errors.
+		next := frame.Timestamp.Sub(firstTimestamp).Seconds() + interval
+		total := uint64(math.Round(float64(clockRate) * next))
+		var samples uint32
+		if total > written {
+			samples = uint32(total - written)
+			written = total
+		}
+		return samples
 	})
 }
 
-// newAudioSampler creates a audio sampler that uses a fixed latency and
-// the codec's clock rate to come up with a duration for each sample.
-func newAudioSampler(t LocalTrack, latency time.Duration) samplerFunc {
-	samples := uint32(math.Round(float64(t.Codec().ClockRate) * latency.Seconds()))
-	return samplerFunc(func(b []byte) error {
-		return t.WriteSample(media.Sample{Data: b, Samples: samples})
+// newAudioSampler creates a audio sampler that uses the duration of the encoded samples and
+// the codec's clock rate to come up with a duration for each sample. If the encoder doesn't
+// report the duration, the given latency is used instead.
+func newAudioSampler(clockRate uint32, latency time.Duration) samplerFunc {
+	var elapsed time.Duration
+	var written uint64
+
+	return samplerFunc(func(frame codec.EncodedFrame) uint32 {
+		duration := frame.Duration
+		if duration == 0 {
+			duration = latency
+		}
+		elapsed += duration
+
+		// Empty frames don't advance the RTP timestamp. Their duration will be added to the next frame.
+		if len(frame.Data) == 0 {
+			return 0
+		}
+
+		// Samples are calculated from the total duration to avoid accumulating rounding errors.
+		total := uint64(math.Round(float64(clockRate) * elapsed.Seconds()))
+		samples := uint32(total - written)
+		written = total
+		return samples
 	})
 }
@@ -0,0 +1,76 @@
+package mediadevices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+)
+
+func TestVideoSampler(t *testing.T) {
+	sample := newVideoSampler(90000, 30)
+
+	t0 := time.Unix(100, 0)
+	var samples []uint32
+	// Capture timestamps are steady at 30 fps regardless of when the frames come out of the encoder.
+	for i := 0; i < 10; i++ {
+		samples = append(samples, sample(codec.EncodedFrame{
+			Data:      []byte{1},
+			Timestamp: t0.Add(time.Duration(i) * time.Second / 30),
+		}))
+		// Encoder jitter must not affect the samples.
+		time.Sleep(time.Duration(i%3) * time.Millisecond)
+	}
+	// A skipped frame doesn't advance the RTP timestamp.
+	samples = append(samples, sample(codec.EncodedFrame{Timestamp: t0.Add(10 * time.Second / 30)}))
+	samples = append(samples, sample(codec.EncodedFrame{Data: []byte{1}, Timestamp: t0.Add(11 * time.Second / 30)}))
+
+	expected := []uint32{3000, 3000, 3000, 3000, 3000, 3000, 3000, 3000, 3000, 3000, 0, 6000}
+	if len(samples) != len(expected) {
+		t.Fatalf("Expected %d samples, got %d", len(expected), len(samples))
+	}
+	for i, s := range samples {
+		if s != expected[i] {
+			t.Errorf("Expected frame %d to have %d samples, got %d", i, expected[i], s)
+		}
+	}
+}
+
+func TestAudioSampler(t *testing.T) {
+	testCases := map[string]struct {
+		clockRate uint32
+		durations []time.Duration
+		expected  []uint32
+	}{
+		"Duration": {
+			clockRate: 48000,
+			durations: []time.Duration{20 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond},
+			expected:  []uint32{960, 480, 960},
+		},
+		"Rounding": {
+			clockRate: 8000,
+			// 1/3 ms is 2.666... samples
+			durations: []time.Duration{time.Millisecond / 3, time.Millisecond / 3, time.Millisecond / 3},
+			expected:  []uint32{3, 2, 3},
+		},
+		"Latency": {
+			clockRate: 48000,
+			durations: []time.Duration{0, 0},
+			expected:  []uint32{960, 960},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			sample := newAudioSampler(testCase.clockRate, 20*time.Millisecond)
+
+			for i, d := range testCase.durations {
+				s := sample(codec.EncodedFrame{Data: []byte{1}, Duration: d})
+				if s != testCase.expected[i] {
+					t.Errorf("Expected frame %d to have %d samples, got %d", i, testCase.expected[i], s)
+				}
+			}
+		})
+	}
+}
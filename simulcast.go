@@ -0,0 +1,63 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+var errInvalidSimulcastLayers = errors.New("invalid simulcast layers")
+
+// validateSimulcastLayers checks that every layer has a unique RID.
+func validateSimulcastLayers(layers []SimulcastLayer) error {
+	rids := make(map[string]bool)
+	for _, layer := range layers {
+		if layer.RID == "" {
+			return fmt.Errorf("%w: empty RID", errInvalidSimulcastLayers)
+		}
+		if rids[layer.RID] {
+			return fmt.Errorf("%w: duplicated RID %s", errInvalidSimulcastLayers, layer.RID)
+		}
+		rids[layer.RID] = true
+	}
+	return nil
+}
+
+// media returns the settings which the encoder of the layer is built with from the settings of the capture.
+func (l SimulcastLayer) media(p prop.Media) prop.Media {
+	if l.ScaleResolutionDownBy > 1 {
+		p.Width, p.Height = l.scale(p.Width), l.scale(p.Height)
+	}
+	if l.MaxFrameRate > 0 && (p.FrameRate == 0 || l.MaxFrameRate < p.FrameRate) {
+		p.FrameRate = l.MaxFrameRate
+	}
+	return p
+}
+
+// scale divides the length by ScaleResolutionDownBy. The result is rounded down to an even number,
+// which can be subsampled by the encoders.
+func (l SimulcastLayer) scale(length int) int {
+	scaled := int(float64(length)/l.ScaleResolutionDownBy) &^ 1
+	if scaled < 2 {
+		return 2
+	}
+	return scaled
+}
+
+// transform returns the transform which scales and throttles the video read from the capture recording
+// with p. It returns nil if the layer doesn't change the video.
+func (l SimulcastLayer) transform(p prop.Media) video.TransformFunc {
+	var transforms []video.TransformFunc
+	if l.ScaleResolutionDownBy > 1 {
+		transforms = append(transforms, video.Scale(l.scale(p.Width), l.scale(p.Height), nil))
+	}
+	if l.MaxFrameRate > 0 && (p.FrameRate == 0 || l.MaxFrameRate < p.FrameRate) {
+		transforms = append(transforms, video.Throttle(l.MaxFrameRate))
+	}
+	if len(transforms) == 0 {
+		return nil
+	}
+	return video.Merge(transforms...)
+}
@@ -0,0 +1,278 @@
+package mediadevices
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+)
+
+func TestValidateSimulcastLayers(t *testing.T) {
+	testCases := map[string]struct {
+		layers []SimulcastLayer
+		valid  bool
+	}{
+		"Valid": {
+			layers: []SimulcastLayer{{RID: "f"}, {RID: "h"}, {RID: "q"}},
+			valid:  true,
+		},
+		"EmptyRID": {
+			layers: []SimulcastLayer{{RID: "f"}, {}},
+		},
+		"DuplicatedRID": {
+			layers: []SimulcastLayer{{RID: "f"}, {RID: "f"}},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			err := validateSimulcastLayers(testCase.layers)
+			if testCase.valid && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !testCase.valid && !errors.Is(err, errInvalidSimulcastLayers) {
+				t.Errorf("Expected %v, got %v", errInvalidSimulcastLayers, err)
+			}
+		})
+	}
+}
+
+func TestSimulcastLayerMedia(t *testing.T) {
+	settings := prop.Media{Video: prop.Video{Width: 640, Height: 360, FrameRate: 30}}
+
+	testCases := map[string]struct {
+		layer    SimulcastLayer
+		expected prop.Video
+	}{
+		"Full": {
+			layer:    SimulcastLayer{RID: "f"},
+			expected: prop.Video{Width: 640, Height: 360, FrameRate: 30},
+		},
+		"Half": {
+			layer:    SimulcastLayer{RID: "h", ScaleResolutionDownBy: 2},
+			expected: prop.Video{Width: 320, Height: 180, FrameRate: 30},
+		},
+		"Quarter": {
+			layer:    SimulcastLayer{RID: "q", ScaleResolutionDownBy: 4},
+			expected: prop.Video{Width: 160, Height: 90, FrameRate: 30},
+		},
+		"RoundedToEven": {
+			layer:    SimulcastLayer{RID: "t", ScaleResolutionDownBy: 3},
+			expected: prop.Video{Width: 212, Height: 120, FrameRate: 30},
+		},
+		"LowerFrameRate": {
+			layer:    SimulcastLayer{RID: "l", MaxFrameRate: 15},
+			expected: prop.Video{Width: 640, Height: 360, FrameRate: 15},
+		},
+		"HigherFrameRate": {
+			layer:    SimulcastLayer{RID: "l", MaxFrameRate: 60},
+			expected: prop.Video{Width: 640, Height: 360, FrameRate: 30},
+		},
+		"UpScale": {
+			layer:    SimulcastLayer{RID: "u", ScaleResolutionDownBy: 0.5},
+			expected: prop.Video{Width: 640, Height: 360, FrameRate: 30},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			if p := testCase.layer.media(settings); p.Video != testCase.expected {
+				t.Errorf("Expected %v, got %v", testCase.expected, p.Video)
+			}
+		})
+	}
+}
+
+// mockLayerParams builds encoders which record the settings they are built with.
+type mockLayerParams struct {
+	mockParams
+	mu       sync.Mutex
+	encoders []*mockLayerCodec
+}
+
+func (params *mockLayerParams) BuildVideoEncoder(r video.Reader, p prop.Media) (codec.ReadCloser, error) {
+	params.mu.Lock()
+	defer params.mu.Unlock()
+	e := &mockLayerCodec{r: r, p: p, frames: make(chan image.Image, 1)}
+	params.encoders = append(params.encoders, e)
+	return e, nil
+}
+
+func (params *mockLayerParams) encoder(i int) *mockLayerCodec {
+	params.mu.Lock()
+	defer params.mu.Unlock()
+	return params.encoders[i]
+}
+
+type mockLayerCodec struct {
+	r      video.Reader
+	p      prop.Media
+	frames chan image.Image
+
+	mu      sync.Mutex
+	bitRate int
+	reads   int
+}
+
+func (m *mockLayerCodec) Read(b []byte) (int, error) {
+	img, err := m.r.Read()
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.reads++
+	m.mu.Unlock()
+	select {
+	case m.frames <- img:
+	default:
+	}
+	return mio.Copy(b, []byte{0})
+}
+
+func (m *mockLayerCodec) SetBitRate(b int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bitRate = b
+	return nil
+}
+
+func (m *mockLayerCodec) numReads() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reads
+}
+
+func (m *mockLayerCodec) ForceKeyFrame() error { return nil }
+func (m *mockLayerCodec) Close() error         { return nil }
+
+func TestNewTracksSimulcast(t *testing.T) {
+	params := &mockLayerParams{mockParams: mockParams{name: "MockVideo"}}
+	opts := &MediaDevicesOptions{
+		codecs: map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Type: webrtc.RTPCodecTypeVideo, Name: "MockVideo", PayloadType: 1, RTPCodecCapability: webrtc.RTPCodecCapability{ClockRate: 90000}},
+			},
+		},
+		trackGenerator: func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			return newMockTrack(codec, id), nil
+		},
+	}
+
+	var constraints MediaTrackConstraints
+	constraints.VideoEncoderBuilders = []codec.VideoEncoderBuilder{params}
+	constraints.selectedMedia = prop.Media{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}}
+	constraints.SimulcastLayers = []SimulcastLayer{
+		{RID: "f"},
+		{RID: "h", ScaleResolutionDownBy: 2, MaxBitrate: 50000},
+		{RID: "q", ScaleResolutionDownBy: 4, MaxFrameRate: 10},
+	}
+
+	t.Run("InvalidLayers", func(t *testing.T) {
+		d := &mockVideoDriver{id: "mockVideo"}
+		invalid := constraints
+		invalid.SimulcastLayers = []SimulcastLayer{{RID: "f"}, {RID: "f"}}
+		if _, err := newTracks(opts, d, invalid, nil); !errors.Is(err, errInvalidSimulcastLayers) {
+			t.Fatalf("Expected %v, got %v", errInvalidSimulcastLayers, err)
+		}
+		if _, ok := runningSettings(d); ok {
+			t.Error("Expected the driver not to be running")
+		}
+	})
+
+	d := &mockVideoDriver{id: "mockVideo"}
+	trackers, err := newTracks(opts, d, constraints, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer func() {
+		for _, tr := range trackers {
+			tr.Stop()
+		}
+	}()
+
+	if len(trackers) != 3 {
+		t.Fatalf("Expected a track for each layer, got %d tracks", len(trackers))
+	}
+	for i, tr := range trackers {
+		layer := constraints.SimulcastLayers[i]
+		if rid := tr.RID(); rid != layer.RID {
+			t.Errorf("Expected RID %s, got %s", layer.RID, rid)
+		}
+		if id := tr.LocalTrack().ID(); id != trackers[0].LocalTrack().ID() {
+			t.Errorf("Expected the layers to share the track ID %s, got %s", trackers[0].LocalTrack().ID(), id)
+		}
+		if tr.MediaClock() != trackers[0].MediaClock() {
+			t.Error("Expected the layers to share the clock")
+		}
+	}
+	d.mu.Lock()
+	records := len(d.records)
+	d.mu.Unlock()
+	if records != 1 {
+		t.Errorf("Expected the layers to share the capture, the driver is recorded %d times", records)
+	}
+
+	expected := []struct {
+		width, height int
+		frameRate     float32
+		bitRate       int
+	}{
+		{width: 640, height: 480},
+		{width: 320, height: 240, bitRate: 50000},
+		{width: 160, height: 120, frameRate: 10},
+	}
+	for i, e := range expected {
+		encoder := params.encoder(i)
+		if encoder.p.Width != e.width || encoder.p.Height != e.height || encoder.p.FrameRate != e.frameRate {
+			t.Errorf("Expected the encoder of %s to be built for %dx%d@%.0f, got %dx%d@%.0f",
+				constraints.SimulcastLayers[i].RID, e.width, e.height, e.frameRate,
+				encoder.p.Width, encoder.p.Height, encoder.p.FrameRate)
+		}
+		encoder.mu.Lock()
+		bitRate := encoder.bitRate
+		encoder.mu.Unlock()
+		if bitRate != e.bitRate {
+			t.Errorf("Expected the bitrate of %s to be %d, got %d", constraints.SimulcastLayers[i].RID, e.bitRate, bitRate)
+		}
+
+		select {
+		case img := <-encoder.frames:
+			if size := img.Bounds().Size(); size.X != e.width || size.Y != e.height {
+				t.Errorf("Expected the frames of %s to be %dx%d, got %v", constraints.SimulcastLayers[i].RID, e.width, e.height, size)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("No frame has been encoded for %s", constraints.SimulcastLayers[i].RID)
+		}
+	}
+
+	// The quarter layer is throttled to 10 fps, while the driver produces a frame every millisecond.
+	full, quarter := params.encoder(0), params.encoder(2)
+	full0, quarter0 := full.numReads(), quarter.numReads()
+	time.Sleep(500 * time.Millisecond)
+	if n := quarter.numReads() - quarter0; n > 10 {
+		t.Errorf("Expected the quarter layer to be throttled to 10 fps, got %d frames in 500ms", n)
+	}
+	if n := full.numReads() - full0; n <= 10 {
+		t.Errorf("Expected the full layer not to be throttled, got %d frames in 500ms", n)
+	}
+
+	trackers[0].Stop()
+	trackers[1].Stop()
+	if d.isClosed() {
+		t.Fatal("Expected the driver to be kept open for the remaining layer")
+	}
+	trackers[2].Stop()
+	if !d.isClosed() {
+		t.Error("Expected the driver to be closed after all the layers are stopped")
+	}
+}
@@ -2,12 +2,22 @@ package mediadevices
 
 import (
 	"errors"
+	"fmt"
+	"image"
+	"io"
 	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/pion/mediadevices/pkg/codec"
 	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
 	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v2"
 	"github.com/pion/webrtc/v2/pkg/media"
 )
@@ -23,6 +33,49 @@ type Tracker interface {
 	// If the error is already occured before registering, the handler will be
 	// immediately called.
 	OnEnded(func(error))
+	// MediaClock returns the clock which the capture timestamps of the track are mapped onto.
+	// Tracks of the same MediaStream from GetUserMedia share the same clock.
+	MediaClock() *MediaClock
+	// RTPClockMapping returns the mapping between the MediaClock and the RTP timestamp of
+	// the last sample written to the track. It can be used to generate RTCP Sender Reports.
+	// An error is returned if no sample has been written yet, or the LocalTrack doesn't
+	// implement RTPWriter.
+	RTPClockMapping() (RTPClockMapping, error)
+	// SetEnabled enables or disables the track. A disabled track keeps sending black frames or silence,
+	// so that the peer doesn't need to renegotiate.
+	SetEnabled(enabled bool)
+	// Enabled returns true if the track is enabled.
+	Enabled() bool
+	// ReplaceSource replaces the device feeding the running encoder, e.g. to switch cameras in the middle
+	// of a call. The settings are selected from the properties of the new device by the constraints of the track.
+	// If the new device is already running, the track shares it with the settings it's running with.
+	ReplaceSource(d driver.Driver) error
+	// Clone creates a new track which shares the device with the track. The clone has its own encoder and
+	// localTrack, which are built from the same constraints. The device is closed when all the tracks
+	// sharing it are stopped.
+	Clone() (Tracker, error)
+	// RID returns the RTP stream ID of the simulcast layer which the track encodes.
+	// It's empty if the track is not a simulcast layer.
+	RID() string
+	// SetBitRate sets the target bitrate of the encoder in bps. The bitrate is kept when the encoder
+	// is rebuilt, e.g. by ApplyConstraints.
+	SetBitRate(bitRate int) error
+	// ForceKeyFrame forces the next frame of the encoder to be a key frame.
+	ForceKeyFrame() error
+	// HandleRTCP reads the RTCP packets sent from the receivers of the track, e.g. by *webrtc.RTPSender.
+	// Key frames are forced on PLI and FIR, and the bitrate is controlled by REMB and receiver reports.
+	// The requests which the encoder fails to handle are skipped.
+	// It blocks until the reader is closed or the track is stopped.
+	HandleRTCP(r RTCPReader, opts ...RTCPHandlerOption) error
+	// GetSettings returns the settings which the track is actually recording with.
+	GetSettings() prop.Media
+	// GetCapabilities returns the ranges of the settings supported by the device of the track.
+	GetCapabilities() MediaTrackCapabilities
+	// ApplyConstraints changes the settings of the running track without renegotiation.
+	// The best settings are selected from the properties of the device of the track. Only the media
+	// constraints are applied, the encoders and the transforms of the track are kept.
+	// The settings of the other tracks sharing the device are changed as well.
+	ApplyConstraints(constraints MediaTrackConstraints) error
 }
 
 type LocalTrack interface {
@@ -32,51 +85,152 @@ type LocalTrack interface {
 	Kind() webrtc.RTPCodecType
 }
 
+// FrameWriter is implemented by the LocalTracks which take the encoded frames with their metadata,
+// e.g. recorders writing the frames into containers. If the LocalTrack implements FrameWriter,
+// WriteFrame is called instead of WriteSample. The timestamps of the frames are on the MediaClock
+// of the track.
+type FrameWriter interface {
+	WriteFrame(frame codec.EncodedFrame) error
+}
+
+// RTPWriter is implemented by the LocalTracks which expose their packetizer, e.g. webrtc.Track.
+// If the LocalTrack implements RTPWriter, the samples are packetized by the packetizer and written by
+// WriteRTP instead of WriteSample, so that the RTP timestamps of the samples are known to RTPClockMapping.
+type RTPWriter interface {
+	Packetizer() rtp.Packetizer
+	WriteRTP(p *rtp.Packet) error
+}
+
+var (
+	errNoRTPSample         = errors.New("no sample has been written to the track")
+	errRTPTimestampUnknown = errors.New("the LocalTrack doesn't expose the RTP timestamps")
+)
+
 type track struct {
+	opts       *MediaDevicesOptions
 	localTrack LocalTrack
-	d          driver.Driver
 	sample     samplerFunc
-	encoder    codec.ReadCloser
+	src        *source
+	build      func(p prop.Media) (codec.ReadCloser, error)
+	clock      *MediaClock
+	layer      SimulcastLayer
+
+	// encoder, constraints, stopped, rebuilding and bitRate are protected by mu.
+	encoder     codec.ReadCloser
+	constraints MediaTrackConstraints
+	stopped     bool
+	// rebuilding is true while encoder is closed to be replaced by a new one in start.
+	rebuilding bool
+	// bitRate is the bitrate set by SetBitRate. 0 if it's not set.
+	bitRate int
 
 	onErrorHandler func(error)
 	err            error
 	mu             sync.Mutex
 	endOnce        sync.Once
 	kind           MediaDeviceType
+
+	// rtpMapping is the RTP clock mapping of the last sample. It's protected by mu.
+	rtpMapping RTPClockMapping
+	rtpMapped  bool
 }
 
-func newTrack(opts *MediaDevicesOptions, d driver.Driver, constraints MediaTrackConstraints) (*track, error) {
-	var encoderBuilders []encoderBuilder
-	var rtpCodecs []*webrtc.RTPCodec
-	var buildSampler func(t LocalTrack) samplerFunc
-	var kind MediaDeviceType
-	var err error
+// newTrack creates a track from the driver. If the driver is already running, the track shares it with
+// the other tracks. The capture timestamps of the track are mapped onto the given clock.
+// If clock is nil, the track has its own clock.
+func newTrack(opts *MediaDevicesOptions, d driver.Driver, constraints MediaTrackConstraints, clock *MediaClock) (*track, error) {
+	c, err := acquireCapture(d, func([]prop.Media) (prop.Media, error) {
+		return constraints.selectedMedia, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	err = d.Open()
+	t, err := newCaptureTrack(opts, c, constraints, SimulcastLayer{}, clock)
 	if err != nil {
+		c.release()
 		return nil, err
 	}
+	return t, nil
+}
+
+// newTracks creates a track for each simulcast layer of the constraints. The tracks share the capture
+// of the driver. If the constraints have no simulcast layers, or the driver is not a video recorder,
+// a single track is created.
+func newTracks(opts *MediaDevicesOptions, d driver.Driver, constraints MediaTrackConstraints, clock *MediaClock) ([]Tracker, error) {
+	if kind, _ := driverKind(d); kind != VideoInput || len(constraints.SimulcastLayers) == 0 {
+		t, err := newTrack(opts, d, constraints, clock)
+		if err != nil {
+			return nil, err
+		}
+		return []Tracker{t}, nil
+	}
+
+	if err := validateSimulcastLayers(constraints.SimulcastLayers); err != nil {
+		return nil, err
+	}
+
+	c, err := acquireCapture(d, func([]prop.Media) (prop.Media, error) {
+		return constraints.selectedMedia, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if clock == nil {
+		// The layers are sent in sync.
+		clock = NewMediaClock()
+	}
+
+	trackers := make([]Tracker, 0, len(constraints.SimulcastLayers))
+	for i, layer := range constraints.SimulcastLayers {
+		// The capture has been acquired for the first layer.
+		if i > 0 {
+			c.acquire()
+		}
+
+		t, err := newCaptureTrack(opts, c, constraints, layer, clock)
+		if err != nil {
+			c.release()
+			for _, t := range trackers {
+				t.Stop()
+			}
+			return nil, err
+		}
+		trackers = append(trackers, t)
+	}
+	return trackers, nil
+}
 
-	switch r := d.(type) {
-	case driver.VideoRecorder:
-		kind = VideoInput
+// newCaptureTrack creates a track recording from the capture. The caller must have acquired the capture
+// for the track. If the layer is not zero, the track encodes the video as the simulcast layer.
+func newCaptureTrack(opts *MediaDevicesOptions, c *capture, constraints MediaTrackConstraints, layer SimulcastLayer, clock *MediaClock) (*track, error) {
+	var encoderBuilders []encoderBuilder
+	var rtpCodecs []*webrtc.RTPCodec
+	var buildSampler func(clockRate uint32) samplerFunc
+
+	src := newSource(c)
+	src.layer = layer
+	settings := c.Settings()
+	switch c.kind {
+	case VideoInput:
 		rtpCodecs = opts.codecs[webrtc.RTPCodecTypeVideo]
-		buildSampler = newVideoSampler
-		encoderBuilders, err = newVideoEncoderBuilders(r, constraints)
-	case driver.AudioRecorder:
-		kind = AudioInput
+		buildSampler = func(clockRate uint32) samplerFunc {
+			return newVideoSampler(clockRate, layer.media(settings).FrameRate)
+		}
+		encoderBuilders = newVideoEncoderBuilders(src, constraints)
+	case AudioInput:
 		rtpCodecs = opts.codecs[webrtc.RTPCodecTypeAudio]
-		buildSampler = func(t LocalTrack) samplerFunc {
-			return newAudioSampler(t, constraints.selectedMedia.Latency)
+		buildSampler = func(clockRate uint32) samplerFunc {
+			return newAudioSampler(clockRate, settings.Latency)
 		}
-		encoderBuilders, err = newAudioEncoderBuilders(r, constraints)
+		encoderBuilders = newAudioEncoderBuilders(src, constraints)
 	default:
-		err = errors.New("newTrack: invalid driver type")
+		return nil, errors.New("newTrack: invalid driver type")
 	}
 
-	if err != nil {
-		d.Close()
-		return nil, err
+	if clock == nil {
+		clock = NewMediaClock()
 	}
 
 	for _, builder := range encoderBuilders {
@@ -95,7 +249,7 @@ func newTrack(opts *MediaDevicesOptions, d driver.Driver, constraints MediaTrack
 		localTrack, err := opts.trackGenerator(
 			matchedRTPCodec.PayloadType,
 			rand.Uint32(),
-			d.ID(),
+			c.d.ID(),
 			matchedRTPCodec.Type.String(),
 			matchedRTPCodec,
 		)
@@ -103,23 +257,27 @@ func newTrack(opts *MediaDevicesOptions, d driver.Driver, constraints MediaTrack
 			continue
 		}
 
-		encoder, err := builder.build()
+		encoder, err := builder.build(settings)
 		if err != nil {
 			continue
 		}
 
 		t := track{
-			localTrack: localTrack,
-			sample:     buildSampler(localTrack),
-			d:          d,
-			encoder:    encoder,
-			kind:       kind,
+			opts:        opts,
+			localTrack:  localTrack,
+			sample:      buildSampler(localTrack.Codec().ClockRate),
+			src:         src,
+			build:       builder.build,
+			encoder:     encoder,
+			constraints: constraints,
+			clock:       clock,
+			layer:       layer,
+			kind:        c.kind,
 		}
 		go t.start()
 		return &t, nil
 	}
 
-	d.Close()
 	return nil, errors.New("newTrack: failed to find a matching codec")
 }
 
@@ -160,32 +318,318 @@ func (t *track) onError(err error) {
 
 // start starts the data flow from the driver all the way to the localTrack
 func (t *track) start() {
-	var n int
-	var err error
-	buff := make([]byte, 1024)
+	t.mu.Lock()
+	encoder := t.encoder
+	t.mu.Unlock()
+
+	readFrame := newFrameReader(encoder)
 	for {
-		n, err = t.encoder.Read(buff)
+		frame, err := readFrame()
 		if err != nil {
-			if e, ok := err.(*mio.InsufficientBufferError); ok {
-				buff = make([]byte, 2*e.RequiredSize)
-				continue
+			settings, changed := t.src.takeChanged()
+			if !changed {
+				t.onError(err)
+				return
 			}
 
-			t.onError(err)
-			return
+			// The settings of the source have been changed by ApplyConstraints or ReplaceSource,
+			// and the encoder can't encode the new media, or the video is downscaled by setDegradation.
+			t.mu.Lock()
+			t.rebuilding = true
+			t.mu.Unlock()
+			encoder.Close()
+			encoder, err = t.build(settings)
+			if err != nil {
+				t.mu.Lock()
+				t.rebuilding = false
+				t.mu.Unlock()
+				t.onError(err)
+				return
+			}
+
+			t.mu.Lock()
+			stopped := t.stopped
+			t.encoder = encoder
+			t.rebuilding = false
+			bitRate := t.bitRate
+			t.mu.Unlock()
+			if stopped {
+				encoder.Close()
+				t.onError(io.EOF)
+				return
+			}
+			if bitRate > 0 {
+				if err := encoder.SetBitRate(bitRate); err != nil {
+					t.onError(err)
+					return
+				}
+			}
+
+			readFrame = newFrameReader(encoder)
+			continue
 		}
+		t.src.stamp(&frame)
+		frame.Timestamp = t.clock.Time(frame.Timestamp)
 
-		if err := t.sample(buff[:n]); err != nil {
+		if err := t.writeFrame(frame); err != nil {
 			t.onError(err)
 			return
 		}
 	}
 }
 
-// Stop stops the underlying driver and encoder
+// writeFrame writes the frame to the localTrack and updates the RTP clock mapping
+func (t *track) writeFrame(frame codec.EncodedFrame) error {
+	samples := t.sample(frame)
+	// Empty frames don't advance the RTP timestamp.
+	if len(frame.Data) == 0 {
+		return nil
+	}
+
+	if w, ok := t.localTrack.(FrameWriter); ok {
+		return w.WriteFrame(frame)
+	}
+	w, ok := t.localTrack.(RTPWriter)
+	if !ok {
+		return t.localTrack.WriteSample(media.Sample{Data: frame.Data, Samples: samples})
+	}
+
+	packets := w.Packetizer().Packetize(frame.Data, samples)
+	if len(packets) > 0 {
+		t.mu.Lock()
+		t.rtpMapping = RTPClockMapping{
+			Time:         frame.Timestamp,
+			RTPTimestamp: packets[0].Timestamp,
+			ClockRate:    t.localTrack.Codec().ClockRate,
+		}
+		t.rtpMapped = true
+		t.mu.Unlock()
+	}
+	for _, p := range packets {
+		if err := w.WriteRTP(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MediaClock returns the clock which the capture timestamps of the track are mapped onto.
+func (t *track) MediaClock() *MediaClock {
+	return t.clock
+}
+
+// RTPClockMapping returns the mapping between the MediaClock and the RTP timestamp of
+// the last sample written to the track.
+func (t *track) RTPClockMapping() (RTPClockMapping, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rtpMapped {
+		return t.rtpMapping, nil
+	}
+	if _, ok := t.localTrack.(FrameWriter); ok {
+		return RTPClockMapping{}, errRTPTimestampUnknown
+	}
+	if _, ok := t.localTrack.(RTPWriter); !ok {
+		return RTPClockMapping{}, errRTPTimestampUnknown
+	}
+	return RTPClockMapping{}, errNoRTPSample
+}
+
+// newFrameReader returns a function that reads encoded frames from the encoder. If the encoder
+// doesn't implement codec.FrameReader, the frames are read through io.Reader and only Data is set.
+// In that case, Data is only valid until the next call.
+func newFrameReader(encoder codec.ReadCloser) func() (codec.EncodedFrame, error) {
+	if r, ok := encoder.(codec.FrameReader); ok {
+		return r.ReadFrame
+	}
+
+	buff := make([]byte, 1024)
+	return func() (codec.EncodedFrame, error) {
+		for {
+			n, err := encoder.Read(buff)
+			if err != nil {
+				if e, ok := err.(*mio.InsufficientBufferError); ok {
+					buff = make([]byte, 2*e.RequiredSize)
+					continue
+				}
+
+				return codec.EncodedFrame{}, err
+			}
+
+			return codec.EncodedFrame{Data: buff[:n]}, nil
+		}
+	}
+}
+
+// GetSettings returns the settings which the track is actually recording with.
+func (t *track) GetSettings() prop.Media {
+	return t.src.capture().Settings()
+}
+
+// GetCapabilities returns the ranges of the settings supported by the device of the track.
+func (t *track) GetCapabilities() MediaTrackCapabilities {
+	d := t.src.capture().d
+	return newMediaTrackCapabilities(d.ID(), d.Properties())
+}
+
+// ApplyConstraints selects the best settings from the properties of the device, and records the device
+// again with them. The reader under the encoder is swapped, so the track keeps sending to the same
+// localTrack. If the new settings can't be encoded by the running encoder, e.g. the resolution is changed,
+// the encoder is rebuilt by the same builder.
+func (t *track) ApplyConstraints(constraints MediaTrackConstraints) error {
+	t.mu.Lock()
+	c := t.constraints
+	t.mu.Unlock()
+	c.MediaConstraints = constraints.MediaConstraints
+
+	if err := t.src.capture().apply(c); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.constraints.MediaConstraints = constraints.MediaConstraints
+	t.mu.Unlock()
+	return nil
+}
+
+// ReplaceSource replaces the device feeding the encoder with d, e.g. to switch cameras in the middle of a call.
+// The settings are selected from the properties of d by the constraints of the track. d must be the same kind
+// as the track, and it's closed when the track is stopped. The previous device is closed unless it's shared
+// with other tracks.
+func (t *track) ReplaceSource(d driver.Driver) error {
+	if kind, ok := driverKind(d); !ok || kind != t.kind {
+		return errInvalidDriverType
+	}
+
+	t.mu.Lock()
+	constraints := t.constraints
+	t.mu.Unlock()
+
+	c, err := acquireCapture(d, func(props []prop.Media) (prop.Media, error) {
+		best, _, ok := fitBestProperty(props, constraints)
+		if !ok {
+			return prop.Media{}, errOverconstrained
+		}
+		return selectSettings(constraints.MediaConstraints, best), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t.src.setCapture(c).release()
+	return nil
+}
+
+// Clone creates a new track which shares the device with the track. The clone has its own encoder and
+// localTrack, which are built from the same constraints, and it's enabled if the track is enabled.
+func (t *track) Clone() (Tracker, error) {
+	c := t.src.capture()
+	if !c.acquire() {
+		return nil, errors.New("the device of the track has been closed")
+	}
+
+	t.mu.Lock()
+	constraints := t.constraints
+	t.mu.Unlock()
+
+	clone, err := newCaptureTrack(t.opts, c, constraints, t.layer, t.clock)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+	clone.SetEnabled(t.Enabled())
+	return clone, nil
+}
+
+// RID returns the RTP stream ID of the simulcast layer which the track encodes.
+func (t *track) RID() string {
+	return t.layer.RID
+}
+
+// SetBitRate sets the target bitrate of the encoder in bps.
+func (t *track) SetBitRate(bitRate int) error {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return io.EOF
+	}
+	t.bitRate = bitRate
+	encoder, rebuilding := t.encoder, t.rebuilding
+	t.mu.Unlock()
+
+	if rebuilding {
+		// The new encoder gets the bitrate in start.
+		return nil
+	}
+	return encoder.SetBitRate(bitRate)
+}
+
+func (t *track) isStopped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stopped
+}
+
+// ForceKeyFrame forces the next frame of the encoder to be a key frame.
+func (t *track) ForceKeyFrame() error {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return io.EOF
+	}
+	encoder, rebuilding := t.encoder, t.rebuilding
+	t.mu.Unlock()
+
+	if rebuilding {
+		// The new encoder starts with a key frame.
+		return nil
+	}
+	return encoder.ForceKeyFrame()
+}
+
+// setDegradation downscales the video of the track, which rebuilds the encoder.
+func (t *track) setDegradation(d degradation) {
+	if t.kind != VideoInput {
+		return
+	}
+	t.src.setDegradation(d)
+}
+
+// SetEnabled enables or disables the track. A disabled video track sends black frames at a reduced rate,
+// and a disabled audio track sends silence. The encoder and the localTrack keep running, so that the
+// peer doesn't need to renegotiate.
+// Reference: https://w3c.github.io/mediacapture-main/#dom-mediastreamtrack-enabled
+func (t *track) SetEnabled(enabled bool) {
+	t.src.setEnabled(enabled)
+}
+
+// Enabled returns true if the track is enabled.
+func (t *track) Enabled() bool {
+	t.src.mu.Lock()
+	defer t.src.mu.Unlock()
+	return !t.src.disabled
+}
+
+// needsNewEncoder returns true if the encoder built for the old settings can't encode the media recorded
+// with the new settings. Encoders follow frame rate changes as they read frames.
+func needsNewEncoder(old, settings prop.Media) bool {
+	return old.Width != settings.Width || old.Height != settings.Height ||
+		old.SampleRate != settings.SampleRate || old.ChannelCount != settings.ChannelCount
+}
+
+// Stop stops the encoder, and the underlying driver unless it's shared with other tracks
 func (t *track) Stop() {
-	t.d.Close()
-	t.encoder.Close()
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	t.stopped = true
+	encoder := t.encoder
+	t.mu.Unlock()
+
+	t.src.stop().release()
+	encoder.Close()
 }
 
 func (t *track) Track() *webrtc.Track {
@@ -201,49 +645,413 @@ func (t *track) LocalTrack() LocalTrack {
 // duplicated for managing video and audio.
 type encoderBuilder struct {
 	name  string
-	build func() (codec.ReadCloser, error)
+	build func(p prop.Media) (codec.ReadCloser, error)
 }
 
-// newVideoEncoderBuilders transforms video given by VideoRecorder with the video transformer that is passed through
-// constraints and create a list of generic encoder builders
-func newVideoEncoderBuilders(vr driver.VideoRecorder, constraints MediaTrackConstraints) ([]encoderBuilder, error) {
-	r, err := vr.VideoRecord(constraints.selectedMedia)
-	if err != nil {
-		return nil, err
+// stampFunc sets the capture timestamp to an encoded frame.
+type stampFunc func(frame *codec.EncodedFrame)
+
+// disabledFrameInterval is the interval of the black frames sent by a disabled video track.
+const disabledFrameInterval = time.Second
+
+// errSourceChanged is returned by the readers of a source when the encoder can't encode the media
+// recorded with the new settings of the source.
+var errSourceChanged = errors.New("the settings of the source have been changed")
+
+// source is the reader of a capture which the encoder of a track reads from. The capture can be replaced,
+// or recorded again with new settings, while the encoder is running.
+type source struct {
+	mu    sync.Mutex
+	c     *capture
+	video video.Reader
+	audio audio.Reader
+	// readerGen is incremented when the reader is replaced.
+	readerGen int
+	// stopped is true if the track is stopped. Readers return io.EOF after the track is stopped.
+	stopped bool
+	// disabled is true if the track is disabled. wake is closed when disabled is changed.
+	disabled bool
+	wake     chan struct{}
+	// changed holds the new settings which the encoder has to be rebuilt for.
+	changed *prop.Media
+	// timestamp is the capture timestamp of the data read last.
+	timestamp time.Time
+	// recording identifies the recording of the data read last. resync is set when it's changed.
+	recording recordingID
+	resync    bool
+	// stamp sets the capture timestamp of a frame encoded from the source.
+	stamp stampFunc
+	// layer is the simulcast layer of the track, and degradation is the downscaling applied over it.
+	// rebuild is set when degradation is changed, so that the encoder is rebuilt for it.
+	layer       SimulcastLayer
+	degradation degradation
+	rebuild     bool
+}
+
+type recordingID struct {
+	c *capture
+	n int
+}
+
+func newSource(c *capture) *source {
+	src := &source{wake: make(chan struct{})}
+	src.setReader(c)
+	return src
+}
+
+// setReader makes the source read from the latest data of c. mu must be held once the source is created.
+func (src *source) setReader(c *capture) {
+	src.c = c
+	src.readerGen++
+	switch c.kind {
+	case VideoInput:
+		src.video = c.video.NewReader(false)
+	case AudioInput:
+		src.audio = c.audio.NewReader(false)
 	}
+}
 
-	if constraints.VideoTransform != nil {
-		r = constraints.VideoTransform(r)
+// capture returns the capture which the source reads from.
+func (src *source) capture() *capture {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.c
+}
+
+// setCapture makes the source read from c, and returns the capture to be released by the caller.
+func (src *source) setCapture(c *capture) *capture {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	if src.stopped {
+		return c
+	}
+	old := src.c
+	src.setReader(c)
+	src.wakeUp()
+	return old
+}
+
+func (src *source) setEnabled(enabled bool) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	if src.disabled == !enabled {
+		return
+	}
+	src.disabled = !enabled
+	if enabled && src.c.kind == VideoInput {
+		// Skip the frames which have been broadcasted while the track was disabled.
+		src.setReader(src.c)
+	}
+	src.wakeUp()
+}
+
+// stop makes the readers return io.EOF, and returns the capture to be released by the caller.
+func (src *source) stop() *capture {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	src.stopped = true
+	src.wakeUp()
+	return src.c
+}
+
+// wakeUp wakes up the readers waiting for the next black frame. mu must be held.
+func (src *source) wakeUp() {
+	close(src.wake)
+	src.wake = make(chan struct{})
+}
+
+func (src *source) setDegradation(d degradation) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	if src.degradation == d {
+		return
+	}
+	src.degradation = d
+	src.rebuild = true
+	src.wakeUp()
+}
+
+// videoLayer returns the layer which the video recorded with p is encoded as.
+func (src *source) videoLayer(p prop.Media) SimulcastLayer {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.layer.degrade(src.degradation, p)
+}
+
+// takeChanged returns the settings which the encoder has to be rebuilt for, if any.
+func (src *source) takeChanged() (prop.Media, bool) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	if src.changed == nil {
+		return prop.Media{}, false
+	}
+	settings := *src.changed
+	src.changed = nil
+	return settings, true
+}
+
+// newVideoEncoderBuilders creates a list of generic encoder builders. The built encoders read the video from
+// the source through the video transformer that is passed through constraints, and then through the
+// transform of the layer of the source. The frames compressed by the driver are decoded unless the encoder
+// accepts them and no transform is applied.
+func newVideoEncoderBuilders(src *source, constraints MediaTrackConstraints) []encoderBuilder {
+	src.stamp = func(frame *codec.EncodedFrame) {
+		if !frame.Timestamp.IsZero() {
+			// The encoder got the capture timestamp of the source frame by video.Timestamp.
+			return
+		}
+		src.mu.Lock()
+		defer src.mu.Unlock()
+		frame.Timestamp = src.timestamp
+	}
+
+	newReader := func(p prop.Media, layer SimulcastLayer, passthrough bool) video.Reader {
+		transform := layer.transform(p)
+		if passthrough && needsPassthrough(p.FrameFormat) {
+			// The frames can't be downscaled or throttled without decoding, so the degradation is not applied.
+			transform = nil
+		}
+		// The transforms need the pixels of the frames.
+		passthrough = passthrough && constraints.VideoTransform == nil && transform == nil
+
+		// Black frames have the same size as the last frame.
+		bounds := image.Rect(0, 0, p.Width, p.Height)
+		var black *image.YCbCr
+		var next time.Time
+
+		// The frames carry their capture timestamps through the transforms to the encoder.
+		var reader video.Reader = video.NewTimestampReader(func() (image.Image, time.Time, error) {
+			for {
+				src.mu.Lock()
+				if src.stopped {
+					src.mu.Unlock()
+					return nil, time.Time{}, io.EOF
+				}
+
+				if src.rebuild {
+					src.rebuild = false
+					settings := p
+					src.changed = &settings
+					src.mu.Unlock()
+					return nil, time.Time{}, errSourceChanged
+				}
+
+				if src.disabled {
+					now := time.Now()
+					if !now.Before(next) {
+						next = now.Add(disabledFrameInterval)
+						if black == nil || black.Rect != bounds {
+							black = newBlackFrame(bounds)
+						}
+						src.timestamp = now
+						src.mu.Unlock()
+						return black, now, nil
+					}
+
+					wake := src.wake
+					src.mu.Unlock()
+					select {
+					case <-time.After(next.Sub(now)):
+					case <-wake:
+					}
+					continue
+				}
+
+				r, gen := src.video, src.readerGen
+				src.mu.Unlock()
+
+				img, err := r.Read()
+
+				src.mu.Lock()
+				if src.readerGen != gen {
+					// The reader has been replaced while reading.
+					src.mu.Unlock()
+					continue
+				}
+				if err != nil {
+					src.mu.Unlock()
+					return nil, time.Time{}, err
+				}
+
+				frame := img.(*capturedFrame)
+				if needsNewEncoder(p, frame.settings) {
+					settings := frame.settings
+					src.changed = &settings
+					src.mu.Unlock()
+					return nil, time.Time{}, errSourceChanged
+				}
+				bounds = frame.Image.Bounds()
+				src.timestamp = frame.timestamp
+				src.mu.Unlock()
+				if passthrough {
+					return frame.Image, frame.timestamp, nil
+				}
+				decoded, err := decodeCompressed(frame.Image)
+				return decoded, frame.timestamp, err
+			}
+		})
+		if constraints.VideoTransform != nil {
+			reader = constraints.VideoTransform(reader)
+		}
+		if transform != nil {
+			reader = transform(reader)
+		}
+		return reader
 	}
 
 	encoderBuilders := make([]encoderBuilder, len(constraints.VideoEncoderBuilders))
 	for i, b := range constraints.VideoEncoderBuilders {
+		b := b
 		encoderBuilders[i].name = b.RTPCodec().Name
-		encoderBuilders[i].build = func() (codec.ReadCloser, error) {
-			return b.BuildVideoEncoder(r, constraints.selectedMedia)
+		encoderBuilders[i].build = func(p prop.Media) (codec.ReadCloser, error) {
+			layer := src.videoLayer(p)
+			var passthrough bool
+			if pb, ok := b.(codec.PassthroughVideoEncoderBuilder); ok {
+				passthrough = pb.Passthrough(p.FrameFormat)
+			}
+			encoder, err := b.BuildVideoEncoder(newReader(p, layer, passthrough), layer.media(p))
+			if err != nil {
+				return nil, err
+			}
+			if layer.MaxBitrate > 0 {
+				if err := encoder.SetBitRate(layer.MaxBitrate); err != nil {
+					encoder.Close()
+					return nil, err
+				}
+			}
+			return encoder, nil
 		}
 	}
-	return encoderBuilders, nil
+	return encoderBuilders
 }
 
-// newAudioEncoderBuilders transforms audio given by AudioRecorder with the audio transformer that is passed through
-// constraints and create a list of generic encoder builders
-func newAudioEncoderBuilders(ar driver.AudioRecorder, constraints MediaTrackConstraints) ([]encoderBuilder, error) {
-	r, err := ar.AudioRecord(constraints.selectedMedia)
-	if err != nil {
-		return nil, err
+// decodeCompressed decodes img if it's a frame compressed by the driver.
+func decodeCompressed(img image.Image) (image.Image, error) {
+	switch compressed := img.(type) {
+	case *frame.JPEG:
+		return compressed.Decode()
+	case frame.Compressed:
+		return nil, fmt.Errorf("%s frames can't be decoded", compressed.Format())
+	}
+	return img, nil
+}
+
+// newBlackFrame creates a black frame of the given size.
+func newBlackFrame(r image.Rectangle) *image.YCbCr {
+	img := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+	for i := range img.Cb {
+		img.Cb[i] = 128
+		img.Cr[i] = 128
+	}
+	return img
+}
+
+// newAudioEncoderBuilders creates a list of generic encoder builders. The built encoders read the audio from
+// the source through the audio transformer that is passed through constraints.
+func newAudioEncoderBuilders(src *source, constraints MediaTrackConstraints) []encoderBuilder {
+	var start time.Time
+	var elapsed time.Duration
+	src.stamp = func(frame *codec.EncodedFrame) {
+		src.mu.Lock()
+		defer src.mu.Unlock()
+
+		if frame.Duration == 0 {
+			// The encoder doesn't tell how many samples are encoded.
+			frame.Timestamp = src.timestamp
+			return
+		}
+
+		// Encoders may buffer samples across chunks. Since the samples of a recording are continuous,
+		// the timestamps are derived from the number of samples encoded so far.
+		if src.resync {
+			start = src.timestamp.Add(-frame.Duration)
+			elapsed = 0
+			src.resync = false
+		}
+		frame.Timestamp = start.Add(elapsed)
+		elapsed += frame.Duration
 	}
 
-	if constraints.AudioTransform != nil {
-		r = constraints.AudioTransform(r)
+	newReader := func(p prop.Media) audio.Reader {
+		var reader audio.Reader = audio.ReaderFunc(func() (wave.Audio, error) {
+			for {
+				src.mu.Lock()
+				if src.stopped {
+					src.mu.Unlock()
+					return nil, io.EOF
+				}
+				r, gen := src.audio, src.readerGen
+				src.mu.Unlock()
+
+				data, err := r.Read()
+
+				src.mu.Lock()
+				if src.readerGen != gen {
+					// The reader has been replaced while reading.
+					src.mu.Unlock()
+					continue
+				}
+				if err != nil {
+					src.mu.Unlock()
+					return nil, err
+				}
+
+				chunk := data.(*capturedChunk)
+				if needsNewEncoder(p, chunk.settings) {
+					settings := chunk.settings
+					src.changed = &settings
+					src.mu.Unlock()
+					return nil, errSourceChanged
+				}
+				if id := (recordingID{c: src.c, n: chunk.recording}); id != src.recording {
+					// Samples are not continuous across recordings.
+					src.recording = id
+					src.resync = true
+				}
+				src.timestamp = chunk.timestamp
+				disabled := src.disabled
+				src.mu.Unlock()
+
+				if disabled {
+					// Silence is sent at the pace of the driver.
+					return newSilence(chunk.Audio), nil
+				}
+				return chunk.Audio, nil
+			}
+		})
+		if constraints.AudioTransform != nil {
+			reader = constraints.AudioTransform(reader)
+		}
+		return reader
 	}
 
 	encoderBuilders := make([]encoderBuilder, len(constraints.AudioEncoderBuilders))
 	for i, b := range constraints.AudioEncoderBuilders {
+		b := b
 		encoderBuilders[i].name = b.RTPCodec().Name
-		encoderBuilders[i].build = func() (codec.ReadCloser, error) {
-			return b.BuildAudioEncoder(r, constraints.selectedMedia)
+		encoderBuilders[i].build = func(p prop.Media) (codec.ReadCloser, error) {
+			return b.BuildAudioEncoder(newReader(p), p)
 		}
 	}
-	return encoderBuilders, nil
+	return encoderBuilders
+}
+
+// newSilence creates a silent chunk which has the same format as the given chunk.
+func newSilence(chunk wave.Audio) wave.Audio {
+	info := chunk.ChunkInfo()
+	switch chunk.(type) {
+	case *wave.Int16NonInterleaved:
+		return wave.NewInt16NonInterleaved(info)
+	case *wave.Float32Interleaved:
+		return wave.NewFloat32Interleaved(info)
+	case *wave.Float32NonInterleaved:
+		return wave.NewFloat32NonInterleaved(info)
+	default:
+		return wave.NewInt16Interleaved(info)
+	}
 }
@@ -1,9 +1,24 @@
 package mediadevices
 
 import (
+	"bytes"
 	"errors"
+	"image"
+	"io"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/mediadevices/pkg/wave"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
 )
 
 func TestOnEnded(t *testing.T) {
@@ -53,3 +68,869 @@ func TestOnEnded(t *testing.T) {
 		}
 	})
 }
+
+type mockFrameCodec struct {
+	mockCodec
+	frames []codec.EncodedFrame
+}
+
+func (m *mockFrameCodec) Read(b []byte) (int, error) {
+	return 0, errors.New("Read must not be called")
+}
+
+func (m *mockFrameCodec) ReadFrame() (codec.EncodedFrame, error) {
+	if len(m.frames) == 0 {
+		return codec.EncodedFrame{}, io.EOF
+	}
+	frame := m.frames[0]
+	m.frames = m.frames[1:]
+	return frame, nil
+}
+
+func (m *mockFrameCodec) Close() error { return nil }
+
+type mockBytesCodec struct {
+	mockCodec
+	frames [][]byte
+}
+
+func (m *mockBytesCodec) Read(b []byte) (int, error) {
+	if len(m.frames) == 0 {
+		return 0, io.EOF
+	}
+	n, err := mio.Copy(b, m.frames[0])
+	if err != nil {
+		return 0, err
+	}
+	m.frames = m.frames[1:]
+	return n, nil
+}
+
+func (m *mockBytesCodec) Close() error { return nil }
+
+func TestFrameReader(t *testing.T) {
+	large := make([]byte, 3000)
+	for i := range large {
+		large[i] = byte(i)
+	}
+
+	t.Run("FrameReader", func(t *testing.T) {
+		frames := []codec.EncodedFrame{
+			{Data: []byte{1, 2, 3}, KeyFrame: true, Timestamp: time.Unix(1, 0)},
+			{Data: []byte{4, 5}, Timestamp: time.Unix(2, 0), Duration: time.Second},
+		}
+		readFrame := newFrameReader(&mockFrameCodec{frames: append([]codec.EncodedFrame{}, frames...)})
+
+		for i, expected := range frames {
+			frame, err := readFrame()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(expected, frame) {
+				t.Errorf("Expected frame %d to be %v, got %v", i, expected, frame)
+			}
+		}
+		if _, err := readFrame(); err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		frames := [][]byte{{1, 2, 3}, large, {4, 5}}
+		readFrame := newFrameReader(&mockBytesCodec{frames: append([][]byte{}, frames...)})
+
+		for i, expected := range frames {
+			frame, err := readFrame()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(expected, frame.Data) {
+				t.Errorf("Expected frame %d to be %v, got %v", i, expected, frame.Data)
+			}
+			if frame.Size() != len(expected) {
+				t.Errorf("Expected size of frame %d to be %d, got %d", i, len(expected), frame.Size())
+			}
+		}
+		if _, err := readFrame(); err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+	})
+}
+
+type mockFrameWriterTrack struct {
+	mockTrack
+	frames []codec.EncodedFrame
+}
+
+func (t *mockFrameWriterTrack) WriteSample(s media.Sample) error {
+	return errors.New("WriteSample must not be called")
+}
+
+func (t *mockFrameWriterTrack) WriteFrame(frame codec.EncodedFrame) error {
+	t.frames = append(t.frames, frame)
+	return nil
+}
+
+func TestWriteFrame(t *testing.T) {
+	localTrack := &mockFrameWriterTrack{
+		mockTrack: mockTrack{codec: webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)},
+	}
+	tr := &track{
+		localTrack: localTrack,
+		sample:     newVideoSampler(90000, 30),
+	}
+
+	frames := []codec.EncodedFrame{
+		{Data: []byte{1}, KeyFrame: true, Timestamp: time.Unix(1, 0)},
+		{Timestamp: time.Unix(1, 0).Add(time.Second / 30)},
+		{Data: []byte{2}, Timestamp: time.Unix(1, 0).Add(2 * time.Second / 30), Duration: 2 * time.Second / 30},
+	}
+	for _, frame := range frames {
+		if err := tr.writeFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Empty frames are not written.
+	expected := []codec.EncodedFrame{frames[0], frames[2]}
+	if !reflect.DeepEqual(expected, localTrack.frames) {
+		t.Errorf("Expected %v, got %v", expected, localTrack.frames)
+	}
+	if _, err := tr.RTPClockMapping(); err != errRTPTimestampUnknown {
+		t.Errorf("Expected %v, got %v", errRTPTimestampUnknown, err)
+	}
+}
+
+func TestWriteFrameRTP(t *testing.T) {
+	localTrack := newMockRTPTrack(webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000), "video")
+	tr := &track{
+		localTrack: localTrack,
+		sample:     newVideoSampler(90000, 30),
+	}
+	if _, err := tr.RTPClockMapping(); err != errNoRTPSample {
+		t.Errorf("Expected %v, got %v", errNoRTPSample, err)
+	}
+
+	frames := []codec.EncodedFrame{
+		{Data: []byte{1}, KeyFrame: true, Timestamp: time.Unix(1, 0)},
+		{Timestamp: time.Unix(1, 0).Add(time.Second / 30)},
+		{Data: []byte{2}, Timestamp: time.Unix(1, 0).Add(2 * time.Second / 30), Duration: 2 * time.Second / 30},
+	}
+	for _, frame := range frames {
+		if err := tr.writeFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Empty frames are not written, and the packets keep the random initial RTP timestamp.
+	packets := localTrack.packets
+	if len(packets) != 2 {
+		t.Fatalf("Expected 2 packets, got %d", len(packets))
+	}
+	if ts := packets[1].Timestamp - packets[0].Timestamp; ts != 3000 {
+		t.Errorf("Expected the timestamps of the packets to be 3000 apart, got %d", ts)
+	}
+	mapping, err := tr.RTPClockMapping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mapping.Time.Equal(frames[2].Timestamp) || mapping.RTPTimestamp != packets[1].Timestamp {
+		t.Errorf("Expected the mapping of the last frame at RTP timestamp %d, got %v", packets[1].Timestamp, mapping)
+	}
+}
+
+type mockVideoDriver struct {
+	id    string
+	props []prop.Media
+
+	mu      sync.Mutex
+	closed  chan struct{}
+	records []prop.Media
+}
+
+func (d *mockVideoDriver) Open() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = make(chan struct{})
+	return nil
+}
+
+func (d *mockVideoDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case <-d.closed:
+	default:
+		close(d.closed)
+	}
+	return nil
+}
+
+func (d *mockVideoDriver) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case <-d.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *mockVideoDriver) Properties() []prop.Media {
+	if d.props != nil {
+		return d.props
+	}
+	return []prop.Media{
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}},
+		{Video: prop.Video{Width: 320, Height: 240, FrameFormat: frame.FormatI420}},
+	}
+}
+
+func (d *mockVideoDriver) ID() string { return d.id }
+func (d *mockVideoDriver) Info() driver.Info {
+	return driver.Info{Label: d.id, DeviceType: driver.Camera}
+}
+func (d *mockVideoDriver) Status() driver.State { return driver.StateRunning }
+
+func (d *mockVideoDriver) VideoRecord(p prop.Media) (video.Reader, error) {
+	d.mu.Lock()
+	closed := d.closed
+	d.records = append(d.records, p)
+	d.mu.Unlock()
+
+	return video.ReaderFunc(func() (image.Image, error) {
+		select {
+		case <-closed:
+			return nil, io.EOF
+		case <-time.After(time.Millisecond):
+		}
+		img := image.NewYCbCr(image.Rect(0, 0, p.Width, p.Height), image.YCbCrSubsampleRatio420)
+		for i := range img.Y {
+			img.Y[i] = 235
+		}
+		return img, nil
+	}), nil
+}
+
+func (d *mockVideoDriver) lastRecord() prop.Media {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.records[len(d.records)-1]
+}
+
+// mockFrameParams builds encoders which report the frames they read.
+type mockFrameParams struct {
+	mockParams
+	mu     sync.Mutex
+	builds int
+	frames chan image.Image
+}
+
+func (params *mockFrameParams) BuildVideoEncoder(r video.Reader, p prop.Media) (codec.ReadCloser, error) {
+	params.mu.Lock()
+	params.builds++
+	params.mu.Unlock()
+	return &mockReportCodec{r: r, frames: params.frames}, nil
+}
+
+func (params *mockFrameParams) numBuilds() int {
+	params.mu.Lock()
+	defer params.mu.Unlock()
+	return params.builds
+}
+
+type mockReportCodec struct {
+	mockCodec
+	r      video.Reader
+	frames chan image.Image
+}
+
+func (m *mockReportCodec) Read(b []byte) (int, error) {
+	img, err := m.r.Read()
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case m.frames <- img:
+	default:
+	}
+	return mio.Copy(b, []byte{0})
+}
+
+func (m *mockReportCodec) Close() error { return nil }
+
+// newMockVideoTrack creates a track from d which is constrained to 640 pixels wide. The encoder of the track
+// reports the frames it reads to the returned channel.
+func newMockVideoTrack(t *testing.T, d *mockVideoDriver) (*track, *mockFrameParams, <-chan error) {
+	params := &mockFrameParams{
+		mockParams: mockParams{name: "MockVideo"},
+		frames:     make(chan image.Image, 1),
+	}
+	opts := &MediaDevicesOptions{
+		codecs: map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Type: webrtc.RTPCodecTypeVideo, Name: "MockVideo", PayloadType: 1, RTPCodecCapability: webrtc.RTPCodecCapability{ClockRate: 90000}},
+			},
+		},
+		trackGenerator: func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			return newMockTrack(codec, id), nil
+		},
+	}
+
+	var constraints MediaTrackConstraints
+	constraints.Width = prop.IntExact(640)
+	constraints.VideoEncoderBuilders = []codec.VideoEncoderBuilder{params}
+	constraints.selectedMedia = prop.Media{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}}
+
+	tr, err := newTrack(opts, d, constraints, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ended := make(chan error, 1)
+	tr.OnEnded(func(err error) {
+		ended <- err
+	})
+	return tr, params, ended
+}
+
+// waitFrame waits for the encoder to read a frame which satisfies cond.
+func waitFrame(t *testing.T, frames <-chan image.Image, ended <-chan error, timeout time.Duration, cond func(img *image.YCbCr) bool) {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case img := <-frames:
+			if cond(img.(*image.YCbCr)) {
+				return
+			}
+		case err := <-ended:
+			t.Fatalf("Unexpected end of the track: %v", err)
+		case <-deadline:
+			t.Fatal("Timeout")
+		}
+	}
+}
+
+func sizeOf(width, height int) func(img *image.YCbCr) bool {
+	return func(img *image.YCbCr) bool {
+		return img.Rect.Dx() == width && img.Rect.Dy() == height
+	}
+}
+
+func isBlack(img *image.YCbCr) bool {
+	return img.Y[0] == 0 && img.Cb[0] == 128 && img.Cr[0] == 128
+}
+
+// closedCodec is an encoder which has been closed.
+type closedCodec struct{}
+
+func (closedCodec) Read(b []byte) (int, error) { return 0, io.EOF }
+func (closedCodec) Close() error               { return nil }
+func (closedCodec) SetBitRate(int) error       { return io.EOF }
+func (closedCodec) ForceKeyFrame() error       { return io.EOF }
+
+func TestRebuildingEncoder(t *testing.T) {
+	tr := &track{encoder: closedCodec{}, rebuilding: true}
+
+	// The requests are taken over by the new encoder in start.
+	if err := tr.SetBitRate(100000); err != nil {
+		t.Errorf("Expected no error while rebuilding the encoder, got %v", err)
+	}
+	if tr.bitRate != 100000 {
+		t.Errorf("Expected the bitrate to be recorded, got %d", tr.bitRate)
+	}
+	if err := tr.ForceKeyFrame(); err != nil {
+		t.Errorf("Expected no error while rebuilding the encoder, got %v", err)
+	}
+
+	tr.rebuilding = false
+	if err := tr.SetBitRate(100000); err != io.EOF {
+		t.Errorf("Expected %v from the closed encoder, got %v", io.EOF, err)
+	}
+	if err := tr.ForceKeyFrame(); err != io.EOF {
+		t.Errorf("Expected %v from the closed encoder, got %v", io.EOF, err)
+	}
+}
+
+func TestApplyConstraints(t *testing.T) {
+	d := &mockVideoDriver{id: "mockVideo"}
+	tr, params, ended := newMockVideoTrack(t, d)
+	defer tr.Stop()
+
+	waitFrame(t, params.frames, ended, time.Second, sizeOf(640, 480))
+
+	expectedSettings := prop.Media{
+		DeviceID: "mockVideo",
+		Video:    prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420},
+	}
+	if settings := tr.GetSettings(); settings != expectedSettings {
+		t.Errorf("Expected settings %v, got %v", &expectedSettings, &settings)
+	}
+
+	expectedCapabilities := MediaTrackCapabilities{
+		DeviceID:    "mockVideo",
+		Width:       prop.IntRanged{Min: 320, Max: 640},
+		Height:      prop.IntRanged{Min: 240, Max: 480},
+		FrameFormat: prop.FrameFormatOneOf{frame.FormatI420},
+	}
+	if capabilities := tr.GetCapabilities(); !reflect.DeepEqual(expectedCapabilities, capabilities) {
+		t.Errorf("Expected capabilities %v, got %v", expectedCapabilities, capabilities)
+	}
+
+	t.Run("FrameRate", func(t *testing.T) {
+		var c MediaTrackConstraints
+		c.Width = prop.IntExact(640)
+		c.FrameRate = prop.Float(15)
+		if err := tr.ApplyConstraints(c); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if fps := tr.GetSettings().FrameRate; fps != 15 {
+			t.Errorf("Expected frame rate 15, got %v", fps)
+		}
+		if fps := d.lastRecord().FrameRate; fps != 15 {
+			t.Errorf("Expected the driver to record at 15 fps, got %v", fps)
+		}
+		waitFrame(t, params.frames, ended, time.Second, sizeOf(640, 480))
+		if builds := params.numBuilds(); builds != 1 {
+			t.Errorf("Expected the encoder to be kept, got %d builds", builds)
+		}
+	})
+
+	t.Run("Resolution", func(t *testing.T) {
+		var c MediaTrackConstraints
+		c.Width = prop.IntExact(320)
+		if err := tr.ApplyConstraints(c); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if settings := tr.GetSettings(); settings.Width != 320 || settings.Height != 240 {
+			t.Errorf("Expected 320x240, got %dx%d", settings.Width, settings.Height)
+		}
+		waitFrame(t, params.frames, ended, time.Second, sizeOf(320, 240))
+		if builds := params.numBuilds(); builds != 2 {
+			t.Errorf("Expected the encoder to be rebuilt, got %d builds", builds)
+		}
+	})
+
+	t.Run("Overconstrained", func(t *testing.T) {
+		before := tr.GetSettings()
+
+		var c MediaTrackConstraints
+		c.Width = prop.IntExact(1280)
+		if err := tr.ApplyConstraints(c); err != errOverconstrained {
+			t.Fatalf("Expected error %v, got %v", errOverconstrained, err)
+		}
+
+		if settings := tr.GetSettings(); settings != before {
+			t.Errorf("Expected settings to be kept %v, got %v", &before, &settings)
+		}
+		waitFrame(t, params.frames, ended, time.Second, sizeOf(320, 240))
+	})
+}
+
+func TestSetEnabled(t *testing.T) {
+	tr, params, ended := newMockVideoTrack(t, &mockVideoDriver{id: "mockVideo"})
+	defer tr.Stop()
+
+	notBlack := func(img *image.YCbCr) bool { return !isBlack(img) }
+	waitFrame(t, params.frames, ended, time.Second, notBlack)
+
+	tr.SetEnabled(false)
+	if tr.Enabled() {
+		t.Error("Expected the track to be disabled")
+	}
+	waitFrame(t, params.frames, ended, time.Second, func(img *image.YCbCr) bool {
+		return isBlack(img) && img.Rect.Dx() == 640 && img.Rect.Dy() == 480
+	})
+
+	// Black frames are sent at a reduced rate.
+	select {
+	case <-params.frames:
+		t.Error("Expected no frame within the interval of the black frames")
+	case <-time.After(disabledFrameInterval / 2):
+	}
+
+	tr.SetEnabled(true)
+	if !tr.Enabled() {
+		t.Error("Expected the track to be enabled")
+	}
+	// The encoder must not wait for the next black frame.
+	waitFrame(t, params.frames, ended, disabledFrameInterval/2, notBlack)
+
+	if builds := params.numBuilds(); builds != 1 {
+		t.Errorf("Expected the encoder to be kept, got %d builds", builds)
+	}
+}
+
+func TestReplaceSource(t *testing.T) {
+	d := &mockVideoDriver{id: "mockVideo"}
+	tr, params, ended := newMockVideoTrack(t, d)
+	defer tr.Stop()
+
+	waitFrame(t, params.frames, ended, time.Second, sizeOf(640, 480))
+
+	t.Run("Overconstrained", func(t *testing.T) {
+		small := &mockVideoDriver{
+			id:    "mockVideoSmall",
+			props: []prop.Media{{Video: prop.Video{Width: 320, Height: 240, FrameFormat: frame.FormatI420}}},
+		}
+		if err := tr.ReplaceSource(small); err != errOverconstrained {
+			t.Fatalf("Expected error %v, got %v", errOverconstrained, err)
+		}
+		if !small.isClosed() {
+			t.Error("Expected the rejected driver to be closed")
+		}
+		if id := tr.GetSettings().DeviceID; id != "mockVideo" {
+			t.Errorf("Expected the device to be kept, got %s", id)
+		}
+		waitFrame(t, params.frames, ended, time.Second, sizeOf(640, 480))
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		next := &mockVideoDriver{id: "mockVideoNext"}
+		if err := tr.ReplaceSource(next); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !d.isClosed() {
+			t.Error("Expected the previous driver to be closed")
+		}
+		if id := tr.GetSettings().DeviceID; id != "mockVideoNext" {
+			t.Errorf("Expected mockVideoNext, got %s", id)
+		}
+		if p := next.lastRecord(); p.Width != 640 || p.Height != 480 {
+			t.Errorf("Expected the driver to record 640x480, got %dx%d", p.Width, p.Height)
+		}
+		waitFrame(t, params.frames, ended, time.Second, sizeOf(640, 480))
+		if builds := params.numBuilds(); builds != 1 {
+			t.Errorf("Expected the encoder to be kept, got %d builds", builds)
+		}
+
+		tr.Stop()
+		if !next.isClosed() {
+			t.Error("Expected the replaced driver to be closed by Stop")
+		}
+	})
+}
+
+func TestNewSilence(t *testing.T) {
+	info := wave.ChunkInfo{Len: 4, Channels: 2, SamplingRate: 48000}
+	testCases := map[string]wave.EditableAudio{
+		"Int16Interleaved":      wave.NewInt16Interleaved(info),
+		"Int16NonInterleaved":   wave.NewInt16NonInterleaved(info),
+		"Float32Interleaved":    wave.NewFloat32Interleaved(info),
+		"Float32NonInterleaved": wave.NewFloat32NonInterleaved(info),
+	}
+
+	for name, chunk := range testCases {
+		chunk := chunk
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < info.Len; i++ {
+				for ch := 0; ch < info.Channels; ch++ {
+					chunk.Set(i, ch, wave.Int16Sample(1000))
+				}
+			}
+
+			silence := newSilence(chunk)
+			if reflect.TypeOf(silence) != reflect.TypeOf(chunk) {
+				t.Errorf("Expected %T, got %T", chunk, silence)
+			}
+			if silence.ChunkInfo() != info {
+				t.Errorf("Expected %v, got %v", info, silence.ChunkInfo())
+			}
+			for i := 0; i < info.Len; i++ {
+				for ch := 0; ch < info.Channels; ch++ {
+					if v := silence.At(i, ch).Int(); v != 0 {
+						t.Errorf("Expected silence at (%d, %d), got %d", i, ch, v)
+					}
+				}
+			}
+		})
+	}
+}
+
+// mockTimestampVideoDriver tells the capture time of every frame, which is also written in the first pixel.
+type mockTimestampVideoDriver struct {
+	mockVideoDriver
+}
+
+func (d *mockTimestampVideoDriver) VideoRecord(p prop.Media) (video.Reader, error) {
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+
+	var n int
+	return video.NewTimestampReader(func() (image.Image, time.Time, error) {
+		select {
+		case <-closed:
+			return nil, time.Time{}, io.EOF
+		case <-time.After(time.Millisecond):
+		}
+		n++
+		img := image.NewYCbCr(image.Rect(0, 0, p.Width, p.Height), image.YCbCrSubsampleRatio420)
+		img.Y[0] = byte(n)
+		return img, time.Unix(int64(n), 0), nil
+	}), nil
+}
+
+// mockDelayParams builds encoders which output the first pixel of every frame 3 frames later.
+type mockDelayParams struct {
+	mockParams
+}
+
+func (params *mockDelayParams) BuildVideoEncoder(r video.Reader, p prop.Media) (codec.ReadCloser, error) {
+	return &mockDelayCodec{r: r}, nil
+}
+
+type mockDelayCodec struct {
+	mockCodec
+	r       video.Reader
+	delayed []codec.EncodedFrame
+}
+
+func (m *mockDelayCodec) Read(b []byte) (int, error) {
+	f, err := m.ReadFrame()
+	if err != nil {
+		return 0, err
+	}
+	return mio.Copy(b, f.Data)
+}
+
+func (m *mockDelayCodec) ReadFrame() (codec.EncodedFrame, error) {
+	img, err := m.r.Read()
+	if err != nil {
+		return codec.EncodedFrame{}, err
+	}
+	m.delayed = append(m.delayed, codec.EncodedFrame{
+		Data:      []byte{img.(*image.YCbCr).Y[0]},
+		Timestamp: video.Timestamp(m.r),
+	})
+	if len(m.delayed) <= 3 {
+		return codec.EncodedFrame{Timestamp: video.Timestamp(m.r)}, nil
+	}
+	f := m.delayed[0]
+	m.delayed = m.delayed[1:]
+	return f, nil
+}
+
+func (m *mockDelayCodec) Close() error { return nil }
+
+// mockFrameChanTrack sends the frames written to it to frames. The frames are dropped if frames is full.
+type mockFrameChanTrack struct {
+	mockTrack
+	frames chan codec.EncodedFrame
+}
+
+func (t *mockFrameChanTrack) WriteFrame(frame codec.EncodedFrame) error {
+	select {
+	case t.frames <- frame:
+	default:
+	}
+	return nil
+}
+
+func TestVideoCaptureTimestamp(t *testing.T) {
+	d := &mockTimestampVideoDriver{mockVideoDriver: mockVideoDriver{id: "mockTimestampVideo"}}
+	localTrack := &mockFrameChanTrack{frames: make(chan codec.EncodedFrame, 1)}
+	opts := &MediaDevicesOptions{
+		codecs: map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Type: webrtc.RTPCodecTypeVideo, Name: "MockVideo", PayloadType: 1, RTPCodecCapability: webrtc.RTPCodecCapability{ClockRate: 90000}},
+			},
+		},
+		trackGenerator: func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			localTrack.codec, localTrack.id = codec, id
+			return localTrack, nil
+		},
+	}
+
+	var constraints MediaTrackConstraints
+	constraints.VideoEncoderBuilders = []codec.VideoEncoderBuilder{&mockDelayParams{mockParams{name: "MockVideo"}}}
+	// Every other frame is dropped by the transform.
+	constraints.VideoTransform = func(r video.Reader) video.Reader {
+		return video.WithTimestamp(r, video.ReaderFunc(func() (image.Image, error) {
+			if _, err := r.Read(); err != nil {
+				return nil, err
+			}
+			return r.Read()
+		}))
+	}
+	constraints.selectedMedia = prop.Media{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}}
+
+	tr, err := newTrack(opts, d, constraints, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Stop()
+
+	// The frames are stamped with the capture timestamps of their source frames, even though the encoder
+	// outputs them later than it reads them and the transform drops the frames.
+	for i := 0; i < 10; i++ {
+		select {
+		case f := <-localTrack.frames:
+			if expected := time.Unix(int64(f.Data[0]), 0); !f.Timestamp.Equal(expected) {
+				t.Errorf("Expected the frame %d to be stamped with %v, got %v", f.Data[0], expected, f.Timestamp)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout")
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	d := &mockVideoDriver{id: "mockVideo"}
+	tr, params, ended := newMockVideoTrack(t, d)
+	defer tr.Stop()
+
+	waitFrame(t, params.frames, ended, time.Second, sizeOf(640, 480))
+
+	tr.SetEnabled(false)
+	cloned, err := tr.Clone()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cloned.Stop()
+	clone := cloned.(*track)
+
+	if clone.localTrack == tr.localTrack {
+		t.Error("Expected the clone to have its own localTrack")
+	}
+	if clone.MediaClock() != tr.MediaClock() {
+		t.Error("Expected the clone to share the clock")
+	}
+	if clone.Enabled() {
+		t.Error("Expected the clone of a disabled track to be disabled")
+	}
+	if builds := params.numBuilds(); builds != 2 {
+		t.Errorf("Expected the clone to have its own encoder, got %d builds", builds)
+	}
+
+	clone.SetEnabled(true)
+	if tr.Enabled() {
+		t.Error("Expected the track not to be enabled by the clone")
+	}
+
+	// The settings of the shared device are changed for both of the tracks.
+	var c MediaTrackConstraints
+	c.Width = prop.IntExact(320)
+	if err := clone.ApplyConstraints(c); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if settings := tr.GetSettings(); settings.Width != 320 {
+		t.Errorf("Expected the track to be 320 pixels wide, got %d", settings.Width)
+	}
+
+	cloneEnded := make(chan error, 1)
+	clone.OnEnded(func(err error) {
+		cloneEnded <- err
+	})
+
+	tr.Stop()
+	if d.isClosed() {
+		t.Fatal("Expected the driver to be kept open for the clone")
+	}
+
+	// The clone keeps running after the original track is stopped.
+	waitFrame(t, params.frames, cloneEnded, time.Second, func(img *image.YCbCr) bool {
+		return !isBlack(img) && img.Rect.Dx() == 320
+	})
+
+	clone.Stop()
+	if !d.isClosed() {
+		t.Error("Expected the driver to be closed after the last clone is stopped")
+	}
+
+	if _, err := clone.Clone(); err == nil {
+		t.Error("Expected an error to clone a stopped track of a closed device")
+	}
+}
+
+// mockEncodedVideoDriver is a camera which outputs H.264 as well as I420.
+type mockEncodedVideoDriver struct {
+	mockVideoDriver
+	encodedRecords int
+}
+
+func (d *mockEncodedVideoDriver) Properties() []prop.Media {
+	return []prop.Media{
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}},
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatH264}},
+	}
+}
+
+func (d *mockEncodedVideoDriver) EncodedVideoRecord(p prop.Media) (video.Reader, error) {
+	d.mu.Lock()
+	closed := d.closed
+	d.records = append(d.records, p)
+	d.encodedRecords++
+	d.mu.Unlock()
+
+	return video.ReaderFunc(func() (image.Image, error) {
+		select {
+		case <-closed:
+			return nil, io.EOF
+		case <-time.After(time.Millisecond):
+		}
+		return frame.NewH264([]byte{0, 0, 0, 1, 0x65, 0x88}, p.Width, p.Height), nil
+	}), nil
+}
+
+// mockPassthroughParams builds encoders which accept H.264 frames.
+type mockPassthroughParams struct {
+	mockFrameParams
+}
+
+func (params *mockPassthroughParams) Passthrough(f frame.Format) bool {
+	return f == frame.FormatH264
+}
+
+func TestEncodedVideoPassthrough(t *testing.T) {
+	params := &mockPassthroughParams{
+		mockFrameParams: mockFrameParams{
+			mockParams: mockParams{name: "MockVideo"},
+			frames:     make(chan image.Image, 1),
+		},
+	}
+	opts := &MediaDevicesOptions{
+		codecs: map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Type: webrtc.RTPCodecTypeVideo, Name: "MockVideo", PayloadType: 1, RTPCodecCapability: webrtc.RTPCodecCapability{ClockRate: 90000}},
+			},
+		},
+		trackGenerator: func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			return newMockTrack(codec, id), nil
+		},
+	}
+	d := &mockEncodedVideoDriver{mockVideoDriver: mockVideoDriver{id: "mockEncodedVideo"}}
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	var constraints MediaTrackConstraints
+	constraints.VideoEncoderBuilders = []codec.VideoEncoderBuilder{params}
+	best, _, ok := fitBestProperty(d.Properties(), constraints)
+	if !ok || best.FrameFormat != frame.FormatH264 {
+		t.Fatalf("Expected H.264 to be selected, got %v", best)
+	}
+	constraints.selectedMedia = selectSettings(constraints.MediaConstraints, best)
+
+	tr, err := newTrack(opts, d, constraints, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Stop()
+
+	select {
+	case img := <-params.frames:
+		if _, ok := img.(*frame.H264); !ok {
+			t.Errorf("Expected the encoder to read *frame.H264, got %T", img)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+	if d.encodedRecords != 1 {
+		t.Errorf("Expected the driver to be recorded by EncodedVideoRecord, got %d records", d.encodedRecords)
+	}
+}